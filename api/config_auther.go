@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-gost/core/auth"
+	xauth "github.com/wznpp1/gost_x/auth"
 	"github.com/wznpp1/gost_x/config"
 	"github.com/wznpp1/gost_x/config/parsing"
 	"github.com/wznpp1/gost_x/registry"
@@ -93,6 +95,8 @@ func updateAuther(ctx *gin.Context) {
 
 	req.Data.Name = req.Auther
 
+	users := listUsers(registry.AutherRegistry().Get(req.Auther))
+
 	v := parsing.ParseAuther(&req.Data)
 	registry.AutherRegistry().Unregister(req.Auther)
 
@@ -100,6 +104,7 @@ func updateAuther(ctx *gin.Context) {
 		writeError(ctx, ErrDup)
 		return
 	}
+	parsing.CloseUserConnections(req.Auther, xauth.RevokedUsers(users, listUsers(v))...)
 
 	config.OnUpdate(func(c *config.Config) error {
 		for i := range c.Authers {
@@ -147,7 +152,9 @@ func deleteAuther(ctx *gin.Context) {
 		writeError(ctx, ErrNotFound)
 		return
 	}
+	users := listUsers(registry.AutherRegistry().Get(req.Auther))
 	registry.AutherRegistry().Unregister(req.Auther)
+	parsing.CloseUserConnections(req.Auther, xauth.RevokedUsers(users, nil)...)
 
 	config.OnUpdate(func(c *config.Config) error {
 		authers := c.Authers
@@ -165,3 +172,11 @@ func deleteAuther(ctx *gin.Context) {
 		Msg: "OK",
 	})
 }
+
+// listUsers returns the users of the auther, including the ones from the file, redis and HTTP loaders.
+func listUsers(au auth.Authenticator) map[string]string {
+	if lister, ok := au.(xauth.Lister); ok {
+		return lister.Users()
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wznpp1/gost_x/config"
 	"github.com/wznpp1/gost_x/config/parsing"
+	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/registry"
+	xservice "github.com/wznpp1/gost_x/service"
 )
 
 // swagger:parameters createServiceRequest
@@ -104,26 +107,41 @@ func updateService(ctx *gin.Context) {
 		writeError(ctx, ErrNotFound)
 		return
 	}
-	old.Close()
 
 	req.Data.Name = req.Service
 
+	// the new service takes over the bound socket of the old one if they listen on the same address,
+	// otherwise the old one has to stop accepting to release the address before the new one is created.
+	done := xnet.Handoff(req.Service)
 	svc, err := parsing.ParseService(&req.Data)
+	done()
 	if err != nil {
-		writeError(ctx, ErrCreate)
-		return
+		if d, ok := old.(xservice.Drainer); ok {
+			d.Drain()
+		} else {
+			old.Close()
+		}
+		if svc, err = parsing.ParseService(&req.Data); err != nil {
+			go xservice.Shutdown(context.Background(), old)
+			writeError(ctx, ErrCreate)
+			return
+		}
 	}
 
 	registry.ServiceRegistry().Unregister(req.Service)
 
 	if err := registry.ServiceRegistry().Register(req.Service, svc); err != nil {
 		svc.Close()
+		go xservice.Shutdown(context.Background(), old)
 		writeError(ctx, ErrDup)
 		return
 	}
 
 	go svc.Serve()
 
+	// the active connections of the old service are drained in background.
+	go xservice.Shutdown(context.Background(), old)
+
 	config.OnUpdate(func(c *config.Config) error {
 		for i := range c.Services {
 			if c.Services[i].Name == req.Service {
@@ -173,7 +191,7 @@ func deleteService(ctx *gin.Context) {
 	}
 
 	registry.ServiceRegistry().Unregister(req.Service)
-	svc.Close()
+	go xservice.Shutdown(context.Background(), svc)
 
 	config.OnUpdate(func(c *config.Config) error {
 		services := c.Services
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wznpp1/gost_x/config/parsing"
+	"github.com/wznpp1/gost_x/registry"
+	xservice "github.com/wznpp1/gost_x/service"
+)
+
+// swagger:parameters getConnectionsRequest
+type getConnectionsRequest struct {
+	// filter by service name.
+	// in: query
+	Service string `form:"service" json:"service"`
+	// filter by client address.
+	// in: query
+	Client string `form:"client" json:"client"`
+	// filter by user.
+	// in: query
+	User string `form:"user" json:"user"`
+	// filter by target address.
+	// in: query
+	Target string `form:"target" json:"target"`
+	// filter by chain node name.
+	// in: query
+	Node string `form:"node" json:"node"`
+}
+
+// successful operation.
+// swagger:response getConnectionsResponse
+type getConnectionsResponse struct {
+	// in: body
+	Data connectionList
+}
+
+type connectionList struct {
+	Count       int                 `json:"count"`
+	Connections []xservice.ConnInfo `json:"connections"`
+}
+
+func getConnections(ctx *gin.Context) {
+	// swagger:route GET /connections Connection getConnectionsRequest
+	//
+	// Get the active connections of the services.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getConnectionsResponse
+
+	var req getConnectionsRequest
+	ctx.ShouldBindQuery(&req)
+
+	list := connectionList{
+		Connections: []xservice.ConnInfo{},
+	}
+	for name, svc := range registry.ServiceRegistry().GetAll() {
+		if req.Service != "" && req.Service != name {
+			continue
+		}
+		tracker, ok := svc.(xservice.ConnTracker)
+		if !ok {
+			continue
+		}
+		for _, c := range tracker.Connections() {
+			if (req.Client != "" && req.Client != c.Client) ||
+				(req.User != "" && req.User != c.User) ||
+				(req.Target != "" && req.Target != c.Target) ||
+				(req.Node != "" && req.Node != c.Node) {
+				continue
+			}
+			list.Connections = append(list.Connections, c)
+		}
+	}
+	list.Count = len(list.Connections)
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// swagger:parameters deleteConnectionRequest
+type deleteConnectionRequest struct {
+	// in: path
+	// required: true
+	Sid string `uri:"sid" json:"sid"`
+}
+
+// successful operation.
+// swagger:response deleteConnectionResponse
+type deleteConnectionResponse struct {
+	Data Response
+}
+
+func deleteConnection(ctx *gin.Context) {
+	// swagger:route DELETE /connections/{sid} Connection deleteConnectionRequest
+	//
+	// Close the connection by session ID.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: deleteConnectionResponse
+
+	var req deleteConnectionRequest
+	ctx.ShouldBindUri(&req)
+
+	for _, svc := range registry.ServiceRegistry().GetAll() {
+		if tracker, ok := svc.(xservice.ConnTracker); ok &&
+			tracker.CloseConnection(req.Sid) {
+			ctx.JSON(http.StatusOK, Response{
+				Msg: "OK",
+			})
+			return
+		}
+	}
+
+	writeError(ctx, ErrNotFound)
+}
+
+// swagger:parameters deleteConnectionsRequest
+type deleteConnectionsRequest struct {
+	// close the connections of the user.
+	// in: query
+	// required: true
+	User string `form:"user" json:"user"`
+}
+
+// successful operation.
+// swagger:response deleteConnectionsResponse
+type deleteConnectionsResponse struct {
+	Data Response
+}
+
+func deleteConnections(ctx *gin.Context) {
+	// swagger:route DELETE /connections Connection deleteConnectionsRequest
+	//
+	// Close all the connections of the user.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: deleteConnectionsResponse
+
+	var req deleteConnectionsRequest
+	ctx.ShouldBindQuery(&req)
+
+	if req.User == "" {
+		writeError(ctx, ErrInvalid)
+		return
+	}
+
+	parsing.CloseUserConnections("", req.User)
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
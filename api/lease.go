@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wznpp1/gost_x/internal/util/ipam"
+)
+
+// swagger:parameters getLeasesRequest
+type getLeasesRequest struct {
+	// filter by service name.
+	// in: query
+	Service string `form:"service" json:"service"`
+	// filter by client ID.
+	// in: query
+	Client string `form:"client" json:"client"`
+}
+
+// successful operation.
+// swagger:response getLeasesResponse
+type getLeasesResponse struct {
+	// in: body
+	Data leaseList
+}
+
+type leaseInfo struct {
+	Service string    `json:"service"`
+	IP      string    `json:"ip"`
+	Client  string    `json:"client"`
+	Expire  time.Time `json:"expire"`
+}
+
+type leaseList struct {
+	Count  int         `json:"count"`
+	Leases []leaseInfo `json:"leases"`
+}
+
+func getLeases(ctx *gin.Context) {
+	// swagger:route GET /leases Lease getLeasesRequest
+	//
+	// Get the addresses assigned to the clients of the tun services.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getLeasesResponse
+
+	var req getLeasesRequest
+	ctx.ShouldBindQuery(&req)
+
+	pools := ipam.GetAll()
+	services := make([]string, 0, len(pools))
+	for name := range pools {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	list := leaseList{
+		Leases: []leaseInfo{},
+	}
+	for _, name := range services {
+		if req.Service != "" && req.Service != name {
+			continue
+		}
+		for _, lease := range pools[name].Leases() {
+			if req.Client != "" && req.Client != lease.Client {
+				continue
+			}
+			list.Leases = append(list.Leases, leaseInfo{
+				Service: name,
+				IP:      lease.IP.String(),
+				Client:  lease.Client,
+				Expire:  lease.Expire,
+			})
+		}
+	}
+	list.Count = len(list.Leases)
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// swagger:parameters deleteLeaseRequest
+type deleteLeaseRequest struct {
+	// in: path
+	// required: true
+	IP string `uri:"ip" json:"ip"`
+	// revoke the lease of the service only.
+	// in: query
+	Service string `form:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response deleteLeaseResponse
+type deleteLeaseResponse struct {
+	Data Response
+}
+
+func deleteLease(ctx *gin.Context) {
+	// swagger:route DELETE /leases/{ip} Lease deleteLeaseRequest
+	//
+	// Revoke the lease of the address, the client is refused for the lease time.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: deleteLeaseResponse
+
+	var req deleteLeaseRequest
+	ctx.ShouldBindUri(&req)
+	ctx.ShouldBindQuery(&req)
+
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		writeError(ctx, ErrInvalid)
+		return
+	}
+
+	found := false
+	for name, pool := range ipam.GetAll() {
+		if req.Service != "" && req.Service != name {
+			continue
+		}
+		if pool.Revoke(ip) {
+			found = true
+		}
+	}
+	if !found {
+		writeError(ctx, ErrNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
@@ -78,6 +78,14 @@ func NewService(addr string, opts ...Option) (service.Service, error) {
 	config.Use(mwBasicAuth(options.auther))
 	registerConfig(config)
 
+	conns := router.Group("/connections")
+	conns.Use(mwBasicAuth(options.auther))
+	registerConnections(conns)
+
+	leases := router.Group("/leases")
+	leases.Use(mwBasicAuth(options.auther))
+	registerLeases(leases)
+
 	return &server{
 		s: &http.Server{
 			Handler: r,
@@ -98,6 +106,17 @@ func (s *server) Close() error {
 	return s.s.Close()
 }
 
+func registerConnections(conns *gin.RouterGroup) {
+	conns.GET("", getConnections)
+	conns.DELETE("", deleteConnections)
+	conns.DELETE("/:sid", deleteConnection)
+}
+
+func registerLeases(leases *gin.RouterGroup) {
+	leases.GET("", getLeases)
+	leases.DELETE("/:ip", deleteLease)
+}
+
 func registerConfig(config *gin.RouterGroup) {
 	config.GET("", getConfig)
 	config.POST("", saveConfig)
@@ -20,6 +20,7 @@ type options struct {
 	redisLoader loader.Loader
 	httpLoader  loader.Loader
 	period      time.Duration
+	revoke      func(users ...string)
 	logger      logger.Logger
 }
 
@@ -55,6 +56,14 @@ func HTTPLoaderOption(httpLoader loader.Loader) Option {
 	}
 }
 
+// RevokeOption sets the function which is called with the users
+// removed or whose password is changed by the reload.
+func RevokeOption(revoke func(users ...string)) Option {
+	return func(opts *options) {
+		opts.revoke = revoke
+	}
+}
+
 func LoggerOption(logger logger.Logger) Option {
 	return func(opts *options) {
 		opts.logger = logger
@@ -109,6 +118,18 @@ func (p *authenticator) Authenticate(user, password string) bool {
 	return ok && (v == "" || password == v)
 }
 
+// Users implements Lister interface.
+func (p *authenticator) Users() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	users := make(map[string]string, len(p.kvs))
+	for k, v := range p.kvs {
+		users[k] = v
+	}
+	return users
+}
+
 func (p *authenticator) periodReload(ctx context.Context) error {
 	period := p.options.period
 	if period < time.Second {
@@ -142,10 +163,24 @@ func (p *authenticator) reload(ctx context.Context) (err error) {
 	}
 
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
+	revoked := RevokedUsers(p.kvs, kvs)
 	p.kvs = kvs
+	p.mu.Unlock()
 
+	if len(revoked) > 0 && p.options.revoke != nil {
+		p.options.revoke(revoked...)
+	}
+
+	return
+}
+
+// RevokedUsers returns the users in old which are removed or whose password is changed in new.
+func RevokedUsers(old, new map[string]string) (users []string) {
+	for user, password := range old {
+		if v, ok := new[user]; !ok || v != password {
+			users = append(users, user)
+		}
+	}
 	return
 }
 
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"github.com/go-gost/core/auth"
+)
+
+// Lister is implemented by the authenticators which can list their users.
+type Lister interface {
+	// Users returns the user-password pairs.
+	Users() map[string]string
+}
+
+type authenticatorGroup struct {
+	authers []auth.Authenticator
+}
+
+// AuthenticatorGroup creates an Authenticator which authenticates the client by any of the authers,
+// the users of the group are the union of the users of the authers.
+func AuthenticatorGroup(authers ...auth.Authenticator) auth.Authenticator {
+	return &authenticatorGroup{
+		authers: authers,
+	}
+}
+
+func (p *authenticatorGroup) Authenticate(user, password string) bool {
+	if len(p.authers) == 0 {
+		return true
+	}
+	for _, auther := range p.authers {
+		if auther != nil && auther.Authenticate(user, password) {
+			return true
+		}
+	}
+	return false
+}
+
+// Users implements Lister interface.
+func (p *authenticatorGroup) Users() map[string]string {
+	users := make(map[string]string)
+	for _, auther := range p.authers {
+		if lister, ok := auther.(Lister); ok {
+			for k, v := range lister.Users() {
+				if _, ok := users[k]; !ok {
+					users[k] = v
+				}
+			}
+		}
+	}
+	return users
+}
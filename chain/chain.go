@@ -2,6 +2,8 @@ package chain
 
 import (
 	"context"
+	"io"
+	"sync"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/logger"
@@ -14,8 +16,9 @@ var (
 )
 
 type ChainOptions struct {
-	Metadata metadata.Metadata
-	Logger   logger.Logger
+	Metadata      metadata.Metadata
+	HappyEyeballs HappyEyeballsOptions
+	Logger        logger.Logger
 }
 
 type ChainOption func(*ChainOptions)
@@ -26,6 +29,14 @@ func MetadataChainOption(md metadata.Metadata) ChainOption {
 	}
 }
 
+// HappyEyeballsChainOption sets the options used to dial the target directly
+// when the route of the chain has no node.
+func HappyEyeballsChainOption(he HappyEyeballsOptions) ChainOption {
+	return func(opts *ChainOptions) {
+		opts.HappyEyeballs = he
+	}
+}
+
 func LoggerChainOption(logger logger.Logger) ChainOption {
 	return func(opts *ChainOptions) {
 		opts.Logger = logger
@@ -41,6 +52,7 @@ type Chain struct {
 	hops     []chain.Hop
 	marker   selector.Marker
 	metadata metadata.Metadata
+	eyeballs *eyeballs
 	logger   logger.Logger
 }
 
@@ -56,6 +68,7 @@ func NewChain(name string, opts ...ChainOption) *Chain {
 		name:     name,
 		metadata: options.Metadata,
 		marker:   selector.NewFailMarker(),
+		eyeballs: newEyeballs(options.HappyEyeballs),
 		logger:   options.Logger,
 	}
 }
@@ -78,14 +91,36 @@ func (c *Chain) Name() string {
 	return c.name
 }
 
+// Close implements io.Closer interface, the hops of the chain are closed,
+// the hops referenced by name are owned by the hop registry and are not closed.
+func (c *Chain) Close() error {
+	var err error
+	for _, hop := range c.hops {
+		if closer, ok := hop.(io.Closer); ok {
+			if e := closer.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
 func (c *Chain) Route(ctx context.Context, network, address string) chain.Route {
 	if c == nil || len(c.hops) == 0 {
 		return nil
 	}
 
+	opts := []chain.SelectOption{chain.AddrSelectOption(address)}
+	if host := hostFromContext(ctx); host != "" {
+		opts = []chain.SelectOption{
+			chain.AddrSelectOption(host),
+			chain.HostSelectOption(host),
+		}
+	}
+
 	rt := NewRoute(ChainRouteOption(c))
 	for _, hop := range c.hops {
-		node := hop.Select(ctx, chain.AddrSelectOption(address))
+		node := hop.Select(ctx, opts...)
 		if node == nil {
 			return rt
 		}
@@ -94,7 +129,7 @@ func (c *Chain) Route(ctx context.Context, network, address string) chain.Route
 			tr.Options().Route = rt
 			node = node.Copy()
 			node.Options().Transport = tr
-			rt = NewRoute()
+			rt = NewRoute(ChainRouteOption(c))
 		}
 
 		rt.addNode(node)
@@ -105,6 +140,10 @@ func (c *Chain) Route(ctx context.Context, network, address string) chain.Route
 type chainGroup struct {
 	chains   []chain.Chainer
 	selector selector.Selector[chain.Chainer]
+	fallback *FallbackOptions
+	winners  sync.Map // network/address -> *fallbackWinner
+	// swept is the last time the expired winners are removed, in unix nanoseconds.
+	swept int64
 }
 
 func NewChainGroup(chains ...chain.Chainer) *chainGroup {
@@ -116,7 +155,19 @@ func (p *chainGroup) WithSelector(s selector.Selector[chain.Chainer]) *chainGrou
 	return p
 }
 
+// WithFallback makes the chains tried in order for each request, the selector is not used.
+func (p *chainGroup) WithFallback(opts *FallbackOptions) *chainGroup {
+	if opts != nil && opts.TTL <= 0 {
+		opts.TTL = defaultFallbackTTL
+	}
+	p.fallback = opts
+	return p
+}
+
 func (p *chainGroup) Route(ctx context.Context, network, address string) chain.Route {
+	if p != nil && p.fallback != nil {
+		return p.fallbackRoute(ctx, network, address)
+	}
 	if chain := p.next(ctx); chain != nil {
 		return chain.Route(ctx, network, address)
 	}
@@ -128,5 +179,24 @@ func (p *chainGroup) next(ctx context.Context) chain.Chainer {
 		return nil
 	}
 
-	return p.selector.Select(ctx, p.chains...)
+	return p.selector.Select(ctx, p.available(ctx)...)
+}
+
+// available returns the chains except the ones failed in the previous attempts of the request.
+func (p *chainGroup) available(ctx context.Context) []chain.Chainer {
+	ex := getExclusion(ctx)
+	if ex == nil {
+		return p.chains
+	}
+
+	var chains []chain.Chainer
+	for _, c := range p.chains {
+		if !ex.excludeChain(c) {
+			chains = append(chains, c)
+		}
+	}
+	if len(chains) == 0 {
+		return p.chains
+	}
+	return chains
 }
@@ -0,0 +1,62 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/go-gost/core/chain"
+)
+
+type dialOptionsKey struct{}
+
+type hostKey struct{}
+
+// ContextWithHost returns the context carrying the host of the target, such as the host sniffed from the traffic,
+// the route is selected by the host in place of the address it dials, so the domain rules of the hops apply to it.
+func ContextWithHost(ctx context.Context, host string) context.Context {
+	if host == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+func hostFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(hostKey{}).(string)
+	return v
+}
+
+type dialOptions struct {
+	ifceName string
+	sockOpts *chain.SockOpts
+}
+
+// ContextWithDialOptions returns the context carrying the interface and the socket options,
+// they are used to dial the first node of the route if the node has none of its own,
+// such as the outbound interface which keeps the connections of the TUN handler out of the TUN device.
+func ContextWithDialOptions(ctx context.Context, ifceName string, so *chain.SockOpts) context.Context {
+	if ifceName == "" && so == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, dialOptionsKey{}, &dialOptions{
+		ifceName: ifceName,
+		sockOpts: so,
+	})
+}
+
+func dialOptionsFromContext(ctx context.Context) *dialOptions {
+	v, _ := ctx.Value(dialOptionsKey{}).(*dialOptions)
+	return v
+}
+
+// nodeTransport returns the transport of the node with the dial options of ctx applied.
+func nodeTransport(ctx context.Context, node *chain.Node) *chain.Transport {
+	tr := node.Options().Transport
+	opts := dialOptionsFromContext(ctx)
+	if opts == nil || tr.Options().IfceName != "" || tr.Options().SockOpts != nil {
+		return tr
+	}
+
+	tr2 := *tr
+	tr2.Options().IfceName = opts.ifceName
+	tr2.Options().SockOpts = opts.sockOpts
+	return &tr2
+}
@@ -0,0 +1,310 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/hosts"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/resolver"
+	xlogger "github.com/wznpp1/gost_x/logger"
+)
+
+const (
+	// defaultAttemptDelay is the Connection Attempt Delay recommended by RFC 8305.
+	defaultAttemptDelay = 250 * time.Millisecond
+	defaultFailTimeout  = 30 * time.Second
+)
+
+type HappyEyeballsOptions struct {
+	// Prefer is the address family tried first, ipv6 (default) or ipv4.
+	Prefer string
+	// AttemptDelay is the delay before the next address is tried while the previous attempts are in progress.
+	AttemptDelay time.Duration
+	// FailTimeout is how long a failed address is tried after the other addresses.
+	FailTimeout time.Duration
+}
+
+var (
+	nodeEyeballs   sync.Map // nodeKey -> *eyeballs
+	directEyeballs = newEyeballs(HappyEyeballsOptions{})
+)
+
+// SetNodeHappyEyeballs sets the options used to dial the resolved addresses of the node.
+func SetNodeHappyEyeballs(node *chain.Node, opts HappyEyeballsOptions) {
+	if node == nil {
+		return
+	}
+	nodeEyeballs.Store(nodeKey(node), newEyeballs(opts))
+}
+
+func getNodeEyeballs(node *chain.Node) *eyeballs {
+	key := nodeKey(node)
+	if v, ok := nodeEyeballs.Load(key); ok {
+		return v.(*eyeballs)
+	}
+	v, _ := nodeEyeballs.LoadOrStore(key, newEyeballs(HappyEyeballsOptions{}))
+	return v.(*eyeballs)
+}
+
+// eyeballs dials the addresses of a host with the staggered concurrent attempts of RFC 8305 (Happy Eyeballs),
+// and remembers the addresses failed recently.
+type eyeballs struct {
+	options HappyEyeballsOptions
+	failed  sync.Map // address -> time.Time
+}
+
+func newEyeballs(opts HappyEyeballsOptions) *eyeballs {
+	if opts.AttemptDelay <= 0 {
+		opts.AttemptDelay = defaultAttemptDelay
+	}
+	if opts.FailTimeout <= 0 {
+		opts.FailTimeout = defaultFailTimeout
+	}
+	return &eyeballs{
+		options: opts,
+	}
+}
+
+// Dial dials the addresses until one of them succeeds, the first successful connection is returned,
+// the other connections are closed.
+func (e *eyeballs) Dial(ctx context.Context, addrs []string, dial func(ctx context.Context, addr string) (net.Conn, error)) (net.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address to dial")
+	}
+	if len(addrs) == 1 {
+		conn, err := dial(ctx, addrs[0])
+		e.mark(ctx, addrs[0], err)
+		return conn, err
+	}
+
+	addrs = e.sort(addrs)
+
+	conn, _, overtaken, err := race(ctx, len(addrs), e.options.AttemptDelay,
+		func(ctx context.Context, i int) (net.Conn, error) {
+			return dial(ctx, addrs[i])
+		},
+		func(i int, err error) {
+			e.mark(ctx, addrs[i], err)
+		},
+	)
+	// the attempts overtaken are likely unreachable, the addresses are tried last next time.
+	for _, i := range overtaken {
+		e.fail(addrs[i])
+	}
+	return conn, err
+}
+
+// Select returns the address which is preferred to be tried first.
+func (e *eyeballs) Select(addrs []string) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+	return e.sort(addrs)[0]
+}
+
+// mark records the result of the connection attempt to the address.
+func (e *eyeballs) mark(ctx context.Context, addr string, err error) {
+	if err == nil {
+		e.failed.Delete(addr)
+		return
+	}
+	// the attempts are canceled, the address is not to blame.
+	if ctx.Err() != nil {
+		return
+	}
+	e.fail(addr)
+}
+
+func (e *eyeballs) fail(addr string) {
+	t := time.Now()
+	e.failed.Store(addr, t)
+	time.AfterFunc(e.options.FailTimeout, func() {
+		e.failed.CompareAndDelete(addr, t)
+	})
+}
+
+func (e *eyeballs) isFailed(addr string) bool {
+	_, ok := e.failed.Load(addr)
+	return ok
+}
+
+// sort orders the addresses for the connection attempts,
+// the address families are interleaved starting with the preferred one (RFC 8305 section 4),
+// the addresses failed recently are moved to the end.
+func (e *eyeballs) sort(addrs []string) []string {
+	var ip4, ip6, others []string
+	for _, addr := range addrs {
+		host, _, _ := net.SplitHostPort(addr)
+		ip := net.ParseIP(host)
+		switch {
+		case ip == nil:
+			others = append(others, addr)
+		case ip.To4() != nil:
+			ip4 = append(ip4, addr)
+		default:
+			ip6 = append(ip6, addr)
+		}
+	}
+
+	first, second := ip6, ip4
+	if e.options.Prefer == "ipv4" {
+		first, second = ip4, ip6
+	}
+
+	sorted := make([]string, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			sorted = append(sorted, first[i])
+		}
+		if i < len(second) {
+			sorted = append(sorted, second[i])
+		}
+	}
+	sorted = append(sorted, others...)
+
+	var failed []string
+	n := 0
+	for _, addr := range sorted {
+		if e.isFailed(addr) {
+			failed = append(failed, addr)
+			continue
+		}
+		sorted[n] = addr
+		n++
+	}
+	return append(sorted[:n], failed...)
+}
+
+// resolveAll resolves the address to all the addresses of the host of the network family,
+// both the IPv4 and IPv6 addresses for the network without family, such as tcp and ip.
+// The address is returned as is if neither the host mapper nor the resolver is available for the host.
+func resolveAll(ctx context.Context, network, addr string, r resolver.Resolver, hosts hosts.HostMapper, log logger.Logger) ([]string, error) {
+	if addr == "" {
+		return []string{addr}, nil
+	}
+	if log == nil {
+		log = xlogger.Nop()
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+
+	ipNetwork := ipNetworkOf(network)
+	if hosts != nil {
+		ips, _ := hosts.Lookup("ip", host)
+		if ips = filterIPs(ipNetwork, ips); len(ips) > 0 {
+			log.Debugf("hit host mapper: %s -> %s", host, ips)
+			return joinHostPort(ips, port), nil
+		}
+	}
+
+	if r == nil {
+		return []string{addr}, nil
+	}
+
+	// the A and AAAA queries are sent concurrently.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var ips []net.IP
+	invalid := false
+	networks := []string{"ip6", "ip4"}
+	if ipNetwork != "ip" {
+		networks = []string{ipNetwork}
+	}
+	for _, network := range networks {
+		wg.Add(1)
+		go func(network string) {
+			defer wg.Done()
+
+			v, err := r.Resolve(ctx, network, host)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == resolver.ErrInvalid {
+				invalid = true
+				return
+			}
+			if err != nil {
+				log.Error(err)
+			}
+			ips = append(ips, v...)
+		}(network)
+	}
+	wg.Wait()
+
+	if invalid {
+		return []string{addr}, nil
+	}
+	if ips = filterIPs(ipNetwork, ips); len(ips) == 0 {
+		return nil, fmt.Errorf("resolver: domain %s does not exist", host)
+	}
+	return joinHostPort(ips, port), nil
+}
+
+// lookupAll resolves the address with the system resolver for the network.
+func lookupAll(ctx context.Context, network, addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, ipNetworkOf(network), host)
+	if err != nil {
+		return nil, err
+	}
+	return joinHostPort(ips, port), nil
+}
+
+// ipNetworkOf returns the IP network of the address family of the network, ip for the network without family.
+func ipNetworkOf(network string) string {
+	switch network {
+	case "tcp4", "udp4", "ip4":
+		return "ip4"
+	case "tcp6", "udp6", "ip6":
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
+
+// filterIPs returns the IPs of the IP network.
+func filterIPs(network string, ips []net.IP) []net.IP {
+	if network == "ip" {
+		return ips
+	}
+	var v []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (network == "ip4") {
+			v = append(v, ip)
+		}
+	}
+	return v
+}
+
+func joinHostPort(ips []net.IP, port string) []string {
+	addrs := make([]string, 0, len(ips))
+	seen := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		addr := net.JoinHostPort(ip.String(), port)
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
@@ -0,0 +1,196 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/chain"
+)
+
+const (
+	// DirectChainName is the name of the chain group member which dials the target directly.
+	DirectChainName = "direct"
+
+	defaultFallbackTTL = 10 * time.Second
+)
+
+type FallbackOptions struct {
+	// Hedge is the delay after which the next chain is tried in parallel while the previous ones are in progress,
+	// the next chain is tried only when the previous ones fail if it is not positive.
+	Hedge time.Duration
+	// TTL is how long the winning chain is tried first for the same destination.
+	TTL time.Duration
+}
+
+type directChain struct{}
+
+var direct = &directChain{}
+
+// DirectChain returns the chain without hop, the target is dialed directly through it.
+func DirectChain() chain.Chainer {
+	return direct
+}
+
+func (c *directChain) Name() string {
+	return DirectChainName
+}
+
+func (c *directChain) Route(ctx context.Context, network, address string) chain.Route {
+	return NewRoute(ChainRouteOption(c))
+}
+
+type fallbackWinner struct {
+	chain  chain.Chainer
+	expire time.Time
+}
+
+// winnerKey returns the key of the winning chain for the destination,
+// the destinations of the different networks may be reached through the different chains.
+func winnerKey(network, address string) string {
+	return network + "/" + address
+}
+
+func (p *chainGroup) fallbackRoute(ctx context.Context, network, address string) chain.Route {
+	available := p.available(ctx)
+	if len(available) == 0 {
+		return nil
+	}
+
+	chains := make([]chain.Chainer, 0, len(available))
+	// the chain won last time for the destination is tried first.
+	key := winnerKey(network, address)
+	if v, ok := p.winners.Load(key); ok {
+		if w := v.(*fallbackWinner); time.Now().Before(w.expire) {
+			for _, c := range available {
+				if c == w.chain {
+					chains = append(chains, c)
+					break
+				}
+			}
+		} else {
+			p.winners.CompareAndDelete(key, w)
+		}
+	}
+	for _, c := range available {
+		if len(chains) > 0 && c == chains[0] {
+			continue
+		}
+		chains = append(chains, c)
+	}
+
+	routes := make([]chain.Route, len(chains))
+	// the routes are selected until one of them has nodes,
+	// so the route is not taken as empty by the router for Bind if the first chain is direct.
+	for i := range chains {
+		if routes[i] = routeOf(ctx, chains[i], network, address); len(routes[i].Nodes()) > 0 {
+			break
+		}
+	}
+
+	return &fallbackRoute{
+		group:   p,
+		chains:  chains,
+		routes:  routes,
+		network: network,
+		address: address,
+	}
+}
+
+// record remembers the winning chain for the destination for the TTL, the expired one is dropped when it is loaded.
+// The winners of the destinations not requested again are swept once per TTL.
+func (p *chainGroup) record(network, address string, c chain.Chainer) {
+	now := time.Now()
+	p.winners.Store(winnerKey(network, address), &fallbackWinner{
+		chain:  c,
+		expire: now.Add(p.fallback.TTL),
+	})
+
+	swept := atomic.LoadInt64(&p.swept)
+	if now.Sub(time.Unix(0, swept)) < p.fallback.TTL ||
+		!atomic.CompareAndSwapInt64(&p.swept, swept, now.UnixNano()) {
+		return
+	}
+	p.winners.Range(func(key, value any) bool {
+		if w := value.(*fallbackWinner); !now.Before(w.expire) {
+			p.winners.CompareAndDelete(key, w)
+		}
+		return true
+	})
+}
+
+// routeOf returns the route of the chain, the target is dialed directly if the chain has no route for it.
+func routeOf(ctx context.Context, c chain.Chainer, network, address string) chain.Route {
+	if rt := c.Route(ctx, network, address); rt != nil {
+		return rt
+	}
+	return NewRoute()
+}
+
+// fallbackRoute tries the chains of the group in order until one of them succeeds.
+type fallbackRoute struct {
+	group  *chainGroup
+	chains []chain.Chainer
+	// routes are the routes of the chains selected in advance, the others are selected when they are tried.
+	routes []chain.Route
+	// network and address are the ones the route is selected for.
+	network string
+	address string
+}
+
+func (r *fallbackRoute) Dial(ctx context.Context, network, address string, opts ...chain.DialOption) (net.Conn, error) {
+	conn, i, _, err := race(ctx, len(r.chains), r.group.fallback.Hedge,
+		func(ctx context.Context, i int) (net.Conn, error) {
+			return r.route(ctx, i).Dial(ctx, network, address, opts...)
+		}, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.group.record(r.network, r.address, r.chains[i])
+	return conn, nil
+}
+
+func (r *fallbackRoute) Bind(ctx context.Context, network, address string, opts ...chain.BindOption) (ln net.Listener, err error) {
+	var firstErr error
+	for i := range r.chains {
+		ln, err = r.route(ctx, i).Bind(ctx, network, address, opts...)
+		if err == nil {
+			r.group.record(r.network, r.address, r.chains[i])
+			return
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// Nodes returns the nodes of the first route having nodes.
+func (r *fallbackRoute) Nodes() []*chain.Node {
+	for _, rt := range r.routes {
+		if rt == nil {
+			break
+		}
+		if nodes := rt.Nodes(); len(nodes) > 0 {
+			return nodes
+		}
+	}
+	return nil
+}
+
+// routeChains returns the chains of the group tried by the route, they all fail if the route fails.
+func (r *fallbackRoute) routeChains() []chain.Chainer {
+	return r.chains
+}
+
+func (r *fallbackRoute) route(ctx context.Context, i int) chain.Route {
+	if rt := r.routes[i]; rt != nil {
+		return rt
+	}
+	return routeOf(ctx, r.chains[i], r.network, r.address)
+}
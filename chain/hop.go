@@ -62,6 +62,17 @@ func (p *chainHop) Nodes() []*chain.Node {
 	return p.nodes
 }
 
+// Close implements io.Closer interface, the resources of the nodes are released.
+func (p *chainHop) Close() error {
+	var err error
+	for _, node := range p.nodes {
+		if e := CloseNode(node); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 func (p *chainHop) Select(ctx context.Context, opts ...chain.SelectOption) *chain.Node {
 	var options chain.SelectOptions
 	for _, opt := range opts {
@@ -131,6 +142,19 @@ func (p *chainHop) Select(ctx context.Context, opts ...chain.SelectOption) *chai
 		return nil
 	}
 
+	// avoid the nodes failed in the previous attempts of the request.
+	if ex := getExclusion(ctx); ex != nil {
+		var available []*chain.Node
+		for _, node := range nodes {
+			if !ex.excludeNode(node) {
+				available = append(available, node)
+			}
+		}
+		if len(available) > 0 {
+			nodes = available
+		}
+	}
+
 	if s := p.options.selector; s != nil {
 		return s.Select(ctx, nodes...)
 	}
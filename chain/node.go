@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"io"
+	"sync"
+
+	"github.com/go-gost/core/chain"
+)
+
+var (
+	nodeClosers     = make(map[*chain.Node][]io.Closer)
+	nodeClosersLock sync.Mutex
+)
+
+// AddNodeCloser adds a resource owned by the node, such as the dialer which keeps the tunnels to the node,
+// it is closed along with the node.
+func AddNodeCloser(node *chain.Node, closer io.Closer) {
+	if node == nil || closer == nil {
+		return
+	}
+
+	nodeClosersLock.Lock()
+	defer nodeClosersLock.Unlock()
+
+	nodeClosers[node] = append(nodeClosers[node], closer)
+}
+
+// nodeKey returns the identity of the node which is shared by its copies,
+// the multiplexed node is copied for each route, and the copies share the marker of the node.
+func nodeKey(node *chain.Node) any {
+	if m := node.Marker(); m != nil {
+		return m
+	}
+	return node
+}
+
+// CloseNode releases the resources of the node, it is called when the hop of the node is closed.
+func CloseNode(node *chain.Node) error {
+	if node == nil {
+		return nil
+	}
+	nodeEyeballs.Delete(nodeKey(node))
+
+	nodeClosersLock.Lock()
+	closers := nodeClosers[node]
+	delete(nodeClosers, node)
+	nodeClosersLock.Unlock()
+
+	var err error
+	for _, closer := range closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
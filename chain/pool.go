@@ -0,0 +1,244 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+	xlogger "github.com/wznpp1/gost_x/logger"
+)
+
+const (
+	defaultPoolMaxAge = 30 * time.Second
+	// defaultPoolDialTimeout bounds the dial of the pool if the node has no timeout.
+	defaultPoolDialTimeout = 10 * time.Second
+)
+
+type PoolOptions struct {
+	// MinIdle is the number of idle connections kept ready in the pool.
+	MinIdle int
+	// MaxIdle is the maximum number of idle connections, the pool is disabled if it is not positive.
+	// The pool is filled up to MaxIdle when it runs out of the idle connections,
+	// the connections above MinIdle are not replaced when they expire.
+	MaxIdle int
+	// MaxAge is the maximum idle time of the connections in the pool.
+	MaxAge time.Duration
+	Logger logger.Logger
+}
+
+var pools sync.Map // *chain.Node -> *connPool
+
+// SetNodePool enables the pool of the handshaken connections for the node,
+// the connections are used when the node is the first hop of the route.
+// The pool is filled up to MinIdle in the background, it is closed along with the node.
+func SetNodePool(node *chain.Node, opts PoolOptions) {
+	if node == nil || opts.MaxIdle <= 0 || node.Options().Transport.Multiplex() {
+		return
+	}
+	if opts.MinIdle > opts.MaxIdle {
+		opts.MinIdle = opts.MaxIdle
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultPoolMaxAge
+	}
+	if opts.Logger == nil {
+		opts.Logger = xlogger.Nop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &connPool{
+		node:    node,
+		options: opts,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if v, loaded := pools.Swap(node, p); loaded {
+		v.(*connPool).Close()
+	}
+	AddNodeCloser(node, p)
+	p.refill(opts.MinIdle)
+}
+
+func getNodePool(node *chain.Node) *connPool {
+	if v, ok := pools.Load(node); ok {
+		return v.(*connPool)
+	}
+	return nil
+}
+
+type pooledConn struct {
+	net.Conn
+	timer *time.Timer
+}
+
+// connPool holds the idle connections which have been dialed and handshaken with the node.
+type connPool struct {
+	node    *chain.Node
+	options PoolOptions
+	// ctx is canceled when the pool is closed, the pending dials are aborted.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	idle      []*pooledConn
+	pending   int
+	markTime  time.Time
+	markCount int64
+}
+
+// Get returns an idle connection, or nil if the pool is empty, the pool is refilled in the background.
+func (p *connPool) Get() net.Conn {
+	if p.marked() {
+		p.flush()
+	}
+
+	p.mu.Lock()
+	var conn net.Conn
+	if n := len(p.idle); n > 0 {
+		pc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		if pc.timer.Stop() {
+			conn = pc.Conn
+		}
+	}
+	p.mu.Unlock()
+
+	if conn == nil {
+		p.refill(p.options.MaxIdle)
+	} else {
+		p.refill(p.options.MinIdle)
+	}
+	return conn
+}
+
+func (p *connPool) put(conn net.Conn) {
+	pc := &pooledConn{Conn: conn}
+	pc.timer = time.AfterFunc(p.options.MaxAge, func() {
+		p.expire(pc)
+	})
+	p.idle = append(p.idle, pc)
+}
+
+func (p *connPool) expire(pc *pooledConn) {
+	p.mu.Lock()
+	for i := range p.idle {
+		if p.idle[i] == pc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	pc.Close()
+	p.refill(p.options.MinIdle)
+}
+
+// Close closes the idle connections and stops refilling the pool, it is called when the node is closed.
+func (p *connPool) Close() error {
+	p.cancel()
+	pools.CompareAndDelete(p.node, p)
+	p.flush()
+	return nil
+}
+
+// flush closes all the idle connections, it is called when the node is marked as failed.
+func (p *connPool) flush() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		if pc.timer.Stop() {
+			pc.Close()
+		}
+	}
+}
+
+// marked reports whether the node has been marked as failed since the last check.
+func (p *connPool) marked() bool {
+	marker := p.node.Marker()
+	if marker == nil {
+		return false
+	}
+	count, t := marker.Count(), marker.Time()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if count == 0 || (count == p.markCount && t.Equal(p.markTime)) {
+		return false
+	}
+	p.markCount, p.markTime = count, t
+	return true
+}
+
+// refill dials the connections in the background until there are n connections in the pool,
+// it is suspended while the node is marked as failed.
+func (p *connPool) refill(n int) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	if marker := p.node.Marker(); marker != nil && marker.Count() > 0 {
+		return
+	}
+
+	p.mu.Lock()
+	n -= len(p.idle) + p.pending
+	if n <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.pending += n
+	p.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		go func() {
+			conn, err := p.dial(p.ctx)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			p.pending--
+			if err != nil {
+				p.options.Logger.Debugf("pool: dial node %s(%s): %v", p.node.Name, p.node.Addr, err)
+				return
+			}
+			if len(p.idle) >= p.options.MaxIdle || p.ctx.Err() != nil {
+				conn.Close()
+				return
+			}
+			p.put(conn)
+		}()
+	}
+}
+
+// dial dials and handshakes with the node within the timeout of the node.
+func (p *connPool) dial(ctx context.Context) (net.Conn, error) {
+	node := p.node
+
+	timeout := node.Options().Transport.Options().Timeout
+	if timeout <= 0 {
+		timeout = defaultPoolDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	addrs, err := resolveAll(ctx, "ip", node.Addr, node.Options().Resolver, node.Options().HostMapper, p.options.Logger)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := getNodeEyeballs(node).Dial(ctx, addrs, node.Options().Transport.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer setDeadline(node, conn)()
+	cc, err := node.Options().Transport.Handshake(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return cc, nil
+}
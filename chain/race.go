@@ -0,0 +1,110 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sort"
+	"time"
+)
+
+// race runs the n attempts in order, the next attempt starts immediately when the previous ones fail,
+// or after the delay while they are still in progress, it never starts early if the delay is not positive.
+// The first successful connection is returned with the index of its attempt,
+// the connections of the other attempts in progress are closed when they complete.
+// done is called with the result of each attempt completed before the winner,
+// overtaken holds the attempts started earlier than the winner and still in progress.
+// The error of the first failed attempt is returned if all of them fail.
+func race(ctx context.Context, n int, delay time.Duration,
+	attempt func(ctx context.Context, i int) (net.Conn, error),
+	done func(i int, err error)) (conn net.Conn, winner int, overtaken []int, err error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		conn  net.Conn
+		err   error
+	}
+	results := make(chan result, n)
+
+	next := 0
+	pending := make(map[int]bool)
+	start := func() {
+		index := next
+		next++
+		pending[index] = true
+		go func() {
+			conn, err := attempt(ctx, index)
+			results <- result{index: index, conn: conn, err: err}
+		}()
+	}
+
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	resetTimer := func() {
+		if delay <= 0 || next >= n {
+			timeout = nil
+			return
+		}
+		if timer == nil {
+			timer = time.NewTimer(delay)
+		} else {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+		}
+		timeout = timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	start()
+	resetTimer()
+
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.index)
+			if done != nil {
+				done(res.index, res.err)
+			}
+			if res.err == nil {
+				for index := range pending {
+					if index < res.index {
+						overtaken = append(overtaken, index)
+					}
+				}
+				sort.Ints(overtaken)
+				// close the connections of the attempts still in progress.
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}
+				}(len(pending))
+				return res.conn, res.index, overtaken, nil
+			}
+			if err == nil {
+				err = res.err
+			}
+			if next < n {
+				start()
+				resetTimer()
+			}
+		case <-timeout:
+			start()
+			resetTimer()
+		}
+	}
+
+	return nil, -1, nil, err
+}
@@ -0,0 +1,281 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+)
+
+// The stages of the route at which the errors occur.
+const (
+	// StageDial is the stage of dialing the node.
+	StageDial = "dial"
+	// StageHandshake is the stage of the handshake with the node.
+	StageHandshake = "handshake"
+	// StageConnect is the stage of connecting to the target, or binding on it.
+	StageConnect = "connect"
+)
+
+// RouteError is the error of the route, it tells the stage and the node at which the route failed.
+type RouteError struct {
+	Stage string
+	// Node is nil if the target is dialed directly.
+	Node *chain.Node
+	Err  error
+}
+
+func (e *RouteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+func routeError(stage string, node *chain.Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	// the error of the nested route is more specific.
+	var re *RouteError
+	if errors.As(err, &re) {
+		return err
+	}
+	return &RouteError{
+		Stage: stage,
+		Node:  node,
+		Err:   err,
+	}
+}
+
+type RetryPolicy struct {
+	// Retries is the number of the retries after the first attempt fails.
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for each retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout is the deadline of the request, including all the retries.
+	Timeout time.Duration
+	// On is the stages at which the failures are retried, all the stages by default.
+	On []string
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if len(p.On) == 0 {
+		return true
+	}
+
+	stage := StageConnect
+	var re *RouteError
+	if errors.As(err, &re) {
+		stage = re.Stage
+	}
+	for _, s := range p.On {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+type retryChainer struct {
+	chainer chain.Chainer
+	policy  RetryPolicy
+}
+
+// RetryChainer returns a chainer of which the routes are dialed with the retry policy,
+// the nodes and chains failed in the previous attempts of the request are avoided in the retries.
+func RetryChainer(c chain.Chainer, policy RetryPolicy) chain.Chainer {
+	if c == nil {
+		return nil
+	}
+	return &retryChainer{
+		chainer: c,
+		policy:  policy,
+	}
+}
+
+func (c *retryChainer) Route(ctx context.Context, network, address string) chain.Route {
+	rt := c.chainer.Route(ctx, network, address)
+	if rt == nil {
+		return nil
+	}
+	return &retryRoute{
+		route:   rt,
+		chainer: c.chainer,
+		policy:  c.policy,
+		network: network,
+		address: address,
+	}
+}
+
+type retryRoute struct {
+	route   chain.Route
+	chainer chain.Chainer
+	policy  RetryPolicy
+	// network and address are the ones the route is selected for.
+	network string
+	address string
+}
+
+func (r *retryRoute) Dial(ctx context.Context, network, address string, opts ...chain.DialOption) (conn net.Conn, err error) {
+	var options chain.DialOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	err = r.retry(ctx, options.Logger, func(ctx context.Context, rt chain.Route) (err error) {
+		conn, err = rt.Dial(ctx, network, address, opts...)
+		return
+	})
+	return
+}
+
+func (r *retryRoute) Bind(ctx context.Context, network, address string, opts ...chain.BindOption) (ln net.Listener, err error) {
+	var options chain.BindOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	err = r.retry(ctx, options.Logger, func(ctx context.Context, rt chain.Route) (err error) {
+		ln, err = rt.Bind(ctx, network, address, opts...)
+		return
+	})
+	return
+}
+
+func (r *retryRoute) Nodes() []*chain.Node {
+	return r.route.Nodes()
+}
+
+func (r *retryRoute) routeChains() []chain.Chainer {
+	if rc, _ := r.route.(routeChainer); rc != nil {
+		return rc.routeChains()
+	}
+	return nil
+}
+
+func (r *retryRoute) retry(ctx context.Context, log logger.Logger, fn func(ctx context.Context, rt chain.Route) error) error {
+	if r.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.policy.Timeout)
+		defer cancel()
+	}
+
+	ex := &exclusion{}
+	ctx = context.WithValue(ctx, exclusionKey{}, ex)
+
+	rt := r.route
+	backoff := r.policy.Backoff
+	for i := 0; ; i++ {
+		err := fn(ctx, rt)
+		if err == nil {
+			return nil
+		}
+		if i >= r.policy.Retries || ctx.Err() != nil || !r.policy.retryable(err) {
+			return err
+		}
+		if log != nil {
+			log.Debugf("route(retry=%d) %s", i, err)
+		}
+
+		ex.add(rt, err)
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+			if backoff *= 2; r.policy.MaxBackoff > 0 && backoff > r.policy.MaxBackoff {
+				backoff = r.policy.MaxBackoff
+			}
+		}
+
+		if rt = r.chainer.Route(ctx, r.network, r.address); rt == nil {
+			rt = chain.DefaultRoute
+		}
+	}
+}
+
+// routeChainer is implemented by the routes to tell the chains they are selected from,
+// the chains of the failed route are avoided in the retries.
+type routeChainer interface {
+	routeChains() []chain.Chainer
+}
+
+type exclusionKey struct{}
+
+// exclusion holds the nodes and chains failed in the previous attempts of the request.
+// The nodes are identified by the nodeKey rather than the names, which may be shared by the nodes of the different hops,
+// so the copy of the multiplexed node in the failed route matches the node of the hop.
+type exclusion struct {
+	nodes  map[any]bool
+	chains map[string]bool
+	mu     sync.RWMutex
+}
+
+func getExclusion(ctx context.Context) *exclusion {
+	ex, _ := ctx.Value(exclusionKey{}).(*exclusion)
+	return ex
+}
+
+func (ex *exclusion) add(rt chain.Route, err error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	var re *RouteError
+	if errors.As(err, &re) && re.Node != nil {
+		if ex.nodes == nil {
+			ex.nodes = make(map[any]bool)
+		}
+		ex.nodes[nodeKey(re.Node)] = true
+	}
+	if rc, _ := rt.(routeChainer); rc != nil {
+		for _, c := range rc.routeChains() {
+			cn, _ := c.(chainNamer)
+			if cn == nil || cn.Name() == "" {
+				continue
+			}
+			if ex.chains == nil {
+				ex.chains = make(map[string]bool)
+			}
+			ex.chains[cn.Name()] = true
+		}
+	}
+}
+
+func (ex *exclusion) excludeNode(node *chain.Node) bool {
+	if ex == nil || node == nil {
+		return false
+	}
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	return ex.nodes[nodeKey(node)]
+}
+
+func (ex *exclusion) excludeChain(c chain.Chainer) bool {
+	if ex == nil {
+		return false
+	}
+	cn, _ := c.(chainNamer)
+	if cn == nil {
+		return false
+	}
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	return ex.chains[cn.Name()]
+}
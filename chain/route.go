@@ -2,6 +2,8 @@ package chain
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
 	"time"
 
@@ -10,7 +12,12 @@ import (
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/metrics"
 	"github.com/go-gost/core/selector"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xmetrics "github.com/wznpp1/gost_x/metrics"
+	metrics_wrapper "github.com/wznpp1/gost_x/metrics/wrapper"
+	xtracing "github.com/wznpp1/gost_x/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RouteOptions struct {
@@ -48,8 +55,20 @@ func (r *route) addNode(nodes ...*chain.Node) {
 }
 
 func (r *route) Dial(ctx context.Context, network, address string, opts ...chain.DialOption) (net.Conn, error) {
+	ctx, span := xtracing.Start(ctx, "route.dial",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(xtracing.AttrChain, r.chainName()),
+			attribute.String(xtracing.AttrNetwork, network),
+			attribute.String(xtracing.AttrAddress, address),
+		),
+	)
+
 	if len(r.Nodes()) == 0 {
-		return chain.DefaultRoute.Dial(ctx, network, address, opts...)
+		conn, err := r.dialDirect(ctx, network, address, opts...)
+		err = routeError(StageConnect, nil, err)
+		xtracing.End(span, err)
+		return conn, err
 	}
 
 	var options chain.DialOptions
@@ -58,18 +77,42 @@ func (r *route) Dial(ctx context.Context, network, address string, opts ...chain
 			opt(&options)
 		}
 	}
-	conn, err := r.connect(ctx, options.Logger)
+	conn, pooled, err := r.connect(ctx, options.Logger, false)
 	if err != nil {
+		xtracing.End(span, err)
 		return nil, err
 	}
 
-	cc, err := r.getNode(len(r.Nodes())-1).Options().Transport.Connect(ctx, conn, network, address)
+	last := r.getNode(len(r.Nodes()) - 1)
+	cc, err := r.connectNode(ctx, last, conn, network, address)
+	if err != nil && pooled && ctx.Err() == nil && isDeadConn(err) {
+		// the pooled connection may be closed by the peer, retry with a new one,
+		// the failure reported by the node, such as the target refused, is not retried.
+		conn.Close()
+		if conn, _, err = r.connect(ctx, options.Logger, true); err != nil {
+			xtracing.End(span, err)
+			return nil, err
+		}
+		cc, err = r.connectNode(ctx, last, conn, network, address)
+	}
 	if err != nil {
 		if conn != nil {
 			conn.Close()
 		}
+		err = routeError(StageConnect, last, err)
+		xtracing.End(span, err)
 		return nil, err
 	}
+	xtracing.End(span, nil)
+
+	if r.options.Chain != nil {
+		var nodes []string
+		for _, node := range routePath(r) {
+			nodes = append(nodes, node.Name)
+		}
+		cc = metrics_wrapper.WrapNodeConn(r.chainName(), nodes, cc)
+	}
+	xctx.SetNode(ctx, last.Name)
 	return cc, nil
 }
 
@@ -85,12 +128,13 @@ func (r *route) Bind(ctx context.Context, network, address string, opts ...chain
 		}
 	}
 
-	conn, err := r.connect(ctx, options.Logger)
+	conn, _, err := r.connect(ctx, options.Logger, true)
 	if err != nil {
 		return nil, err
 	}
 
-	ln, err := r.getNode(len(r.Nodes())-1).Options().Transport.Bind(ctx,
+	last := r.getNode(len(r.Nodes()) - 1)
+	ln, err := last.Options().Transport.Bind(ctx,
 		conn, network, address,
 		connector.BacklogBindOption(options.Backlog),
 		connector.MuxBindOption(options.Mux),
@@ -100,18 +144,22 @@ func (r *route) Bind(ctx context.Context, network, address string, opts ...chain
 	)
 	if err != nil {
 		conn.Close()
-		return nil, err
+		return nil, routeError(StageConnect, last, err)
 	}
 
 	return ln, nil
 }
 
-func (r *route) connect(ctx context.Context, logger logger.Logger) (conn net.Conn, err error) {
+// connect establishes the connection to the last node through the route.
+// The connection of the first node is taken from the pool of the node unless noPool is set,
+// pooled reports whether it is used.
+func (r *route) connect(ctx context.Context, logger logger.Logger, noPool bool) (conn net.Conn, pooled bool, err error) {
 	network := "ip"
 	node := r.nodes[0]
 
 	defer func() {
-		if r.options.Chain != nil {
+		// the errors of the nested route of the multiplexed node are counted by the nested route.
+		if r.options.Chain != nil && !r.isNestedError(err) {
 			var marker selector.Marker
 			if m, ok := r.options.Chain.(selector.Markable); ok && m != nil {
 				marker = m.Marker()
@@ -137,72 +185,70 @@ func (r *route) connect(ctx context.Context, logger logger.Logger) (conn net.Con
 		}
 	}()
 
-	addr, err := chain.Resolve(ctx, network, node.Addr, node.Options().Resolver, node.Options().HostMapper, logger)
-	marker := node.Marker()
-	if err != nil {
-		if marker != nil {
-			marker.Mark()
-		}
-		return
-	}
+	conn, pooled, err = r.connectNodes(ctx, network, logger, noPool)
+	return
+}
 
-	start := time.Now()
-	cc, err := node.Options().Transport.Dial(ctx, addr)
-	if err != nil {
-		if marker != nil {
-			marker.Mark()
-		}
-		return
-	}
+func (r *route) connectNodes(ctx context.Context, network string, logger logger.Logger, noPool bool) (conn net.Conn, pooled bool, err error) {
+	node := r.nodes[0]
 
-	cn, err := node.Options().Transport.Handshake(ctx, cc)
-	if err != nil {
-		cc.Close()
-		if marker != nil {
-			marker.Mark()
-		}
-		return
-	}
-	if marker != nil {
-		marker.Reset()
+	var cn net.Conn
+	// the pooled connections are dialed without the dial options of the context.
+	if pool := getNodePool(node); pool != nil && !noPool && dialOptionsFromContext(ctx) == nil {
+		cn = pool.Get()
+		pooled = cn != nil
 	}
-
-	if r.options.Chain != nil {
-		var name string
-		if cn, _ := r.options.Chain.(chainNamer); cn != nil {
-			name = cn.Name()
-		}
-		if v := xmetrics.GetObserver(xmetrics.MetricNodeConnectDurationObserver,
-			metrics.Labels{"chain": name, "node": node.Name}); v != nil {
-			v.Observe(time.Since(start).Seconds())
+	if cn == nil {
+		if cn, err = r.connectFirst(ctx, network, node, logger); err != nil {
+			return
 		}
 	}
+	var addr string
+	var cc net.Conn
 
 	preNode := node
 	for _, node := range r.nodes[1:] {
 		marker := node.Marker()
-		addr, err = chain.Resolve(ctx, network, node.Addr, node.Options().Resolver, node.Options().HostMapper, logger)
+		var addrs []string
+		addrs, err = r.resolve(ctx, network, node, logger)
 		if err != nil {
 			cn.Close()
 			if marker != nil {
 				marker.Mark()
 			}
+			err = routeError(StageDial, node, err)
 			return
 		}
-		cc, err = preNode.Options().Transport.Connect(ctx, cn, "tcp", addr)
+		// only one address can be requested through the connection,
+		// the address failed last time is tried after the others.
+		eyeballs := getNodeEyeballs(node)
+		addr = eyeballs.Select(addrs)
+		cc, err = r.connectNode(ctx, preNode, cn, "tcp", addr)
 		if err != nil {
 			cn.Close()
+			if pooled && preNode == r.nodes[0] && ctx.Err() == nil && isDeadConn(err) {
+				// the pooled connection may be closed by the peer, retry with a new one.
+				return r.connectNodes(ctx, network, logger, true)
+			}
+			eyeballs.mark(ctx, addr, err)
 			if marker != nil {
 				marker.Mark()
 			}
+			err = routeError(StageDial, node, err)
 			return
 		}
-		cc, err = node.Options().Transport.Handshake(ctx, cc)
+		cc, err = r.handshake(ctx, node, cc)
 		if err != nil {
 			cn.Close()
+			if pooled && preNode == r.nodes[0] && ctx.Err() == nil && isDeadConn(err) {
+				// the handshake through the dead pooled connection fails, retry with a new one.
+				return r.connectNodes(ctx, network, logger, true)
+			}
 			if marker != nil {
 				marker.Mark()
 			}
+			r.handshakeError(node)
+			err = routeError(StageHandshake, node, err)
 			return
 		}
 		if marker != nil {
@@ -217,6 +263,194 @@ func (r *route) connect(ctx context.Context, logger logger.Logger) (conn net.Con
 	return
 }
 
+// connectFirst dials and handshakes with the first node of the route.
+func (r *route) connectFirst(ctx context.Context, network string, node *chain.Node, logger logger.Logger) (conn net.Conn, err error) {
+	addrs, err := r.resolve(ctx, network, node, logger)
+	marker := node.Marker()
+	if err != nil {
+		if marker != nil {
+			marker.Mark()
+		}
+		err = routeError(StageDial, node, err)
+		return
+	}
+
+	start := time.Now()
+	cc, err := r.dialNode(ctx, node, addrs)
+	if err != nil {
+		if marker != nil {
+			marker.Mark()
+		}
+		err = routeError(StageDial, node, err)
+		return
+	}
+
+	conn, err = r.handshake(ctx, node, cc)
+	if err != nil {
+		cc.Close()
+		if marker != nil {
+			marker.Mark()
+		}
+		r.handshakeError(node)
+		err = routeError(StageHandshake, node, err)
+		return
+	}
+	if marker != nil {
+		marker.Reset()
+	}
+
+	if r.options.Chain != nil {
+		if v := xmetrics.GetObserver(xmetrics.MetricNodeConnectDurationObserver,
+			metrics.Labels{"chain": r.chainName(), "node": node.Name}); v != nil {
+			v.Observe(time.Since(start).Seconds())
+		}
+	}
+	return
+}
+
+// resolve resolves the address of the node to all the addresses of the host.
+func (r *route) resolve(ctx context.Context, network string, node *chain.Node, logger logger.Logger) (addrs []string, err error) {
+	ctx, span := xtracing.Start(ctx, "chain.resolve",
+		trace.WithAttributes(r.nodeAttributes(node)...))
+	defer func() {
+		xtracing.End(span, err)
+	}()
+
+	return resolveAll(ctx, network, node.Addr, node.Options().Resolver, node.Options().HostMapper, logger)
+}
+
+// dialNode dials the addresses of the node with Happy Eyeballs.
+func (r *route) dialNode(ctx context.Context, node *chain.Node, addrs []string) (conn net.Conn, err error) {
+	ctx, span := xtracing.Start(ctx, "chain.dial",
+		trace.WithAttributes(r.nodeAttributes(node)...))
+	defer func() {
+		xtracing.End(span, err)
+	}()
+
+	return getNodeEyeballs(node).Dial(ctx, addrs, nodeTransport(ctx, node).Dial)
+}
+
+// dialDirect dials the target through the default route,
+// the addresses of the host are dialed with Happy Eyeballs for the TCP networks.
+func (r *route) dialDirect(ctx context.Context, network, address string, opts ...chain.DialOption) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return chain.DefaultRoute.Dial(ctx, network, address, opts...)
+	}
+
+	addrs, err := lookupAll(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	eyeballs := directEyeballs
+	if c, ok := r.options.Chain.(*Chain); ok && c != nil {
+		eyeballs = c.eyeballs
+	}
+	return eyeballs.Dial(ctx, addrs, func(ctx context.Context, addr string) (net.Conn, error) {
+		return chain.DefaultRoute.Dial(ctx, network, addr, opts...)
+	})
+}
+
+func (r *route) handshake(ctx context.Context, node *chain.Node, conn net.Conn) (cc net.Conn, err error) {
+	ctx, span := xtracing.Start(ctx, "chain.handshake",
+		trace.WithAttributes(r.nodeAttributes(node)...))
+	defer func() {
+		xtracing.End(span, err)
+	}()
+
+	defer setDeadline(node, conn)()
+	return node.Options().Transport.Handshake(ctx, conn)
+}
+
+// connectNode requests the node to connect to the address through the established connection.
+func (r *route) connectNode(ctx context.Context, node *chain.Node, conn net.Conn, network, address string) (cc net.Conn, err error) {
+	attrs := append(r.nodeAttributes(node),
+		attribute.String(xtracing.AttrNetwork, network),
+		attribute.String(xtracing.AttrAddress, address),
+	)
+	ctx, span := xtracing.Start(ctx, "chain.connect", trace.WithAttributes(attrs...))
+	defer func() {
+		xtracing.End(span, err)
+	}()
+
+	defer setDeadline(node, conn)()
+	return node.Options().Transport.Connect(ctx, conn, network, address)
+}
+
+// setDeadline bounds the operation with the node on the connection by the timeout of the node,
+// the returned function clears the deadline.
+// The connections of the multiplexed transports are shared by the streams and left untouched.
+func setDeadline(node *chain.Node, conn net.Conn) func() {
+	tr := node.Options().Transport
+	timeout := tr.Options().Timeout
+	if timeout <= 0 || tr.Multiplex() {
+		return func() {}
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	return func() {
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+func (r *route) nodeAttributes(node *chain.Node) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(xtracing.AttrChain, r.chainName()),
+		attribute.String(xtracing.AttrNode, node.Name),
+		attribute.String(xtracing.AttrNodeAddr, node.Addr),
+	}
+}
+
+func (r *route) routeChains() []chain.Chainer {
+	if r.options.Chain == nil {
+		return nil
+	}
+	return []chain.Chainer{r.options.Chain}
+}
+
+func (r *route) chainName() string {
+	if cn, _ := r.options.Chain.(chainNamer); cn != nil {
+		return cn.Name()
+	}
+	return ""
+}
+
+// isNestedError reports whether the error occurs at the node of the nested route,
+// which is the route of the multiplexed transport of the first node.
+func (r *route) isNestedError(err error) bool {
+	var re *RouteError
+	if !errors.As(err, &re) || re.Node == nil {
+		return false
+	}
+	for _, node := range r.nodes {
+		if node == re.Node {
+			return false
+		}
+	}
+	return true
+}
+
+// isDeadConn reports whether the error is the I/O error of the connection, such as the connection closed by the peer.
+func isDeadConn(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe) && !oe.Timeout()
+}
+
+func (r *route) handshakeError(node *chain.Node) {
+	if r.options.Chain == nil {
+		return
+	}
+	if v := xmetrics.GetCounter(xmetrics.MetricNodeHandshakeErrorsCounter,
+		metrics.Labels{"chain": r.chainName(), "node": node.Name}); v != nil {
+		v.Inc()
+	}
+}
+
 func (r *route) getNode(index int) *chain.Node {
 	if r == nil || len(r.Nodes()) == 0 || index < 0 || index >= len(r.Nodes()) {
 		return nil
@@ -230,3 +464,18 @@ func (r *route) Nodes() []*chain.Node {
 	}
 	return nil
 }
+
+// routePath returns the nodes of the route,
+// including the nodes of the routes nested in the multiplexed transports.
+func routePath(route chain.Route) (path []*chain.Node) {
+	if route == nil {
+		return
+	}
+	for _, node := range route.Nodes() {
+		if tr := node.Options().Transport; tr != nil {
+			path = append(path, routePath(tr.Options().Route)...)
+		}
+		path = append(path, node)
+	}
+	return
+}
@@ -94,6 +94,22 @@ type APIConfig struct {
 type MetricsConfig struct {
 	Addr string `json:"addr"`
 	Path string `yaml:",omitempty" json:"path,omitempty"`
+	// Labels enables the opt-in labels (user, target) which are disabled by default
+	// because of their unbounded cardinality.
+	Labels []string `yaml:",omitempty" json:"labels,omitempty"`
+	// MaxLabelValues is the maximum number of distinct values of each opt-in label,
+	// the excess values are aggregated into the "other" value.
+	MaxLabelValues int `yaml:"maxLabelValues,omitempty" json:"maxLabelValues,omitempty"`
+}
+
+type TracingConfig struct {
+	// Addr is the address of the OTLP collector (gRPC).
+	Addr        string `json:"addr"`
+	ServiceName string `yaml:"serviceName,omitempty" json:"serviceName,omitempty"`
+	Insecure    bool   `yaml:",omitempty" json:"insecure,omitempty"`
+	// SampleRatio is the fraction of the traces to be sampled, all traces are sampled by default.
+	SampleRatio float64       `yaml:"sampleRatio,omitempty" json:"sampleRatio,omitempty"`
+	Timeout     time.Duration `yaml:",omitempty" json:"timeout,omitempty"`
 }
 
 type TLSConfig struct {
@@ -258,9 +274,17 @@ type ListenerConfig struct {
 	Metadata   map[string]any    `yaml:",omitempty" json:"metadata,omitempty"`
 }
 
+type RetryConfig struct {
+	Backoff    time.Duration `yaml:",omitempty" json:"backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"maxBackoff,omitempty" json:"maxBackoff,omitempty"`
+	On         []string      `yaml:",omitempty" json:"on,omitempty"`
+}
+
 type HandlerConfig struct {
 	Type       string            `json:"type"`
 	Retries    int               `yaml:",omitempty" json:"retries,omitempty"`
+	Timeout    time.Duration     `yaml:",omitempty" json:"timeout,omitempty"`
+	Retry      *RetryConfig      `yaml:",omitempty" json:"retry,omitempty"`
 	Chain      string            `yaml:",omitempty" json:"chain,omitempty"`
 	ChainGroup *ChainGroupConfig `yaml:"chainGroup,omitempty" json:"chainGroup,omitempty"`
 	Auther     string            `yaml:",omitempty" json:"auther,omitempty"`
@@ -353,6 +377,12 @@ type ChainConfig struct {
 type ChainGroupConfig struct {
 	Chains   []string        `yaml:",omitempty" json:"chains,omitempty"`
 	Selector *SelectorConfig `yaml:",omitempty" json:"selector,omitempty"`
+	Fallback *FallbackConfig `yaml:",omitempty" json:"fallback,omitempty"`
+}
+
+type FallbackConfig struct {
+	Hedge time.Duration `yaml:",omitempty" json:"hedge,omitempty"`
+	TTL   time.Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
 }
 
 type HopConfig struct {
@@ -364,6 +394,7 @@ type HopConfig struct {
 	Bypasses  []string        `yaml:",omitempty" json:"bypasses,omitempty"`
 	Resolver  string          `yaml:",omitempty" json:"resolver,omitempty"`
 	Hosts     string          `yaml:",omitempty" json:"hosts,omitempty"`
+	Timeout   time.Duration   `yaml:",omitempty" json:"timeout,omitempty"`
 	Nodes     []*NodeConfig   `yaml:",omitempty" json:"nodes,omitempty"`
 }
 
@@ -378,6 +409,7 @@ type NodeConfig struct {
 	Bypasses  []string         `yaml:",omitempty" json:"bypasses,omitempty"`
 	Resolver  string           `yaml:",omitempty" json:"resolver,omitempty"`
 	Hosts     string           `yaml:",omitempty" json:"hosts,omitempty"`
+	Timeout   time.Duration    `yaml:",omitempty" json:"timeout,omitempty"`
 	Connector *ConnectorConfig `yaml:",omitempty" json:"connector,omitempty"`
 	Dialer    *DialerConfig    `yaml:",omitempty" json:"dialer,omitempty"`
 	Metadata  map[string]any   `yaml:",omitempty" json:"metadata,omitempty"`
@@ -405,6 +437,7 @@ type Config struct {
 	Profiling  *ProfilingConfig   `yaml:",omitempty" json:"profiling,omitempty"`
 	API        *APIConfig         `yaml:",omitempty" json:"api,omitempty"`
 	Metrics    *MetricsConfig     `yaml:",omitempty" json:"metrics,omitempty"`
+	Tracing    *TracingConfig     `yaml:",omitempty" json:"tracing,omitempty"`
 }
 
 func (c *Config) Load() error {
@@ -2,6 +2,7 @@ package parsing
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -20,6 +21,10 @@ import (
 	"github.com/wznpp1/gost_x/registry"
 )
 
+const (
+	defaultNodeTimeout = 10 * time.Second
+)
+
 func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 	if cfg == nil {
 		return nil, nil
@@ -37,6 +42,7 @@ func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 
 	c := xchain.NewChain(cfg.Name,
 		xchain.MetadataChainOption(md),
+		xchain.HappyEyeballsChainOption(parseHappyEyeballs(md)),
 		xchain.LoggerChainOption(chainLogger),
 	)
 
@@ -46,6 +52,7 @@ func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 
 		if len(ch.Nodes) > 0 {
 			if hop, err = ParseHop(ch); err != nil {
+				c.Close()
 				return nil, err
 			}
 		} else {
@@ -59,7 +66,7 @@ func ParseChain(cfg *config.ChainConfig) (chain.Chainer, error) {
 	return c, nil
 }
 
-func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
+func ParseHop(cfg *config.HopConfig) (hop chain.Hop, err error) {
 	if cfg == nil {
 		return nil, nil
 	}
@@ -70,6 +77,20 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 	})
 
 	var nodes []*chain.Node
+	// the connector of the node being created.
+	var pending io.Closer
+	// the resources of the created nodes, such as the pools, are released if the hop fails to be created.
+	defer func() {
+		if err != nil {
+			for _, node := range nodes {
+				xchain.CloseNode(node)
+			}
+			if pending != nil {
+				pending.Close()
+			}
+		}
+	}()
+
 	for _, v := range cfg.Nodes {
 		if v == nil {
 			continue
@@ -132,6 +153,7 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 			connectorLogger.Error("init: ", err)
 			return nil, err
 		}
+		pending, _ = cr.(io.Closer)
 
 		tlsCfg = v.Dialer.TLS
 		if tlsCfg == nil {
@@ -186,6 +208,12 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 		if v.SockOpts == nil {
 			v.SockOpts = cfg.SockOpts
 		}
+		if v.Timeout <= 0 {
+			v.Timeout = cfg.Timeout
+		}
+		if v.Timeout <= 0 {
+			v.Timeout = defaultNodeTimeout
+		}
 
 		var sockOpts *chain.SockOpts
 		if v.SockOpts != nil {
@@ -198,7 +226,7 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 			chain.AddrTransportOption(v.Addr),
 			chain.InterfaceTransportOption(v.Interface),
 			chain.SockOptsTransportOption(sockOpts),
-			chain.TimeoutTransportOption(10*time.Second),
+			chain.TimeoutTransportOption(v.Timeout),
 		)
 
 		// convert *.example.com to .example.com
@@ -246,6 +274,22 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 				)))
 		}
 		node := chain.NewNode(v.Name, v.Addr, opts...)
+		if closer, ok := d.(io.Closer); ok {
+			xchain.AddNodeCloser(node, closer)
+		}
+		if closer, ok := cr.(io.Closer); ok {
+			xchain.AddNodeCloser(node, closer)
+		}
+		pending = nil
+		if nm != nil {
+			xchain.SetNodePool(node, xchain.PoolOptions{
+				MinIdle: mdutil.GetInt(nm, mdKeyPoolMinIdle),
+				MaxIdle: mdutil.GetInt(nm, mdKeyPoolMaxIdle),
+				MaxAge:  mdutil.GetDuration(nm, mdKeyPoolMaxAge),
+				Logger:  nodeLogger,
+			})
+			xchain.SetNodeHappyEyeballs(node, parseHappyEyeballs(nm))
+		}
 		nodes = append(nodes, node)
 	}
 
@@ -259,3 +303,11 @@ func ParseHop(cfg *config.HopConfig) (chain.Hop, error) {
 		xchain.LoggerHopOption(hopLogger),
 	), nil
 }
+
+func parseHappyEyeballs(md metadata.Metadata) xchain.HappyEyeballsOptions {
+	return xchain.HappyEyeballsOptions{
+		Prefer:       mdutil.GetString(md, mdKeyDialPrefer),
+		AttemptDelay: mdutil.GetDuration(md, mdKeyDialAttemptDelay),
+		FailTimeout:  mdutil.GetDuration(md, mdKeyDialFailTimeout),
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/go-gost/core/limiter/rate"
 	"github.com/go-gost/core/limiter/traffic"
 	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/metrics"
 	"github.com/go-gost/core/recorder"
 	"github.com/go-gost/core/resolver"
 	"github.com/go-gost/core/selector"
@@ -27,10 +28,13 @@ import (
 	xconn "github.com/wznpp1/gost_x/limiter/conn"
 	xrate "github.com/wznpp1/gost_x/limiter/rate"
 	xtraffic "github.com/wznpp1/gost_x/limiter/traffic"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
 	xrecorder "github.com/wznpp1/gost_x/recorder"
 	"github.com/wznpp1/gost_x/registry"
 	resolver_impl "github.com/wznpp1/gost_x/resolver"
 	xs "github.com/wznpp1/gost_x/selector"
+	xtracing "github.com/wznpp1/gost_x/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 const (
@@ -43,6 +47,14 @@ const (
 	mdKeyPostUp        = "postUp"
 	mdKeyPostDown      = "postDown"
 	mdKeyIgnoreChain   = "ignoreChain"
+	mdKeyGracePeriod   = "gracePeriod"
+	mdKeyPoolMinIdle   = "pool.minIdle"
+	mdKeyPoolMaxIdle   = "pool.maxIdle"
+	mdKeyPoolMaxAge    = "pool.maxAge"
+
+	mdKeyDialPrefer       = "dial.prefer"
+	mdKeyDialAttemptDelay = "dial.attemptDelay"
+	mdKeyDialFailTimeout  = "dial.failTimeout"
 )
 
 func ParseAuther(cfg *config.AutherConfig) auth.Authenticator {
@@ -62,6 +74,10 @@ func ParseAuther(cfg *config.AutherConfig) auth.Authenticator {
 	opts := []auth_impl.Option{
 		auth_impl.AuthsOption(m),
 		auth_impl.ReloadPeriodOption(cfg.Reload),
+		// the connections of the users revoked by the reload are closed.
+		auth_impl.RevokeOption(func(users ...string) {
+			CloseUserConnections(cfg.Name, users...)
+		}),
 		auth_impl.LoggerOption(logger.Default().WithFields(map[string]any{
 			"kind":   "auther",
 			"auther": cfg.Name,
@@ -413,6 +429,32 @@ func ParseRecorder(cfg *config.RecorderConfig) (r recorder.Recorder) {
 	return
 }
 
+// ParseMetrics creates the metrics with the opt-in labels of the config,
+// the metrics are disabled if cfg is nil.
+func ParseMetrics(cfg *config.MetricsConfig) metrics.Metrics {
+	if cfg == nil || cfg.Addr == "" {
+		return nil
+	}
+	return xmetrics.NewMetrics(
+		xmetrics.LabelsOption(cfg.Labels),
+		xmetrics.MaxLabelValuesOption(cfg.MaxLabelValues),
+	)
+}
+
+// ParseTracing creates the tracer provider which exports the spans to the collector of the config,
+// the tracing is disabled if cfg is nil.
+func ParseTracing(cfg *config.TracingConfig) (*sdktrace.TracerProvider, error) {
+	if cfg == nil || cfg.Addr == "" {
+		return nil, nil
+	}
+	return xtracing.NewTracerProvider(cfg.Addr,
+		xtracing.ServiceNameOption(cfg.ServiceName),
+		xtracing.InsecureOption(cfg.Insecure),
+		xtracing.SampleRatioOption(cfg.SampleRatio),
+		xtracing.TimeoutOption(cfg.Timeout),
+	)
+}
+
 func defaultNodeSelector() selector.Selector[*chain.Node] {
 	return xs.NewSelector(
 		xs.RoundRobinStrategy[*chain.Node](),
@@ -15,10 +15,13 @@ import (
 	"github.com/go-gost/core/recorder"
 	"github.com/go-gost/core/selector"
 	"github.com/go-gost/core/service"
+	xauth "github.com/wznpp1/gost_x/auth"
 	xchain "github.com/wznpp1/gost_x/chain"
 	"github.com/wznpp1/gost_x/config"
+	"github.com/wznpp1/gost_x/internal/net/tproxy"
 	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/metadata"
+	metrics_wrapper "github.com/wznpp1/gost_x/metrics/wrapper"
 	"github.com/wznpp1/gost_x/registry"
 	xservice "github.com/wznpp1/gost_x/service"
 )
@@ -67,10 +70,11 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	}
 	var auther auth.Authenticator
 	if len(authers) > 0 {
-		auther = auth.AuthenticatorGroup(authers...)
+		auther = xauth.AuthenticatorGroup(authers...)
 	}
 
 	admissions := admissionList(cfg.Admission, cfg.Admissions...)
+	adm := metrics_wrapper.WrapAdmission(cfg.Name, admission.AdmissionGroup(admissions...))
 
 	var sockOpts *chain.SockOpts
 	if cfg.SockOpts != nil {
@@ -83,6 +87,7 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	ifce := cfg.Interface
 	var preUp, preDown, postUp, postDown []string
 	var ignoreChain bool
+	gracePeriod := xservice.DefaultGracePeriod
 	if cfg.Metadata != nil {
 		md := metadata.NewMetadata(cfg.Metadata)
 		ppv = mdutil.GetInt(md, mdKeyProxyProtocol)
@@ -99,6 +104,9 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		postUp = mdutil.GetStrings(md, mdKeyPostUp)
 		postDown = mdutil.GetStrings(md, mdKeyPostDown)
 		ignoreChain = mdutil.GetBool(md, mdKeyIgnoreChain)
+		if md.IsExists(mdKeyGracePeriod) {
+			gracePeriod = mdutil.GetDuration(md, mdKeyGracePeriod)
+		}
 	}
 
 	listenOpts := []listener.Option{
@@ -106,7 +114,7 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		listener.AutherOption(auther),
 		listener.AuthOption(parseAuth(cfg.Listener.Auth)),
 		listener.TLSConfigOption(tlsConfig),
-		listener.AdmissionOption(admission.AdmissionGroup(admissions...)),
+		listener.AdmissionOption(adm),
 		listener.TrafficLimiterOption(registry.TrafficLimiterRegistry().Get(cfg.Limiter)),
 		listener.ConnLimiterOption(registry.ConnLimiterRegistry().Get(cfg.CLimiter)),
 		listener.LoggerOption(listenerLogger),
@@ -134,6 +142,28 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		listenerLogger.Error("init: ", err)
 		return nil, err
 	}
+	// the listener is closed to release the bound socket if the service fails to be created.
+	created := false
+	defer func() {
+		if !created {
+			ln.Close()
+		}
+	}()
+
+	// the TPROXY rules of the listener are installed with the listener and removed when it is closed,
+	// the outbound connections are marked so they are not intercepted again.
+	if m, ok := ln.(tproxy.Manager); ok {
+		mark := tproxy.DefaultBypassMark
+		if sockOpts != nil && sockOpts.Mark > 0 {
+			mark = sockOpts.Mark
+		}
+		if m.SetupRules(mark) {
+			if sockOpts == nil {
+				sockOpts = &chain.SockOpts{}
+			}
+			sockOpts.Mark = mark
+		}
+	}
 
 	handlerLogger := serviceLogger.WithFields(map[string]any{
 		"kind": "handler",
@@ -162,7 +192,8 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 
 	auther = nil
 	if len(authers) > 0 {
-		auther = auth.AuthenticatorGroup(authers...)
+		auther = metrics_wrapper.WrapAuthenticator(cfg.Name, cfg.Handler.Type,
+			xauth.AuthenticatorGroup(authers...))
 	}
 
 	var recorders []recorder.RecorderObject
@@ -173,9 +204,20 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		})
 	}
 
+	var chainer chain.Chainer
+	retries := cfg.Handler.Retries
+	if !ignoreChain {
+		chainer = chainGroup(cfg.Handler.Chain, cfg.Handler.ChainGroup)
+	}
+	if chainer != nil {
+		// the retries are taken over by the chain, a different node or chain is tried for each retry.
+		chainer = xchain.RetryChainer(chainer, parseRetryPolicy(cfg.Handler))
+		retries = 0
+	}
+
 	routerOpts := []chain.RouterOption{
-		chain.RetriesRouterOption(cfg.Handler.Retries),
-		// chain.TimeoutRouterOption(10*time.Second),
+		chain.RetriesRouterOption(retries),
+		chain.TimeoutRouterOption(cfg.Handler.Timeout),
 		chain.InterfaceRouterOption(ifce),
 		chain.SockOptsRouterOption(sockOpts),
 		chain.ResolverRouterOption(registry.ResolverRegistry().Get(cfg.Resolver)),
@@ -183,10 +225,8 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 		chain.RecordersRouterOption(recorders...),
 		chain.LoggerRouterOption(handlerLogger),
 	}
-	if !ignoreChain {
-		routerOpts = append(routerOpts,
-			chain.ChainRouterOption(chainGroup(cfg.Handler.Chain, cfg.Handler.ChainGroup)),
-		)
+	if chainer != nil {
+		routerOpts = append(routerOpts, chain.ChainRouterOption(chainer))
 	}
 	router := chain.NewRouter(routerOpts...)
 
@@ -196,7 +236,8 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 			handler.RouterOption(router),
 			handler.AutherOption(auther),
 			handler.AuthOption(parseAuth(cfg.Handler.Auth)),
-			handler.BypassOption(bypass.BypassGroup(bypassList(cfg.Bypass, cfg.Bypasses...)...)),
+			handler.BypassOption(metrics_wrapper.WrapBypass(cfg.Name,
+				bypass.BypassGroup(bypassList(cfg.Bypass, cfg.Bypasses...)...))),
 			handler.TLSConfigOption(tlsConfig),
 			handler.RateLimiterOption(registry.RateLimiterRegistry().Get(cfg.RLimiter)),
 			handler.LoggerOption(handlerLogger),
@@ -224,15 +265,18 @@ func ParseService(cfg *config.ServiceConfig) (service.Service, error) {
 	}
 
 	s := xservice.NewService(cfg.Name, ln, h,
-		xservice.AdmissionOption(admission.AdmissionGroup(admissions...)),
+		xservice.AdmissionOption(adm),
 		xservice.PreUpOption(preUp),
 		xservice.PreDownOption(preDown),
 		xservice.PostUpOption(postUp),
 		xservice.PostDownOption(postDown),
 		xservice.RecordersOption(recorders...),
+		xservice.GracePeriodOption(gracePeriod),
 		xservice.LoggerOption(serviceLogger),
 	)
 
+	created = true
+
 	serviceLogger.Infof("listening on %s/%s", s.Addr().String(), s.Addr().Network())
 	return s, nil
 }
@@ -323,20 +367,40 @@ func admissionList(name string, names ...string) []admission.Admission {
 	return admissions
 }
 
+func parseRetryPolicy(cfg *config.HandlerConfig) xchain.RetryPolicy {
+	policy := xchain.RetryPolicy{
+		Retries: cfg.Retries,
+		Timeout: cfg.Timeout,
+	}
+	if cfg.Retry != nil {
+		policy.Backoff = cfg.Retry.Backoff
+		policy.MaxBackoff = cfg.Retry.MaxBackoff
+		policy.On = cfg.Retry.On
+	}
+	return policy
+}
+
 func chainGroup(name string, group *config.ChainGroupConfig) chain.Chainer {
 	var chains []chain.Chainer
 	var sel selector.Selector[chain.Chainer]
 
-	if c := registry.ChainRegistry().Get(name); c != nil {
+	if c := getChain(name); c != nil {
 		chains = append(chains, c)
 	}
+	var fallback *xchain.FallbackOptions
 	if group != nil {
 		for _, s := range group.Chains {
-			if c := registry.ChainRegistry().Get(s); c != nil {
+			if c := getChain(s); c != nil {
 				chains = append(chains, c)
 			}
 		}
 		sel = parseChainSelector(group.Selector)
+		if group.Fallback != nil {
+			fallback = &xchain.FallbackOptions{
+				Hedge: group.Fallback.Hedge,
+				TTL:   group.Fallback.TTL,
+			}
+		}
 	}
 	if len(chains) == 0 {
 		return nil
@@ -347,5 +411,67 @@ func chainGroup(name string, group *config.ChainGroupConfig) chain.Chainer {
 	}
 
 	return xchain.NewChainGroup(chains...).
-		WithSelector(sel)
+		WithSelector(sel).
+		WithFallback(fallback)
+}
+
+// getChain returns the chain by name, the name direct refers to dialing the target directly
+// unless a chain is registered with it.
+func getChain(name string) chain.Chainer {
+	if name == xchain.DirectChainName && !registry.ChainRegistry().IsRegistered(name) {
+		return xchain.DirectChain()
+	}
+	return registry.ChainRegistry().Get(name)
+}
+
+// CloseUserConnections closes the connections of the users in the services which use the auther,
+// or in all services if auther is empty.
+func CloseUserConnections(auther string, users ...string) {
+	if len(users) == 0 {
+		return
+	}
+
+	var services []service.Service
+	if auther == "" {
+		for _, svc := range registry.ServiceRegistry().GetAll() {
+			services = append(services, svc)
+		}
+	} else {
+		for _, cfg := range config.Global().Services {
+			if cfg == nil || !usesAuther(cfg, auther) {
+				continue
+			}
+			if svc := registry.ServiceRegistry().Get(cfg.Name); svc != nil {
+				services = append(services, svc)
+			}
+		}
+	}
+
+	for _, svc := range services {
+		tracker, ok := svc.(xservice.ConnTracker)
+		if !ok {
+			continue
+		}
+		for _, user := range users {
+			tracker.CloseUserConnections(user)
+		}
+	}
+}
+
+func usesAuther(cfg *config.ServiceConfig, name string) bool {
+	var names []string
+	if cfg.Handler != nil {
+		names = append(names, cfg.Handler.Auther)
+		names = append(names, cfg.Handler.Authers...)
+	}
+	if cfg.Listener != nil {
+		names = append(names, cfg.Listener.Auther)
+		names = append(names, cfg.Listener.Authers...)
+	}
+	for _, v := range names {
+		if v == name {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,79 @@
+package hysteria2
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("hysteria2", NewConnector)
+	registry.ConnectorRegistry().Register("hy2", NewConnector)
+}
+
+type hysteria2Connector struct {
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &hysteria2Connector{
+		options: options,
+	}
+}
+
+func (c *hysteria2Connector) Init(md md.Metadata) (err error) {
+	return nil
+}
+
+// Connect makes the TCP request or creates the UDP session through the client of the hysteria2 dialer.
+func (c *hysteria2Connector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	cc, ok := conn.(*hy2.ClientConn)
+	if !ok {
+		return nil, hy2.ErrInvalidConnection
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		conn, err := cc.Client().DialTCP(ctx, address)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return conn, nil
+	case "udp", "udp4", "udp6":
+		var taddr net.Addr
+		if address != "" {
+			var err error
+			if taddr, err = net.ResolveUDPAddr(network, address); err != nil {
+				log.Error(err)
+				return nil, err
+			}
+		}
+		conn, err := cc.Client().DialUDP(taddr)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return conn, nil
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+}
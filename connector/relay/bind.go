@@ -50,7 +50,7 @@ func (c *relayConnector) bindTunnel(ctx context.Context, conn net.Conn, network
 	}
 	log.Debugf("create tunnel %s connector %s/%s OK", c.md.tunnelID.String(), cid, network)
 
-	session, err := mux.ServerSession(conn)
+	session, err := mux.ServerSession(conn, c.md.muxCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +122,7 @@ func (c *relayConnector) bindTCP(ctx context.Context, conn net.Conn, network, ad
 	}
 	log.Debugf("bind on %s/%s OK", laddr, laddr.Network())
 
-	session, err := mux.ServerSession(conn)
+	session, err := mux.ServerSession(conn, c.md.muxCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -7,12 +7,14 @@ import (
 	mdutil "github.com/go-gost/core/metadata/util"
 	"github.com/go-gost/relay"
 	"github.com/google/uuid"
+	"github.com/wznpp1/gost_x/internal/util/mux"
 )
 
 type metadata struct {
 	connectTimeout time.Duration
 	noDelay        bool
 	tunnelID       relay.TunnelID
+	muxCfg         *mux.Config
 }
 
 func (c *relayConnector) parseMetadata(md mdata.Metadata) (err error) {
@@ -23,6 +25,7 @@ func (c *relayConnector) parseMetadata(md mdata.Metadata) (err error) {
 
 	c.md.connectTimeout = mdutil.GetDuration(md, connectTimeout)
 	c.md.noDelay = mdutil.GetBool(md, noDelay)
+	c.md.muxCfg = mux.ParseConfig(md)
 
 	if s := mdutil.GetString(md, "tunnelID", "tunnel.id"); s != "" {
 		uuid, err := uuid.Parse(s)
@@ -5,6 +5,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
 )
 
 const (
@@ -16,6 +17,7 @@ type metadata struct {
 	noTLS          bool
 	relay          string
 	udpBufferSize  int
+	muxCfg         *mux.Config
 }
 
 func (c *socks5Connector) parseMetadata(md mdata.Metadata) (err error) {
@@ -34,5 +36,7 @@ func (c *socks5Connector) parseMetadata(md mdata.Metadata) (err error) {
 		c.md.udpBufferSize = defaultUDPBufferSize
 	}
 
+	c.md.muxCfg = mux.ParseConfig(md)
+
 	return
 }
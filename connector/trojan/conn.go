@@ -0,0 +1,29 @@
+package trojan
+
+import (
+	"bytes"
+	"net"
+	"sync"
+)
+
+// tcpConn sends the cached request header along with the first write.
+type tcpConn struct {
+	net.Conn
+	wbuf bytes.Buffer
+	mu   sync.Mutex
+}
+
+func (c *tcpConn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n = len(b) // force byte length consistent
+	if c.wbuf.Len() > 0 {
+		c.wbuf.Write(b) // append the data to the cached header
+		_, err = c.Conn.Write(c.wbuf.Bytes())
+		c.wbuf.Reset()
+		return
+	}
+	_, err = c.Conn.Write(b)
+	return
+}
@@ -0,0 +1,125 @@
+package trojan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	"github.com/go-gost/gosocks5"
+	"github.com/wznpp1/gost_x/internal/util/trojan"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("trojan", NewConnector)
+}
+
+type trojanConnector struct {
+	key     []byte
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &trojanConnector{
+		options: options,
+	}
+}
+
+func (c *trojanConnector) Init(md md.Metadata) (err error) {
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	// the password is the trojan password, the username is ignored.
+	var password string
+	if c.options.Auth != nil {
+		password, _ = c.options.Auth.Password()
+		if password == "" {
+			password = c.options.Auth.Username()
+		}
+	}
+	c.key = trojan.Key(password)
+
+	return
+}
+
+func (c *trojanConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"remote":  conn.RemoteAddr().String(),
+		"local":   conn.LocalAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	req := trojan.Request{
+		Key: c.key,
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		req.Cmd = trojan.CmdConnect
+	case "udp", "udp4", "udp6":
+		req.Cmd = trojan.CmdUDPAssociate
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+
+	var taddr net.Addr
+	if address != "" {
+		req.Addr = &gosocks5.Addr{}
+		if err := req.Addr.ParseFrom(address); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if req.Cmd == trojan.CmdUDPAssociate {
+			var err error
+			if taddr, err = net.ResolveUDPAddr(network, address); err != nil {
+				log.Error(err)
+				return nil, err
+			}
+		}
+	} else if req.Cmd == trojan.CmdConnect {
+		err := fmt.Errorf("address is required")
+		log.Error(err)
+		return nil, err
+	}
+
+	if c.md.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if c.md.noDelay {
+		if _, err := req.WriteTo(conn); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	} else {
+		// cache the header
+		cc := &tcpConn{
+			Conn: conn,
+		}
+		if _, err := req.WriteTo(&cc.wbuf); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		conn = cc
+	}
+
+	if req.Cmd == trojan.CmdUDPAssociate {
+		// UDP association if the address is empty.
+		return trojan.UDPClientConn(conn, taddr), nil
+	}
+	return conn, nil
+}
@@ -0,0 +1,25 @@
+package trojan
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+type metadata struct {
+	connectTimeout time.Duration
+	noDelay        bool
+}
+
+func (c *trojanConnector) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		connectTimeout = "timeout"
+		noDelay        = "nodelay"
+	)
+
+	c.md.connectTimeout = mdutil.GetDuration(md, connectTimeout)
+	c.md.noDelay = mdutil.GetBool(md, noDelay)
+
+	return
+}
@@ -0,0 +1,78 @@
+package tuic
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	"github.com/wznpp1/gost_x/internal/util/tuic"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("tuic", NewConnector)
+}
+
+type tuicConnector struct {
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &tuicConnector{
+		options: options,
+	}
+}
+
+func (c *tuicConnector) Init(md md.Metadata) (err error) {
+	return nil
+}
+
+// Connect relays to the address by the Connect command or creates the UDP association through the client of the tuic dialer.
+func (c *tuicConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	cc, ok := conn.(*tuic.ClientConn)
+	if !ok {
+		return nil, tuic.ErrInvalidConnection
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		conn, err := cc.Client().DialTCP(ctx, address)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return conn, nil
+	case "udp", "udp4", "udp6":
+		var taddr net.Addr
+		if address != "" {
+			var err error
+			if taddr, err = net.ResolveUDPAddr(network, address); err != nil {
+				log.Error(err)
+				return nil, err
+			}
+		}
+		conn, err := cc.Client().DialUDP(taddr)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return conn, nil
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+}
@@ -0,0 +1,111 @@
+package vless
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	"github.com/google/uuid"
+	"github.com/wznpp1/gost_x/internal/util/vless"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("vless", NewConnector)
+}
+
+type vlessConnector struct {
+	id      uuid.UUID
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vlessConnector{
+		options: options,
+	}
+}
+
+func (c *vlessConnector) Init(md md.Metadata) (err error) {
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	// the password is the user ID, the username is used if the password is absent.
+	var id string
+	if c.options.Auth != nil {
+		id, _ = c.options.Auth.Password()
+		if id == "" {
+			id = c.options.Auth.Username()
+		}
+	}
+	c.id = vless.ID(id)
+
+	return
+}
+
+func (c *vlessConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"remote":  conn.RemoteAddr().String(),
+		"local":   conn.LocalAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	req := vless.Request{
+		ID:   c.id,
+		Addr: address,
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		req.Cmd = vless.CmdTCP
+	case "udp", "udp4", "udp6":
+		req.Cmd = vless.CmdUDP
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+	if address == "" {
+		// VLESS does not support the UDP association without the target address.
+		err := fmt.Errorf("address is required")
+		log.Error(err)
+		return nil, err
+	}
+
+	if c.md.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	var buf bytes.Buffer
+	if _, err := req.WriteTo(&buf); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	header := buf.Bytes()
+	if c.md.noDelay {
+		if _, err := conn.Write(header); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		header = nil
+	}
+	conn = vless.ClientConn(conn, header)
+
+	if req.Cmd == vless.CmdUDP {
+		return vless.PacketConn(conn), nil
+	}
+	return conn, nil
+}
@@ -0,0 +1,101 @@
+package vmess
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	"github.com/google/uuid"
+	"github.com/wznpp1/gost_x/internal/util/vmess"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("vmess", NewConnector)
+}
+
+type vmessConnector struct {
+	id      uuid.UUID
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vmessConnector{
+		options: options,
+	}
+}
+
+func (c *vmessConnector) Init(md md.Metadata) (err error) {
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	// the password is the user ID, the username is used if the password is absent.
+	var id string
+	if c.options.Auth != nil {
+		id, _ = c.options.Auth.Password()
+		if id == "" {
+			id = c.options.Auth.Username()
+		}
+	}
+	c.id = vmess.ID(id)
+
+	return
+}
+
+func (c *vmessConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"remote":  conn.RemoteAddr().String(),
+		"local":   conn.LocalAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	var cmd uint8
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		cmd = vmess.CmdTCP
+	case "udp", "udp4", "udp6":
+		cmd = vmess.CmdUDP
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+	if address == "" {
+		// VMess does not support the UDP association without the target address.
+		err := fmt.Errorf("address is required")
+		log.Error(err)
+		return nil, err
+	}
+
+	if c.md.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	cc, err := vmess.ClientConn(conn, vmess.NewRequest(c.id, c.md.security, cmd, address))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if c.md.noDelay {
+		// send the request header immediately.
+		if _, err := cc.Write(nil); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+	}
+	return cc, nil
+}
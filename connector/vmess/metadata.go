@@ -0,0 +1,31 @@
+package vmess
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/vmess"
+)
+
+type metadata struct {
+	connectTimeout time.Duration
+	security       uint8
+	noDelay        bool
+}
+
+func (c *vmessConnector) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		connectTimeout = "timeout"
+		security       = "security"
+		noDelay        = "nodelay"
+	)
+
+	c.md.connectTimeout = mdutil.GetDuration(md, connectTimeout)
+	if c.md.security, err = vmess.ParseSecurity(mdutil.GetString(md, security)); err != nil {
+		return
+	}
+	c.md.noDelay = mdutil.GetBool(md, noDelay)
+
+	return
+}
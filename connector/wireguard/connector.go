@@ -0,0 +1,107 @@
+package wireguard
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/go-gost/core/common/net/udp"
+	"github.com/go-gost/core/connector"
+	md "github.com/go-gost/core/metadata"
+	wg_util "github.com/wznpp1/gost_x/internal/util/wireguard"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ConnectorRegistry().Register("wireguard", NewConnector)
+	registry.ConnectorRegistry().Register("wg", NewConnector)
+}
+
+type wireguardConnector struct {
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.Connector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &wireguardConnector{
+		options: options,
+	}
+}
+
+func (c *wireguardConnector) Init(md md.Metadata) (err error) {
+	return nil
+}
+
+func (c *wireguardConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	tc, ok := conn.(*wg_util.TunnelConn)
+	if !ok {
+		return nil, wg_util.ErrInvalidConnection
+	}
+
+	cc, err := tc.Tunnel().DialContext(ctx, network, address)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return cc, nil
+}
+
+// Bind implements connector.Binder.
+func (c *wireguardConnector) Bind(ctx context.Context, conn net.Conn, network, address string, opts ...connector.BindOption) (net.Listener, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("bind on %s/%s", address, network)
+
+	options := connector.BindOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tc, ok := conn.(*wg_util.TunnelConn)
+	if !ok {
+		return nil, wg_util.ErrInvalidConnection
+	}
+
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		pc, err := tc.Tunnel().ListenPacket(addr)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return udp.NewListener(pc, &udp.ListenConfig{
+			Addr:           pc.LocalAddr(),
+			Backlog:        options.Backlog,
+			ReadQueueSize:  options.UDPDataQueueSize,
+			ReadBufferSize: options.UDPDataBufferSize,
+			TTL:            options.UDPConnTTL,
+			KeepAlive:      true,
+			Logger:         log,
+		}), nil
+	default:
+		ln, err := tc.Tunnel().Listen(network, addr)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return ln, nil
+	}
+}
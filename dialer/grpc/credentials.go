@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
+	"google.golang.org/grpc/credentials"
+)
+
+// utlsCredentials is the TLS transport credentials
+// which makes the client handshake by uTLS, see tls_util.WrapTLSClient.
+type utlsCredentials struct {
+	credentials.TransportCredentials
+	config *tls.Config
+	opts   []tls_util.ClientOption
+}
+
+func newUTLSCredentials(config *tls.Config, opts ...tls_util.ClientOption) credentials.TransportCredentials {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	config.NextProtos = []string{"h2"}
+
+	return &utlsCredentials{
+		TransportCredentials: credentials.NewTLS(config),
+		config:               config,
+		opts:                 opts,
+	}
+}
+
+func (c *utlsCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	config := c.config
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = authority
+		if host, _, err := net.SplitHostPort(authority); err == nil {
+			config.ServerName = host
+		}
+	}
+
+	conn, err := tls_util.WrapTLSClient(ctx, rawConn, config, 0, c.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, _ := tls_util.ConnectionState(conn)
+
+	return conn, credentials.TLSInfo{
+		State: state,
+		CommonAuthInfo: credentials.CommonAuthInfo{
+			SecurityLevel: credentials.PrivacyAndIntegrity,
+		},
+	}, nil
+}
+
+func (c *utlsCredentials) Clone() credentials.TransportCredentials {
+	return newUTLSCredentials(c.config, c.opts...)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	pb "github.com/wznpp1/gost_x/internal/util/grpc/proto"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/registry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
@@ -87,7 +88,14 @@ func (d *grpcDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 			grpc.FailOnNonTempDialError(true),
 		}
 		if !d.md.insecure {
-			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(credentials.NewTLS(d.options.TLSConfig)))
+			creds := credentials.NewTLS(d.options.TLSConfig)
+			if d.md.fingerprint != "" || d.md.camouflageKey != "" {
+				creds = newUTLSCredentials(d.options.TLSConfig,
+					tls_util.FingerprintClientOption(d.md.fingerprint),
+					tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+				)
+			}
+			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(creds))
 		} else {
 			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		}
@@ -5,6 +5,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 type metadata struct {
@@ -16,6 +17,8 @@ type metadata struct {
 	keepaliveTimeout             time.Duration
 	keepalivePermitWithoutStream bool
 	minConnectTimeout            time.Duration
+	fingerprint                  string
+	camouflageKey                string
 }
 
 func (d *grpcDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -38,6 +41,11 @@ func (d *grpcDialer) parseMetadata(md mdata.Metadata) (err error) {
 	if d.md.minConnectTimeout <= 0 {
 		d.md.minConnectTimeout = 30 * time.Second
 	}
+	d.md.fingerprint = mdutil.GetString(md, "grpc.fingerprint", "fingerprint")
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, "grpc.camouflageKey", "camouflageKey")
 
 	return
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/registry"
 	"golang.org/x/net/http2"
 )
@@ -98,16 +99,19 @@ func (d *h2Dialer) Dial(ctx context.Context, address string, opts ...dialer.Dial
 				},
 			}
 		} else {
-			client.Transport = &http.Transport{
+			// the transport fills the server name and the h2 protocol in the config.
+			client.Transport = &http2.Transport{
 				TLSClientConfig: d.options.TLSConfig,
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return options.NetDialer.Dial(ctx, network, addr)
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					conn, err := options.NetDialer.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return tls_util.WrapTLSClient(ctx, conn, cfg, 10*time.Second,
+						tls_util.FingerprintClientOption(d.md.fingerprint),
+						tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+					)
 				},
-				ForceAttemptHTTP2:     true,
-				MaxIdleConns:          100,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
 			}
 		}
 
@@ -5,19 +5,24 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 type metadata struct {
-	host   string
-	path   string
-	header http.Header
+	host          string
+	path          string
+	header        http.Header
+	fingerprint   string
+	camouflageKey string
 }
 
 func (d *h2Dialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
-		host   = "host"
-		path   = "path"
-		header = "header"
+		host          = "host"
+		path          = "path"
+		header        = "header"
+		fingerprint   = "fingerprint"
+		camouflageKey = "camouflageKey"
 	)
 
 	d.md.host = mdutil.GetString(md, host)
@@ -29,5 +34,10 @@ func (d *h2Dialer) parseMetadata(md mdata.Metadata) (err error) {
 		}
 		d.md.header = h
 	}
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
 	return
 }
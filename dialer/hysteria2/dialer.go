@@ -0,0 +1,182 @@
+package hysteria2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/go-gost/core/dialer"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.DialerRegistry().Register("hysteria2", NewDialer)
+	registry.DialerRegistry().Register("hy2", NewDialer)
+}
+
+type hysteria2Dialer struct {
+	// clients are the authenticated connections, keyed by the address of the server.
+	clients     map[string]*hy2.Client
+	clientMutex sync.Mutex
+	logger      logger.Logger
+	md          metadata
+	options     dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.Dialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &hysteria2Dialer{
+		clients: make(map[string]*hy2.Client),
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (d *hysteria2Dialer) Init(md md.Metadata) (err error) {
+	return d.parseMetadata(md)
+}
+
+// Dial connects and authenticates to the server at addr, the returned connection is a placeholder of the client,
+// the hysteria2 connector makes the requests through it.
+func (d *hysteria2Dialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	client := d.clients[addr]
+	if client != nil {
+		select {
+		case <-client.Closed():
+			delete(d.clients, addr) // connection is dead
+			client = nil
+		default:
+		}
+	}
+
+	if client == nil {
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		options := &dialer.DialOptions{}
+		for _, opt := range opts {
+			opt(options)
+		}
+
+		c, err := options.NetDialer.Dial(ctx, "udp", "")
+		if err != nil {
+			return nil, err
+		}
+		pc, ok := c.(net.PacketConn)
+		if !ok {
+			c.Close()
+			return nil, errors.New("hysteria2: wrong connection type")
+		}
+
+		if d.md.obfsPassword != "" {
+			if pc, err = hy2.SalamanderPacketConn(pc, []byte(d.md.obfsPassword)); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+
+		client, err = d.initClient(ctx, udpAddr, pc)
+		if err != nil {
+			d.logger.Error(err)
+			pc.Close()
+			return nil, err
+		}
+		d.clients[addr] = client
+
+		go func() {
+			<-client.Closed()
+			pc.Close()
+		}()
+	}
+
+	return hy2.NewClientConn(client), nil
+}
+
+func (d *hysteria2Dialer) initClient(ctx context.Context, addr net.Addr, pc net.PacketConn) (*hy2.Client, error) {
+	quicConfig := &quic.Config{
+		KeepAlivePeriod:      d.md.keepAlivePeriod,
+		HandshakeIdleTimeout: d.md.handshakeTimeout,
+		MaxIdleTimeout:       d.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		EnableDatagrams: true,
+	}
+
+	tlsCfg := d.options.TLSConfig.Clone()
+	tlsCfg.NextProtos = []string{http3.NextProtoH3}
+
+	host := tlsCfg.ServerName
+	if host == "" {
+		host, _, _ = net.SplitHostPort(addr.String())
+	}
+	conn, err := quic.DialEarlyContext(ctx, pc, addr, host, tlsCfg, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// the auth is the password, or in the form of user:password if both are present.
+	var auth string
+	if d.options.Auth != nil {
+		user := d.options.Auth.Username()
+		password, _ := d.options.Auth.Password()
+		switch {
+		case user == "":
+			auth = password
+		case password == "":
+			auth = user
+		default:
+			auth = user + ":" + password
+		}
+	}
+
+	client, err := hy2.NewClient(ctx, conn, &hy2.ClientConfig{
+		Auth: auth,
+		Rx:   d.md.down,
+	})
+	if err != nil {
+		conn.CloseWithError(0, "")
+		return nil, err
+	}
+
+	rx, auto := client.Rx()
+	d.logger.Debugf("%s: authenticated, server rx %d B/s, auto %v", addr, rx, auto)
+
+	return client, nil
+}
+
+// Close closes all the connections of the dialer.
+func (d *hysteria2Dialer) Close() error {
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	for addr, client := range d.clients {
+		client.Close()
+		delete(d.clients, addr)
+	}
+	return nil
+}
+
+// Multiplex implements dialer.Multiplexer interface.
+func (d *hysteria2Dialer) Multiplex() bool {
+	return true
+}
@@ -0,0 +1,60 @@
+package hysteria2
+
+import (
+	"fmt"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+)
+
+type metadata struct {
+	keepAlivePeriod  time.Duration
+	maxIdleTimeout   time.Duration
+	handshakeTimeout time.Duration
+
+	obfsPassword string
+	down         uint64
+}
+
+func (d *hysteria2Dialer) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		keepAlive        = "keepAlive"
+		keepAlivePeriod  = "ttl"
+		handshakeTimeout = "handshakeTimeout"
+		maxIdleTimeout   = "maxIdleTimeout"
+
+		obfs         = "obfs"
+		obfsPassword = "obfsPassword"
+		up           = "up"
+		down         = "down"
+	)
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		d.md.keepAlivePeriod = mdutil.GetDuration(md, keepAlivePeriod)
+		if d.md.keepAlivePeriod <= 0 {
+			d.md.keepAlivePeriod = 10 * time.Second
+		}
+	}
+	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	d.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
+
+	switch v := mdutil.GetString(md, obfs); v {
+	case "", "plain":
+	case "salamander":
+		d.md.obfsPassword = mdutil.GetString(md, obfsPassword)
+		if len(d.md.obfsPassword) < hy2.SalamanderMinPSKLen {
+			return hy2.ErrSalamanderPSK
+		}
+	default:
+		return fmt.Errorf("hysteria2: unknown obfs %s", v)
+	}
+
+	if mdutil.GetString(md, up) != "" {
+		return hy2.ErrUpBandwidth
+	}
+	d.md.down, err = hy2.ParseBandwidth(mdutil.GetString(md, down))
+
+	return
+}
@@ -5,15 +5,14 @@ import (
 	"errors"
 	"net"
 	"sync"
-	"time"
 
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	kcp_util "github.com/wznpp1/gost_x/internal/util/kcp"
+	"github.com/wznpp1/gost_x/internal/util/mux"
 	"github.com/wznpp1/gost_x/registry"
 	"github.com/xtaci/kcp-go/v5"
-	"github.com/xtaci/smux"
 	"github.com/xtaci/tcpraw"
 )
 
@@ -145,16 +144,11 @@ func (d *kcpDialer) initSession(ctx context.Context, addr net.Addr, conn net.Pac
 	}
 
 	// stream multiplex
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.Version = config.SmuxVer
-	smuxConfig.MaxReceiveBuffer = config.SmuxBuf
-	smuxConfig.MaxStreamBuffer = config.StreamBuf
-	smuxConfig.KeepAliveInterval = time.Duration(config.KeepAlive) * time.Second
 	var cc net.Conn = kcpconn
 	if !config.NoComp {
 		cc = kcp_util.CompStreamConn(kcpconn)
 	}
-	session, err := smux.Client(cc, smuxConfig)
+	session, err := mux.ClientSession(cc, config.MuxConfig())
 	if err != nil {
 		return nil, err
 	}
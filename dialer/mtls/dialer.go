@@ -2,7 +2,6 @@ package mtls
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"net"
 	"sync"
@@ -11,8 +10,9 @@ import (
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/registry"
-	"github.com/xtaci/smux"
 )
 
 func init() {
@@ -123,32 +123,16 @@ func (d *mtlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...di
 }
 
 func (d *mtlsDialer) initSession(ctx context.Context, conn net.Conn) (*muxSession, error) {
-	tlsConn := tls.Client(conn, d.options.TLSConfig)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
+	conn, err := tls_util.WrapTLSClient(ctx, conn, d.options.TLSConfig, 0,
+		tls_util.FingerprintClientOption(d.md.fingerprint),
+		tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+	)
+	if err != nil {
 		return nil, err
 	}
-	conn = tlsConn
 
 	// stream multiplex
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.KeepAliveDisabled = d.md.muxKeepAliveDisabled
-	if d.md.muxKeepAliveInterval > 0 {
-		smuxConfig.KeepAliveInterval = d.md.muxKeepAliveInterval
-	}
-	if d.md.muxKeepAliveTimeout > 0 {
-		smuxConfig.KeepAliveTimeout = d.md.muxKeepAliveTimeout
-	}
-	if d.md.muxMaxFrameSize > 0 {
-		smuxConfig.MaxFrameSize = d.md.muxMaxFrameSize
-	}
-	if d.md.muxMaxReceiveBuffer > 0 {
-		smuxConfig.MaxReceiveBuffer = d.md.muxMaxReceiveBuffer
-	}
-	if d.md.muxMaxStreamBuffer > 0 {
-		smuxConfig.MaxStreamBuffer = d.md.muxMaxStreamBuffer
-	}
-
-	session, err := smux.Client(conn, smuxConfig)
+	session, err := mux.ClientSession(conn, d.md.muxCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -5,39 +5,33 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 type metadata struct {
 	handshakeTimeout time.Duration
+	fingerprint      string
+	camouflageKey    string
 
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
 }
 
 func (d *mtlsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		handshakeTimeout = "handshakeTimeout"
-
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+		fingerprint      = "fingerprint"
+		camouflageKey    = "camouflageKey"
 	)
 
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
 
-	d.md.muxKeepAliveDisabled = mdutil.GetBool(md, muxKeepAliveDisabled)
-	d.md.muxKeepAliveInterval = mdutil.GetDuration(md, muxKeepAliveInterval)
-	d.md.muxKeepAliveTimeout = mdutil.GetDuration(md, muxKeepAliveTimeout)
-	d.md.muxMaxFrameSize = mdutil.GetInt(md, muxMaxFrameSize)
-	d.md.muxMaxReceiveBuffer = mdutil.GetInt(md, muxMaxReceiveBuffer)
-	d.md.muxMaxStreamBuffer = mdutil.GetInt(md, muxMaxStreamBuffer)
+	d.md.muxCfg = mux.ParseConfig(md)
 
 	return
 }
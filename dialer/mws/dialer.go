@@ -2,6 +2,7 @@ package mws
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/url"
@@ -11,9 +12,10 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	"github.com/gorilla/websocket"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	ws_util "github.com/wznpp1/gost_x/internal/util/ws"
 	"github.com/wznpp1/gost_x/registry"
-	"github.com/xtaci/smux"
 )
 
 func init() {
@@ -148,7 +150,24 @@ func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn)
 	url := url.URL{Scheme: "ws", Host: host, Path: d.md.path}
 	if d.tlsEnabled {
 		url.Scheme = "wss"
-		dialer.TLSClientConfig = d.options.TLSConfig
+		tlsConfig := &tls.Config{}
+		if d.options.TLSConfig != nil {
+			tlsConfig = d.options.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				tlsConfig.ServerName = h
+			}
+		}
+		// websocket upgrade is only available in HTTP/1.1.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return tls_util.WrapTLSClient(ctx, conn, tlsConfig, 0,
+				tls_util.FingerprintClientOption(d.md.fingerprint),
+				tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+			)
+		}
 	}
 
 	if d.md.handshakeTimeout > 0 {
@@ -177,25 +196,7 @@ func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn)
 	}
 
 	// stream multiplex
-	smuxConfig := smux.DefaultConfig()
-	smuxConfig.KeepAliveDisabled = d.md.muxKeepAliveDisabled
-	if d.md.muxKeepAliveInterval > 0 {
-		smuxConfig.KeepAliveInterval = d.md.muxKeepAliveInterval
-	}
-	if d.md.muxKeepAliveTimeout > 0 {
-		smuxConfig.KeepAliveTimeout = d.md.muxKeepAliveTimeout
-	}
-	if d.md.muxMaxFrameSize > 0 {
-		smuxConfig.MaxFrameSize = d.md.muxMaxFrameSize
-	}
-	if d.md.muxMaxReceiveBuffer > 0 {
-		smuxConfig.MaxReceiveBuffer = d.md.muxMaxReceiveBuffer
-	}
-	if d.md.muxMaxStreamBuffer > 0 {
-		smuxConfig.MaxStreamBuffer = d.md.muxMaxStreamBuffer
-	}
-
-	session, err := smux.Client(cc, smuxConfig)
+	session, err := mux.ClientSession(cc, d.md.muxCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -6,6 +6,8 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -21,13 +23,10 @@ type metadata struct {
 	readBufferSize    int
 	writeBufferSize   int
 	enableCompression bool
+	fingerprint       string
+	camouflageKey     string
 
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
 
 	header    http.Header
 	keepAlive time.Duration
@@ -43,16 +42,11 @@ func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
 		readBufferSize    = "readBufferSize"
 		writeBufferSize   = "writeBufferSize"
 		enableCompression = "enableCompression"
+		fingerprint       = "fingerprint"
+		camouflageKey     = "camouflageKey"
 
 		header    = "header"
 		keepAlive = "keepAlive"
-
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
 	)
 
 	d.md.host = mdutil.GetString(md, host)
@@ -62,18 +56,18 @@ func (d *mwsDialer) parseMetadata(md mdata.Metadata) (err error) {
 		d.md.path = defaultPath
 	}
 
-	d.md.muxKeepAliveDisabled = mdutil.GetBool(md, muxKeepAliveDisabled)
-	d.md.muxKeepAliveInterval = mdutil.GetDuration(md, muxKeepAliveInterval)
-	d.md.muxKeepAliveTimeout = mdutil.GetDuration(md, muxKeepAliveTimeout)
-	d.md.muxMaxFrameSize = mdutil.GetInt(md, muxMaxFrameSize)
-	d.md.muxMaxReceiveBuffer = mdutil.GetInt(md, muxMaxReceiveBuffer)
-	d.md.muxMaxStreamBuffer = mdutil.GetInt(md, muxMaxStreamBuffer)
+	d.md.muxCfg = mux.ParseConfig(md)
 
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
 	d.md.readHeaderTimeout = mdutil.GetDuration(md, readHeaderTimeout)
 	d.md.readBufferSize = mdutil.GetInt(md, readBufferSize)
 	d.md.writeBufferSize = mdutil.GetInt(md, writeBufferSize)
 	d.md.enableCompression = mdutil.GetBool(md, enableCompression)
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
 
 	if m := mdutil.GetStringMapString(md, header); len(m) > 0 {
 		h := http.Header{}
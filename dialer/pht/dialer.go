@@ -2,6 +2,7 @@ package pht
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	pht_util "github.com/wznpp1/gost_x/internal/util/pht"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -97,6 +99,28 @@ func (d *phtDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 		}
 		if d.tlsEnabled {
 			tr.TLSClientConfig = d.options.TLSConfig
+			if d.md.fingerprint != "" || d.md.camouflageKey != "" {
+				// the connection made by uTLS is not recognized by the transport for HTTP/2,
+				// so the HTTP/1.1 is negotiated explicitly.
+				tlsConfig := &tls.Config{}
+				if d.options.TLSConfig != nil {
+					tlsConfig = d.options.TLSConfig.Clone()
+				}
+				if tlsConfig.ServerName == "" {
+					tlsConfig.ServerName = host
+				}
+				tlsConfig.NextProtos = []string{"http/1.1"}
+				tr.DialTLSContext = func(ctx context.Context, network, adr string) (net.Conn, error) {
+					conn, err := options.NetDialer.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return tls_util.WrapTLSClient(ctx, conn, tlsConfig, tr.TLSHandshakeTimeout,
+						tls_util.FingerprintClientOption(d.md.fingerprint),
+						tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+					)
+				}
+			}
 		}
 
 		client = &pht_util.Client{
@@ -5,6 +5,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -19,6 +20,8 @@ type metadata struct {
 	pushPath      string
 	pullPath      string
 	host          string
+	fingerprint   string
+	camouflageKey string
 }
 
 func (d *phtDialer) parseMetadata(md mdata.Metadata) (err error) {
@@ -27,6 +30,8 @@ func (d *phtDialer) parseMetadata(md mdata.Metadata) (err error) {
 		pushPath      = "pushPath"
 		pullPath      = "pullPath"
 		host          = "host"
+		fingerprint   = "fingerprint"
+		camouflageKey = "camouflageKey"
 	)
 
 	d.md.authorizePath = mdutil.GetString(md, authorizePath)
@@ -43,5 +48,11 @@ func (d *phtDialer) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	d.md.host = mdutil.GetString(md, host)
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
+
 	return
 }
@@ -2,13 +2,12 @@ package tls
 
 import (
 	"context"
-	"crypto/tls"
 	"net"
-	"time"
 
 	"github.com/go-gost/core/dialer"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -53,16 +52,8 @@ func (d *tlsDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOp
 
 // Handshake implements dialer.Handshaker
 func (d *tlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dialer.HandshakeOption) (net.Conn, error) {
-	if d.md.handshakeTimeout > 0 {
-		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
-		defer conn.SetDeadline(time.Time{})
-	}
-
-	tlsConn := tls.Client(conn, d.options.TLSConfig)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
-		conn.Close()
-		return nil, err
-	}
-
-	return tlsConn, nil
+	return tls_util.WrapTLSClient(ctx, conn, d.options.TLSConfig, d.md.handshakeTimeout,
+		tls_util.FingerprintClientOption(d.md.fingerprint),
+		tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+	)
 }
@@ -5,18 +5,28 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 type metadata struct {
 	handshakeTimeout time.Duration
+	fingerprint      string
+	camouflageKey    string
 }
 
 func (d *tlsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		handshakeTimeout = "handshakeTimeout"
+		fingerprint      = "fingerprint"
+		camouflageKey    = "camouflageKey"
 	)
 
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
 
 	return
 }
@@ -0,0 +1,150 @@
+package tuic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/go-gost/core/dialer"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/wznpp1/gost_x/internal/util/tuic"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.DialerRegistry().Register("tuic", NewDialer)
+}
+
+type tuicDialer struct {
+	// clients are the connections, keyed by the address of the server.
+	clients      map[string]*tuic.Client
+	clientMutex  sync.Mutex
+	sessionCache tls.ClientSessionCache
+	logger       logger.Logger
+	md           metadata
+	options      dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.Dialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &tuicDialer{
+		clients: make(map[string]*tuic.Client),
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (d *tuicDialer) Init(md md.Metadata) (err error) {
+	if err = d.parseMetadata(md); err != nil {
+		return
+	}
+
+	if d.md.zeroRTT {
+		d.sessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return nil
+}
+
+// Dial connects to the server at addr, the returned connection is a placeholder of the client,
+// the tuic connector relays through it.
+func (d *tuicDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	client := d.clients[addr]
+	if client != nil {
+		select {
+		case <-client.Closed():
+			delete(d.clients, addr) // connection is dead
+			client = nil
+		default:
+		}
+	}
+
+	if client == nil {
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		options := &dialer.DialOptions{}
+		for _, opt := range opts {
+			opt(options)
+		}
+
+		c, err := options.NetDialer.Dial(ctx, "udp", "")
+		if err != nil {
+			return nil, err
+		}
+		pc, ok := c.(net.PacketConn)
+		if !ok {
+			c.Close()
+			return nil, errors.New("tuic: wrong connection type")
+		}
+
+		client, err = d.initClient(ctx, udpAddr, pc)
+		if err != nil {
+			d.logger.Error(err)
+			pc.Close()
+			return nil, err
+		}
+		d.clients[addr] = client
+	}
+
+	return tuic.NewClientConn(client), nil
+}
+
+func (d *tuicDialer) initClient(ctx context.Context, addr net.Addr, pc net.PacketConn) (*tuic.Client, error) {
+	quicConfig := &quic.Config{
+		KeepAlivePeriod:      d.md.keepAlivePeriod,
+		HandshakeIdleTimeout: d.md.handshakeTimeout,
+		MaxIdleTimeout:       d.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		EnableDatagrams: true,
+	}
+
+	tlsCfg := d.options.TLSConfig.Clone()
+	tlsCfg.NextProtos = d.md.alpn
+	tlsCfg.ClientSessionCache = d.sessionCache
+
+	host := tlsCfg.ServerName
+	if host == "" {
+		host, _, _ = net.SplitHostPort(addr.String())
+	}
+	conn, err := quic.DialEarlyContext(ctx, pc, addr, host, tlsCfg, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// the username is the UUID of the user.
+	config := &tuic.ClientConfig{
+		UDPMode:   d.md.udpMode,
+		Heartbeat: d.md.heartbeat,
+		Logger:    d.logger,
+	}
+	if d.options.Auth != nil {
+		config.UUID = tuic.ID(d.options.Auth.Username())
+		config.Password, _ = d.options.Auth.Password()
+	}
+
+	return tuic.NewClient(conn, config), nil
+}
+
+// Multiplex implements dialer.Multiplexer interface.
+func (d *tuicDialer) Multiplex() bool {
+	return true
+}
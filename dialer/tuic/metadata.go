@@ -0,0 +1,64 @@
+package tuic
+
+import (
+	"fmt"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/tuic"
+)
+
+type metadata struct {
+	keepAlivePeriod  time.Duration
+	maxIdleTimeout   time.Duration
+	handshakeTimeout time.Duration
+
+	alpn      []string
+	zeroRTT   bool
+	udpMode   string
+	heartbeat time.Duration
+}
+
+func (d *tuicDialer) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		keepAlive        = "keepAlive"
+		keepAlivePeriod  = "ttl"
+		handshakeTimeout = "handshakeTimeout"
+		maxIdleTimeout   = "maxIdleTimeout"
+
+		alpn      = "alpn"
+		zeroRTT   = "zeroRTT"
+		udpMode   = "udpMode"
+		heartbeat = "heartbeat"
+	)
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		d.md.keepAlivePeriod = mdutil.GetDuration(md, keepAlivePeriod)
+		if d.md.keepAlivePeriod <= 0 {
+			d.md.keepAlivePeriod = 10 * time.Second
+		}
+	}
+	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	d.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
+
+	d.md.alpn = mdutil.GetStrings(md, alpn)
+	if len(d.md.alpn) == 0 {
+		d.md.alpn = []string{"h3"}
+	}
+	d.md.zeroRTT = true
+	if md.IsExists(zeroRTT) {
+		d.md.zeroRTT = mdutil.GetBool(md, zeroRTT)
+	}
+
+	switch d.md.udpMode = mdutil.GetString(md, udpMode); d.md.udpMode {
+	case "":
+		d.md.udpMode = tuic.UDPModeNative
+	case tuic.UDPModeNative, tuic.UDPModeQUIC:
+	default:
+		return fmt.Errorf("tuic: unknown UDP mode %s", d.md.udpMode)
+	}
+	d.md.heartbeat = mdutil.GetDuration(md, heartbeat)
+
+	return
+}
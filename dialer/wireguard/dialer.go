@@ -0,0 +1,109 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/go-gost/core/dialer"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	wg_util "github.com/wznpp1/gost_x/internal/util/wireguard"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.DialerRegistry().Register("wireguard", NewDialer)
+	registry.DialerRegistry().Register("wg", NewDialer)
+}
+
+type wireguardDialer struct {
+	// tunnels are the WireGuard devices, keyed by the endpoint of the peer.
+	tunnels     map[string]*wg_util.Tunnel
+	tunnelMutex sync.Mutex
+	logger      logger.Logger
+	md          metadata
+	options     dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.Dialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &wireguardDialer{
+		tunnels: make(map[string]*wg_util.Tunnel),
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (d *wireguardDialer) Init(md md.Metadata) (err error) {
+	return d.parseMetadata(md)
+}
+
+// Dial brings up the tunnel to the peer at addr, the returned connection is a placeholder of the tunnel,
+// the wireguard connector makes the connections to the targets through it.
+func (d *wireguardDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	d.tunnelMutex.Lock()
+	defer d.tunnelMutex.Unlock()
+
+	tunnel := d.tunnels[addr]
+	if tunnel != nil {
+		select {
+		case <-tunnel.Closed():
+			delete(d.tunnels, addr) // tunnel is dead
+			tunnel = nil
+		default:
+		}
+	}
+
+	if tunnel == nil {
+		var options dialer.DialOptions
+		for _, opt := range opts {
+			opt(&options)
+		}
+		netd := options.NetDialer
+
+		config := *d.md.config
+		config.Peers = append([]wg_util.Peer{}, config.Peers...)
+		config.Peers[0].Endpoint = addr
+		// the socket of the tunnel is bound to the interface and marked by the node settings.
+		config.ListenPacket = func() (net.PacketConn, error) {
+			c, err := netd.Dial(context.Background(), "udp", "")
+			if err != nil {
+				return nil, err
+			}
+			pc, ok := c.(net.PacketConn)
+			if !ok {
+				c.Close()
+				return nil, errors.New("wireguard: wrong connection type")
+			}
+			return pc, nil
+		}
+
+		var err error
+		tunnel, err = wg_util.NewTunnel(&config, d.logger)
+		if err != nil {
+			d.logger.Error(err)
+			return nil, err
+		}
+		d.tunnels[addr] = tunnel
+	}
+
+	return wg_util.NewTunnelConn(tunnel), nil
+}
+
+// Close closes all the tunnels of the dialer.
+func (d *wireguardDialer) Close() error {
+	d.tunnelMutex.Lock()
+	defer d.tunnelMutex.Unlock()
+
+	for addr, tunnel := range d.tunnels {
+		tunnel.Close()
+		delete(d.tunnels, addr)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package wireguard
+
+import (
+	"errors"
+	"net/netip"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	wg_util "github.com/wznpp1/gost_x/internal/util/wireguard"
+)
+
+const (
+	defaultKeepAlivePeriod = 25 * time.Second
+	defaultAllowedIPs      = "0.0.0.0/0,::/0"
+)
+
+var (
+	// defaultDNS are the resolvers used in the tunnel for the targets of the domain names if dns is not set.
+	defaultDNS = []netip.Addr{
+		netip.MustParseAddr("1.1.1.1"),
+		netip.MustParseAddr("2606:4700:4700::1111"),
+	}
+)
+
+type metadata struct {
+	config *wg_util.Config
+}
+
+func (d *wireguardDialer) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		privateKey   = "privateKey"
+		netKey       = "net"
+		dns          = "dns"
+		mtu          = "mtu"
+		publicKey    = "publicKey"
+		presharedKey = "presharedKey"
+		allowedIPs   = "allowedIPs"
+		keepAlive    = "keepAlive"
+		ttl          = "ttl"
+	)
+
+	config := &wg_util.Config{
+		PrivateKey: mdutil.GetString(md, privateKey),
+		MTU:        mdutil.GetInt(md, mtu),
+	}
+	if config.PrivateKey == "" {
+		return errors.New("wireguard: private key is required")
+	}
+	if config.Addresses, err = wg_util.ParseAddrs(mdutil.GetString(md, netKey)); err != nil {
+		return
+	}
+	if len(config.Addresses) == 0 {
+		return errors.New("wireguard: tunnel address (net) is required")
+	}
+	if config.DNS, err = wg_util.ParseAddrs(mdutil.GetString(md, dns)); err != nil {
+		return
+	}
+	if len(config.DNS) == 0 {
+		// use the resolvers of the address families available in the tunnel.
+		for _, addr := range defaultDNS {
+			for _, v := range config.Addresses {
+				if v.Is4() == addr.Is4() {
+					config.DNS = append(config.DNS, addr)
+					break
+				}
+			}
+		}
+	}
+
+	peer := wg_util.Peer{
+		PublicKey:    mdutil.GetString(md, publicKey),
+		PresharedKey: mdutil.GetString(md, presharedKey),
+	}
+	if peer.PublicKey == "" {
+		return errors.New("wireguard: public key of the peer is required")
+	}
+
+	s := mdutil.GetString(md, allowedIPs)
+	if s == "" {
+		s = defaultAllowedIPs
+	}
+	if peer.AllowedIPs, err = wg_util.ParsePrefixes(s); err != nil {
+		return
+	}
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		peer.KeepAlive = mdutil.GetDuration(md, ttl)
+		if peer.KeepAlive <= 0 {
+			peer.KeepAlive = defaultKeepAlivePeriod
+		}
+	}
+	config.Peers = []wg_util.Peer{peer}
+	if err = config.Validate(); err != nil {
+		return
+	}
+
+	d.md.config = config
+
+	return
+}
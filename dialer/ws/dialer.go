@@ -2,6 +2,7 @@ package ws
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/url"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-gost/core/dialer"
 	md "github.com/go-gost/core/metadata"
 	"github.com/gorilla/websocket"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	ws_util "github.com/wznpp1/gost_x/internal/util/ws"
 	"github.com/wznpp1/gost_x/registry"
 )
@@ -94,7 +96,24 @@ func (d *wsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dial
 	url := url.URL{Scheme: "ws", Host: host, Path: d.md.path}
 	if d.tlsEnabled {
 		url.Scheme = "wss"
-		dialer.TLSClientConfig = d.options.TLSConfig
+		tlsConfig := &tls.Config{}
+		if d.options.TLSConfig != nil {
+			tlsConfig = d.options.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				tlsConfig.ServerName = h
+			}
+		}
+		// websocket upgrade is only available in HTTP/1.1.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return tls_util.WrapTLSClient(ctx, conn, tlsConfig, 0,
+				tls_util.FingerprintClientOption(d.md.fingerprint),
+				tls_util.CamouflageKeyClientOption(d.md.camouflageKey),
+			)
+		}
 	}
 
 	c, resp, err := dialer.DialContext(ctx, url.String(), d.md.header)
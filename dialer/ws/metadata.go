@@ -6,6 +6,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -22,6 +23,8 @@ type metadata struct {
 	readBufferSize    int
 	writeBufferSize   int
 	enableCompression bool
+	fingerprint       string
+	camouflageKey     string
 
 	header    http.Header
 	keepAlive time.Duration
@@ -37,6 +40,8 @@ func (d *wsDialer) parseMetadata(md mdata.Metadata) (err error) {
 		readBufferSize    = "readBufferSize"
 		writeBufferSize   = "writeBufferSize"
 		enableCompression = "enableCompression"
+		fingerprint       = "fingerprint"
+		camouflageKey     = "camouflageKey"
 
 		header          = "header"
 		keepAlive       = "keepAlive"
@@ -55,6 +60,11 @@ func (d *wsDialer) parseMetadata(md mdata.Metadata) (err error) {
 	d.md.readBufferSize = mdutil.GetInt(md, readBufferSize)
 	d.md.writeBufferSize = mdutil.GetInt(md, writeBufferSize)
 	d.md.enableCompression = mdutil.GetBool(md, enableCompression)
+	d.md.fingerprint = mdutil.GetString(md, fingerprint)
+	if err = tls_util.ValidateFingerprint(d.md.fingerprint); err != nil {
+		return
+	}
+	d.md.camouflageKey = mdutil.GetString(md, camouflageKey)
 
 	if m := mdutil.GetStringMapString(md, header); len(m) > 0 {
 		h := http.Header{}
@@ -0,0 +1,116 @@
+package wt
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+	wt_util "github.com/wznpp1/gost_x/internal/util/wt"
+)
+
+// wtClient is the QUIC connection to the server, the streams are opened in one session.
+type wtClient struct {
+	dialer  *webtransport.Dialer
+	url     string
+	header  http.Header
+	pc      net.PacketConn
+	conn    quic.EarlyConnection
+	session *webtransport.Session
+	mu      sync.Mutex
+}
+
+func newClient(pc net.PacketConn, raddr net.Addr, url string, header http.Header, tlsCfg *tls.Config, quicCfg *quic.Config) *wtClient {
+	c := &wtClient{
+		url:    url,
+		header: header,
+		pc:     pc,
+	}
+	c.dialer = &webtransport.Dialer{
+		RoundTripper: &http3.RoundTripper{
+			TLSClientConfig: tlsCfg,
+			QuicConfig:      quicCfg,
+			Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+
+				// the connection is not redialed, the client is replaced when it is closed.
+				if c.conn != nil {
+					return nil, net.ErrClosed
+				}
+
+				host, _, _ := net.SplitHostPort(addr)
+				conn, err := quic.DialEarlyContext(ctx, pc, raddr, host, tlsCfg, cfg)
+				if err != nil {
+					return nil, err
+				}
+				c.conn = conn
+				return conn, nil
+			},
+		},
+	}
+	return c
+}
+
+func (c *wtClient) dialSession(ctx context.Context) (*webtransport.Session, error) {
+	var header http.Header
+	if c.header != nil {
+		header = c.header.Clone()
+	}
+	_, session, err := c.dialer.Dial(ctx, c.url, header)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (c *wtClient) dialStream(ctx context.Context) (net.Conn, error) {
+	if c.session == nil {
+		session, err := c.dialSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+	}
+
+	stream, err := c.session.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wt_util.StreamConn(stream, c.session.LocalAddr(), c.session.RemoteAddr()), nil
+}
+
+// isClosed checks whether the QUIC connection or the session of the streams is closed.
+func (c *wtClient) isClosed() bool {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return false
+	}
+
+	select {
+	case <-conn.Context().Done():
+		return true
+	default:
+	}
+
+	if c.session != nil {
+		select {
+		case <-c.session.Context().Done():
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+func (c *wtClient) Close() error {
+	c.dialer.RoundTripper.Close()
+	return c.pc.Close()
+}
@@ -0,0 +1,140 @@
+package wt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/go-gost/core/dialer"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.DialerRegistry().Register("wt", NewDialer)
+}
+
+type wtDialer struct {
+	// clients are the connections, keyed by the address of the server.
+	clients     map[string]*wtClient
+	clientMutex sync.Mutex
+	logger      logger.Logger
+	md          metadata
+	options     dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.Dialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &wtDialer{
+		clients: make(map[string]*wtClient),
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (d *wtDialer) Init(md md.Metadata) (err error) {
+	if err = d.parseMetadata(md); err != nil {
+		return
+	}
+
+	return nil
+}
+
+// Dial opens a stream on the session of the server at addr.
+func (d *wtDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	client := d.clients[addr]
+	if client != nil && client.isClosed() {
+		client.Close()
+		delete(d.clients, addr) // connection is dead
+		client = nil
+	}
+
+	if client == nil {
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		options := &dialer.DialOptions{}
+		for _, opt := range opts {
+			opt(options)
+		}
+
+		c, err := options.NetDialer.Dial(ctx, "udp", "")
+		if err != nil {
+			return nil, err
+		}
+		pc, ok := c.(net.PacketConn)
+		if !ok {
+			c.Close()
+			return nil, errors.New("wt: wrong connection type")
+		}
+
+		host := d.md.host
+		if host == "" {
+			host = options.Host
+		}
+		if host == "" {
+			host = addr
+		}
+		client = d.initClient(udpAddr, pc, host)
+		d.clients[addr] = client
+	}
+
+	conn, err := client.dialStream(ctx)
+	if err != nil {
+		d.logger.Error(err)
+		client.Close()
+		delete(d.clients, addr)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (d *wtDialer) initClient(addr net.Addr, pc net.PacketConn, host string) *wtClient {
+	quicConfig := &quic.Config{
+		KeepAlivePeriod:      d.md.keepAlivePeriod,
+		HandshakeIdleTimeout: d.md.handshakeTimeout,
+		MaxIdleTimeout:       d.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		MaxIncomingStreams: int64(d.md.maxStreams),
+	}
+
+	u := url.URL{Scheme: "https", Host: host, Path: d.md.path}
+	return newClient(pc, addr, u.String(), d.md.header, d.options.TLSConfig, quicConfig)
+}
+
+// Close closes all the connections of the dialer.
+func (d *wtDialer) Close() error {
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	for addr, client := range d.clients {
+		client.Close()
+		delete(d.clients, addr)
+	}
+	return nil
+}
+
+// Multiplex implements dialer.Multiplexer interface.
+func (d *wtDialer) Multiplex() bool {
+	return true
+}
@@ -0,0 +1,73 @@
+package wt
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+const (
+	defaultPath = "/wt"
+)
+
+type metadata struct {
+	host   string
+	path   string
+	header http.Header
+
+	// QUIC config options
+	keepAlivePeriod  time.Duration
+	maxIdleTimeout   time.Duration
+	handshakeTimeout time.Duration
+	maxStreams       int
+}
+
+func (d *wtDialer) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		host     = "host"
+		path     = "path"
+		header   = "header"
+		datagram = "datagram"
+
+		keepAlive        = "keepAlive"
+		keepAlivePeriod  = "ttl"
+		handshakeTimeout = "handshakeTimeout"
+		maxIdleTimeout   = "maxIdleTimeout"
+		maxStreams       = "maxStreams"
+	)
+
+	d.md.host = mdutil.GetString(md, host)
+
+	d.md.path = mdutil.GetString(md, path)
+	if d.md.path == "" {
+		d.md.path = defaultPath
+	}
+
+	if m := mdutil.GetStringMapString(md, header); len(m) > 0 {
+		h := http.Header{}
+		for k, v := range m {
+			h.Add(k, v)
+		}
+		d.md.header = h
+	}
+	// the connectors make the stream connections through the dialer,
+	// the datagrams of the session can not carry them.
+	if mdutil.GetBool(md, datagram) {
+		return errors.New("wt: datagram is not supported by the dialer")
+	}
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		d.md.keepAlivePeriod = mdutil.GetDuration(md, keepAlivePeriod)
+		if d.md.keepAlivePeriod <= 0 {
+			d.md.keepAlivePeriod = 10 * time.Second
+		}
+	}
+	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	d.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
+	d.md.maxStreams = mdutil.GetInt(md, maxStreams)
+
+	return
+}
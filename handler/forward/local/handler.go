@@ -17,8 +17,9 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xnet "github.com/wznpp1/gost_x/internal/net"
-	"github.com/wznpp1/gost_x/internal/util/forward"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -92,12 +93,17 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	var rw io.ReadWriter = conn
 	var host string
 	var protocol string
-	if network == "tcp" && h.md.sniffing {
-		rw, host, protocol, _ = forward.Sniffing(ctx, conn)
+	if h.md.sniffing {
+		if network == "udp" {
+			rw, host, protocol, _ = sniffing.SniffDatagram(conn)
+		} else {
+			rw, host, protocol, _ = sniffing.Sniff(ctx, conn)
+		}
 		log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
+		sniffing.Record(ctx, h.options.Service, host, protocol)
 	}
 
-	if protocol == forward.ProtoHTTP {
+	if protocol == sniffing.ProtoHTTP {
 		h.handleHTTP(ctx, rw, log)
 		return nil
 	}
@@ -129,6 +135,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 		"node": target.Name,
 		"dst":  fmt.Sprintf("%s/%s", target.Addr, network),
 	})
+	xctx.SetTarget(ctx, target.Addr)
 
 	log.Debugf("%s >> %s", conn.RemoteAddr(), target.Addr)
 
@@ -184,7 +191,7 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, log l
 			if h.hop != nil {
 				target = h.hop.Select(ctx,
 					chain.HostSelectOption(req.Host),
-					chain.ProtocolSelectOption(forward.ProtoHTTP),
+					chain.ProtocolSelectOption(sniffing.ProtoHTTP),
 				)
 			}
 			if target == nil {
@@ -21,6 +21,7 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 	"github.com/wznpp1/gost_x/registry"
@@ -125,10 +126,12 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 	fields := map[string]any{
 		"dst": addr,
 	}
-	if u, _, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization"), log); u != "" {
+	u, _, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization"), log)
+	if u != "" {
 		fields["user"] = u
 	}
 	log = log.WithFields(fields)
+	xctx.SetTarget(ctx, addr)
 
 	if log.IsLevelEnabled(logger.TraceLevel) {
 		dump, _ := httputil.DumpRequest(req, false)
@@ -160,6 +163,7 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 	if !h.authenticate(conn, req, resp, log) {
 		return nil
 	}
+	xctx.SetUser(ctx, u)
 
 	if network == "udp" {
 		return h.handleUDP(ctx, conn, log)
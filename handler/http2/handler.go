@@ -23,6 +23,7 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xio "github.com/wznpp1/gost_x/internal/io"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
@@ -124,10 +125,12 @@ func (h *http2Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 	fields := map[string]any{
 		"dst": addr,
 	}
-	if u, _, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization")); u != "" {
+	u, _, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization"))
+	if u != "" {
 		fields["user"] = u
 	}
 	log = log.WithFields(fields)
+	xctx.SetTarget(ctx, addr)
 
 	if log.IsLevelEnabled(logger.TraceLevel) {
 		dump, _ := httputil.DumpRequest(req, false)
@@ -155,6 +158,7 @@ func (h *http2Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 	if !h.authenticate(w, req, resp, log) {
 		return nil
 	}
+	xctx.SetUser(ctx, u)
 
 	// delete the proxy related headers.
 	req.Header.Del("Proxy-Authorization")
@@ -0,0 +1,184 @@
+package hysteria2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/net/udp"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.HandlerRegistry().Register("hysteria2", NewHandler)
+	registry.HandlerRegistry().Register("hy2", NewHandler)
+}
+
+type hysteria2Handler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &hysteria2Handler{
+		options: options,
+	}
+}
+
+func (h *hysteria2Handler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	return nil
+}
+
+// Handle handles the TCP request streams and the UDP sessions accepted by the hysteria2 listener.
+func (h *hysteria2Handler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		return nil
+	}
+
+	switch cc := netpkg.UnwrapConn(conn).(type) {
+	case *hy2.TCPConn:
+		if user := cc.User(); user != "" {
+			log = log.WithFields(map[string]any{"user": user})
+			xctx.SetUser(ctx, user)
+		}
+		return h.handleTCP(ctx, conn, cc.DstAddr(), log)
+	case *hy2.UDPConn:
+		if user := cc.User(); user != "" {
+			log = log.WithFields(map[string]any{"user": user})
+			xctx.SetUser(ctx, user)
+		}
+		return h.handleUDP(ctx, cc, log)
+	default:
+		err := hy2.ErrInvalidConnection
+		log.Error(err)
+		return err
+	}
+}
+
+func (h *hysteria2Handler) handleTCP(ctx context.Context, conn net.Conn, address string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"dst": fmt.Sprintf("%s/%s", address, "tcp"),
+		"cmd": "connect",
+	})
+	xctx.SetTarget(ctx, address)
+
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Debug("bypass: ", address)
+		return hy2.WriteTCPResponse(conn, false, "bypass")
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = sx.ContextWithHash(ctx, &sx.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", address)
+	if err != nil {
+		log.Error(err)
+		hy2.WriteTCPResponse(conn, false, err.Error())
+		return err
+	}
+	defer cc.Close()
+
+	if err := hy2.WriteTCPResponse(conn, true, ""); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), address)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
+
+func (h *hysteria2Handler) handleUDP(ctx context.Context, pc net.PacketConn, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"cmd": "udp",
+	})
+
+	// obtain a udp connection
+	c, err := h.router.Dial(ctx, "udp", "") // UDP association
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer c.Close()
+
+	cc, ok := c.(net.PacketConn)
+	if !ok {
+		err := errors.New("hysteria2: wrong connection type")
+		log.Error(err)
+		return err
+	}
+
+	r := udp.NewRelay(pc, cc).
+		WithBypass(h.options.Bypass).
+		WithLogger(log)
+	r.SetBufferSize(h.md.udpBufferSize)
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", pc.LocalAddr(), cc.LocalAddr())
+	r.Run()
+	log.WithFields(map[string]any{"duration": time.Since(t)}).
+		Debugf("%s >-< %s", pc.LocalAddr(), cc.LocalAddr())
+
+	return nil
+}
+
+func (h *hysteria2Handler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
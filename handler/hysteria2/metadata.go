@@ -0,0 +1,29 @@
+package hysteria2
+
+import (
+	"math"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+type metadata struct {
+	udpBufferSize int
+	hash          string
+}
+
+func (h *hysteria2Handler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		udpBufferSize = "udpBufferSize"
+		hash          = "hash"
+	)
+
+	if bs := mdutil.GetInt(md, udpBufferSize); bs > 0 {
+		h.md.udpBufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
+	} else {
+		h.md.udpBufferSize = 4096
+	}
+	h.md.hash = mdutil.GetString(md, hash)
+
+	return
+}
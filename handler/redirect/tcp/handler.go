@@ -4,23 +4,21 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/tls"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
-	"strings"
 	"time"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
-	dissector "github.com/go-gost/tls-dissector"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xio "github.com/wznpp1/gost_x/internal/io"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -95,21 +93,23 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	log = log.WithFields(map[string]any{
 		"dst": fmt.Sprintf("%s/%s", dstAddr, dstAddr.Network()),
 	})
+	xctx.SetTarget(ctx, dstAddr.String())
 
 	var rw io.ReadWriter = conn
 	if h.md.sniffing {
-		// try to sniff TLS traffic
-		var hdr [dissector.RecordHeaderLen]byte
-		_, err := io.ReadFull(rw, hdr[:])
-		rw = xio.NewReadWriter(io.MultiReader(bytes.NewReader(hdr[:]), rw), rw)
-		if err == nil &&
-			hdr[0] == dissector.Handshake &&
-			binary.BigEndian.Uint16(hdr[1:3]) == tls.VersionTLS10 {
-			return h.handleHTTPS(ctx, rw, conn.RemoteAddr(), dstAddr, log)
-		}
-
-		// try to sniff HTTP traffic
-		if isHTTP(string(hdr[:])) {
+		var host, protocol string
+		rw, host, protocol, err = sniffing.Sniff(ctx, conn)
+		log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
+		sniffing.Record(ctx, h.options.Service, host, protocol)
+
+		switch protocol {
+		case sniffing.ProtoTLS:
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			return h.handleHTTPS(ctx, rw, conn.RemoteAddr(), dstAddr, host, log)
+		case sniffing.ProtoHTTP:
 			return h.handleHTTP(ctx, rw, conn.RemoteAddr(), log)
 		}
 	}
@@ -203,13 +203,7 @@ func (h *redirectHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, radd
 	return nil
 }
 
-func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr, dstAddr net.Addr, log logger.Logger) error {
-	buf := new(bytes.Buffer)
-	host, err := h.getServerName(ctx, io.TeeReader(rw, buf))
-	if err != nil {
-		log.Error(err)
-		return err
-	}
+func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr, dstAddr net.Addr, host string, log logger.Logger) error {
 	if host == "" {
 		host = dstAddr.String()
 	} else {
@@ -240,7 +234,7 @@ func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, rad
 
 	t := time.Now()
 	log.Debugf("%s <-> %s", raddr, host)
-	netpkg.Transport(xio.NewReadWriter(io.MultiReader(buf, rw), rw), cc)
+	netpkg.Transport(rw, cc)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
 	}).Debugf("%s >-< %s", raddr, host)
@@ -248,28 +242,6 @@ func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, rad
 	return nil
 }
 
-func (h *redirectHandler) getServerName(ctx context.Context, r io.Reader) (host string, err error) {
-	record, err := dissector.ReadRecord(r)
-	if err != nil {
-		return
-	}
-
-	clientHello := dissector.ClientHelloMsg{}
-	if err = clientHello.Decode(record.Opaque); err != nil {
-		return
-	}
-
-	for _, ext := range clientHello.Extensions {
-		if ext.Type() == dissector.ExtServerName {
-			snExtension := ext.(*dissector.ServerNameExtension)
-			host = snExtension.Name
-			break
-		}
-	}
-
-	return
-}
-
 func (h *redirectHandler) checkRateLimit(addr net.Addr) bool {
 	if h.options.RateLimiter == nil {
 		return true
@@ -281,15 +253,3 @@ func (h *redirectHandler) checkRateLimit(addr net.Addr) bool {
 
 	return true
 }
-
-func isHTTP(s string) bool {
-	return strings.HasPrefix(http.MethodGet, s[:3]) ||
-		strings.HasPrefix(http.MethodPost, s[:4]) ||
-		strings.HasPrefix(http.MethodPut, s[:3]) ||
-		strings.HasPrefix(http.MethodDelete, s) ||
-		strings.HasPrefix(http.MethodOptions, s) ||
-		strings.HasPrefix(http.MethodPatch, s) ||
-		strings.HasPrefix(http.MethodHead, s[:4]) ||
-		strings.HasPrefix(http.MethodConnect, s) ||
-		strings.HasPrefix(http.MethodTrace, s)
-}
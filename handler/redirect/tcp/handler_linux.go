@@ -4,12 +4,19 @@ import (
 	"errors"
 	"net"
 	"syscall"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// IP6T_SO_ORIGINAL_DST in linux/netfilter_ipv6/ip6_tables.h,
+	// it shares the value of SO_ORIGINAL_DST at the IPv6 level.
+	ip6tSoOriginalDst = 80
+)
+
 func (h *redirectHandler) getOriginalDstAddr(conn net.Conn) (addr net.Addr, err error) {
-	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr)
 	if !ok {
 		err = errors.New("wrong connection type, must be TCP Conn")
 		return
@@ -28,27 +35,11 @@ func (h *redirectHandler) getOriginalDstAddr(conn net.Conn) (addr net.Addr, err
 
 	var cerr error
 	err = rc.Control(func(fd uintptr) {
+		// the IPv4 connection accepted by the dual-stack socket is tracked by the IPv4 conntrack.
 		if tcpAddr.IP.To4() != nil {
-			mreq, err := unix.GetsockoptIPv6Mreq(int(fd), unix.IPPROTO_IP, unix.SO_ORIGINAL_DST)
-			if err != nil {
-				cerr = err
-				return
-			}
-
-			addr = &net.TCPAddr{
-				IP:   net.IP(mreq.Multiaddr[4:8]),
-				Port: int(mreq.Multiaddr[2])<<8 + int(mreq.Multiaddr[3]),
-			}
+			addr, cerr = getOriginalDst4(int(fd))
 		} else {
-			info, err := unix.GetsockoptIPv6MTUInfo(int(fd), unix.IPPROTO_IPV6, unix.SO_ORIGINAL_DST)
-			if err != nil {
-				cerr = err
-				return
-			}
-			addr = &net.TCPAddr{
-				IP:   net.IP(info.Addr.Addr[:]),
-				Port: int(info.Addr.Port),
-			}
+			addr, cerr = getOriginalDst6(int(fd))
 		}
 	})
 	if err != nil {
@@ -60,3 +51,47 @@ func (h *redirectHandler) getOriginalDstAddr(conn net.Conn) (addr net.Addr, err
 
 	return
 }
+
+func getOriginalDst4(fd int) (net.Addr, error) {
+	var sa unix.RawSockaddrInet4
+	if err := getsockopt(fd, unix.SOL_IP, unix.SO_ORIGINAL_DST, unsafe.Pointer(&sa), unsafe.Sizeof(sa)); err != nil {
+		return nil, err
+	}
+
+	p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+	return &net.TCPAddr{
+		IP:   net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]),
+		Port: int(p[0])<<8 + int(p[1]),
+	}, nil
+}
+
+func getOriginalDst6(fd int) (net.Addr, error) {
+	var sa unix.RawSockaddrInet6
+	if err := getsockopt(fd, unix.SOL_IPV6, ip6tSoOriginalDst, unsafe.Pointer(&sa), unsafe.Sizeof(sa)); err != nil {
+		return nil, err
+	}
+
+	p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+	addr := &net.TCPAddr{
+		IP:   make(net.IP, net.IPv6len),
+		Port: int(p[0])<<8 + int(p[1]),
+	}
+	copy(addr.IP, sa.Addr[:])
+	if sa.Scope_id > 0 {
+		if ifce, err := net.InterfaceByIndex(int(sa.Scope_id)); err == nil {
+			addr.Zone = ifce.Name
+		}
+	}
+	return addr, nil
+}
+
+// getsockopt reads the socket option of the sockaddr type, which is not covered by the unix package.
+func getsockopt(fd, level, name int, v unsafe.Pointer, size uintptr) error {
+	l := uint32(size)
+	_, _, e := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), uintptr(level), uintptr(name),
+		uintptr(v), uintptr(unsafe.Pointer(&l)), 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
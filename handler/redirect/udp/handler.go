@@ -3,13 +3,17 @@ package redirect
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"time"
 
 	"github.com/go-gost/core/chain"
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
+	xchain "github.com/wznpp1/gost_x/chain"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -72,11 +76,36 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	log = log.WithFields(map[string]any{
 		"dst": fmt.Sprintf("%s/%s", dstAddr, dstAddr.Network()),
 	})
+	xctx.SetTarget(ctx, dstAddr.String())
+
+	// the sniffed host is used by the bypass, the route selection and the logs,
+	// the packets are always sent to the original destination.
+	host := dstAddr.String()
+
+	var rw io.ReadWriter = conn
+	if h.md.sniffing {
+		var sniffed, protocol string
+		rw, sniffed, protocol, _ = sniffing.SniffDatagram(conn)
+		log.Debugf("sniffing: host=%s, protocol=%s", sniffed, protocol)
+		sniffing.Record(ctx, h.options.Service, sniffed, protocol)
+
+		if sniffed != "" {
+			if v, _, err := net.SplitHostPort(sniffed); err == nil {
+				sniffed = v
+			}
+			_, port, _ := net.SplitHostPort(host)
+			host = net.JoinHostPort(sniffed, port)
+			log = log.WithFields(map[string]any{
+				"host": host,
+			})
+			ctx = xchain.ContextWithHost(ctx, host)
+		}
+	}
 
-	log.Debugf("%s >> %s", conn.RemoteAddr(), dstAddr)
+	log.Debugf("%s >> %s", conn.RemoteAddr(), host)
 
-	if h.options.Bypass != nil && h.options.Bypass.Contains(dstAddr.String()) {
-		log.Debug("bypass: ", dstAddr)
+	if h.options.Bypass != nil && h.options.Bypass.Contains(host) {
+		log.Debug("bypass: ", host)
 		return nil
 	}
 
@@ -88,11 +117,11 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	defer cc.Close()
 
 	t := time.Now()
-	log.Debugf("%s <-> %s", conn.RemoteAddr(), dstAddr)
-	netpkg.Transport(conn, cc)
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), host)
+	netpkg.Transport(rw, cc)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
-	}).Debugf("%s >-< %s", conn.RemoteAddr(), dstAddr)
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), host)
 
 	return nil
 }
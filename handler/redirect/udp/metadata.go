@@ -2,10 +2,17 @@ package redirect
 
 import (
 	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
 )
 
-type metadata struct{}
+type metadata struct {
+	sniffing bool
+}
 
 func (h *redirectHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		sniffing = "sniffing"
+	)
+	h.md.sniffing = mdutil.GetBool(md, sniffing)
 	return
 }
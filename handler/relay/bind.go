@@ -79,7 +79,7 @@ func (h *relayHandler) bindTCP(ctx context.Context, conn net.Conn, network, addr
 	}
 
 	// Upgrade connection to multiplex session.
-	session, err := mux.ClientSession(conn)
+	session, err := mux.ClientSession(conn, h.md.muxCfg)
 	if err != nil {
 		log.Error(err)
 		return err
@@ -215,7 +215,7 @@ func (h *relayHandler) handleBindTunnel(ctx context.Context, conn net.Conn, netw
 	resp.WriteTo(conn)
 
 	// Upgrade connection to multiplex session.
-	session, err := mux.ClientSession(conn)
+	session, err := mux.ClientSession(conn, h.md.muxCfg)
 	if err != nil {
 		return
 	}
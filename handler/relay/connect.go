@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/relay"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 )
@@ -19,6 +20,7 @@ func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network
 		"dst": fmt.Sprintf("%s/%s", address, network),
 		"cmd": "connect",
 	})
+	xctx.SetTarget(ctx, address)
 
 	log.Debugf("%s >> %s/%s", conn.RemoteAddr(), address, network)
 
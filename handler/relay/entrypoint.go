@@ -21,8 +21,8 @@ import (
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/internal/net/proxyproto"
-	"github.com/wznpp1/gost_x/internal/util/forward"
 	"github.com/wznpp1/gost_x/internal/util/mux"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
 	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
@@ -175,10 +175,10 @@ func (h *tunnelHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 	var rw io.ReadWriter = conn
 	var host string
 	var protocol string
-	rw, host, protocol, _ = forward.Sniffing(ctx, conn)
+	rw, host, protocol, _ = sniffing.Sniff(ctx, conn)
 	h.options.Logger.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
 
-	if protocol == forward.ProtoHTTP {
+	if protocol == sniffing.ProtoHTTP {
 		h.handleHTTP(ctx, conn.RemoteAddr(), rw, log)
 		return nil
 	}
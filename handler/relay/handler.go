@@ -14,6 +14,7 @@ import (
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/core/service"
 	"github.com/go-gost/relay"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/registry"
 	xservice "github.com/wznpp1/gost_x/service"
@@ -201,6 +202,7 @@ func (h *relayHandler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 		resp.WriteTo(conn)
 		return ErrUnauthorized
 	}
+	xctx.SetUser(ctx, user)
 
 	network := "tcp"
 	if (req.Cmd & relay.FUDP) == relay.FUDP {
@@ -10,6 +10,7 @@ import (
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
 	xingress "github.com/wznpp1/gost_x/ingress"
+	"github.com/wznpp1/gost_x/internal/util/mux"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -22,6 +23,7 @@ type metadata struct {
 	entryPoint    string
 	ingress       ingress.Ingress
 	directTunnel  bool
+	muxCfg        *mux.Config
 }
 
 func (h *relayHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -45,6 +47,7 @@ func (h *relayHandler) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	h.md.hash = mdutil.GetString(md, hash)
+	h.md.muxCfg = mux.ParseConfig(md)
 
 	h.md.entryPoint = mdutil.GetString(md, entryPoint)
 	h.md.ingress = registry.IngressRegistry().Get(mdutil.GetString(md, "ingress"))
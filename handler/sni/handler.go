@@ -20,9 +20,11 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	dissector "github.com/go-gost/tls-dissector"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	xio "github.com/wznpp1/gost_x/internal/io"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -107,6 +109,8 @@ func (h *sniHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, raddr net
 		log.Trace(string(dump))
 	}
 
+	sniffing.Record(ctx, h.options.Service, req.Host, sniffing.ProtoHTTP)
+
 	host := req.Host
 	if _, _, err := net.SplitHostPort(host); err != nil {
 		host = net.JoinHostPort(host, "80")
@@ -173,6 +177,7 @@ func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr ne
 		log.Error(err)
 		return err
 	}
+	sniffing.Record(ctx, h.options.Service, host, sniffing.ProtoTLS)
 
 	if _, _, err := net.SplitHostPort(host); err != nil {
 		host = net.JoinHostPort(host, "443")
@@ -181,6 +186,7 @@ func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr ne
 	log = log.WithFields(map[string]any{
 		"dst": host,
 	})
+	xctx.SetTarget(ctx, host)
 	log.Debugf("%s >> %s", raddr, host)
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(host) {
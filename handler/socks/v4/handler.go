@@ -11,6 +11,7 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/gosocks4"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 	"github.com/wznpp1/gost_x/registry"
@@ -90,11 +91,14 @@ func (h *socks4Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 
 	conn.SetReadDeadline(time.Time{})
 
-	if h.options.Auther != nil &&
-		!h.options.Auther.Authenticate(string(req.Userid), "") {
-		resp := gosocks4.NewReply(gosocks4.RejectedUserid, nil)
-		log.Trace(resp)
-		return resp.Write(conn)
+	if h.options.Auther != nil {
+		if !h.options.Auther.Authenticate(string(req.Userid), "") {
+			resp := gosocks4.NewReply(gosocks4.RejectedUserid, nil)
+			log.Trace(resp)
+			return resp.Write(conn)
+		}
+		// the userid is only trusted when it is validated by the auther.
+		xctx.SetUser(ctx, string(req.Userid))
 	}
 
 	switch req.Cmd {
@@ -115,6 +119,7 @@ func (h *socks4Handler) handleConnect(ctx context.Context, conn net.Conn, req *g
 	log = log.WithFields(map[string]any{
 		"dst": addr,
 	})
+	xctx.SetTarget(ctx, addr)
 	log.Debugf("%s >> %s", conn.RemoteAddr(), addr)
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(addr) {
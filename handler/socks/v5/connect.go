@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/gosocks5"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 )
@@ -17,6 +18,7 @@ func (h *socks5Handler) handleConnect(ctx context.Context, conn net.Conn, networ
 		"dst": fmt.Sprintf("%s/%s", address, network),
 		"cmd": "connect",
 	})
+	xctx.SetTarget(ctx, address)
 	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
@@ -24,7 +24,7 @@ func init() {
 }
 
 type socks5Handler struct {
-	selector gosocks5.Selector
+	selector *serverSelector
 	router   *chain.Router
 	md       metadata
 	options  handler.Options
@@ -86,7 +86,10 @@ func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
 	}
 
-	conn = gosocks5.ServerConn(conn, h.selector)
+	// the selector is copied for each connection to record the authenticated user in ctx.
+	selector := *h.selector
+	selector.ctx = ctx
+	conn = gosocks5.ServerConn(conn, &selector)
 	req, err := gosocks5.ReadRequest(conn)
 	if err != nil {
 		log.Error(err)
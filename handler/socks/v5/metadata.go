@@ -6,6 +6,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
 )
 
 type metadata struct {
@@ -16,6 +17,7 @@ type metadata struct {
 	udpBufferSize     int
 	compatibilityMode bool
 	hash              string
+	muxCfg            *mux.Config
 }
 
 func (h *socks5Handler) parseMetadata(md mdata.Metadata) (err error) {
@@ -42,6 +44,7 @@ func (h *socks5Handler) parseMetadata(md mdata.Metadata) (err error) {
 
 	h.md.compatibilityMode = mdutil.GetBool(md, compatibilityMode)
 	h.md.hash = mdutil.GetString(md, hash)
+	h.md.muxCfg = mux.ParseConfig(md)
 
 	return nil
 }
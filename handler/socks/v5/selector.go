@@ -1,12 +1,14 @@
 package v5
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 
 	"github.com/go-gost/core/auth"
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/gosocks5"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	"github.com/wznpp1/gost_x/internal/util/socks"
 )
 
@@ -16,6 +18,7 @@ type serverSelector struct {
 	TLSConfig     *tls.Config
 	logger        logger.Logger
 	noTLS         bool
+	ctx           context.Context
 }
 
 func (selector *serverSelector) Methods() []uint8 {
@@ -75,6 +78,10 @@ func (s *serverSelector) OnSelected(method uint8, conn net.Conn) (net.Conn, erro
 			return nil, gosocks5.ErrAuthFailure
 		}
 
+		if s.Authenticator != nil && s.ctx != nil {
+			xctx.SetUser(s.ctx, req.Username)
+		}
+
 		resp := gosocks5.NewUserPassResponse(gosocks5.UserPassVer, gosocks5.Succeeded)
 		s.logger.Trace(resp)
 		if err := resp.Write(conn); err != nil {
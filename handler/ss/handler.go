@@ -12,9 +12,11 @@ import (
 	md "github.com/go-gost/core/metadata"
 	"github.com/go-gost/gosocks5"
 	"github.com/shadowsocks/go-shadowsocks2/core"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 	"github.com/wznpp1/gost_x/internal/util/ss"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -23,7 +25,9 @@ func init() {
 }
 
 type ssHandler struct {
-	cipher  core.Cipher
+	cipher core.Cipher
+	// users is the cipher of the legacy AEAD method shared by multiple users.
+	users   *ss.MultiUserCipher
 	router  *chain.Router
 	md      metadata
 	options handler.Options
@@ -47,9 +51,14 @@ func (h *ssHandler) Init(md md.Metadata) (err error) {
 	if h.options.Auth != nil {
 		method := h.options.Auth.Username()
 		password, _ := h.options.Auth.Password()
-		h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
-		if err != nil {
-			return
+		users := ss.Users(h.options.Auther, method)
+		if users != nil && ss.IsAEAD(method) {
+			h.users = ss.NewMultiUserCipher(method, password, h.md.key, users)
+		} else {
+			h.cipher, err = ss.ShadowServerCipher(method, password, h.md.key, users)
+			if err != nil {
+				return
+			}
 		}
 	}
 
@@ -81,14 +90,25 @@ func (h *ssHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.H
 		return nil
 	}
 
-	if h.cipher != nil {
-		conn = ss.ShadowConn(h.cipher.StreamConn(conn), nil)
-	}
-
 	if h.md.readTimeout > 0 {
 		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
 	}
 
+	var user string
+	var sc net.Conn
+	if h.users != nil {
+		var err error
+		if sc, user, err = h.users.StreamConn(conn); err != nil {
+			log.Error(err)
+			io.Copy(ioutil.Discard, conn)
+			return err
+		}
+		conn = ss.ShadowConn(sc, nil)
+	} else if h.cipher != nil {
+		sc = h.cipher.StreamConn(conn)
+		conn = ss.ShadowConn(sc, nil)
+	}
+
 	addr := &gosocks5.Addr{}
 	if _, err := addr.ReadFrom(conn); err != nil {
 		log.Error(err)
@@ -99,6 +119,19 @@ func (h *ssHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.H
 	log = log.WithFields(map[string]any{
 		"dst": addr.String(),
 	})
+	xctx.SetTarget(ctx, addr.String())
+
+	// the user identified by the multi-user cipher.
+	if u, ok := sc.(interface{ User() string }); ok {
+		user = u.User()
+	}
+	if user != "" {
+		log = log.WithFields(map[string]any{
+			"user": user,
+		})
+		xctx.SetUser(ctx, user)
+		conn = limiter.WrapUserConn(h.md.userLimiter, user, conn)
+	}
 
 	log.Debugf("%s >> %s", conn.RemoteAddr(), addr)
 
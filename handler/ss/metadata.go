@@ -3,14 +3,18 @@ package ss
 import (
 	"time"
 
+	"github.com/go-gost/core/limiter/traffic"
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/registry"
 )
 
 type metadata struct {
 	key         string
 	readTimeout time.Duration
 	hash        string
+	// userLimiter is the traffic limiter of which the limits are keyed by the users.
+	userLimiter traffic.TrafficLimiter
 }
 
 func (h *ssHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -18,11 +22,13 @@ func (h *ssHandler) parseMetadata(md mdata.Metadata) (err error) {
 		key         = "key"
 		readTimeout = "readTimeout"
 		hash        = "hash"
+		userLimiter = "userLimiter"
 	)
 
 	h.md.key = mdutil.GetString(md, key)
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
 	h.md.hash = mdutil.GetString(md, hash)
+	h.md.userLimiter = registry.TrafficLimiterRegistry().Get(mdutil.GetString(md, userLimiter))
 
 	return
 }
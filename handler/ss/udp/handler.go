@@ -12,8 +12,10 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	"github.com/shadowsocks/go-shadowsocks2/core"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	"github.com/wznpp1/gost_x/internal/util/relay"
 	"github.com/wznpp1/gost_x/internal/util/ss"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	"github.com/wznpp1/gost_x/registry"
 )
 
@@ -22,7 +24,9 @@ func init() {
 }
 
 type ssuHandler struct {
-	cipher  core.Cipher
+	cipher core.Cipher
+	// users is the cipher of the legacy AEAD method shared by multiple users.
+	users   *ss.MultiUserCipher
 	router  *chain.Router
 	md      metadata
 	options handler.Options
@@ -47,9 +51,14 @@ func (h *ssuHandler) Init(md md.Metadata) (err error) {
 	if h.options.Auth != nil {
 		method := h.options.Auth.Username()
 		password, _ := h.options.Auth.Password()
-		h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
-		if err != nil {
-			return
+		users := ss.Users(h.options.Auther, method)
+		if users != nil && ss.IsAEAD(method) {
+			h.users = ss.NewMultiUserCipher(method, password, h.md.key, users)
+		} else {
+			h.cipher, err = ss.ShadowServerCipher(method, password, h.md.key, users)
+			if err != nil {
+				return
+			}
 		}
 	}
 
@@ -81,20 +90,54 @@ func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return nil
 	}
 
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	var user string
 	pc, ok := conn.(net.PacketConn)
 	if ok {
-		if h.cipher != nil {
+		if h.users != nil {
+			var err error
+			if pc, user, err = h.users.PacketConn(pc); err != nil {
+				log.Error(err)
+				return err
+			}
+		} else if h.cipher != nil {
 			pc = h.cipher.PacketConn(pc)
 		}
 		// standard UDP relay.
 		pc = ss.UDPServerConn(pc, conn.RemoteAddr(), h.md.bufferSize)
 	} else {
-		if h.cipher != nil {
-			conn = ss.ShadowConn(h.cipher.StreamConn(conn), nil)
+		var sc net.Conn
+		if h.users != nil {
+			var err error
+			if sc, user, err = h.users.StreamConn(conn); err != nil {
+				log.Error(err)
+				return err
+			}
+		} else if h.cipher != nil {
+			sc = h.cipher.StreamConn(conn)
+		}
+		if sc != nil {
+			// the user identified by the multi-user cipher.
+			if u, ok := sc.(interface{ User() string }); ok {
+				user = u.User()
+			}
+			conn = ss.ShadowConn(sc, nil)
 		}
 		// UDP over TCP
 		pc = relay.UDPTunServerConn(conn)
 	}
+	conn.SetReadDeadline(time.Time{})
+
+	if user != "" {
+		log = log.WithFields(map[string]any{
+			"user": user,
+		})
+		xctx.SetUser(ctx, user)
+		pc = limiter.WrapUserPacketConn(h.md.userLimiter, user, pc)
+	}
 
 	// obtain a udp connection
 	c, err := h.router.Dial(ctx, "udp", "") // UDP association
@@ -4,14 +4,18 @@ import (
 	"math"
 	"time"
 
+	"github.com/go-gost/core/limiter/traffic"
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/registry"
 )
 
 type metadata struct {
 	key         string
 	readTimeout time.Duration
 	bufferSize  int
+	// userLimiter is the traffic limiter of which the limits are keyed by the users.
+	userLimiter traffic.TrafficLimiter
 }
 
 func (h *ssuHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -19,10 +23,12 @@ func (h *ssuHandler) parseMetadata(md mdata.Metadata) (err error) {
 		key         = "key"
 		readTimeout = "readTimeout"
 		bufferSize  = "bufferSize"
+		userLimiter = "userLimiter"
 	)
 
 	h.md.key = mdutil.GetString(md, key)
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	h.md.userLimiter = registry.TrafficLimiterRegistry().Get(mdutil.GetString(md, userLimiter))
 
 	if bs := mdutil.GetInt(md, bufferSize); bs > 0 {
 		h.md.bufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
@@ -13,6 +13,7 @@ import (
 	"github.com/go-gost/core/handler"
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	netpkg "github.com/wznpp1/gost_x/internal/net"
 	sshd_util "github.com/wznpp1/gost_x/internal/util/sshd"
 	"github.com/wznpp1/gost_x/registry"
@@ -70,7 +71,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 		return nil
 	}
 
-	switch cc := conn.(type) {
+	switch cc := netpkg.UnwrapConn(conn).(type) {
 	case *sshd_util.DirectForwardConn:
 		return h.handleDirectForward(ctx, cc, log)
 	case *sshd_util.RemoteForwardConn:
@@ -89,6 +90,7 @@ func (h *forwardHandler) handleDirectForward(ctx context.Context, conn *sshd_uti
 		"dst": fmt.Sprintf("%s/%s", targetAddr, "tcp"),
 		"cmd": "connect",
 	})
+	xctx.SetTarget(ctx, targetAddr)
 
 	log.Debugf("%s >> %s", conn.RemoteAddr(), targetAddr)
 
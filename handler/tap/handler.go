@@ -18,6 +18,7 @@ import (
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	"github.com/shadowsocks/go-shadowsocks2/shadowaead"
 	"github.com/songgao/water/waterutil"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
 	"github.com/wznpp1/gost_x/internal/util/ss"
 	tap_util "github.com/wznpp1/gost_x/internal/util/tap"
 	"github.com/wznpp1/gost_x/registry"
@@ -57,7 +58,13 @@ func (h *tapHandler) Init(md md.Metadata) (err error) {
 	if h.options.Auth != nil {
 		method := h.options.Auth.Username()
 		password, _ := h.options.Auth.Password()
-		h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
+		if h.hop != nil {
+			h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
+		} else {
+			// server side, the clients may be identified by the multi-user cipher.
+			h.cipher, err = ss.ShadowServerCipher(method, password, h.md.key,
+				ss.Users(h.options.Auther, method))
+		}
 		if err != nil {
 			return
 		}
@@ -157,6 +164,28 @@ func (h *tapHandler) handleLoop(ctx context.Context, conn net.Conn, addr net.Add
 			}
 			defer pc.Close()
 
+			if h.md.privateKey != nil {
+				config := &noise_util.Config{
+					PrivateKey: h.md.privateKey,
+					Logger:     log,
+				}
+				if addr != nil {
+					if h.md.peerKey == nil {
+						return errors.New("tap: peerKey is required in the secure mode")
+					}
+					config.PeerKey = h.md.peerKey
+				} else {
+					if len(h.md.peers) == 0 && !h.md.anyPeer {
+						return errors.New("tap: peers or anyPeer is required in the secure mode")
+					}
+					config.AllowedKeys = h.md.peers
+					config.AllowAnyPeer = h.md.anyPeer
+				}
+				if pc, err = noise_util.PacketConn(pc, config); err != nil {
+					return err
+				}
+			}
+
 			return h.transport(conn, pc, addr, config, log)
 		}()
 		if err != nil {
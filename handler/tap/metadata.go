@@ -1,19 +1,34 @@
 package tap
 
 import (
+	"fmt"
+
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
 )
 
 type metadata struct {
 	key        string
 	bufferSize int
+
+	// the secure mode is enabled by the private key.
+	privateKey []byte
+	peerKey    []byte
+	peers      [][]byte
+	// anyPeer accepts the clients of any key.
+	anyPeer bool
 }
 
 func (h *tapHandler) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		key        = "key"
 		bufferSize = "bufferSize"
+
+		privateKey = "privateKey"
+		peerKey    = "peerKey"
+		peers      = "peers"
+		anyPeer    = "anyPeer"
 	)
 
 	h.md.key = mdutil.GetString(md, key)
@@ -21,5 +36,20 @@ func (h *tapHandler) parseMetadata(md mdata.Metadata) (err error) {
 	if h.md.bufferSize <= 0 {
 		h.md.bufferSize = 4096
 	}
+
+	if v := mdutil.GetString(md, privateKey); v != "" {
+		if h.md.privateKey, err = noise_util.ParseKey(v); err != nil {
+			return fmt.Errorf("tap: privateKey: %w", err)
+		}
+	}
+	if v := mdutil.GetString(md, peerKey); v != "" {
+		if h.md.peerKey, err = noise_util.ParseKey(v); err != nil {
+			return fmt.Errorf("tap: peerKey: %w", err)
+		}
+	}
+	if h.md.peers, err = noise_util.ParseKeys(mdutil.GetStrings(md, peers)); err != nil {
+		return fmt.Errorf("tap: peers: %w", err)
+	}
+	h.md.anyPeer = mdutil.GetBool(md, anyPeer)
 	return
 }
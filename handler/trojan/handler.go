@@ -0,0 +1,261 @@
+package trojan
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/internal/util/trojan"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+const (
+	usersTTL = 5 * time.Second
+)
+
+var (
+	ErrAuth = errors.New("trojan: authentication failed")
+)
+
+func init() {
+	registry.HandlerRegistry().Register("trojan", NewHandler)
+}
+
+type trojanHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+
+	// keys maps the keys of the passwords to the users.
+	keys    map[string]trojanUser
+	updated time.Time
+	mu      sync.Mutex
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &trojanHandler{
+		options: options,
+	}
+}
+
+func (h *trojanHandler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	return
+}
+
+func (h *trojanHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		return nil
+	}
+
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	br := bufio.NewReader(conn)
+	key, err := trojan.ReadKey(br)
+	if err != nil {
+		log.Error(err)
+		return h.handleFallback(conn, br, log)
+	}
+
+	user, ok := h.authenticate(key)
+	if !ok {
+		log.Error(ErrAuth)
+		return h.handleFallback(conn, br, log)
+	}
+	if user != "" {
+		log = log.WithFields(map[string]any{"user": user})
+		xctx.SetUser(ctx, user)
+	}
+	br.Discard(trojan.KeyLen + 2)
+
+	req := trojan.Request{}
+	if _, err := req.ReadFrom(br); err != nil {
+		log.Error(err)
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	conn = netpkg.NewBufferReaderConn(conn, br)
+
+	switch req.Cmd {
+	case trojan.CmdConnect:
+		return h.handleConnect(ctx, conn, req.Addr.String(), log)
+	case trojan.CmdUDPAssociate:
+		return h.handleUDP(ctx, conn, log)
+	default:
+		err := trojan.ErrBadRequest
+		log.Error(err)
+		return err
+	}
+}
+
+func (h *trojanHandler) handleConnect(ctx context.Context, conn net.Conn, address string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"dst": address,
+		"cmd": "connect",
+	})
+	xctx.SetTarget(ctx, address)
+
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Debug("bypass: ", address)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = sx.ContextWithHash(ctx, &sx.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), address)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
+
+// handleFallback relays the unauthenticated connection to the fallback backend,
+// so that the server looks like an ordinary web server to the active probers.
+func (h *trojanHandler) handleFallback(conn net.Conn, br *bufio.Reader, log logger.Logger) error {
+	if h.md.fallback == "" {
+		io.Copy(ioutil.Discard, br)
+		return ErrAuth
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	cc, err := net.Dial("tcp", h.md.fallback)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	log.Debugf("%s <-> fallback %s", conn.RemoteAddr(), h.md.fallback)
+	netpkg.Transport(netpkg.NewBufferReaderConn(conn, br), cc)
+
+	return nil
+}
+
+type trojanUser struct {
+	name     string
+	password string
+	// auther reports whether the user is listed by the auther.
+	auther bool
+}
+
+// authenticate returns the user of the key.
+// The key is checked against the password of the handler auth and the users of the auther,
+// the user listed by the auther is authenticated by the auther with the password.
+// The auther which can not list its users checks the key as the password of an empty user.
+func (h *trojanHandler) authenticate(key []byte) (string, bool) {
+	keys := h.userKeys()
+
+	if u, ok := keys[string(key)]; ok {
+		if u.auther {
+			return u.name, h.options.Auther.Authenticate(u.name, u.password)
+		}
+		return u.name, true
+	}
+
+	if h.options.Auther != nil {
+		if _, ok := h.options.Auther.(xauth.Lister); !ok {
+			return "", h.options.Auther.Authenticate("", string(key))
+		}
+	}
+
+	return "", len(keys) == 0
+}
+
+func (h *trojanHandler) userKeys() map[string]trojanUser {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.keys == nil || time.Since(h.updated) > usersTTL {
+		keys := make(map[string]trojanUser)
+		if lister, ok := h.options.Auther.(xauth.Lister); ok {
+			for user, password := range lister.Users() {
+				keys[string(trojan.Key(password))] = trojanUser{
+					name:     user,
+					password: password,
+					auther:   true,
+				}
+			}
+		}
+		if h.options.Auth != nil {
+			password, _ := h.options.Auth.Password()
+			keys[string(trojan.Key(password))] = trojanUser{
+				name:     h.options.Auth.Username(),
+				password: password,
+			}
+		}
+		h.keys = keys
+		h.updated = time.Now()
+	}
+	return h.keys
+}
+
+func (h *trojanHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
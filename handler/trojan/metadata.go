@@ -0,0 +1,51 @@
+package trojan
+
+import (
+	"math"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+const (
+	// defaultReadTimeout bounds the handshake, so the clients which never send the key do not hold the connections.
+	defaultReadTimeout = 15 * time.Second
+)
+
+type metadata struct {
+	readTimeout   time.Duration
+	fallback      string
+	enableUDP     bool
+	udpBufferSize int
+	hash          string
+}
+
+func (h *trojanHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		readTimeout   = "readTimeout"
+		fallback      = "fallback"
+		enableUDP     = "udp"
+		udpBufferSize = "udpBufferSize"
+		hash          = "hash"
+	)
+
+	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	if h.md.readTimeout <= 0 {
+		h.md.readTimeout = defaultReadTimeout
+	}
+	h.md.fallback = mdutil.GetString(md, fallback)
+
+	h.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		h.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+	if bs := mdutil.GetInt(md, udpBufferSize); bs > 0 {
+		h.md.udpBufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
+	} else {
+		h.md.udpBufferSize = 4096
+	}
+	h.md.hash = mdutil.GetString(md, hash)
+
+	return
+}
@@ -0,0 +1,120 @@
+package trojan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+	"github.com/wznpp1/gost_x/internal/util/trojan"
+)
+
+func (h *trojanHandler) handleUDP(ctx context.Context, conn net.Conn, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"cmd": "udp",
+	})
+
+	if !h.md.enableUDP {
+		err := errors.New("trojan: UDP relay is disabled")
+		log.Error(err)
+		return err
+	}
+
+	// obtain a udp connection
+	c, err := h.router.Dial(ctx, "udp", "") // UDP association
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer c.Close()
+
+	cc, ok := c.(net.PacketConn)
+	if !ok {
+		err := errors.New("trojan: wrong connection type")
+		log.Error(err)
+		return err
+	}
+
+	pc := trojan.UDPServerConn(conn)
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), cc.LocalAddr())
+	h.relayPacket(pc, cc, log)
+	log.WithFields(map[string]any{"duration": time.Since(t)}).
+		Debugf("%s >-< %s", conn.RemoteAddr(), cc.LocalAddr())
+
+	return nil
+}
+
+func (h *trojanHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.Logger) (err error) {
+	bufSize := h.md.udpBufferSize
+	errc := make(chan error, 2)
+
+	go func() {
+		for {
+			err := func() error {
+				b := bufpool.Get(bufSize)
+				defer bufpool.Put(b)
+
+				n, addr, err := pc1.ReadFrom(*b)
+				if err != nil {
+					return err
+				}
+
+				if h.options.Bypass != nil && h.options.Bypass.Contains(addr.String()) {
+					log.Warn("bypass: ", addr)
+					return nil
+				}
+
+				if _, err = pc2.WriteTo((*b)[:n], addr); err != nil {
+					return err
+				}
+
+				log.Tracef("%s >>> %s data: %d",
+					pc2.LocalAddr(), addr, n)
+				return nil
+			}()
+
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			err := func() error {
+				b := bufpool.Get(bufSize)
+				defer bufpool.Put(b)
+
+				n, raddr, err := pc2.ReadFrom(*b)
+				if err != nil {
+					return err
+				}
+
+				if h.options.Bypass != nil && h.options.Bypass.Contains(raddr.String()) {
+					log.Warn("bypass: ", raddr)
+					return nil
+				}
+
+				if _, err = pc1.WriteTo((*b)[:n], raddr); err != nil {
+					return err
+				}
+
+				log.Tracef("%s <<< %s data: %d",
+					pc2.LocalAddr(), raddr, n)
+				return nil
+			}()
+
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	return <-errc
+}
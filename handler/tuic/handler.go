@@ -0,0 +1,183 @@
+package tuic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/net/udp"
+	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/internal/util/tuic"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.HandlerRegistry().Register("tuic", NewHandler)
+}
+
+type tuicHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &tuicHandler{
+		options: options,
+	}
+}
+
+func (h *tuicHandler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	return nil
+}
+
+// Handle handles the Connect streams and the UDP associations accepted by the tuic listener.
+func (h *tuicHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		return nil
+	}
+
+	switch cc := netpkg.UnwrapConn(conn).(type) {
+	case *tuic.TCPConn:
+		if user := cc.User(); user != "" {
+			log = log.WithFields(map[string]any{"user": user})
+			xctx.SetUser(ctx, user)
+		}
+		return h.handleTCP(ctx, conn, cc.DstAddr(), log)
+	case *tuic.UDPConn:
+		if user := cc.User(); user != "" {
+			log = log.WithFields(map[string]any{"user": user})
+			xctx.SetUser(ctx, user)
+		}
+		return h.handleUDP(ctx, cc, log)
+	default:
+		err := tuic.ErrInvalidConnection
+		log.Error(err)
+		return err
+	}
+}
+
+func (h *tuicHandler) handleTCP(ctx context.Context, conn net.Conn, address string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"dst": fmt.Sprintf("%s/%s", address, "tcp"),
+		"cmd": "connect",
+	})
+	xctx.SetTarget(ctx, address)
+
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Debug("bypass: ", address)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = sx.ContextWithHash(ctx, &sx.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), address)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
+
+func (h *tuicHandler) handleUDP(ctx context.Context, pc net.PacketConn, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"cmd": "udp",
+	})
+
+	if !h.md.enableUDP {
+		err := errors.New("tuic: UDP relay is disabled")
+		log.Error(err)
+		return err
+	}
+
+	// obtain a udp connection
+	c, err := h.router.Dial(ctx, "udp", "") // UDP association
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer c.Close()
+
+	cc, ok := c.(net.PacketConn)
+	if !ok {
+		err := errors.New("tuic: wrong connection type")
+		log.Error(err)
+		return err
+	}
+
+	r := udp.NewRelay(pc, cc).
+		WithBypass(h.options.Bypass).
+		WithLogger(log)
+	r.SetBufferSize(h.md.udpBufferSize)
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", pc.LocalAddr(), cc.LocalAddr())
+	r.Run()
+	log.WithFields(map[string]any{"duration": time.Since(t)}).
+		Debugf("%s >-< %s", pc.LocalAddr(), cc.LocalAddr())
+
+	return nil
+}
+
+func (h *tuicHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
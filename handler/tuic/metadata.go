@@ -0,0 +1,35 @@
+package tuic
+
+import (
+	"math"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+type metadata struct {
+	enableUDP     bool
+	udpBufferSize int
+	hash          string
+}
+
+func (h *tuicHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		enableUDP     = "udp"
+		udpBufferSize = "udpBufferSize"
+		hash          = "hash"
+	)
+
+	h.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		h.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+	if bs := mdutil.GetInt(md, udpBufferSize); bs > 0 {
+		h.md.udpBufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
+	} else {
+		h.md.udpBufferSize = 4096
+	}
+	h.md.hash = mdutil.GetString(md, hash)
+
+	return
+}
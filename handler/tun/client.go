@@ -3,6 +3,7 @@ package tun
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-gost/core/common/bufpool"
 	"github.com/go-gost/core/logger"
 	"github.com/songgao/water/waterutil"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
 	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
@@ -24,13 +26,26 @@ var (
 	magicHeader = []byte("GOST")
 )
 
-func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr string, config *tun_util.Config, log logger.Logger) error {
+func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr string, config *tun_util.Config, configurator tun_util.Configurator, log logger.Logger) error {
 	var ips []net.IP
 	for _, net := range config.Net {
 		ips = append(ips, net.IP)
 	}
+
+	// the address is assigned by the server if the net is not specified.
+	var lc *leaseClient
 	if len(ips) == 0 {
-		return ErrInvalidNet
+		if configurator == nil {
+			return ErrInvalidNet
+		}
+		lc = &leaseClient{
+			h:            h,
+			configurator: configurator,
+			log:          log,
+		}
+	}
+	if h.md.privateKey != nil && h.md.peerKey == nil {
+		return errors.New("tun: peerKey is required in the secure mode")
 	}
 
 	for {
@@ -41,12 +56,30 @@ func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr stri
 			}
 			defer cc.Close()
 
+			if h.md.privateKey != nil {
+				cc, err = noise_util.Conn(cc, &noise_util.Config{
+					PrivateKey: h.md.privateKey,
+					PeerKey:    h.md.peerKey,
+					Logger:     log,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
 			ctx, cancel := context.WithCancel(ctx)
 			defer cancel()
 
-			go h.keepAlive(ctx, cc, ips)
+			if lc != nil {
+				if err := lc.request(cc); err != nil {
+					return err
+				}
+				go lc.renew(ctx, cc)
+			} else {
+				go h.keepAlive(ctx, cc, ips)
+			}
 
-			return h.transportClient(conn, cc, log)
+			return h.transportClient(conn, cc, lc, log)
 		}()
 		if err == ErrTun {
 			return err
@@ -94,7 +127,7 @@ func (h *tunHandler) keepAlive(ctx context.Context, conn net.Conn, ips []net.IP)
 	}
 }
 
-func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, log logger.Logger) error {
+func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, lc *leaseClient, log logger.Logger) error {
 	errc := make(chan error, 1)
 
 	go func() {
@@ -166,6 +199,17 @@ func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, log logge
 					return nil
 				}
 
+				if lc != nil && n > len(leaseMagicHeader) && bytes.Equal((*b)[:4], leaseMagicHeader) {
+					if err := lc.update((*b)[4:n]); err != nil {
+						log.Warnf("lease: %v", err)
+					}
+
+					if h.md.keepAlivePeriod > 0 {
+						conn.SetReadDeadline(time.Now().Add(h.md.keepAlivePeriod * 3))
+					}
+					return nil
+				}
+
 				if waterutil.IsIPv4((*b)[:n]) {
 					header, err := ipv4.ParseHeader((*b)[:n])
 					if err != nil {
@@ -0,0 +1,229 @@
+package tun
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+	"github.com/miekg/dns"
+)
+
+const (
+	// fakeIPTTL is the TTL of the fake IP answers, the client asks again soon
+	// so the mapping of the name is refreshed before its address is reused.
+	fakeIPTTL = 1
+	// dnsTTL is the TTL of the answers resolved by the hosts and the resolver.
+	dnsTTL = 60
+	// dnsTimeout is the timeout of the queries relayed to the DNS server.
+	dnsTimeout = 5 * time.Second
+)
+
+// fakeIPPool maps the domain names to the addresses of the prefix,
+// the addresses are assigned in order and reused from the oldest one when the prefix runs out.
+type fakeIPPool struct {
+	prefix netip.Prefix
+	next   netip.Addr
+	addrs  map[string]netip.Addr
+	names  map[netip.Addr]string
+	mu     sync.Mutex
+}
+
+func newFakeIPPool(prefix netip.Prefix) *fakeIPPool {
+	return &fakeIPPool{
+		prefix: prefix,
+		next:   prefix.Addr().Next(),
+		addrs:  make(map[string]netip.Addr),
+		names:  make(map[netip.Addr]string),
+	}
+}
+
+// Alloc returns the fake IP of the name.
+func (p *fakeIPPool) Alloc(name string) netip.Addr {
+	name = strings.ToLower(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if addr, ok := p.addrs[name]; ok {
+		return addr
+	}
+
+	addr := p.next
+	if p.next = addr.Next(); !p.prefix.Contains(p.next) {
+		p.next = p.prefix.Addr().Next()
+	}
+	if old, ok := p.names[addr]; ok {
+		delete(p.addrs, old)
+	}
+	p.addrs[name] = addr
+	p.names[addr] = name
+
+	return addr
+}
+
+// Lookup returns the name of the fake IP.
+func (p *fakeIPPool) Lookup(addr netip.Addr) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name, ok := p.names[addr]
+	return name, ok
+}
+
+// Contains reports whether addr is in the prefix of the pool.
+func (p *fakeIPPool) Contains(addr netip.Addr) bool {
+	return p.prefix.Contains(addr)
+}
+
+// handleStackDNS answers the DNS queries of the UDP flow to dst,
+// the A and AAAA queries are answered with the fake IPs or by the hosts and the resolver of the router,
+// the other queries are relayed to dst through the router.
+func (h *tunHandler) handleStackDNS(ctx context.Context, router *chain.Router, conn net.Conn, dst string, log logger.Logger) {
+	var cc net.Conn
+	defer func() {
+		if cc != nil {
+			cc.Close()
+		}
+	}()
+
+	b := bufpool.Get(h.md.bufferSize)
+	defer bufpool.Put(b)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(h.md.udpTimeout))
+		n, err := conn.Read(*b)
+		if err != nil {
+			return
+		}
+
+		mq := &dns.Msg{}
+		if err := mq.Unpack((*b)[:n]); err != nil {
+			log.Debugf("dns: %v", err)
+			continue
+		}
+
+		if mr := h.answerDNS(ctx, router, mq, log); mr != nil {
+			reply, err := mr.Pack()
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if _, err := conn.Write(reply); err != nil {
+				return
+			}
+			continue
+		}
+
+		if cc == nil {
+			if cc, err = router.Dial(ctx, "udp", dst); err != nil {
+				log.Error(err)
+				return
+			}
+		}
+		reply, err := exchangeDNS(cc, mq.Id, (*b)[:n])
+		if err != nil {
+			log.Error(err)
+			cc.Close()
+			cc = nil
+			continue
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// answerDNS answers the A and AAAA query, nil is returned if the query should be relayed to the DNS server.
+func (h *tunHandler) answerDNS(ctx context.Context, router *chain.Router, mq *dns.Msg, log logger.Logger) *dns.Msg {
+	if len(mq.Question) != 1 {
+		return nil
+	}
+	q := mq.Question[0]
+	if q.Qclass != dns.ClassINET || (q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA) {
+		return nil
+	}
+	name := strings.TrimSuffix(q.Name, ".")
+	network := "ip4"
+	if q.Qtype == dns.TypeAAAA {
+		network = "ip6"
+	}
+
+	mr := &dns.Msg{}
+	mr.SetReply(mq)
+	mr.RecursionAvailable = true
+
+	if h.fakeIPs != nil {
+		// the query of the other address family is answered without address.
+		if h.fakeIPs.prefix.Addr().Is4() == (q.Qtype == dns.TypeA) {
+			addr := h.fakeIPs.Alloc(name)
+			log.Debugf("dns: %s -> %s (fake)", name, addr)
+			mr.Answer = append(mr.Answer, answerRR(q, addr.AsSlice(), fakeIPTTL))
+		}
+		return mr
+	}
+
+	var ips []net.IP
+	opts := router.Options()
+	if opts.HostMapper != nil {
+		ips, _ = opts.HostMapper.Lookup(network, name)
+	}
+	if len(ips) == 0 {
+		if opts.Resolver == nil {
+			return nil
+		}
+		var err error
+		if ips, err = opts.Resolver.Resolve(ctx, network, name); err != nil {
+			log.Errorf("dns: %s: %v", name, err)
+			mr.Rcode = dns.RcodeServerFailure
+			return mr
+		}
+	}
+	log.Debugf("dns: %s -> %v", name, ips)
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (q.Qtype == dns.TypeA) {
+			mr.Answer = append(mr.Answer, answerRR(q, ip, dnsTTL))
+		}
+	}
+	return mr
+}
+
+func answerRR(q dns.Question, ip net.IP, ttl uint32) dns.RR {
+	hdr := dns.RR_Header{
+		Name:   q.Name,
+		Rrtype: q.Qtype,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+	if q.Qtype == dns.TypeA {
+		return &dns.A{Hdr: hdr, A: ip.To4()}
+	}
+	return &dns.AAAA{Hdr: hdr, AAAA: ip.To16()}
+}
+
+// exchangeDNS sends the query to the DNS server and reads the reply of the id.
+func exchangeDNS(conn net.Conn, id uint16, query []byte) ([]byte, error) {
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(dnsTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	b := make([]byte, dns.MaxMsgSize)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		// the late replies of the previous queries are discarded.
+		if n >= 2 && uint16(b[0])<<8|uint16(b[1]) == id {
+			return b[:n], nil
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/go-gost/core/handler"
 	md "github.com/go-gost/core/metadata"
 	"github.com/songgao/water/waterutil"
+	"github.com/wznpp1/gost_x/internal/util/ipam"
 	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
 	"github.com/wznpp1/gost_x/registry"
 )
@@ -29,6 +30,8 @@ type tunHandler struct {
 	hop     chain.Hop
 	routes  sync.Map
 	router  *chain.Router
+	pool    *ipam.Pool
+	fakeIPs *fakeIPPool
 	md      metadata
 	options handler.Options
 }
@@ -54,9 +57,43 @@ func (h *tunHandler) Init(md md.Metadata) (err error) {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	if h.md.fakeIP.IsValid() {
+		h.fakeIPs = newFakeIPPool(h.md.fakeIP)
+	}
+
+	if h.md.pool.IsValid() {
+		// the client IDs are not authenticated without the auther, any client could claim all the addresses,
+		// the peers of the secure mode are authenticated by their keys.
+		if h.options.Auther == nil && (h.md.privateKey == nil || h.md.anyPeer || len(h.md.peers) == 0) {
+			return errors.New("tun: pool requires the auther or the peers of the secure mode")
+		}
+
+		h.pool, err = ipam.NewPool(h.md.pool,
+			ipam.LeaseTimeOption(h.md.leaseTime),
+			ipam.FileOption(h.md.leaseFile),
+			ipam.OnReleaseOption(func(ip net.IP) {
+				h.routes.Delete(ipToTunRouteKey(ip))
+			}),
+			ipam.LoggerOption(h.options.Logger),
+		)
+		if err != nil {
+			return fmt.Errorf("tun: pool: %w", err)
+		}
+		ipam.Register(h.options.Service, h.pool)
+	}
+
 	return
 }
 
+// Close implements io.Closer interface.
+func (h *tunHandler) Close() error {
+	if h.pool != nil {
+		ipam.Unregister(h.options.Service, h.pool)
+		return h.pool.Close()
+	}
+	return nil
+}
+
 // Forward implements handler.Forwarder.
 func (h *tunHandler) Forward(hop chain.Hop) {
 	h.hop = hop
@@ -74,6 +111,7 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return err
 	}
 	config := v.Metadata().Get("config").(*tun_util.Config)
+	configurator, _ := v.Metadata().Get("configurator").(tun_util.Configurator)
 
 	start := time.Now()
 	log = log.WithFields(map[string]any{
@@ -88,6 +126,10 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
 	}()
 
+	if h.md.mode == ModeStack {
+		return h.handleStack(ctx, conn, config, log)
+	}
+
 	var target *chain.Node
 	if h.hop != nil {
 		target = h.hop.Select(ctx)
@@ -98,7 +140,7 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		})
 		log.Debugf("%s >> %s", conn.RemoteAddr(), target.Addr)
 
-		if err := h.handleClient(ctx, conn, target.Addr, config, log); err != nil {
+		if err := h.handleClient(ctx, conn, target.Addr, config, configurator, log); err != nil {
 			log.Error(err)
 		}
 		return nil
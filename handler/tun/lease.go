@@ -0,0 +1,234 @@
+package tun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
+	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
+)
+
+const (
+	// 4-byte magic header followed by 16-byte key, the client ID is appended.
+	leaseHeaderLength = 20
+
+	leaseRequestTimeout = 5 * time.Second
+	leaseRequestRetries = 3
+	minLeaseRenewPeriod = time.Second
+)
+
+var (
+	leaseMagicHeader = []byte("GOSL")
+
+	ErrLeaseTimeout = errors.New("tun: lease request timeout")
+)
+
+// leaseInfo is the reply to the lease request, it is encoded in JSON after the magic header.
+type leaseInfo struct {
+	Net    string   `json:"net"`
+	Routes []string `json:"routes,omitempty"`
+	DNS    []string `json:"dns,omitempty"`
+	// TTL is the remaining seconds of the lease.
+	TTL int `json:"ttl"`
+}
+
+// handleLease assigns the address of the pool to the client, the lease request is also the keepalive of the client.
+func (h *tunHandler) handleLease(conn net.PacketConn, addr net.Addr, b []byte, log logger.Logger) {
+	if h.pool == nil {
+		log.Debugf("lease request from %v: no address pool, discarded", addr)
+		return
+	}
+
+	key := bytes.TrimRight(b[4:leaseHeaderLength], "\x00")
+	client := string(b[leaseHeaderLength:])
+	if addr, ok := addr.(*noise_util.PeerAddr); ok {
+		// the lease is bound to the static public key of the peer in the secure mode.
+		key = []byte(noise_util.EncodeKey(addr.PublicKey()))
+		client = string(key)
+	}
+	if client == "" {
+		log.Debugf("lease request from %v: empty client ID, discarded", addr)
+		return
+	}
+
+	if auther := h.options.Auther; auther != nil &&
+		!auther.Authenticate(string(b[leaseHeaderLength:]), string(key)) {
+		log.Debugf("lease request from %v => %s, auth FAILED", addr, client)
+		return
+	}
+
+	lease, err := h.pool.Allocate(client)
+	if err != nil {
+		log.Warnf("lease request from %v => %s: %v", addr, client, err)
+		return
+	}
+
+	info := leaseInfo{
+		Net: (&net.IPNet{
+			IP:   lease.IP,
+			Mask: net.CIDRMask(h.pool.Prefix().Bits(), len(lease.IP)*8),
+		}).String(),
+		TTL: int(time.Until(lease.Expire).Seconds()),
+	}
+	for _, route := range h.md.pushRoutes {
+		info.Routes = append(info.Routes, route.String())
+	}
+	for _, ip := range h.md.pushDNS {
+		info.DNS = append(info.DNS, ip.String())
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if _, err := conn.WriteTo(append(append([]byte{}, leaseMagicHeader...), data...), addr); err != nil {
+		log.Warnf("lease to %v: %v", addr, err)
+		return
+	}
+
+	log.Debugf("lease %s => %s (%v)", info.Net, client, addr)
+	h.updateRoute(lease.IP, addr, log)
+}
+
+// leaseClient requests the address from the server and applies it to the TUN device.
+type leaseClient struct {
+	h            *tunHandler
+	configurator tun_util.Configurator
+	log          logger.Logger
+
+	mu  sync.Mutex
+	net string
+	ttl time.Duration
+}
+
+func (c *leaseClient) requestData() []byte {
+	b := make([]byte, leaseHeaderLength, leaseHeaderLength+len(c.h.md.clientID))
+	copy(b[:4], leaseMagicHeader)
+	copy(b[4:leaseHeaderLength], []byte(c.h.md.passphrase))
+	return append(b, c.h.md.clientID...)
+}
+
+// request obtains the lease before the traffic is relayed,
+// the packets received before the lease are discarded.
+func (c *leaseClient) request(conn net.Conn) error {
+	data := c.requestData()
+
+	b := bufpool.Get(c.h.md.bufferSize)
+	defer bufpool.Put(b)
+
+	for i := 0; i < leaseRequestRetries; i++ {
+		if _, err := conn.Write(data); err != nil {
+			return err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(leaseRequestTimeout))
+		for {
+			n, err := conn.Read(*b)
+			if err != nil {
+				if e, ok := err.(net.Error); ok && e.Timeout() {
+					break
+				}
+				return err
+			}
+			if n > len(leaseMagicHeader) && bytes.Equal((*b)[:4], leaseMagicHeader) {
+				conn.SetReadDeadline(time.Time{})
+				return c.update((*b)[4:n])
+			}
+		}
+	}
+
+	return ErrLeaseTimeout
+}
+
+// update applies the lease to the TUN device if the assigned address is changed.
+func (c *leaseClient) update(b []byte) error {
+	var info leaseInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return err
+	}
+
+	ip, ipNet, err := net.ParseCIDR(info.Net)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = time.Duration(info.TTL) * time.Second
+	if c.net == info.Net {
+		return nil
+	}
+
+	var routes []tun_util.Route
+	for _, s := range info.Routes {
+		_, route, err := net.ParseCIDR(s)
+		if err != nil {
+			c.log.Warnf("lease: invalid route %s", s)
+			continue
+		}
+		routes = append(routes, tun_util.Route{Net: *route})
+	}
+	var dns []net.IP
+	for _, s := range info.DNS {
+		if ip := net.ParseIP(s); ip != nil {
+			dns = append(dns, ip)
+		}
+	}
+
+	if err := c.configurator.Configure([]net.IPNet{{IP: ip, Mask: ipNet.Mask}}, routes, dns); err != nil {
+		return err
+	}
+	c.log.Infof("lease: net %s, routes %v, dns %v, ttl %v", info.Net, info.Routes, info.DNS, c.ttl)
+	c.net = info.Net
+
+	return nil
+}
+
+// renewPeriod is the keepalive period, or half of the lease time if the keepalive is disabled.
+func (c *leaseClient) renewPeriod() time.Duration {
+	if c.h.md.keepAlivePeriod > 0 {
+		return c.h.md.keepAlivePeriod
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if d := c.ttl / 2; d > minLeaseRenewPeriod {
+		return d
+	}
+	return minLeaseRenewPeriod
+}
+
+// renew sends the lease request periodically as the keepalive.
+func (c *leaseClient) renew(ctx context.Context, conn net.Conn) {
+	data := c.requestData()
+
+	if c.h.md.keepAlivePeriod > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.h.md.keepAlivePeriod * 3))
+	}
+
+	timer := time.NewTimer(c.renewPeriod())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if _, err := conn.Write(data); err != nil {
+				return
+			}
+			c.log.Debugf("lease renewal sended")
+			timer.Reset(c.renewPeriod())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,21 +1,57 @@
 package tun
 
 import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
 	"time"
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
 )
 
 const (
 	defaultKeepAlivePeriod = 10 * time.Second
 	defaultBufferSize      = 4096
+	defaultUDPTimeout      = 60 * time.Second
+	// defaultSniffingTimeout is the time to wait for the first data of the client to sniff the host.
+	defaultSniffingTimeout = 300 * time.Millisecond
+)
+
+const (
+	// ModeStack terminates the TCP and UDP traffic of the TUN device by the userspace TCP/IP stack.
+	ModeStack = "stack"
 )
 
 type metadata struct {
 	bufferSize      int
 	keepAlivePeriod time.Duration
 	passphrase      string
+	mode            string
+	udpTimeout      time.Duration
+
+	// the options of the stack mode.
+	dnsHijack       bool
+	fakeIP          netip.Prefix
+	sniffing        bool
+	sniffingTimeout time.Duration
+
+	// the secure mode is enabled by the private key.
+	privateKey []byte
+	peerKey    []byte
+	peers      [][]byte
+	// anyPeer accepts the clients of any key.
+	anyPeer bool
+
+	// the server assigns the addresses of the pool to the clients without the net.
+	pool       netip.Prefix
+	leaseTime  time.Duration
+	leaseFile  string
+	pushRoutes []net.IPNet
+	pushDNS    []net.IP
+	clientID   string
 }
 
 func (h *tunHandler) parseMetadata(md mdata.Metadata) (err error) {
@@ -24,6 +60,24 @@ func (h *tunHandler) parseMetadata(md mdata.Metadata) (err error) {
 		keepAlive       = "keepAlive"
 		keepAlivePeriod = "ttl"
 		passphrase      = "passphrase"
+		mode            = "mode"
+		udpTimeout      = "udpTimeout"
+		dnsHijack       = "dnsHijack"
+		fakeIP          = "fakeIP"
+		sniffing        = "sniffing"
+		sniffingTimeout = "sniffingTimeout"
+
+		privateKey = "privateKey"
+		peerKey    = "peerKey"
+		peers      = "peers"
+		anyPeer    = "anyPeer"
+
+		pool       = "pool"
+		leaseTime  = "leaseTime"
+		leaseFile  = "leaseFile"
+		pushRoutes = "pushRoutes"
+		pushDNS    = "pushDNS"
+		clientID   = "clientID"
 	)
 
 	h.md.bufferSize = mdutil.GetInt(md, bufferSize)
@@ -39,5 +93,73 @@ func (h *tunHandler) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	h.md.passphrase = mdutil.GetString(md, passphrase)
+
+	switch h.md.mode = mdutil.GetString(md, mode); h.md.mode {
+	case "", ModeStack:
+	default:
+		return fmt.Errorf("tun: unknown mode %s", h.md.mode)
+	}
+	h.md.udpTimeout = mdutil.GetDuration(md, udpTimeout)
+	if h.md.udpTimeout <= 0 {
+		h.md.udpTimeout = defaultUDPTimeout
+	}
+	if v := mdutil.GetString(md, fakeIP); v != "" {
+		if h.md.fakeIP, err = netip.ParsePrefix(v); err != nil {
+			return fmt.Errorf("tun: fakeIP: %w", err)
+		}
+		h.md.fakeIP = h.md.fakeIP.Masked()
+		if h.md.fakeIP.Addr().BitLen()-h.md.fakeIP.Bits() < 2 {
+			return fmt.Errorf("tun: fakeIP: prefix %s is too small", h.md.fakeIP)
+		}
+	}
+	// the fake IPs are only known by the DNS hijack.
+	h.md.dnsHijack = mdutil.GetBool(md, dnsHijack) || h.md.fakeIP.IsValid()
+	h.md.sniffing = mdutil.GetBool(md, sniffing)
+	h.md.sniffingTimeout = mdutil.GetDuration(md, sniffingTimeout)
+	if h.md.sniffingTimeout <= 0 {
+		h.md.sniffingTimeout = defaultSniffingTimeout
+	}
+
+	if v := mdutil.GetString(md, privateKey); v != "" {
+		if h.md.privateKey, err = noise_util.ParseKey(v); err != nil {
+			return fmt.Errorf("tun: privateKey: %w", err)
+		}
+	}
+	if v := mdutil.GetString(md, peerKey); v != "" {
+		if h.md.peerKey, err = noise_util.ParseKey(v); err != nil {
+			return fmt.Errorf("tun: peerKey: %w", err)
+		}
+	}
+	if h.md.peers, err = noise_util.ParseKeys(mdutil.GetStrings(md, peers)); err != nil {
+		return fmt.Errorf("tun: peers: %w", err)
+	}
+	h.md.anyPeer = mdutil.GetBool(md, anyPeer)
+
+	if v := mdutil.GetString(md, pool); v != "" {
+		if h.md.pool, err = netip.ParsePrefix(v); err != nil {
+			return fmt.Errorf("tun: pool: %w", err)
+		}
+	}
+	h.md.leaseTime = mdutil.GetDuration(md, leaseTime)
+	h.md.leaseFile = mdutil.GetString(md, leaseFile)
+	for _, s := range mdutil.GetStrings(md, pushRoutes) {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return fmt.Errorf("tun: pushRoutes: %w", err)
+		}
+		h.md.pushRoutes = append(h.md.pushRoutes, *ipNet)
+	}
+	for _, s := range mdutil.GetStrings(md, pushDNS) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("tun: pushDNS: invalid IP %s", s)
+		}
+		h.md.pushDNS = append(h.md.pushDNS, ip)
+	}
+
+	h.md.clientID = mdutil.GetString(md, clientID)
+	if h.md.clientID == "" {
+		h.md.clientID, _ = os.Hostname()
+	}
 	return
 }
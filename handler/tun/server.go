@@ -3,6 +3,7 @@ package tun
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net"
 	"net/netip"
@@ -11,12 +12,23 @@ import (
 	"github.com/go-gost/core/common/bufpool"
 	"github.com/go-gost/core/logger"
 	"github.com/songgao/water/waterutil"
+	noise_util "github.com/wznpp1/gost_x/internal/util/noise"
 	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
 
 func (h *tunHandler) handleServer(ctx context.Context, conn net.Conn, config *tun_util.Config, log logger.Logger) error {
+	if h.md.privateKey != nil && len(h.md.peers) == 0 && !h.md.anyPeer {
+		return errors.New("tun: peers or anyPeer is required in the secure mode")
+	}
+
+	if h.pool != nil {
+		for _, net := range config.Net {
+			h.pool.Reserve(net.IP)
+		}
+	}
+
 	for {
 		err := func() error {
 			pc, err := net.ListenPacket(conn.LocalAddr().Network(), conn.LocalAddr().String())
@@ -25,6 +37,18 @@ func (h *tunHandler) handleServer(ctx context.Context, conn net.Conn, config *tu
 			}
 			defer pc.Close()
 
+			if h.md.privateKey != nil {
+				pc, err = noise_util.PacketConn(pc, &noise_util.Config{
+					PrivateKey:   h.md.privateKey,
+					AllowedKeys:  h.md.peers,
+					AllowAnyPeer: h.md.anyPeer,
+					Logger:       log,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
 			return h.transportServer(conn, pc, config, log)
 		}()
 		if err == ErrTun {
@@ -110,6 +134,11 @@ func (h *tunHandler) transportServer(tun io.ReadWriter, conn net.PacketConn, con
 				if err != nil {
 					return err
 				}
+				if n >= leaseHeaderLength && bytes.Equal((*b)[:4], leaseMagicHeader) {
+					h.handleLease(conn, addr, (*b)[:n], log)
+					return nil
+				}
+
 				if n > keepAliveHeaderLength && bytes.Equal((*b)[:4], magicHeader) {
 					var peerIPs []net.IP
 					data := (*b)[keepAliveHeaderLength:n]
@@ -131,9 +160,23 @@ func (h *tunHandler) transportServer(tun io.ReadWriter, conn net.PacketConn, con
 						}
 					}
 
+					// the addresses of the pool can only be claimed by their lease holders.
+					if h.pool != nil {
+						for _, ip := range peerIPs {
+							if h.pool.Contains(ip) && !isSameAddr(h.findRouteFor(ip), addr) {
+								log.Debugf("keepalive from %v => %v, %s is not leased, discarded", addr, peerIPs, ip)
+								return nil
+							}
+						}
+					}
+
 					if auther := h.options.Auther; auther != nil {
 						ok := true
 						key := bytes.TrimRight((*b)[4:20], "\x00")
+						if addr, ok := addr.(*noise_util.PeerAddr); ok {
+							// the peer is identified by its static public key in the secure mode.
+							key = []byte(noise_util.EncodeKey(addr.PublicKey()))
+						}
 						for _, ip := range peerIPs {
 							if ok = auther.Authenticate(ip.String(), string(key)); !ok {
 								break
@@ -197,6 +240,12 @@ func (h *tunHandler) transportServer(tun io.ReadWriter, conn net.PacketConn, con
 					return nil
 				}
 
+				// the peer can only send the packets from its tunnel IPs in the secure mode.
+				if _, ok := addr.(*noise_util.PeerAddr); ok && h.findRouteFor(src, config.Routes...) != addr {
+					log.Debugf("packet from %v with unauthorized source %s, discarded", addr, src)
+					return nil
+				}
+
 				if addr := h.findRouteFor(dst, config.Routes...); addr != nil {
 					log.Debugf("find route: %s -> %s", dst, addr)
 
@@ -236,3 +285,7 @@ func (h *tunHandler) updateRoute(ip net.IP, addr net.Addr, log logger.Logger) {
 		log.Debugf("new route: %s -> %s", ip, addr)
 	}
 }
+
+func isSameAddr(a, b net.Addr) bool {
+	return a != nil && b != nil && a.String() == b.String()
+}
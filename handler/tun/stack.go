@@ -0,0 +1,413 @@
+package tun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+	xchain "github.com/wznpp1/gost_x/chain"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/util/sniffing"
+	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
+	"gvisor.dev/gvisor/pkg/bufferv2"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	stackNICID = 1
+	// stackQueueSize is the size of the outbound packet queue of the stack.
+	stackQueueSize = 1024
+	// stackTCPMaxInFlight is the max number of the TCP connections in the handshake.
+	stackTCPMaxInFlight = 1024
+)
+
+// handleStack terminates the TCP connections and UDP flows of the TUN device by the userspace TCP/IP stack,
+// each of them is relayed to its destination through the router.
+func (h *tunHandler) handleStack(ctx context.Context, conn net.Conn, config *tun_util.Config, log logger.Logger) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ep := channel.New(stackQueueSize, uint32(config.MTU), "")
+	defer ep.Close()
+
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol, ipv6.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol, udp.NewProtocol},
+	})
+	defer s.Close()
+
+	if err := s.CreateNIC(stackNICID, ep); err != nil {
+		return fmt.Errorf("tun: create NIC: %v", err)
+	}
+	// the stack accepts the packets to any address and replies from it.
+	if err := s.SetPromiscuousMode(stackNICID, true); err != nil {
+		return fmt.Errorf("tun: set promiscuous mode: %v", err)
+	}
+	if err := s.SetSpoofing(stackNICID, true); err != nil {
+		return fmt.Errorf("tun: set spoofing: %v", err)
+	}
+	s.SetRouteTable([]tcpip.Route{
+		{Destination: header.IPv4EmptySubnet, NIC: stackNICID},
+		{Destination: header.IPv6EmptySubnet, NIC: stackNICID},
+	})
+
+	dialCtx, router := h.outbound(ctx, config.Name, log)
+
+	tcpForwarder := tcp.NewForwarder(s, 0, stackTCPMaxInFlight, func(r *tcp.ForwarderRequest) {
+		h.handleStackTCP(dialCtx, router, r, log)
+	})
+	s.SetTransportProtocolHandler(tcp.ProtocolNumber, tcpForwarder.HandlePacket)
+
+	udpForwarder := udp.NewForwarder(s, func(r *udp.ForwarderRequest) {
+		h.handleStackUDP(dialCtx, s, router, r, log)
+	})
+	s.SetTransportProtocolHandler(udp.ProtocolNumber, udpForwarder.HandlePacket)
+
+	go func() {
+		for {
+			pkt := ep.ReadContext(ctx)
+			if pkt.IsNil() {
+				return
+			}
+			view := pkt.ToView()
+			pkt.DecRef()
+
+			_, err := conn.Write(view.AsSlice())
+			view.Release()
+			if err != nil {
+				log.Error(err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for {
+		err := func() error {
+			b := bufpool.Get(h.md.bufferSize)
+			defer bufpool.Put(b)
+
+			n, err := conn.Read(*b)
+			if err != nil {
+				return ErrTun
+			}
+			if n == 0 {
+				return nil
+			}
+
+			pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+				Payload: bufferv2.MakeWithData((*b)[:n]),
+			})
+			defer pkt.DecRef()
+
+			switch (*b)[0] >> 4 {
+			case 4:
+				ep.InjectInbound(header.IPv4ProtocolNumber, pkt)
+			case 6:
+				ep.InjectInbound(header.IPv6ProtocolNumber, pkt)
+			default:
+				log.Warn("unknown packet, discarded")
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// handleStackTCP dials the destination before the handshake is completed,
+// so the connection is reset by the stack if the destination is unreachable.
+// The destination is dialed after the handshake if the host is sniffed from the first data of the client.
+func (h *tunHandler) handleStackTCP(ctx context.Context, router *chain.Router, r *tcp.ForwarderRequest, log logger.Logger) {
+	id := r.ID()
+	src := net.JoinHostPort(id.RemoteAddress.String(), strconv.Itoa(int(id.RemotePort)))
+	dst, fake, err := h.stackTarget(id.LocalAddress, id.LocalPort)
+
+	log = log.WithFields(map[string]any{
+		"src": src,
+		"dst": fmt.Sprintf("%s/tcp", dst),
+	})
+	log.Debugf("%s >> %s", src, dst)
+
+	if err != nil {
+		log.Error(err)
+		r.Complete(true)
+		return
+	}
+	if h.options.Bypass != nil && h.options.Bypass.Contains(dst) {
+		log.Debug("bypass: ", dst)
+		r.Complete(true)
+		return
+	}
+
+	sniff := h.md.sniffing && !fake
+
+	var cc net.Conn
+	if !sniff {
+		if cc, err = router.Dial(ctx, "tcp", dst); err != nil {
+			log.Error(err)
+			r.Complete(true)
+			return
+		}
+		defer cc.Close()
+	}
+
+	var wq waiter.Queue
+	ep, tcpErr := r.CreateEndpoint(&wq)
+	if tcpErr != nil {
+		log.Error(tcpErr)
+		r.Complete(true)
+		return
+	}
+	r.Complete(false)
+
+	conn := gonet.NewTCPConn(&wq, ep)
+	defer conn.Close()
+
+	var rw io.ReadWriter = conn
+	if sniff {
+		var host, protocol string
+		conn.SetReadDeadline(time.Now().Add(h.md.sniffingTimeout))
+		rw, host, protocol, _ = sniffing.Sniff(ctx, conn)
+		conn.SetReadDeadline(time.Time{})
+		log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
+		sniffing.Record(ctx, h.options.Service, host, protocol)
+
+		if host != "" {
+			dst = sniffedTarget(host, dst)
+			log = log.WithFields(map[string]any{
+				"host": dst,
+			})
+			if h.options.Bypass != nil && h.options.Bypass.Contains(dst) {
+				log.Debug("bypass: ", dst)
+				return
+			}
+		}
+
+		if cc, err = router.Dial(ctx, "tcp", dst); err != nil {
+			log.Error(err)
+			return
+		}
+		defer cc.Close()
+	}
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", src, dst)
+	netpkg.Transport(rw, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", src, dst)
+}
+
+func (h *tunHandler) handleStackUDP(ctx context.Context, s *stack.Stack, router *chain.Router, r *udp.ForwarderRequest, log logger.Logger) {
+	id := r.ID()
+	src := net.JoinHostPort(id.RemoteAddress.String(), strconv.Itoa(int(id.RemotePort)))
+	dst, fake, err := h.stackTarget(id.LocalAddress, id.LocalPort)
+
+	log = log.WithFields(map[string]any{
+		"src": src,
+		"dst": fmt.Sprintf("%s/udp", dst),
+	})
+
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	hijack := h.md.dnsHijack && id.LocalPort == 53
+	if !hijack && h.options.Bypass != nil && h.options.Bypass.Contains(dst) {
+		log.Debug("bypass: ", dst)
+		return
+	}
+
+	var wq waiter.Queue
+	ep, tcpErr := r.CreateEndpoint(&wq)
+	if tcpErr != nil {
+		log.Error(tcpErr)
+		return
+	}
+	conn := gonet.NewUDPConn(s, &wq, ep)
+
+	go func() {
+		defer conn.Close()
+
+		log.Debugf("%s >> %s", src, dst)
+
+		if hijack {
+			h.handleStackDNS(ctx, router, conn, dst, log)
+			return
+		}
+
+		var b []byte
+		if h.md.sniffing && !fake {
+			b = make([]byte, h.md.bufferSize)
+			conn.SetReadDeadline(time.Now().Add(h.md.udpTimeout))
+			n, err := conn.Read(b)
+			if err != nil {
+				return
+			}
+			b = b[:n]
+
+			host, protocol := sniffing.SniffPacket(b)
+			log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
+			sniffing.Record(ctx, h.options.Service, host, protocol)
+
+			if host != "" {
+				dst = sniffedTarget(host, dst)
+				log = log.WithFields(map[string]any{
+					"host": dst,
+				})
+				if h.options.Bypass != nil && h.options.Bypass.Contains(dst) {
+					log.Debug("bypass: ", dst)
+					return
+				}
+			}
+		}
+
+		cc, err := router.Dial(ctx, "udp", dst)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		defer cc.Close()
+
+		if b != nil {
+			if _, err := cc.Write(b); err != nil {
+				log.Error(err)
+				return
+			}
+		}
+
+		t := time.Now()
+		log.Debugf("%s <-> %s", src, dst)
+		h.relayUDP(conn, cc)
+		log.WithFields(map[string]any{
+			"duration": time.Since(t),
+		}).Debugf("%s >-< %s", src, dst)
+	}()
+}
+
+// stackTarget returns the destination of the flow, the fake IP is replaced by its domain name.
+func (h *tunHandler) stackTarget(addr tcpip.Address, port uint16) (dst string, fake bool, err error) {
+	dst = net.JoinHostPort(addr.String(), strconv.Itoa(int(port)))
+	if h.fakeIPs == nil {
+		return
+	}
+
+	ip, _ := netip.AddrFromSlice([]byte(addr))
+	if !h.fakeIPs.Contains(ip.Unmap()) {
+		return
+	}
+	name, ok := h.fakeIPs.Lookup(ip.Unmap())
+	if !ok {
+		return dst, true, fmt.Errorf("tun: unknown fake IP %s", ip)
+	}
+	return net.JoinHostPort(name, strconv.Itoa(int(port))), true, nil
+}
+
+// sniffedTarget returns the address of the sniffed host with the port of dst.
+func sniffedTarget(host, dst string) string {
+	if v, _, err := net.SplitHostPort(host); err == nil {
+		host = v
+	}
+	_, port, _ := net.SplitHostPort(dst)
+	return net.JoinHostPort(host, port)
+}
+
+// outbound returns the router and the context whose connections are kept out of the TUN device to avoid the routing loop,
+// they are bound to the interface or marked by the socket options of the router,
+// or bound to the interface of the default route if neither is set.
+func (h *tunHandler) outbound(ctx context.Context, device string, log logger.Logger) (context.Context, *chain.Router) {
+	opts := h.router.Options()
+	if opts.IfceName != "" || opts.SockOpts != nil {
+		return xchain.ContextWithDialOptions(ctx, opts.IfceName, opts.SockOpts), h.router
+	}
+
+	ifce, err := netpkg.DefaultInterface(device)
+	if err != nil {
+		log.Warnf("outbound interface: %v, set the interface or sockopts of the service to avoid the routing loop", err)
+		return ctx, h.router
+	}
+	log.Debugf("outbound interface: %s", ifce)
+
+	router := chain.NewRouter(
+		chain.RetriesRouterOption(opts.Retries),
+		chain.TimeoutRouterOption(opts.Timeout),
+		chain.InterfaceRouterOption(ifce),
+		chain.ChainRouterOption(opts.Chain),
+		chain.ResolverRouterOption(opts.Resolver),
+		chain.HostMapperRouterOption(opts.HostMapper),
+		chain.RecordersRouterOption(opts.Recorders...),
+		chain.LoggerRouterOption(opts.Logger),
+	)
+	return xchain.ContextWithDialOptions(ctx, ifce, nil), router
+}
+
+// relayUDP relays the packets between the UDP flow and the connection to the destination,
+// it returns when there is no packet in either direction for the UDP timeout.
+func (h *tunHandler) relayUDP(c1, c2 net.Conn) {
+	var active int64
+	touch := func() {
+		atomic.StoreInt64(&active, time.Now().UnixNano())
+	}
+	touch()
+
+	pipe := func(dst, src net.Conn) error {
+		b := bufpool.Get(h.md.bufferSize)
+		defer bufpool.Put(b)
+
+		for {
+			src.SetReadDeadline(time.Now().Add(h.md.udpTimeout))
+			n, err := src.Read(*b)
+			if err != nil {
+				if e, ok := err.(net.Error); ok && e.Timeout() &&
+					time.Since(time.Unix(0, atomic.LoadInt64(&active))) < h.md.udpTimeout {
+					continue
+				}
+				return err
+			}
+			touch()
+
+			if _, err := dst.Write((*b)[:n]); err != nil {
+				return err
+			}
+		}
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- pipe(c2, c1)
+	}()
+	go func() {
+		errc <- pipe(c1, c2)
+	}()
+
+	<-errc
+	c1.Close()
+	c2.Close()
+	<-errc
+}
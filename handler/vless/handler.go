@@ -0,0 +1,237 @@
+package vless
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/google/uuid"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/internal/util/vless"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+const (
+	usersTTL = 5 * time.Second
+)
+
+var (
+	ErrAuth = errors.New("vless: authentication failed")
+)
+
+func init() {
+	registry.HandlerRegistry().Register("vless", NewHandler)
+}
+
+type vlessHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+
+	// ids maps the user IDs to the users.
+	ids     map[uuid.UUID]string
+	updated time.Time
+	mu      sync.Mutex
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vlessHandler{
+		options: options,
+	}
+}
+
+func (h *vlessHandler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	return
+}
+
+func (h *vlessHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		return nil
+	}
+
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	br := bufio.NewReader(conn)
+	id, err := vless.ReadID(br)
+	if err != nil {
+		log.Error(err)
+		return h.handleFallback(conn, br, log)
+	}
+
+	user, ok := h.authenticate(id)
+	if !ok {
+		log.Error(ErrAuth)
+		return h.handleFallback(conn, br, log)
+	}
+	if user != "" {
+		log = log.WithFields(map[string]any{"user": user})
+		xctx.SetUser(ctx, user)
+	}
+
+	req := vless.Request{}
+	if _, err := req.ReadFrom(br); err != nil {
+		log.Error(err)
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	conn = vless.ServerConn(netpkg.NewBufferReaderConn(conn, br))
+
+	switch req.Cmd {
+	case vless.CmdTCP:
+		return h.handleConnect(ctx, conn, "tcp", req.Addr, log)
+	case vless.CmdUDP:
+		if !h.md.enableUDP {
+			err := errors.New("vless: UDP relay is disabled")
+			log.Error(err)
+			return err
+		}
+		return h.handleConnect(ctx, vless.PacketConn(conn), "udp", req.Addr, log)
+	default:
+		err := errors.New("vless: mux is not supported")
+		log.Error(err)
+		return err
+	}
+}
+
+func (h *vlessHandler) handleConnect(ctx context.Context, conn net.Conn, network, address string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"dst": address + "/" + network,
+		"cmd": "connect",
+	})
+	xctx.SetTarget(ctx, address)
+
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Debug("bypass: ", address)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = sx.ContextWithHash(ctx, &sx.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, network, address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), address)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
+
+// handleFallback relays the unauthenticated connection to the fallback backend.
+func (h *vlessHandler) handleFallback(conn net.Conn, br *bufio.Reader, log logger.Logger) error {
+	if h.md.fallback == "" {
+		io.Copy(ioutil.Discard, br)
+		return ErrAuth
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	cc, err := net.Dial("tcp", h.md.fallback)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	log.Debugf("%s <-> fallback %s", conn.RemoteAddr(), h.md.fallback)
+	netpkg.Transport(netpkg.NewBufferReaderConn(conn, br), cc)
+
+	return nil
+}
+
+// authenticate returns the user of the ID.
+// The ID is checked against the password of the handler auth and the users of the auther,
+// the passwords are the user IDs.
+func (h *vlessHandler) authenticate(id uuid.UUID) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ids == nil || time.Since(h.updated) > usersTTL {
+		ids := make(map[uuid.UUID]string)
+		if lister, ok := h.options.Auther.(xauth.Lister); ok {
+			for user, password := range lister.Users() {
+				ids[vless.ID(password)] = user
+			}
+		}
+		if h.options.Auth != nil {
+			password, _ := h.options.Auth.Password()
+			ids[vless.ID(password)] = h.options.Auth.Username()
+		}
+		h.ids = ids
+		h.updated = time.Now()
+	}
+
+	if len(h.ids) == 0 {
+		return "", true
+	}
+
+	user, ok := h.ids[id]
+	return user, ok
+}
+
+func (h *vlessHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
@@ -0,0 +1,35 @@
+package vless
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+type metadata struct {
+	readTimeout time.Duration
+	fallback    string
+	enableUDP   bool
+	hash        string
+}
+
+func (h *vlessHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		readTimeout = "readTimeout"
+		fallback    = "fallback"
+		enableUDP   = "udp"
+		hash        = "hash"
+	)
+
+	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	h.md.fallback = mdutil.GetString(md, fallback)
+
+	h.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		h.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+	h.md.hash = mdutil.GetString(md, hash)
+
+	return
+}
@@ -0,0 +1,183 @@
+package vmess
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	netpkg "github.com/wznpp1/gost_x/internal/net"
+	sx "github.com/wznpp1/gost_x/internal/util/selector"
+	"github.com/wznpp1/gost_x/internal/util/vmess"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.HandlerRegistry().Register("vmess", NewHandler)
+}
+
+type vmessHandler struct {
+	router  *chain.Router
+	server  *vmess.Server
+	md      metadata
+	options handler.Options
+}
+
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vmessHandler{
+		options: options,
+	}
+}
+
+func (h *vmessHandler) Init(md md.Metadata) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.server = vmess.NewServer(h.users)
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	return
+}
+
+func (h *vmessHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		return nil
+	}
+
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	br := bufio.NewReader(conn)
+	req, user, err := h.server.ReadRequest(br)
+	if err != nil {
+		log.Error(err)
+		// drain the connection, so the failure is not told to the active probers.
+		io.Copy(ioutil.Discard, conn)
+		return err
+	}
+	if user != "" {
+		log = log.WithFields(map[string]any{"user": user})
+		xctx.SetUser(ctx, user)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	conn, err = vmess.ServerConn(netpkg.NewBufferReaderConn(conn, br), req)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	switch req.Cmd {
+	case vmess.CmdTCP:
+		return h.handleConnect(ctx, conn, "tcp", req.Addr, log)
+	case vmess.CmdUDP:
+		if !h.md.enableUDP {
+			err := errors.New("vmess: UDP relay is disabled")
+			log.Error(err)
+			return err
+		}
+		return h.handleConnect(ctx, conn, "udp", req.Addr, log)
+	default:
+		err := errors.New("vmess: mux is not supported")
+		log.Error(err)
+		return err
+	}
+}
+
+func (h *vmessHandler) handleConnect(ctx context.Context, conn net.Conn, network, address string, log logger.Logger) error {
+	log = log.WithFields(map[string]any{
+		"dst": address + "/" + network,
+		"cmd": "connect",
+	})
+	xctx.SetTarget(ctx, address)
+
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(address) {
+		log.Debug("bypass: ", address)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = sx.ContextWithHash(ctx, &sx.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, network, address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), address)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
+
+// users returns the users of the auther and the handler auth, the passwords are the user IDs.
+func (h *vmessHandler) users() map[string]string {
+	users := make(map[string]string)
+	if lister, ok := h.options.Auther.(xauth.Lister); ok {
+		for user, password := range lister.Users() {
+			users[user] = password
+		}
+	}
+	if h.options.Auth != nil {
+		password, _ := h.options.Auth.Password()
+		users[h.options.Auth.Username()] = password
+	}
+	return users
+}
+
+func (h *vmessHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
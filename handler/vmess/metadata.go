@@ -0,0 +1,32 @@
+package vmess
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+type metadata struct {
+	readTimeout time.Duration
+	enableUDP   bool
+	hash        string
+}
+
+func (h *vmessHandler) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		readTimeout = "readTimeout"
+		enableUDP   = "udp"
+		hash        = "hash"
+	)
+
+	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+
+	h.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		h.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+	h.md.hash = mdutil.GetString(md, hash)
+
+	return
+}
@@ -0,0 +1,159 @@
+package ctx
+
+import (
+	"context"
+	"sync"
+)
+
+// Session holds the per-connection values which are only known
+// after the handler has processed the client request,
+// such as the authenticated user, the target address, the chain node
+// and the sniffed protocol and host.
+type Session struct {
+	user     string
+	target   string
+	node     string
+	protocol string
+	host     string
+	mu       sync.RWMutex
+}
+
+func (s *Session) User() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.user
+}
+
+func (s *Session) SetUser(user string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+}
+
+func (s *Session) Target() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.target
+}
+
+func (s *Session) SetTarget(target string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.target = target
+}
+
+func (s *Session) Node() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.node
+}
+
+func (s *Session) SetNode(node string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.node = node
+}
+
+func (s *Session) Protocol() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.protocol
+}
+
+func (s *Session) SetProtocol(protocol string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.protocol = protocol
+}
+
+func (s *Session) Host() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.host
+}
+
+func (s *Session) SetHost(host string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.host = host
+}
+
+type sessionKey struct{}
+
+var (
+	ssession sessionKey
+)
+
+func ContextWithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, ssession, s)
+}
+
+func SessionFromContext(ctx context.Context) *Session {
+	v, _ := ctx.Value(ssession).(*Session)
+	return v
+}
+
+// SetUser records the authenticated user in the session of ctx, if any.
+func SetUser(ctx context.Context, user string) {
+	SessionFromContext(ctx).SetUser(user)
+}
+
+// SetTarget records the target address in the session of ctx, if any.
+func SetTarget(ctx context.Context, target string) {
+	SessionFromContext(ctx).SetTarget(target)
+}
+
+// SetNode records the name of the chain node used to reach the target in the session of ctx, if any.
+func SetNode(ctx context.Context, node string) {
+	SessionFromContext(ctx).SetNode(node)
+}
+
+// SetProtocol records the sniffed protocol of the connection in the session of ctx, if any.
+func SetProtocol(ctx context.Context, protocol string) {
+	SessionFromContext(ctx).SetProtocol(protocol)
+}
+
+// SetHost records the sniffed host, such as the TLS server name, in the session of ctx, if any.
+func SetHost(ctx context.Context, host string) {
+	SessionFromContext(ctx).SetHost(host)
+}
@@ -0,0 +1,174 @@
+package net
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+var (
+	listeners   = make(map[string]*sharedListener)
+	handoffs    = make(map[string]int)
+	listenersMu sync.Mutex
+)
+
+// Handoff allows the listeners of the service created before done is called
+// to take over the bound sockets of the listeners of the same service,
+// so a replacement listener can take over the socket before the old one is closed.
+func Handoff(service string) (done func()) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	handoffs[service]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			listenersMu.Lock()
+			defer listenersMu.Unlock()
+
+			if handoffs[service]--; handoffs[service] <= 0 {
+				delete(handoffs, service)
+			}
+		})
+	}
+}
+
+// Listen announces on the local network address for the service like net.Listen.
+// The bound socket is shared with the listener of the same service on the same network and address
+// only during the Handoff of the service, otherwise the address is in use.
+// The socket is closed when the last of the listeners sharing it is closed.
+func Listen(network, address string, service string) (net.Listener, error) {
+	_, port, _ := net.SplitHostPort(address)
+	if port == "" || port == "0" {
+		return net.Listen(network, address)
+	}
+
+	key := network + "://" + address
+
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	sl := listeners[key]
+	if sl != nil && (service == "" || sl.service != service || handoffs[service] == 0) {
+		return nil, &net.OpError{
+			Op:   "listen",
+			Net:  network,
+			Addr: sl.ln.Addr(),
+			Err:  os.NewSyscallError("bind", syscall.EADDRINUSE),
+		}
+	}
+	if sl == nil {
+		ln, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		sl = &sharedListener{
+			key:     key,
+			service: service,
+			ln:      ln,
+			results: make(chan acceptResult),
+			failed:  make(chan struct{}),
+			closed:  make(chan struct{}),
+		}
+		listeners[key] = sl
+		go sl.acceptLoop()
+	}
+	sl.refs++
+
+	return &listenerRef{
+		sl:     sl,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+type sharedListener struct {
+	key     string
+	service string
+	ln      net.Listener
+	refs    int
+	results chan acceptResult
+	// failed is closed when the socket fails with err.
+	failed chan struct{}
+	err    error
+	closed chan struct{}
+}
+
+func (l *sharedListener) acceptLoop() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); !ok || !ne.Temporary() {
+				l.err = err
+				close(l.failed)
+
+				listenersMu.Lock()
+				if listeners[l.key] == l {
+					delete(listeners, l.key)
+				}
+				listenersMu.Unlock()
+				return
+			}
+		}
+
+		select {
+		case l.results <- acceptResult{conn: conn, err: err}:
+		case <-l.closed:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (l *sharedListener) release() error {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	l.refs--
+	if l.refs > 0 {
+		return nil
+	}
+	if listeners[l.key] == l {
+		delete(listeners, l.key)
+	}
+	close(l.closed)
+	return l.ln.Close()
+}
+
+type listenerRef struct {
+	sl        *sharedListener
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listenerRef) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.sl.results:
+		return r.conn, r.err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-l.sl.failed:
+		return nil, l.sl.err
+	}
+}
+
+func (l *listenerRef) Addr() net.Addr {
+	return l.sl.ln.Addr()
+}
+
+func (l *listenerRef) Close() (err error) {
+	err = net.ErrClosed
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.sl.release()
+	})
+	return
+}
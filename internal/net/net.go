@@ -23,6 +23,22 @@ type SetDSCP interface {
 	SetDSCP(int) error
 }
 
+// Unwrapper is implemented by the connection wrappers which expose the wrapped connection.
+type Unwrapper interface {
+	Unwrap() net.Conn
+}
+
+// UnwrapConn returns the innermost connection of the chained Unwrappers.
+func UnwrapConn(c net.Conn) net.Conn {
+	for {
+		u, ok := c.(Unwrapper)
+		if !ok {
+			return c
+		}
+		c = u.Unwrap()
+	}
+}
+
 func IsIPv4(address string) bool {
 	return address != "" && address[0] != ':' && address[0] != '['
 }
@@ -0,0 +1,52 @@
+package net
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultInterface returns the interface of the IPv4 default route with the lowest metric,
+// the routes of the interface exclude (such as the TUN device) are skipped.
+func DefaultInterface(exclude string) (string, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	const rtfUp = 0x1
+
+	var ifce string
+	metric := -1
+
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 8 || fields[0] == exclude {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		if flags&rtfUp == 0 {
+			continue
+		}
+		m, _ := strconv.Atoi(fields[6])
+		if metric < 0 || m < metric {
+			ifce, metric = fields[0], m
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if ifce == "" {
+		return "", errors.New("no default route")
+	}
+	return ifce, nil
+}
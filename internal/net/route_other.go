@@ -0,0 +1,12 @@
+//go:build !linux
+
+package net
+
+import (
+	"errors"
+)
+
+// DefaultInterface returns the interface of the IPv4 default route, it is only supported on Linux.
+func DefaultInterface(exclude string) (string, error) {
+	return "", errors.New("default interface detection is not supported")
+}
@@ -0,0 +1,315 @@
+package tproxy
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"sync"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	// DefaultMark is the fwmark of the intercepted packets, they are routed to the local host by the policy route.
+	DefaultMark = 0x1
+	// DefaultTable is the routing table of the policy route.
+	DefaultTable = 100
+	// DefaultBypassMark is the socket mark of the outbound connections of the service,
+	// the packets with it are never intercepted, so the relayed traffic does not loop back to the service.
+	DefaultBypassMark = 0xff
+)
+
+const (
+	BackendNftables = "nftables"
+	BackendIptables = "iptables"
+)
+
+// Manager is implemented by the listeners which manage their TPROXY rules.
+type Manager interface {
+	// SetupRules installs the rules of the listener, they are removed when the listener is closed.
+	// The packets with the bypassMark are not intercepted, and the sockets of the listener are marked with it.
+	// It returns false if the rules are not managed by the listener.
+	SetupRules(bypassMark int) bool
+}
+
+// Options is the TPROXY setup of the listener.
+type Options struct {
+	// Name identifies the nftables table or the iptables chains, it is usually the service name.
+	Name string
+	// Network is tcp or udp.
+	Network string
+	// Addr is the listen address, the packets are redirected to its port.
+	Addr string
+	// Mark is the fwmark of the intercepted packets.
+	Mark int
+	// Table is the routing table of the policy route.
+	Table int
+	// BypassMark is the socket mark of the outbound connections of the service.
+	BypassMark int
+	// Output also intercepts the traffic generated by the local host.
+	Output bool
+	// Backend is nftables or iptables.
+	Backend string
+	Logger  logger.Logger
+}
+
+var (
+	// instances numbers the rules of each name, so the rules of the updated service do not collide with the old ones.
+	instances = make(map[string]int)
+	// routes counts the rules using each policy route, the route shared by the services is removed with the last one.
+	routes = make(map[string]int)
+	mu     sync.Mutex
+)
+
+// Rules are the installed rules and policy routes of the listener.
+type Rules struct {
+	down   []string
+	routes []policyRoute
+	logger logger.Logger
+	once   sync.Once
+}
+
+type policyRoute struct {
+	key      string
+	up, down []string
+}
+
+// Setup installs the rules and policy routes of the listener, they are removed by the Close of the Rules.
+// The rules of each call are named uniquely, the stale rules of the previous run with the same name are removed before they are installed.
+func Setup(opts Options) (*Rules, error) {
+	up, down, prs, err := commands(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rules{
+		down:   down,
+		routes: prs,
+		logger: opts.Logger,
+	}
+	if r.logger == nil {
+		r.logger = logger.Default()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, pr := range prs {
+		if routes[pr.key] == 0 {
+			r.exec(pr.down, true)
+			r.exec(pr.up, false)
+		}
+		routes[pr.key]++
+	}
+	r.exec(down, true)
+	r.exec(up, false)
+
+	return r, nil
+}
+
+// Close removes the rules, the policy routes are removed if they are not used by the other rules.
+func (r *Rules) Close() error {
+	r.once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		r.exec(r.down, false)
+		for _, pr := range r.routes {
+			if routes[pr.key]--; routes[pr.key] <= 0 {
+				delete(routes, pr.key)
+				r.exec(pr.down, false)
+			}
+		}
+	})
+	return nil
+}
+
+// exec runs the commands, the failures are ignored if quiet is set, such as removing the absent rules.
+func (r *Rules) exec(cmds []string, quiet bool) {
+	for _, cmd := range cmds {
+		r.logger.Debug(cmd)
+		if err := exec.Command("/bin/sh", "-c", cmd).Run(); err != nil && !quiet {
+			r.logger.Warnf("tproxy: %s: %v", cmd, err)
+		}
+	}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// commands returns the commands which install and remove the rules, and the policy routes of the rules.
+func commands(opts Options) (up, down []string, prs []policyRoute, err error) {
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return
+	}
+	var ip net.IP
+	if host != "" {
+		if ip = net.ParseIP(host); ip == nil {
+			err = fmt.Errorf("tproxy: invalid listen address %s", opts.Addr)
+			return
+		}
+		if ip.IsUnspecified() {
+			ip = nil
+		}
+	}
+	if opts.Network != "tcp" && opts.Network != "udp" {
+		err = fmt.Errorf("tproxy: unsupported network %s", opts.Network)
+		return
+	}
+	if opts.Mark <= 0 {
+		opts.Mark = DefaultMark
+	}
+	if opts.Table <= 0 {
+		opts.Table = DefaultTable
+	}
+	if opts.BypassMark <= 0 {
+		opts.BypassMark = DefaultBypassMark
+	}
+	if opts.Mark == opts.BypassMark {
+		err = fmt.Errorf("tproxy: mark %#x conflicts with the bypass mark", opts.Mark)
+		return
+	}
+
+	name := "gost_" + invalidNameChars.ReplaceAllString(opts.Name, "_")
+	mu.Lock()
+	instances[name]++
+	n := instances[name]
+	mu.Unlock()
+
+	// the IPv4 and IPv6 traffic are both intercepted if the listen address is unspecified.
+	ipv4 := ip == nil || ip.To4() != nil
+	ipv6 := ip == nil || ip.To4() == nil
+
+	if ipv4 {
+		prs = append(prs, policyRoute{
+			key: fmt.Sprintf("ip %#x %d", opts.Mark, opts.Table),
+			up: []string{
+				fmt.Sprintf("ip rule add fwmark %#x lookup %d", opts.Mark, opts.Table),
+				fmt.Sprintf("ip route replace local 0.0.0.0/0 dev lo table %d", opts.Table),
+			},
+			down: []string{
+				fmt.Sprintf("ip rule del fwmark %#x lookup %d", opts.Mark, opts.Table),
+				fmt.Sprintf("ip route del local 0.0.0.0/0 dev lo table %d", opts.Table),
+			},
+		})
+	}
+	if ipv6 {
+		prs = append(prs, policyRoute{
+			key: fmt.Sprintf("ip6 %#x %d", opts.Mark, opts.Table),
+			up: []string{
+				fmt.Sprintf("ip -6 rule add fwmark %#x lookup %d", opts.Mark, opts.Table),
+				fmt.Sprintf("ip -6 route replace local ::/0 dev lo table %d", opts.Table),
+			},
+			down: []string{
+				fmt.Sprintf("ip -6 rule del fwmark %#x lookup %d", opts.Mark, opts.Table),
+				fmt.Sprintf("ip -6 route del local ::/0 dev lo table %d", opts.Table),
+			},
+		})
+	}
+
+	switch opts.Backend {
+	case "", BackendNftables:
+		up, down = nftables(fmt.Sprintf("%s_%d", name, n), opts, ip, port)
+	case BackendIptables:
+		if ipv4 {
+			u, d := iptables("iptables", name, n, opts, ip, port)
+			up, down = append(up, u...), append(down, d...)
+		}
+		if ipv6 {
+			u, d := iptables("ip6tables", name, n, opts, ip, port)
+			up, down = append(up, u...), append(down, d...)
+		}
+	default:
+		err = fmt.Errorf("tproxy: unknown backend %s", opts.Backend)
+	}
+	return
+}
+
+func nftables(table string, opts Options, ip net.IP, port string) (up, down []string) {
+	target := fmt.Sprintf("tproxy to :%s", port)
+	if ip != nil {
+		if ip.To4() != nil {
+			target = fmt.Sprintf("tproxy ip to %s", net.JoinHostPort(ip.String(), port))
+		} else {
+			target = fmt.Sprintf("tproxy ip6 to %s", net.JoinHostPort(ip.String(), port))
+		}
+	}
+
+	up = []string{
+		fmt.Sprintf("nft add table inet %s", table),
+		fmt.Sprintf("nft add chain inet %s prerouting '{ type filter hook prerouting priority mangle; }'", table),
+		fmt.Sprintf("nft add rule inet %s prerouting meta mark %#x return", table, opts.BypassMark),
+		fmt.Sprintf("nft add rule inet %s prerouting 'fib daddr type { local, broadcast, multicast } return'", table),
+		fmt.Sprintf("nft add rule inet %s prerouting meta l4proto %s socket transparent 1 meta mark set %#x accept",
+			table, opts.Network, opts.Mark),
+		fmt.Sprintf("nft add rule inet %s prerouting meta l4proto %s %s meta mark set %#x accept",
+			table, opts.Network, target, opts.Mark),
+	}
+	if opts.Output {
+		// the local traffic is marked and routed back to the prerouting hook by the policy route.
+		up = append(up,
+			fmt.Sprintf("nft add chain inet %s output '{ type route hook output priority mangle; }'", table),
+			fmt.Sprintf("nft add rule inet %s output meta mark %#x return", table, opts.BypassMark),
+			fmt.Sprintf("nft add rule inet %s output 'fib daddr type { local, broadcast, multicast } return'", table),
+			fmt.Sprintf("nft add rule inet %s output meta l4proto %s meta mark set %#x", table, opts.Network, opts.Mark),
+		)
+	}
+	down = []string{
+		fmt.Sprintf("nft delete table inet %s", table),
+	}
+	return
+}
+
+func iptables(cmd string, chain string, n int, opts Options, ip net.IP, port string) (up, down []string) {
+	chain = fmtChain(chain, n)
+	dstTypes := "LOCAL,BROADCAST,MULTICAST"
+	if cmd == "ip6tables" {
+		dstTypes = "LOCAL,MULTICAST"
+	}
+	target := fmt.Sprintf("-j TPROXY --on-port %s --tproxy-mark %#x", port, opts.Mark)
+	if ip != nil {
+		target += " --on-ip " + ip.String()
+	}
+
+	up = []string{
+		fmt.Sprintf("%s -t mangle -N %s", cmd, chain),
+		fmt.Sprintf("%s -t mangle -A %s -m mark --mark %#x -j RETURN", cmd, chain, opts.BypassMark),
+		fmt.Sprintf("%s -t mangle -A %s -m addrtype --dst-type %s -j RETURN", cmd, chain, dstTypes),
+		fmt.Sprintf("%s -t mangle -A %s -p %s -m socket --transparent -j MARK --set-mark %#x", cmd, chain, opts.Network, opts.Mark),
+		fmt.Sprintf("%s -t mangle -A %s -p %s -m socket --transparent -j RETURN", cmd, chain, opts.Network),
+		fmt.Sprintf("%s -t mangle -A %s -p %s %s", cmd, chain, opts.Network, target),
+		fmt.Sprintf("%s -t mangle -I PREROUTING -j %s", cmd, chain),
+	}
+	down = []string{
+		fmt.Sprintf("%s -t mangle -D PREROUTING -j %s", cmd, chain),
+		fmt.Sprintf("%s -t mangle -F %s", cmd, chain),
+		fmt.Sprintf("%s -t mangle -X %s", cmd, chain),
+	}
+
+	if opts.Output {
+		out := chain + "_OUT"
+		up = append(up,
+			fmt.Sprintf("%s -t mangle -N %s", cmd, out),
+			fmt.Sprintf("%s -t mangle -A %s -m mark --mark %#x -j RETURN", cmd, out, opts.BypassMark),
+			fmt.Sprintf("%s -t mangle -A %s -m addrtype --dst-type %s -j RETURN", cmd, out, dstTypes),
+			fmt.Sprintf("%s -t mangle -A %s -p %s -j MARK --set-mark %#x", cmd, out, opts.Network, opts.Mark),
+			fmt.Sprintf("%s -t mangle -I OUTPUT -j %s", cmd, out),
+		)
+		down = append(down,
+			fmt.Sprintf("%s -t mangle -D OUTPUT -j %s", cmd, out),
+			fmt.Sprintf("%s -t mangle -F %s", cmd, out),
+			fmt.Sprintf("%s -t mangle -X %s", cmd, out),
+		)
+	}
+	return
+}
+
+// fmtChain limits the chain name of the nth rules to the 28 characters of iptables.
+func fmtChain(name string, n int) string {
+	suffix := fmt.Sprintf("_%d", n)
+	if maxLen := 28 - len("_OUT") - len(suffix); len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return name + suffix
+}
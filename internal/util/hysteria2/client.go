@@ -0,0 +1,135 @@
+package hysteria2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var (
+	ErrAuth              = errors.New("hysteria2: authentication failed")
+	ErrUDPDisabled       = errors.New("hysteria2: UDP relay is disabled by the server")
+	ErrInvalidConnection = errors.New("hysteria2: invalid connection")
+)
+
+// ClientConfig is the config of the client.
+type ClientConfig struct {
+	// Auth is the authentication of the client, it is the password or in the form of user:password.
+	Auth string
+	// Rx is the max receive rate of the client in bytes per second, 0 means unknown.
+	Rx uint64
+}
+
+// Client is the authenticated QUIC connection to the server.
+type Client struct {
+	conn quic.EarlyConnection
+	rt   *http3.RoundTripper
+	udp  *UDPMux
+	// rx is the max receive rate of the server.
+	rx     uint64
+	rxAuto bool
+}
+
+// NewClient authenticates the client on the QUIC connection by the HTTP/3 request.
+func NewClient(ctx context.Context, conn quic.EarlyConnection, config *ClientConfig) (*Client, error) {
+	if config == nil {
+		config = &ClientConfig{}
+	}
+
+	rt := &http3.RoundTripper{
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			return conn, nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+URLHost+URLPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	ar := AuthRequest{
+		Auth: config.Auth,
+		Rx:   config.Rx,
+	}
+	ar.WriteHeader(req.Header)
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != StatusAuthOK {
+		return nil, ErrAuth
+	}
+
+	var authResp AuthResponse
+	authResp.ReadHeader(resp.Header)
+
+	c := &Client{
+		conn:   conn,
+		rt:     rt,
+		rx:     authResp.Rx,
+		rxAuto: authResp.RxAuto,
+	}
+	if authResp.UDPEnabled {
+		c.udp = NewUDPMux(conn)
+		go c.udp.Serve(nil, 0)
+	}
+
+	return c, nil
+}
+
+// Rx returns the max receive rate of the server in bytes per second,
+// 0 means unlimited and auto means the server asks for the bandwidth detection.
+func (c *Client) Rx() (rx uint64, auto bool) {
+	return c.rx, c.rxAuto
+}
+
+// DialTCP makes the TCP request to addr.
+func (c *Client) DialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn := NewTCPConn(stream, c.conn.LocalAddr(), c.conn.RemoteAddr(), addr, "")
+
+	if err := WriteTCPRequest(stream, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ok, msg, err := ReadTCPResponse(stream)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("hysteria2: %s", msg)
+	}
+
+	return conn, nil
+}
+
+// DialUDP creates the UDP session, the Write of the connection sends the packets to addr.
+func (c *Client) DialUDP(addr net.Addr) (net.Conn, error) {
+	if c.udp == nil {
+		return nil, ErrUDPDisabled
+	}
+	return c.udp.NewConn(addr), nil
+}
+
+// Closed returns a channel which is closed when the client is closed.
+func (c *Client) Closed() <-chan struct{} {
+	return c.conn.Context().Done()
+}
+
+func (c *Client) Close() error {
+	c.rt.Close()
+	return c.conn.CloseWithError(0, "")
+}
@@ -0,0 +1,394 @@
+// Package hysteria2 implements the Hysteria2 protocol on stock quic-go.
+//
+// Brutal congestion control is not implemented: quic-go v0.32 has no pluggable congestion control,
+// so the connections use its default congestion control. The receive rate (down) is exchanged
+// in Hysteria-CC-RX so that a stock Hysteria2 peer paces its Brutal sender,
+// the send rate (up) of this side is not supported.
+package hysteria2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+const (
+	// URLHost and URLPath make the URL of the authentication request.
+	URLHost = "hysteria"
+	URLPath = "/auth"
+
+	// StatusAuthOK is the status code of the successful authentication.
+	StatusAuthOK = 233
+
+	HeaderAuth    = "Hysteria-Auth"
+	HeaderUDP     = "Hysteria-UDP"
+	HeaderCCRX    = "Hysteria-CC-RX"
+	HeaderPadding = "Hysteria-Padding"
+
+	// FrameTypeTCPRequest is the HTTP/3 frame type which starts the TCP request stream.
+	FrameTypeTCPRequest = 0x401
+
+	MaxAddressLength = 2048
+	MaxMessageLength = 2048
+	MaxPaddingLength = 4096
+
+	// MaxDatagramSize is the max size of the UDP message carried in one QUIC datagram,
+	// the larger message is fragmented.
+	MaxDatagramSize = 1150
+	// MaxUDPSize is the max size of the UDP message.
+	MaxUDPSize = 4096
+)
+
+var (
+	ErrBadRequest  = errors.New("hysteria2: bad request")
+	ErrBadResponse = errors.New("hysteria2: bad response")
+	// ErrUpBandwidth is returned if the send rate (up) is set, which needs Brutal congestion control.
+	ErrUpBandwidth = errors.New("hysteria2: up bandwidth needs Brutal congestion control, which is not supported")
+)
+
+const paddingChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// padding returns the random padding of the length in [64, 512).
+func padding() []byte {
+	b := make([]byte, 64+rand.Intn(512-64))
+	for i := range b {
+		b[i] = paddingChars[rand.Intn(len(paddingChars))]
+	}
+	return b
+}
+
+// AuthRequest is carried in the headers of the authentication request.
+type AuthRequest struct {
+	Auth string
+	// Rx is the max receive rate of the client in bytes per second, 0 means unknown.
+	Rx uint64
+}
+
+func (r *AuthRequest) WriteHeader(h http.Header) {
+	h.Set(HeaderAuth, r.Auth)
+	h.Set(HeaderCCRX, strconv.FormatUint(r.Rx, 10))
+	h.Set(HeaderPadding, string(padding()))
+}
+
+func (r *AuthRequest) ReadHeader(h http.Header) {
+	r.Auth = h.Get(HeaderAuth)
+	r.Rx, _ = strconv.ParseUint(h.Get(HeaderCCRX), 10, 64)
+}
+
+// AuthResponse is carried in the headers of the authentication response.
+type AuthResponse struct {
+	UDPEnabled bool
+	// Rx is the max receive rate of the server in bytes per second, 0 means unlimited.
+	Rx uint64
+	// RxAuto means the server asks the client to use the bandwidth detection.
+	RxAuto bool
+}
+
+func (r *AuthResponse) WriteHeader(h http.Header) {
+	h.Set(HeaderUDP, strconv.FormatBool(r.UDPEnabled))
+	if r.RxAuto {
+		h.Set(HeaderCCRX, "auto")
+	} else {
+		h.Set(HeaderCCRX, strconv.FormatUint(r.Rx, 10))
+	}
+	h.Set(HeaderPadding, string(padding()))
+}
+
+func (r *AuthResponse) ReadHeader(h http.Header) {
+	r.UDPEnabled, _ = strconv.ParseBool(h.Get(HeaderUDP))
+	if rx := h.Get(HeaderCCRX); rx == "auto" {
+		r.RxAuto = true
+	} else {
+		r.Rx, _ = strconv.ParseUint(rx, 10, 64)
+	}
+}
+
+// WriteTCPRequest writes the TCP request:
+//
+//	+--------------+----------+---------+-----------+---------+
+//	| 0x401 (VINT) | LEN VINT | ADDRESS | LEN VINT  | PADDING |
+//	+--------------+----------+---------+-----------+---------+
+func WriteTCPRequest(w io.Writer, addr string) error {
+	if len(addr) > MaxAddressLength {
+		return fmt.Errorf("hysteria2: address %s too long", addr)
+	}
+	pad := padding()
+
+	b := make([]byte, 0, 8+len(addr)+8+len(pad))
+	b = quicvarint.Append(b, FrameTypeTCPRequest)
+	b = quicvarint.Append(b, uint64(len(addr)))
+	b = append(b, addr...)
+	b = quicvarint.Append(b, uint64(len(pad)))
+	b = append(b, pad...)
+
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadTCPRequest reads the address of the TCP request, the frame type is read by the caller.
+func ReadTCPRequest(r io.Reader) (string, error) {
+	br := quicvarint.NewReader(r)
+
+	addr, err := readVarBytes(r, br, MaxAddressLength)
+	if err != nil {
+		return "", err
+	}
+	if len(addr) == 0 {
+		return "", ErrBadRequest
+	}
+	if _, err := readVarBytes(r, br, MaxPaddingLength); err != nil {
+		return "", err
+	}
+	return string(addr), nil
+}
+
+// WriteTCPResponse writes the TCP response:
+//
+//	+--------+----------+---------+----------+---------+
+//	| STATUS | LEN VINT | MESSAGE | LEN VINT | PADDING |
+//	+--------+----------+---------+----------+---------+
+//
+// The STATUS is 0x00 on success and 0x01 on error.
+func WriteTCPResponse(w io.Writer, ok bool, msg string) error {
+	if len(msg) > MaxMessageLength {
+		msg = msg[:MaxMessageLength]
+	}
+	pad := padding()
+
+	b := make([]byte, 0, 1+8+len(msg)+8+len(pad))
+	if ok {
+		b = append(b, 0x00)
+	} else {
+		b = append(b, 0x01)
+	}
+	b = quicvarint.Append(b, uint64(len(msg)))
+	b = append(b, msg...)
+	b = quicvarint.Append(b, uint64(len(pad)))
+	b = append(b, pad...)
+
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadTCPResponse reads the status and the message of the TCP response.
+func ReadTCPResponse(r io.Reader) (ok bool, msg string, err error) {
+	var status [1]byte
+	if _, err = io.ReadFull(r, status[:]); err != nil {
+		return
+	}
+
+	br := quicvarint.NewReader(r)
+	b, err := readVarBytes(r, br, MaxMessageLength)
+	if err != nil {
+		return
+	}
+	if _, err = readVarBytes(r, br, MaxPaddingLength); err != nil {
+		return
+	}
+	return status[0] == 0x00, string(b), nil
+}
+
+func readVarBytes(r io.Reader, br quicvarint.Reader, max int) ([]byte, error) {
+	n, err := quicvarint.Read(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, ErrBadRequest
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UDPMessage is the UDP packet carried in the QUIC datagram:
+//
+//	+------------+-----------+---------+-----------+----------+---------+---------+
+//	| SESSION ID | PACKET ID | FRAG ID | FRAG CNT  | LEN VINT | ADDRESS | PAYLOAD |
+//	+------------+-----------+---------+-----------+----------+---------+---------+
+//	|     4      |     2     |    1    |     1     | Variable | Variable| Variable|
+//	+------------+-----------+---------+-----------+----------+---------+---------+
+type UDPMessage struct {
+	SessionID uint32
+	PacketID  uint16
+	FragID    uint8
+	FragCount uint8
+	Addr      string
+	Data      []byte
+}
+
+func (m *UDPMessage) HeaderSize() int {
+	return 4 + 2 + 1 + 1 + int(quicvarint.Len(uint64(len(m.Addr)))) + len(m.Addr)
+}
+
+func (m *UDPMessage) Size() int {
+	return m.HeaderSize() + len(m.Data)
+}
+
+// Serialize writes the message into b, it returns -1 if b is too small.
+func (m *UDPMessage) Serialize(b []byte) int {
+	if len(b) < m.Size() {
+		return -1
+	}
+	binary.BigEndian.PutUint32(b, m.SessionID)
+	binary.BigEndian.PutUint16(b[4:], m.PacketID)
+	b[6] = m.FragID
+	b[7] = m.FragCount
+	n := len(quicvarint.Append(b[8:8], uint64(len(m.Addr))))
+	n += copy(b[8+n:], m.Addr)
+	n += copy(b[8+n:], m.Data)
+	return 8 + n
+}
+
+// ParseUDPMessage parses the message, the Data of the message refers to b.
+func ParseUDPMessage(b []byte) (*UDPMessage, error) {
+	if len(b) < 8 {
+		return nil, ErrBadRequest
+	}
+	m := &UDPMessage{
+		SessionID: binary.BigEndian.Uint32(b),
+		PacketID:  binary.BigEndian.Uint16(b[4:]),
+		FragID:    b[6],
+		FragCount: b[7],
+	}
+
+	r := bytes.NewReader(b[8:])
+	n, err := quicvarint.Read(r)
+	if err != nil {
+		return nil, ErrBadRequest
+	}
+	off := len(b) - r.Len()
+	if n == 0 || n > MaxAddressLength || uint64(len(b)-off) < n {
+		return nil, ErrBadRequest
+	}
+	m.Addr = string(b[off : off+int(n)])
+	m.Data = b[off+int(n):]
+	if m.FragCount == 0 || m.FragID >= m.FragCount {
+		return nil, ErrBadRequest
+	}
+	return m, nil
+}
+
+// FragUDPMessage splits the message into the fragments no larger than maxSize.
+// The message is returned as is if it fits.
+func FragUDPMessage(m *UDPMessage, maxSize int) []UDPMessage {
+	if m.Size() <= maxSize {
+		return []UDPMessage{*m}
+	}
+	data := m.Data
+	maxPayload := maxSize - m.HeaderSize()
+	if maxPayload <= 0 {
+		return nil
+	}
+	count := (len(data) + maxPayload - 1) / maxPayload
+	if count > 255 {
+		return nil
+	}
+
+	frags := make([]UDPMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * maxPayload
+		if end > len(data) {
+			end = len(data)
+		}
+		frag := *m
+		frag.FragID = uint8(i)
+		frag.FragCount = uint8(count)
+		frag.Data = data[i*maxPayload : end]
+		frags = append(frags, frag)
+	}
+	return frags
+}
+
+// Defragger reassembles the fragments of the messages,
+// only the fragments of the latest packet are kept.
+type Defragger struct {
+	packetID uint16
+	frags    []*UDPMessage
+	count    int
+	size     int
+}
+
+// Feed returns the reassembled message, or nil if the message is not complete.
+func (d *Defragger) Feed(m *UDPMessage) *UDPMessage {
+	if m.FragCount <= 1 {
+		return m
+	}
+	if m.PacketID != d.packetID || int(m.FragCount) != len(d.frags) {
+		d.packetID = m.PacketID
+		d.frags = make([]*UDPMessage, m.FragCount)
+		d.count = 0
+		d.size = 0
+	}
+	if d.frags[m.FragID] != nil {
+		return nil
+	}
+	d.frags[m.FragID] = m
+	d.count++
+	d.size += len(m.Data)
+	if d.count < len(d.frags) {
+		return nil
+	}
+
+	data := make([]byte, 0, d.size)
+	for _, frag := range d.frags {
+		data = append(data, frag.Data...)
+	}
+	msg := *m
+	msg.FragID = 0
+	msg.FragCount = 1
+	msg.Data = data
+
+	d.frags = nil
+	d.count = 0
+	d.size = 0
+	return &msg
+}
+
+// ParseBandwidth parses the bandwidth such as 100 mbps to bytes per second,
+// the units are bps, kbps, mbps, gbps and tbps, the number without unit is in bps.
+func ParseBandwidth(s string) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i:])
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("hysteria2: invalid bandwidth %s", s)
+	}
+
+	var bits float64
+	switch unit {
+	case "", "b", "bps":
+		bits = 1
+	case "k", "kb", "kbps":
+		bits = 1e3
+	case "m", "mb", "mbps":
+		bits = 1e6
+	case "g", "gb", "gbps":
+		bits = 1e9
+	case "t", "tb", "tbps":
+		bits = 1e12
+	default:
+		return 0, fmt.Errorf("hysteria2: invalid bandwidth %s", s)
+	}
+	return uint64(v * bits / 8), nil
+}
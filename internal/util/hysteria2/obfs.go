@@ -0,0 +1,81 @@
+package hysteria2
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	salamanderSaltLen = 8
+	// SalamanderMinPSKLen is the min length of the password of the Salamander obfuscation.
+	SalamanderMinPSKLen = 4
+)
+
+var (
+	ErrSalamanderPSK = errors.New("hysteria2: the password of salamander is too short")
+)
+
+type salamanderConn struct {
+	net.PacketConn
+	psk []byte
+}
+
+// SalamanderPacketConn wraps the connection with the Salamander obfuscation:
+//
+//	+------+----------------------------------------+
+//	| SALT | PAYLOAD ^ BLAKE2b-256(PASSWORD | SALT) |
+//	+------+----------------------------------------+
+//	|  8   |                Variable                |
+//	+------+----------------------------------------+
+func SalamanderPacketConn(conn net.PacketConn, psk []byte) (net.PacketConn, error) {
+	if len(psk) < SalamanderMinPSKLen {
+		return nil, ErrSalamanderPSK
+	}
+	return &salamanderConn{
+		PacketConn: conn,
+		psk:        psk,
+	}, nil
+}
+
+func (c *salamanderConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		// drop the packet which is too short
+		if n > salamanderSaltLen {
+			break
+		}
+	}
+
+	key := c.key(b[:salamanderSaltLen])
+	for i := 0; i < n-salamanderSaltLen; i++ {
+		b[i] = b[salamanderSaltLen+i] ^ key[i%len(key)]
+	}
+	return n - salamanderSaltLen, addr, nil
+}
+
+func (c *salamanderConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	buf := make([]byte, salamanderSaltLen+len(b))
+	if _, err = rand.Read(buf[:salamanderSaltLen]); err != nil {
+		return
+	}
+
+	key := c.key(buf[:salamanderSaltLen])
+	for i := range b {
+		buf[salamanderSaltLen+i] = b[i] ^ key[i%len(key)]
+	}
+
+	if _, err = c.PacketConn.WriteTo(buf, addr); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *salamanderConn) key(salt []byte) [blake2b.Size256]byte {
+	return blake2b.Sum256(append(append([]byte{}, c.psk...), salt...))
+}
@@ -0,0 +1,276 @@
+package hysteria2
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+var (
+	ErrUDPClosed   = errors.New("hysteria2: UDP session closed")
+	ErrUDPTooLarge = errors.New("hysteria2: UDP packet too large")
+)
+
+const (
+	udpQueueSize = 128
+)
+
+// UDPMux dispatches the UDP messages of the QUIC connection to the sessions.
+type UDPMux struct {
+	conn     quic.Connection
+	sessions map[uint32]*UDPConn
+	nextID   uint32
+	mu       sync.Mutex
+}
+
+func NewUDPMux(conn quic.Connection) *UDPMux {
+	return &UDPMux{
+		conn:     conn,
+		sessions: make(map[uint32]*UDPConn),
+	}
+}
+
+// Serve receives the datagrams until the QUIC connection is closed.
+// The session of the unknown ID is created and passed to accept on the server side,
+// the messages of the unknown ID are dropped if accept is nil.
+// The sessions idle for idleTimeout are closed if idleTimeout is positive.
+func (m *UDPMux) Serve(accept func(c *UDPConn), idleTimeout time.Duration) error {
+	defer m.closeAll()
+
+	if idleTimeout > 0 {
+		go m.checkIdle(idleTimeout)
+	}
+
+	for {
+		b, err := m.conn.ReceiveMessage()
+		if err != nil {
+			return err
+		}
+		msg, err := ParseUDPMessage(b)
+		if err != nil {
+			continue
+		}
+
+		m.mu.Lock()
+		c := m.sessions[msg.SessionID]
+		if c == nil && accept != nil {
+			c = m.newConn(msg.SessionID, nil)
+			m.mu.Unlock()
+			accept(c)
+		} else {
+			m.mu.Unlock()
+		}
+		if c != nil {
+			c.feed(msg)
+		}
+	}
+}
+
+// NewConn creates the client side session, the Write sends the packets to targetAddr.
+func (m *UDPMux) NewConn(targetAddr net.Addr) *UDPConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	return m.newConn(m.nextID, targetAddr)
+}
+
+func (m *UDPMux) newConn(id uint32, targetAddr net.Addr) *UDPConn {
+	c := &UDPConn{
+		mux:       m,
+		sessionID: id,
+		taddr:     targetAddr,
+		rch:       make(chan *UDPMessage, udpQueueSize),
+		closed:    make(chan struct{}),
+	}
+	c.touch()
+	m.sessions[id] = c
+	return c
+}
+
+func (m *UDPMux) remove(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+}
+
+func (m *UDPMux) checkIdle(idleTimeout time.Duration) {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var conns []*UDPConn
+			m.mu.Lock()
+			for _, c := range m.sessions {
+				if time.Since(time.Unix(0, atomic.LoadInt64(&c.active))) > idleTimeout {
+					conns = append(conns, c)
+				}
+			}
+			m.mu.Unlock()
+
+			for _, c := range conns {
+				c.Close()
+			}
+		case <-m.conn.Context().Done():
+			return
+		}
+	}
+}
+
+func (m *UDPMux) closeAll() {
+	m.mu.Lock()
+	var conns []*UDPConn
+	for _, c := range m.sessions {
+		conns = append(conns, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// UDPConn is the UDP session over the QUIC datagrams,
+// it is both the net.Conn with the target address on the client side and the net.PacketConn on the server side.
+type UDPConn struct {
+	mux       *UDPMux
+	sessionID uint32
+	taddr     net.Addr
+	user      string
+	packetID  uint32
+	active    int64
+	defragger Defragger
+	rch       chan *UDPMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *UDPConn) feed(msg *UDPMessage) {
+	if msg = c.defragger.Feed(msg); msg == nil {
+		return
+	}
+	c.touch()
+
+	select {
+	case c.rch <- msg:
+	case <-c.closed:
+	default: // drop the packet if the queue is full
+	}
+}
+
+func (c *UDPConn) touch() {
+	atomic.StoreInt64(&c.active, time.Now().UnixNano())
+}
+
+func (c *UDPConn) SessionID() uint32 {
+	return c.sessionID
+}
+
+// User returns the user authenticated by the server.
+func (c *UDPConn) User() string {
+	return c.user
+}
+
+func (c *UDPConn) SetUser(user string) {
+	c.user = user
+}
+
+// ReadFrom reads the next packet, the packet of the unresolvable address is dropped.
+func (c *UDPConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	for {
+		select {
+		case msg := <-c.rch:
+			raddr, err := net.ResolveUDPAddr("udp", msg.Addr)
+			if err != nil {
+				continue
+			}
+			return copy(b, msg.Data), raddr, nil
+		case <-c.closed:
+			return 0, nil, ErrUDPClosed
+		}
+	}
+}
+
+func (c *UDPConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, ErrUDPClosed
+	default:
+	}
+	if addr == nil {
+		return 0, errors.New("hysteria2: missing target address")
+	}
+	if len(b) > MaxUDPSize {
+		return 0, ErrUDPTooLarge
+	}
+
+	msg := &UDPMessage{
+		SessionID: c.sessionID,
+		PacketID:  uint16(atomic.AddUint32(&c.packetID, 1)),
+		FragCount: 1,
+		Addr:      addr.String(),
+		Data:      b,
+	}
+	frags := FragUDPMessage(msg, MaxDatagramSize)
+	if len(frags) == 0 {
+		return 0, ErrUDPTooLarge
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	for i := range frags {
+		nn := frags[i].Serialize(buf)
+		if err = c.mux.conn.SendMessage(buf[:nn]); err != nil {
+			return
+		}
+	}
+	c.touch()
+
+	return len(b), nil
+}
+
+func (c *UDPConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.taddr)
+}
+
+func (c *UDPConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mux.remove(c.sessionID)
+	})
+	return nil
+}
+
+func (c *UDPConn) LocalAddr() net.Addr {
+	return c.mux.conn.LocalAddr()
+}
+
+func (c *UDPConn) RemoteAddr() net.Addr {
+	if c.taddr != nil {
+		return c.taddr
+	}
+	return c.mux.conn.RemoteAddr()
+}
+
+func (c *UDPConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *UDPConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *UDPConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,393 @@
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+var (
+	ErrPoolExhausted = errors.New("ipam: address pool exhausted")
+	ErrInvalidClient = errors.New("ipam: invalid client")
+	ErrRevoked       = errors.New("ipam: lease revoked")
+)
+
+var (
+	// files are the pools persisted to the lease files, the pool created later with the same file takes over the leases,
+	// such as the pool of the updated service, so the file is written by one pool only.
+	files     = make(map[string]*Pool)
+	filesLock sync.Mutex
+)
+
+const (
+	DefaultLeaseTime = time.Hour
+)
+
+// Lease is the address assigned to the client.
+type Lease struct {
+	IP     net.IP    `json:"ip"`
+	Client string    `json:"client"`
+	Expire time.Time `json:"expire"`
+}
+
+type options struct {
+	leaseTime time.Duration
+	file      string
+	onRelease func(ip net.IP)
+	logger    logger.Logger
+}
+
+type Option func(*options)
+
+// LeaseTimeOption sets the duration of the leases.
+func LeaseTimeOption(d time.Duration) Option {
+	return func(o *options) {
+		o.leaseTime = d
+	}
+}
+
+// FileOption sets the file the leases are persisted to.
+func FileOption(file string) Option {
+	return func(o *options) {
+		o.file = file
+	}
+}
+
+// OnReleaseOption sets the callback which is called when the lease is revoked or reclaimed after it expires.
+func OnReleaseOption(fn func(ip net.IP)) Option {
+	return func(o *options) {
+		o.onRelease = fn
+	}
+}
+
+// LoggerOption sets the logger which reports the failures of persisting the leases.
+func LoggerOption(logger logger.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Pool assigns the addresses of the prefix to the clients,
+// each client holds at most one lease which is renewed by the client before it expires.
+type Pool struct {
+	prefix   netip.Prefix
+	reserved map[netip.Addr]bool
+	leases   map[netip.Addr]*Lease
+	clients  map[string]netip.Addr
+	// revoked are the clients whose leases are revoked, they are refused until the time.
+	revoked map[string]time.Time
+	// detached is set when the file is taken over by another pool or the pool is closed.
+	detached bool
+	mu       sync.Mutex
+	options  options
+}
+
+// NewPool creates the pool of the prefix, the leases are taken over from the open pool of the same file,
+// or restored from the file.
+func NewPool(prefix netip.Prefix, opts ...Option) (*Pool, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.leaseTime <= 0 {
+		options.leaseTime = DefaultLeaseTime
+	}
+
+	p := &Pool{
+		prefix:   prefix.Masked(),
+		reserved: make(map[netip.Addr]bool),
+		leases:   make(map[netip.Addr]*Lease),
+		clients:  make(map[string]netip.Addr),
+		revoked:  make(map[string]time.Time),
+		options:  options,
+	}
+	if options.file == "" {
+		return p, nil
+	}
+
+	filesLock.Lock()
+	defer filesLock.Unlock()
+
+	if old := files[options.file]; old != nil {
+		old.mu.Lock()
+		old.detached = true
+		p.restore(old.list())
+		for client, t := range old.revoked {
+			p.revoked[client] = t
+		}
+		old.mu.Unlock()
+	} else if err := p.load(); err != nil {
+		return nil, err
+	}
+	files[options.file] = p
+
+	return p, nil
+}
+
+// Close persists the leases and releases the file, the pool stops writing the file.
+func (p *Pool) Close() error {
+	if p.options.file == "" {
+		return nil
+	}
+
+	filesLock.Lock()
+	defer filesLock.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if files[p.options.file] == p {
+		p.save()
+		delete(files, p.options.file)
+	}
+	p.detached = true
+	return nil
+}
+
+// Prefix returns the prefix of the pool.
+func (p *Pool) Prefix() netip.Prefix {
+	return p.prefix
+}
+
+// Reserve excludes the addresses from the pool, such as the address of the server,
+// the leases of the addresses are released.
+func (p *Pool) Reserve(ips ...net.IP) {
+	var released []net.IP
+
+	p.mu.Lock()
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		if p.reserved[addr] {
+			continue
+		}
+		p.reserved[addr] = true
+		if lease := p.leases[addr]; lease != nil {
+			p.remove(addr)
+			released = append(released, lease.IP)
+		}
+	}
+	if len(released) > 0 {
+		p.save()
+	}
+	p.mu.Unlock()
+
+	p.release(released...)
+}
+
+// Contains reports whether the ip is in the pool.
+func (p *Pool) Contains(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	return ok && p.prefix.Contains(addr.Unmap())
+}
+
+// Allocate returns the lease of the client, the lease is renewed if the client holds one,
+// otherwise the first free address is assigned to the client.
+func (p *Pool) Allocate(client string) (Lease, error) {
+	if client == "" {
+		return Lease{}, ErrInvalidClient
+	}
+
+	p.mu.Lock()
+	lease, released, err := p.allocate(client)
+	p.mu.Unlock()
+
+	p.release(released...)
+	return lease, err
+}
+
+// allocate is called with the lock held, it also returns the expired leases reclaimed for the client.
+func (p *Pool) allocate(client string) (Lease, []net.IP, error) {
+	now := time.Now()
+
+	if t, ok := p.revoked[client]; ok {
+		if now.Before(t) {
+			return Lease{}, nil, ErrRevoked
+		}
+		delete(p.revoked, client)
+	}
+
+	if addr, ok := p.clients[client]; ok {
+		lease := p.leases[addr]
+		// the lease is persisted when half of the lease time elapses,
+		// so the frequent renewals do not write the file each time.
+		if lease.Expire.Sub(now) < p.options.leaseTime/2 {
+			lease.Expire = now.Add(p.options.leaseTime)
+			p.save()
+		}
+		return *lease, nil, nil
+	}
+
+	var released []net.IP
+	for addr := p.prefix.Addr().Next(); addr.IsValid() && p.prefix.Contains(addr); addr = addr.Next() {
+		if p.reserved[addr] || p.isBroadcast(addr) {
+			continue
+		}
+		if lease := p.leases[addr]; lease != nil {
+			if now.Before(lease.Expire) {
+				continue
+			}
+			p.remove(addr)
+			released = append(released, lease.IP)
+		}
+
+		lease := &Lease{
+			IP:     net.IP(addr.AsSlice()),
+			Client: client,
+			Expire: now.Add(p.options.leaseTime),
+		}
+		p.leases[addr] = lease
+		p.clients[client] = addr
+		p.save()
+
+		return *lease, released, nil
+	}
+
+	return Lease{}, released, ErrPoolExhausted
+}
+
+// Leases returns the leases ordered by the address.
+func (p *Pool) Leases() []Lease {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.list()
+}
+
+// Revoke releases the lease of the ip, it reports whether the lease is found.
+// The client of the lease is refused for the lease time, so it can not obtain a new lease by the next renewal.
+func (p *Pool) Revoke(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+
+	p.mu.Lock()
+	lease := p.leases[addr]
+	if lease != nil {
+		p.remove(addr)
+		p.revoked[lease.Client] = time.Now().Add(p.options.leaseTime)
+		p.save()
+	}
+	p.mu.Unlock()
+
+	if lease == nil {
+		return false
+	}
+	p.release(lease.IP)
+	return true
+}
+
+func (p *Pool) release(ips ...net.IP) {
+	if p.options.onRelease == nil {
+		return
+	}
+	for _, ip := range ips {
+		p.options.onRelease(ip)
+	}
+}
+
+func (p *Pool) isBroadcast(addr netip.Addr) bool {
+	if !addr.Is4() || p.prefix.Bits() >= 31 {
+		return false
+	}
+	return !p.prefix.Contains(addr.Next())
+}
+
+func (p *Pool) remove(addr netip.Addr) {
+	if lease := p.leases[addr]; lease != nil {
+		delete(p.clients, lease.Client)
+	}
+	delete(p.leases, addr)
+}
+
+func (p *Pool) list() []Lease {
+	leases := make([]Lease, 0, len(p.leases))
+	for _, lease := range p.leases {
+		leases = append(leases, *lease)
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		a, _ := netip.AddrFromSlice(leases[i].IP)
+		b, _ := netip.AddrFromSlice(leases[j].IP)
+		return a.Less(b)
+	})
+	return leases
+}
+
+func (p *Pool) load() error {
+	if p.options.file == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(p.options.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var leases []Lease
+	if err := json.Unmarshal(b, &leases); err != nil {
+		return err
+	}
+	p.restore(leases)
+	return nil
+}
+
+// restore adds the unexpired leases of the prefix.
+func (p *Pool) restore(leases []Lease) {
+	now := time.Now()
+	for i := range leases {
+		lease := &leases[i]
+		addr, ok := netip.AddrFromSlice(lease.IP)
+		if !ok || lease.Client == "" || !now.Before(lease.Expire) {
+			continue
+		}
+		addr = addr.Unmap()
+		if !p.prefix.Contains(addr) || p.reserved[addr] {
+			continue
+		}
+		if _, ok := p.clients[lease.Client]; ok {
+			continue
+		}
+		lease.IP = net.IP(addr.AsSlice())
+		p.leases[addr] = lease
+		p.clients[lease.Client] = addr
+	}
+}
+
+// save writes the leases to the file, it is called with the lock held.
+// The file is replaced atomically, so it is never left partially written.
+func (p *Pool) save() {
+	if p.options.file == "" || p.detached {
+		return
+	}
+
+	err := func() error {
+		b, err := json.MarshalIndent(p.list(), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		tmp := p.options.file + ".tmp"
+		if err := os.WriteFile(tmp, b, 0600); err != nil {
+			return err
+		}
+		return os.Rename(tmp, p.options.file)
+	}()
+	if err != nil && p.options.logger != nil {
+		p.options.logger.Errorf("ipam: save leases to %s: %v", p.options.file, err)
+	}
+}
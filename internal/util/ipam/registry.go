@@ -0,0 +1,38 @@
+package ipam
+
+import (
+	"sync"
+)
+
+var pools sync.Map
+
+// Register registers the pool of the service, so the leases can be managed by the API.
+func Register(service string, p *Pool) {
+	if service == "" || p == nil {
+		return
+	}
+	pools.Store(service, p)
+}
+
+// Unregister removes the pool of the service if it is still registered.
+func Unregister(service string, p *Pool) {
+	pools.CompareAndDelete(service, p)
+}
+
+// Get returns the pool of the service.
+func Get(service string) *Pool {
+	if v, ok := pools.Load(service); ok {
+		return v.(*Pool)
+	}
+	return nil
+}
+
+// GetAll returns the pools of all the services.
+func GetAll() map[string]*Pool {
+	m := make(map[string]*Pool)
+	pools.Range(func(key, value any) bool {
+		m[key.(string)] = value.(*Pool)
+		return true
+	})
+	return m
+}
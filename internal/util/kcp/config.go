@@ -4,7 +4,9 @@ import (
 	"crypto/sha1"
 	"encoding/json"
 	"os"
+	"time"
 
+	"github.com/wznpp1/gost_x/internal/util/mux"
 	"github.com/xtaci/kcp-go/v5"
 	"golang.org/x/crypto/pbkdf2"
 )
@@ -66,6 +68,7 @@ type Config struct {
 	StreamBuf    int    `json:"streambuf"`
 	SmuxVer      int    `json:"smuxver"`
 	KeepAlive    int    `json:"keepalive"`
+	Mux          string `json:"mux"` // Mux is the stream multiplexer, smux (default) or yamux.
 	SnmpLog      string `json:"snmplog"`
 	SnmpPeriod   int    `json:"snmpperiod"`
 	Signal       bool   `json:"signal"` // Signal enables the signal SIGUSR1 feature.
@@ -112,6 +115,17 @@ func (c *Config) Init() {
 	}
 }
 
+// MuxConfig returns the config of the stream multiplexer.
+func (c *Config) MuxConfig() *mux.Config {
+	return &mux.Config{
+		Type:              c.Mux,
+		Version:           c.SmuxVer,
+		KeepAliveInterval: time.Duration(c.KeepAlive) * time.Second,
+		MaxReceiveBuffer:  c.SmuxBuf,
+		MaxStreamBuffer:   c.StreamBuf,
+	}
+}
+
 func BlockCrypt(key, crypt, salt string) (block kcp.BlockCrypt) {
 	pass := pbkdf2.Key([]byte(key), []byte(salt), 4096, 32, sha1.New)
 
@@ -0,0 +1,31 @@
+package mux
+
+import (
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+// ParseConfig reads the mux config from the metadata of the component which multiplexes the streams.
+func ParseConfig(md mdata.Metadata) *Config {
+	const (
+		muxType              = "mux"
+		muxVersion           = "muxVersion"
+		muxKeepAliveDisabled = "muxKeepAliveDisabled"
+		muxKeepAliveInterval = "muxKeepAliveInterval"
+		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
+		muxMaxFrameSize      = "muxMaxFrameSize"
+		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
+		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+	)
+
+	return &Config{
+		Type:              mdutil.GetString(md, muxType),
+		Version:           mdutil.GetInt(md, muxVersion),
+		KeepAliveDisabled: mdutil.GetBool(md, muxKeepAliveDisabled),
+		KeepAliveInterval: mdutil.GetDuration(md, muxKeepAliveInterval),
+		KeepAliveTimeout:  mdutil.GetDuration(md, muxKeepAliveTimeout),
+		MaxFrameSize:      mdutil.GetInt(md, muxMaxFrameSize),
+		MaxReceiveBuffer:  mdutil.GetInt(md, muxMaxReceiveBuffer),
+		MaxStreamBuffer:   mdutil.GetInt(md, muxMaxStreamBuffer),
+	}
+}
@@ -1,29 +1,75 @@
 package mux
 
 import (
+	"fmt"
 	"net"
+	"time"
+)
 
-	smux "github.com/xtaci/smux"
+const (
+	TypeSmux  = "smux"
+	TypeYamux = "yamux"
 )
 
+// Config is the stream multiplexing config shared by the mux backends,
+// the zero values leave the defaults of the backend.
+type Config struct {
+	// Type is the backend, smux (default) or yamux.
+	Type string
+	// Version is the smux protocol version, 1 or 2.
+	Version int
+	// KeepAliveDisabled disables the keepalive of the session.
+	KeepAliveDisabled bool
+	// KeepAliveInterval is the interval of the keepalive pings.
+	KeepAliveInterval time.Duration
+	// KeepAliveTimeout closes the session if no data is received in it,
+	// it is the write timeout of the connection for yamux.
+	KeepAliveTimeout time.Duration
+	// MaxFrameSize is the maximum size of the frame sent to the peer, smux only.
+	MaxFrameSize int
+	// MaxReceiveBuffer is the receive buffer of the session, smux only.
+	MaxReceiveBuffer int
+	// MaxStreamBuffer is the receive buffer (window) of each stream.
+	MaxStreamBuffer int
+}
+
+// session is implemented by the mux backends.
+type session interface {
+	OpenStream() (net.Conn, error)
+	AcceptStream() (net.Conn, error)
+	Close() error
+	IsClosed() bool
+	NumStreams() int
+}
+
 type Session struct {
 	conn    net.Conn
-	session *smux.Session
+	session session
 }
 
-func ClientSession(conn net.Conn) (*Session, error) {
-	s, err := smux.Client(conn, smux.DefaultConfig())
-	if err != nil {
-		return nil, err
-	}
-	return &Session{
-		conn:    conn,
-		session: s,
-	}, nil
+func ClientSession(conn net.Conn, cfg *Config) (*Session, error) {
+	return newSession(conn, cfg, true)
 }
 
-func ServerSession(conn net.Conn) (*Session, error) {
-	s, err := smux.Server(conn, smux.DefaultConfig())
+func ServerSession(conn net.Conn, cfg *Config) (*Session, error) {
+	return newSession(conn, cfg, false)
+}
+
+func newSession(conn net.Conn, cfg *Config, client bool) (*Session, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	var s session
+	var err error
+	switch cfg.Type {
+	case "", TypeSmux:
+		s, err = newSmuxSession(conn, cfg, client)
+	case TypeYamux:
+		s, err = newYamuxSession(conn, cfg, client)
+	default:
+		err = fmt.Errorf("mux: unknown type %s", cfg.Type)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -67,9 +113,11 @@ func (session *Session) NumStreams() int {
 	return session.session.NumStreams()
 }
 
+// streamConn reports the addresses of the underlying connection,
+// the deadlines are applied to the stream only.
 type streamConn struct {
 	net.Conn
-	stream *smux.Stream
+	stream net.Conn
 }
 
 func (c *streamConn) Read(b []byte) (n int, err error) {
@@ -83,3 +131,15 @@ func (c *streamConn) Write(b []byte) (n int, err error) {
 func (c *streamConn) Close() error {
 	return c.stream.Close()
 }
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return c.stream.SetDeadline(t)
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
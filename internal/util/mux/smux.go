@@ -0,0 +1,57 @@
+package mux
+
+import (
+	"net"
+
+	smux "github.com/xtaci/smux"
+)
+
+type smuxSession struct {
+	*smux.Session
+}
+
+func newSmuxSession(conn net.Conn, cfg *Config, client bool) (session, error) {
+	smuxConfig := smux.DefaultConfig()
+	if cfg.Version > 0 {
+		smuxConfig.Version = cfg.Version
+	}
+	smuxConfig.KeepAliveDisabled = cfg.KeepAliveDisabled
+	if cfg.KeepAliveInterval > 0 {
+		smuxConfig.KeepAliveInterval = cfg.KeepAliveInterval
+	}
+	if cfg.KeepAliveTimeout > 0 {
+		smuxConfig.KeepAliveTimeout = cfg.KeepAliveTimeout
+	}
+	if cfg.MaxFrameSize > 0 {
+		smuxConfig.MaxFrameSize = cfg.MaxFrameSize
+	}
+	if cfg.MaxReceiveBuffer > 0 {
+		smuxConfig.MaxReceiveBuffer = cfg.MaxReceiveBuffer
+	}
+	if cfg.MaxStreamBuffer > 0 {
+		smuxConfig.MaxStreamBuffer = cfg.MaxStreamBuffer
+	}
+	if err := smux.VerifyConfig(smuxConfig); err != nil {
+		return nil, err
+	}
+
+	var s *smux.Session
+	var err error
+	if client {
+		s, err = smux.Client(conn, smuxConfig)
+	} else {
+		s, err = smux.Server(conn, smuxConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &smuxSession{Session: s}, nil
+}
+
+func (s *smuxSession) OpenStream() (net.Conn, error) {
+	return s.Session.OpenStream()
+}
+
+func (s *smuxSession) AcceptStream() (net.Conn, error) {
+	return s.Session.AcceptStream()
+}
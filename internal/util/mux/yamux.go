@@ -0,0 +1,50 @@
+package mux
+
+import (
+	"io"
+	"net"
+
+	"github.com/hashicorp/yamux"
+)
+
+type yamuxSession struct {
+	*yamux.Session
+}
+
+func newYamuxSession(conn net.Conn, cfg *Config, client bool) (session, error) {
+	yamuxConfig := yamux.DefaultConfig()
+	yamuxConfig.LogOutput = io.Discard
+	yamuxConfig.EnableKeepAlive = !cfg.KeepAliveDisabled
+	if cfg.KeepAliveInterval > 0 {
+		yamuxConfig.KeepAliveInterval = cfg.KeepAliveInterval
+	}
+	if cfg.KeepAliveTimeout > 0 {
+		yamuxConfig.ConnectionWriteTimeout = cfg.KeepAliveTimeout
+	}
+	if cfg.MaxStreamBuffer > 0 {
+		yamuxConfig.MaxStreamWindowSize = uint32(cfg.MaxStreamBuffer)
+	}
+	if err := yamux.VerifyConfig(yamuxConfig); err != nil {
+		return nil, err
+	}
+
+	var s *yamux.Session
+	var err error
+	if client {
+		s, err = yamux.Client(conn, yamuxConfig)
+	} else {
+		s, err = yamux.Server(conn, yamuxConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &yamuxSession{Session: s}, nil
+}
+
+func (s *yamuxSession) OpenStream() (net.Conn, error) {
+	return s.Session.Open()
+}
+
+func (s *yamuxSession) AcceptStream() (net.Conn, error) {
+	return s.Session.Accept()
+}
@@ -0,0 +1,522 @@
+package noise
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flynn/noise"
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/logger"
+)
+
+// Config is the config of the secure connection.
+type Config struct {
+	// PrivateKey is the static private key of the local peer.
+	PrivateKey []byte
+	// PeerKey is the static public key of the responder, the handshake is initiated to it by the writes.
+	PeerKey []byte
+	// AllowedKeys are the static public keys of the initiators accepted by the responder.
+	AllowedKeys [][]byte
+	// AllowAnyPeer accepts the initiators of any key, the AllowedKeys are ignored.
+	// The handshakes of the initiators are rejected if neither is set.
+	AllowAnyPeer bool
+	Logger       logger.Logger
+}
+
+type transport interface {
+	readFrom(b []byte) (int, net.Addr, error)
+	writeTo(b []byte, addr net.Addr) (int, error)
+}
+
+// secureConn is the Noise IK protocol on the transport, each packet is encrypted by the session of the peer.
+type secureConn struct {
+	tr         transport
+	static     noise.DHKey
+	responder  *peer
+	allowAny   bool
+	allowed    map[[KeyLen]byte]bool
+	peers      map[[KeyLen]byte]*peer
+	pruned     time.Time
+	keypairs   map[uint32]*keypair
+	handshakes map[uint32]*peer
+	mu         sync.Mutex
+	logger     logger.Logger
+}
+
+func newSecureConn(tr transport, config *Config) (*secureConn, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	pub, err := PublicKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &secureConn{
+		tr: tr,
+		static: noise.DHKey{
+			Private: config.PrivateKey,
+			Public:  pub,
+		},
+		allowAny:   config.AllowAnyPeer,
+		allowed:    make(map[[KeyLen]byte]bool),
+		peers:      make(map[[KeyLen]byte]*peer),
+		keypairs:   make(map[uint32]*keypair),
+		handshakes: make(map[uint32]*peer),
+		logger:     config.Logger,
+	}
+	if c.logger == nil {
+		c.logger = logger.Default()
+	}
+
+	for _, key := range config.AllowedKeys {
+		if len(key) != KeyLen {
+			return nil, ErrInvalidKey
+		}
+		var k [KeyLen]byte
+		copy(k[:], key)
+		c.allowed[k] = true
+	}
+
+	if config.PeerKey != nil {
+		if len(config.PeerKey) != KeyLen {
+			return nil, ErrInvalidKey
+		}
+		c.responder = newPeer(config.PeerKey, true)
+		c.peers[c.responder.key] = c.responder
+	}
+
+	return c, nil
+}
+
+// read returns the next data packet, the handshakes are handled in it.
+func (c *secureConn) read(b []byte) (int, *peer, error) {
+	buf := bufpool.Get(maxPacketSize)
+	defer bufpool.Put(buf)
+
+	for {
+		n, addr, err := c.tr.readFrom(*buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n < initiationHeaderLen {
+			continue
+		}
+
+		p := (*buf)[:n]
+		switch p[0] {
+		case msgTypeInitiation:
+			c.handleInitiation(p, addr)
+		case msgTypeResponse:
+			c.handleResponse(p, addr)
+		case msgTypeData:
+			n, peer := c.handleData(p, addr, b)
+			// the empty packet is the keepalive of the session.
+			if peer != nil && n > 0 {
+				return n, peer, nil
+			}
+		}
+	}
+}
+
+func (c *secureConn) handleInitiation(p []byte, addr net.Addr) {
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   cipherSuite,
+		Pattern:       noise.HandshakeIK,
+		Prologue:      prologue,
+		StaticKeypair: c.static,
+	})
+	if err != nil {
+		return
+	}
+
+	senderIndex := binary.LittleEndian.Uint32(p[4:8])
+	payload, _, _, err := hs.ReadMessage(nil, p[initiationHeaderLen:])
+	if err != nil || len(payload) != timestampLen {
+		c.logger.Debugf("noise: invalid handshake from %s", addr)
+		return
+	}
+
+	var key [KeyLen]byte
+	copy(key[:], hs.PeerStatic())
+	if !c.allowAny && !c.allowed[key] {
+		c.logger.Warnf("noise: handshake from %s of unknown peer %s", addr, EncodeKey(key[:]))
+		return
+	}
+
+	ts := binary.BigEndian.Uint64(payload)
+
+	c.mu.Lock()
+	peer := c.peers[key]
+	if peer == nil {
+		// the timestamp of the pruned peer is lost, so the stale handshake of the unknown peer is rejected as replayed.
+		if time.Unix(0, int64(ts)).Before(time.Now().Add(-peerTimeout)) {
+			c.mu.Unlock()
+			c.logger.Debugf("noise: stale handshake from %s", addr)
+			return
+		}
+		c.prune()
+		peer = newPeer(key[:], false)
+		c.peers[key] = peer
+	}
+	if ts > peer.timestamp {
+		peer.timestamp = ts
+	} else {
+		c.mu.Unlock()
+		c.logger.Debugf("noise: replayed handshake from %s", addr)
+		return
+	}
+
+	index := c.newIndex()
+	msg := make([]byte, responseHeaderLen, responseHeaderLen+64)
+	putHeader(msg, msgTypeResponse, index, senderIndex)
+	msg, cs1, cs2, err := hs.WriteMessage(msg, nil)
+	if err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.rotate(peer, &keypair{
+		peer:        peer,
+		localIndex:  index,
+		remoteIndex: senderIndex,
+		send:        cs2.Cipher(),
+		recv:        cs1.Cipher(),
+		created:     time.Now(),
+	})
+	peer.setEndpoint(addr)
+	c.mu.Unlock()
+
+	c.tr.writeTo(msg, addr)
+}
+
+func (c *secureConn) handleResponse(p []byte, addr net.Addr) {
+	if len(p) < responseHeaderLen {
+		return
+	}
+	senderIndex := binary.LittleEndian.Uint32(p[4:8])
+	receiverIndex := binary.LittleEndian.Uint32(p[8:12])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peer := c.handshakes[receiverIndex]
+	if peer == nil || peer.handshake == nil || peer.handshake.index != receiverIndex {
+		return
+	}
+	hs := peer.handshake
+
+	_, cs1, cs2, err := hs.state.ReadMessage(nil, p[responseHeaderLen:])
+	if err != nil {
+		c.logger.Debugf("noise: invalid handshake response from %s", addr)
+		return
+	}
+	delete(c.handshakes, receiverIndex)
+	peer.handshake = nil
+
+	c.rotate(peer, &keypair{
+		peer:        peer,
+		localIndex:  receiverIndex,
+		remoteIndex: senderIndex,
+		send:        cs1.Cipher(),
+		recv:        cs2.Cipher(),
+		created:     time.Now(),
+	})
+	peer.setEndpoint(addr)
+	close(hs.done)
+}
+
+// handleData decrypts the data packet into b, the endpoint of the peer is updated by the authenticated packet.
+func (c *secureConn) handleData(p []byte, addr net.Addr, b []byte) (int, *peer) {
+	if len(p) < dataHeaderLen+tagLen || len(p)-dataHeaderLen-tagLen > len(b) {
+		return 0, nil
+	}
+	receiverIndex := binary.LittleEndian.Uint32(p[4:8])
+	counter := binary.LittleEndian.Uint64(p[8:16])
+
+	c.mu.Lock()
+	kp := c.keypairs[receiverIndex]
+	c.mu.Unlock()
+	if kp == nil || time.Since(kp.created) > rejectAfterTime {
+		return 0, nil
+	}
+
+	data, err := kp.recv.Decrypt(b[:0], counter, p[:dataHeaderLen], p[dataHeaderLen:])
+	if err != nil {
+		return 0, nil
+	}
+
+	kp.replayMu.Lock()
+	ok := kp.replay.validate(counter)
+	kp.replayMu.Unlock()
+	if !ok {
+		return 0, nil
+	}
+
+	if kp.peer.setEndpoint(addr) {
+		c.logger.Debugf("noise: peer %s roams to %s", EncodeKey(kp.peer.key[:]), addr)
+	}
+	return len(data), kp.peer
+}
+
+// write encrypts the packet by the current session of the peer,
+// the handshake is initiated if the local peer is the initiator and the session is absent or old.
+func (c *secureConn) write(b []byte, peer *peer) (int, error) {
+	kp, err := c.keypair(peer)
+	if err != nil {
+		return 0, err
+	}
+
+	counter := atomic.AddUint64(&kp.sendCounter, 1) - 1
+	buf := bufpool.Get(dataHeaderLen + len(b) + tagLen)
+	defer bufpool.Put(buf)
+
+	putHeader(*buf, msgTypeData, kp.remoteIndex)
+	binary.LittleEndian.PutUint64((*buf)[8:16], counter)
+	msg := kp.send.Encrypt((*buf)[:dataHeaderLen], counter, (*buf)[:dataHeaderLen], b)
+
+	if _, err := c.tr.writeTo(msg, peer.getEndpoint()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *secureConn) keypair(peer *peer) (*keypair, error) {
+	c.mu.Lock()
+	kp := peer.current
+	if kp != nil && !kp.expired() {
+		if peer.initiator && time.Since(kp.created) > rekeyAfterTime {
+			c.initiate(peer)
+		}
+		c.mu.Unlock()
+		return kp, nil
+	}
+	c.mu.Unlock()
+
+	if !peer.initiator {
+		return nil, ErrNoSession
+	}
+
+	for i := 0; i < handshakeRetries; i++ {
+		c.mu.Lock()
+		done := c.initiate(peer)
+		c.mu.Unlock()
+
+		select {
+		case <-done:
+			c.mu.Lock()
+			kp := peer.current
+			c.mu.Unlock()
+			return kp, nil
+		case <-time.After(handshakeRetryTimeout):
+		}
+	}
+	return nil, ErrHandshakeTimeout
+}
+
+// initiate sends the handshake initiation to the peer if there is no pending one,
+// it returns the channel closed when the session is established.
+// It is called with the mutex locked.
+func (c *secureConn) initiate(peer *peer) <-chan struct{} {
+	done := make(chan struct{})
+	if hs := peer.handshake; hs != nil {
+		if time.Since(hs.sent) < handshakeRetryTimeout {
+			return hs.done
+		}
+		delete(c.handshakes, hs.index)
+		done = hs.done
+	}
+
+	state, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   cipherSuite,
+		Pattern:       noise.HandshakeIK,
+		Initiator:     true,
+		Prologue:      prologue,
+		StaticKeypair: c.static,
+		PeerStatic:    peer.key[:],
+	})
+	if err != nil {
+		c.logger.Error(err)
+		return done
+	}
+
+	index := c.newIndex()
+	var ts [timestampLen]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixNano()))
+
+	msg := make([]byte, initiationHeaderLen, initiationHeaderLen+128)
+	putHeader(msg, msgTypeInitiation, index)
+	msg, _, _, err = state.WriteMessage(msg, ts[:])
+	if err != nil {
+		c.logger.Error(err)
+		return done
+	}
+
+	peer.handshake = &handshake{
+		state: state,
+		index: index,
+		sent:  time.Now(),
+		done:  done,
+	}
+	c.handshakes[index] = peer
+
+	if endpoint := peer.getEndpoint(); endpoint != nil {
+		go c.tr.writeTo(msg, endpoint)
+	}
+	return done
+}
+
+// rotate makes the keypair the current session of the peer, the previous one is kept for the packets in flight.
+// It is called with the mutex locked.
+func (c *secureConn) rotate(peer *peer, kp *keypair) {
+	if peer.previous != nil {
+		delete(c.keypairs, peer.previous.localIndex)
+	}
+	peer.previous = peer.current
+	peer.current = kp
+	c.keypairs[kp.localIndex] = kp
+}
+
+// prune removes the initiators without session for twice the peerTimeout,
+// so the peer of the stale handshake is always pruned before the handshake is accepted as a new peer.
+// It is called with the mutex locked.
+func (c *secureConn) prune() {
+	if time.Since(c.pruned) < peerTimeout {
+		return
+	}
+	c.pruned = time.Now()
+
+	for key, peer := range c.peers {
+		if peer.initiator || peer.handshake != nil ||
+			(peer.current != nil && time.Since(peer.current.created) < 2*peerTimeout) {
+			continue
+		}
+		if peer.current != nil {
+			delete(c.keypairs, peer.current.localIndex)
+		}
+		if peer.previous != nil {
+			delete(c.keypairs, peer.previous.localIndex)
+		}
+		delete(c.peers, key)
+	}
+}
+
+// newIndex returns a random unused index of the sessions, it is called with the mutex locked.
+func (c *secureConn) newIndex() uint32 {
+	var b [4]byte
+	for {
+		rand.Read(b[:])
+		index := binary.LittleEndian.Uint32(b[:])
+		if _, ok := c.keypairs[index]; ok {
+			continue
+		}
+		if _, ok := c.handshakes[index]; ok {
+			continue
+		}
+		return index
+	}
+}
+
+type packetConn struct {
+	net.PacketConn
+	sc *secureConn
+}
+
+// PacketConn secures the packets of pc by the Noise IK protocol.
+// The ReadFrom returns the *PeerAddr of the peer, the packets written to it follow the roaming peer.
+// The WriteTo to the other address initiates the handshake to the responder of the PeerKey.
+// The responder without the PeerKey requires the AllowedKeys or the AllowAnyPeer.
+func PacketConn(pc net.PacketConn, config *Config) (net.PacketConn, error) {
+	c := &packetConn{
+		PacketConn: pc,
+	}
+	if config == nil || (config.PeerKey == nil && len(config.AllowedKeys) == 0 && !config.AllowAnyPeer) {
+		return nil, ErrNoAllowedPeer
+	}
+
+	sc, err := newSecureConn(c, config)
+	if err != nil {
+		return nil, err
+	}
+	c.sc = sc
+	return c, nil
+}
+
+func (c *packetConn) readFrom(b []byte) (int, net.Addr, error) {
+	return c.PacketConn.ReadFrom(b)
+}
+
+func (c *packetConn) writeTo(b []byte, addr net.Addr) (int, error) {
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func (c *packetConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, peer, err := c.sc.read(b)
+	if err != nil {
+		return
+	}
+	return n, peer.addr, nil
+}
+
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if addr, ok := addr.(*PeerAddr); ok {
+		return c.sc.write(b, addr.peer)
+	}
+
+	peer := c.sc.responder
+	if peer == nil {
+		return 0, ErrUnknownPeer
+	}
+	if addr == nil {
+		return 0, errors.New("noise: missing address")
+	}
+	peer.setEndpoint(addr)
+	return c.sc.write(b, peer)
+}
+
+type conn struct {
+	net.Conn
+	sc *secureConn
+}
+
+// Conn secures the packets of the connected conn by the Noise IK protocol,
+// the handshake is initiated to the responder of the PeerKey by the writes.
+func Conn(c net.Conn, config *Config) (net.Conn, error) {
+	if config == nil || config.PeerKey == nil {
+		return nil, ErrUnknownPeer
+	}
+
+	cc := &conn{
+		Conn: c,
+	}
+	sc, err := newSecureConn(cc, config)
+	if err != nil {
+		return nil, err
+	}
+	sc.responder.setEndpoint(c.RemoteAddr())
+	cc.sc = sc
+	return cc, nil
+}
+
+func (c *conn) readFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Conn.Read(b)
+	return n, c.Conn.RemoteAddr(), err
+}
+
+func (c *conn) writeTo(b []byte, addr net.Addr) (int, error) {
+	return c.Conn.Write(b)
+}
+
+func (c *conn) Read(b []byte) (n int, err error) {
+	n, _, err = c.sc.read(b)
+	return
+}
+
+func (c *conn) Write(b []byte) (n int, err error) {
+	return c.sc.write(b, c.sc.responder)
+}
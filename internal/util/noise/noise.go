@@ -0,0 +1,198 @@
+package noise
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flynn/noise"
+	"golang.org/x/crypto/curve25519"
+)
+
+var (
+	ErrInvalidKey       = errors.New("noise: invalid key")
+	ErrUnknownPeer      = errors.New("noise: unknown peer")
+	ErrNoAllowedPeer    = errors.New("noise: no allowed peer")
+	ErrNoSession        = errors.New("noise: no session")
+	ErrHandshakeTimeout = errors.New("noise: handshake timeout")
+)
+
+const (
+	KeyLen = 32
+
+	msgTypeInitiation = 1
+	msgTypeResponse   = 2
+	msgTypeData       = 4
+
+	// type(1) + reserved(3) + sender index(4)
+	initiationHeaderLen = 8
+	// type(1) + reserved(3) + sender index(4) + receiver index(4)
+	responseHeaderLen = 12
+	// type(1) + reserved(3) + receiver index(4) + counter(8)
+	dataHeaderLen = 16
+	tagLen        = 16
+	timestampLen  = 8
+
+	// Overhead is the bytes added to each packet.
+	Overhead = dataHeaderLen + tagLen
+
+	maxPacketSize = 65535
+
+	rekeyAfterTime        = 120 * time.Second
+	rejectAfterTime       = 180 * time.Second
+	rejectAfterMessages   = math.MaxUint64 - (1 << 13)
+	handshakeRetryTimeout = 5 * time.Second
+	handshakeRetries      = 3
+	// peerTimeout is the age of the handshake accepted from the unknown initiator,
+	// the initiators without session are pruned after twice the time.
+	peerTimeout = 10 * time.Minute
+)
+
+var (
+	cipherSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashBLAKE2s)
+	prologue    = []byte("gost noise IK v1")
+)
+
+// GenerateKey generates a static private key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseKey decodes the base64 encoded key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != KeyLen {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// EncodeKey encodes the key in base64.
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// PublicKey returns the public key of the private key.
+func PublicKey(privateKey []byte) ([]byte, error) {
+	if len(privateKey) != KeyLen {
+		return nil, ErrInvalidKey
+	}
+	return curve25519.X25519(privateKey, curve25519.Basepoint)
+}
+
+// PeerAddr is the address of the peer identified by its static public key,
+// the packets written to it are sent to the latest endpoint of the peer, so the peer can roam.
+type PeerAddr struct {
+	peer *peer
+}
+
+func (a *PeerAddr) Network() string {
+	return "udp"
+}
+
+func (a *PeerAddr) String() string {
+	if endpoint := a.peer.getEndpoint(); endpoint != nil {
+		return endpoint.String()
+	}
+	return ""
+}
+
+// PublicKey returns the static public key of the peer.
+func (a *PeerAddr) PublicKey() []byte {
+	return a.peer.key[:]
+}
+
+type peer struct {
+	key       [KeyLen]byte
+	initiator bool
+	endpoint  atomic.Value
+	addr      *PeerAddr
+
+	// the following fields are guarded by the mutex of the conn.
+	current   *keypair
+	previous  *keypair
+	handshake *handshake
+	// timestamp is the latest handshake timestamp of the initiator, the older handshakes are replayed.
+	timestamp uint64
+}
+
+func newPeer(key []byte, initiator bool) *peer {
+	p := &peer{
+		initiator: initiator,
+	}
+	copy(p.key[:], key)
+	p.addr = &PeerAddr{peer: p}
+	return p
+}
+
+func (p *peer) getEndpoint() net.Addr {
+	v, _ := p.endpoint.Load().(net.Addr)
+	return v
+}
+
+// setEndpoint updates the endpoint, it returns true if the endpoint is changed.
+func (p *peer) setEndpoint(addr net.Addr) bool {
+	if old := p.getEndpoint(); old != nil && old.String() == addr.String() {
+		return false
+	}
+	p.endpoint.Store(addr)
+	return true
+}
+
+type handshake struct {
+	state *noise.HandshakeState
+	index uint32
+	sent  time.Time
+	// done is closed when the session is established, it is shared by the retries.
+	done chan struct{}
+}
+
+type keypair struct {
+	// sendCounter is the first field for the 64-bit alignment of the atomic operations.
+	sendCounter uint64
+	peer        *peer
+	localIndex  uint32
+	remoteIndex uint32
+	send        noise.Cipher
+	recv        noise.Cipher
+	created     time.Time
+
+	replay   replayFilter
+	replayMu sync.Mutex
+}
+
+func (kp *keypair) expired() bool {
+	return time.Since(kp.created) > rejectAfterTime ||
+		atomic.LoadUint64(&kp.sendCounter) >= rejectAfterMessages
+}
+
+func putHeader(b []byte, typ byte, indexes ...uint32) {
+	b[0] = typ
+	b[1], b[2], b[3] = 0, 0, 0
+	for i, index := range indexes {
+		binary.LittleEndian.PutUint32(b[4+4*i:], index)
+	}
+}
+
+// ParseKeys decodes the base64 encoded keys.
+func ParseKeys(ss []string) ([][]byte, error) {
+	var keys [][]byte
+	for _, s := range ss {
+		key, err := ParseKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
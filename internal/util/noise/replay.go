@@ -0,0 +1,42 @@
+package noise
+
+// The replay filter is the sliding window of RFC 6479,
+// the counters older than the window or seen in the window are rejected.
+const (
+	replayBlockBits  = 64
+	replayRingBlocks = 32
+	replayWindowSize = (replayRingBlocks - 1) * replayBlockBits
+)
+
+type replayFilter struct {
+	last uint64
+	ring [replayRingBlocks]uint64
+}
+
+// validate checks the counter of the authenticated packet and marks it as seen.
+func (f *replayFilter) validate(counter uint64) bool {
+	if counter >= rejectAfterMessages {
+		return false
+	}
+
+	block := counter / replayBlockBits
+	if counter > f.last {
+		current := f.last / replayBlockBits
+		diff := block - current
+		if diff > replayRingBlocks {
+			diff = replayRingBlocks
+		}
+		for i := current + 1; i <= current+diff; i++ {
+			f.ring[i%replayRingBlocks] = 0
+		}
+		f.last = counter
+	} else if f.last-counter > replayWindowSize {
+		return false
+	}
+
+	block %= replayRingBlocks
+	bit := uint64(1) << (counter % replayBlockBits)
+	old := f.ring[block]
+	f.ring[block] |= bit
+	return old != f.ring[block]
+}
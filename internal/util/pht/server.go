@@ -21,6 +21,7 @@ import (
 	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/xid"
 	xnet "github.com/wznpp1/gost_x/internal/net"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -36,6 +37,7 @@ type serverOptions struct {
 	backlog        int
 	tlsEnabled     bool
 	tlsConfig      *tls.Config
+	camouflage     *tls_util.Camouflage
 	readBufferSize int
 	readTimeout    time.Duration
 	logger         logger.Logger
@@ -69,6 +71,12 @@ func EnableTLSServerOption(enable bool) ServerOption {
 	}
 }
 
+func CamouflageServerOption(camouflage *tls_util.Camouflage) ServerOption {
+	return func(opts *serverOptions) {
+		opts.camouflage = camouflage
+	}
+}
+
 func ReadBufferSizeServerOption(n int) ServerOption {
 	return func(opts *serverOptions) {
 		opts.readBufferSize = n
@@ -196,6 +204,7 @@ func (s *Server) ListenAndServe() error {
 	s.addr = ln.Addr()
 	if s.options.tlsEnabled {
 		s.httpServer.TLSConfig = s.options.tlsConfig
+		ln = tls_util.WrapCamouflageListener(s.options.camouflage, ln)
 		ln = tls.NewListener(ln, s.options.tlsConfig)
 	}
 
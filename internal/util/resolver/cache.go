@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/metrics"
 	"github.com/miekg/dns"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
 )
 
 type CacheKey string
@@ -40,7 +42,18 @@ func (c *Cache) WithLogger(logger logger.Logger) *Cache {
 	return c
 }
 
-func (c *Cache) Load(key CacheKey) *dns.Msg {
+func (c *Cache) Load(key CacheKey) (msg *dns.Msg) {
+	defer func() {
+		result := "miss"
+		if msg != nil {
+			result = "hit"
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricDNSCacheLookupsCounter,
+			metrics.Labels{"result": result}); v != nil {
+			v.Inc()
+		}
+	}()
+
 	v, ok := c.m.Load(key)
 	if !ok {
 		return nil
@@ -0,0 +1,81 @@
+package sniffing
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrNotDTLSClientHello = errors.New("sniffing: not a DTLS ClientHello")
+)
+
+const (
+	dtlsRecordHeaderLen    = 13
+	dtlsHandshakeHeaderLen = 12
+
+	dtlsVersion10 = 0xfeff
+	dtlsVersion12 = 0xfefd
+)
+
+func isDTLSHandshake(b []byte) bool {
+	if len(b) < dtlsRecordHeaderLen+dtlsHandshakeHeaderLen || b[0] != 22 {
+		return false
+	}
+	switch binary.BigEndian.Uint16(b[1:3]) {
+	case dtlsVersion10, dtlsVersion12:
+		return true
+	}
+	return false
+}
+
+// dtlsClientHello converts the DTLS ClientHello of the first record to the TLS one,
+// the DTLS handshake header fields and the cookie are removed and the version is mapped to the TLS one.
+// A fragmented ClientHello is truncated.
+func dtlsClientHello(b []byte) ([]byte, error) {
+	if !isDTLSHandshake(b) {
+		return nil, ErrNotDTLSClientHello
+	}
+
+	length := int(binary.BigEndian.Uint16(b[11:13]))
+	b = b[dtlsRecordHeaderLen:]
+	if length < len(b) {
+		b = b[:length]
+	}
+	if len(b) < dtlsHandshakeHeaderLen || b[0] != 1 {
+		return nil, ErrNotDTLSClientHello
+	}
+	body := b[dtlsHandshakeHeaderLen:]
+	if fragLen := int(b[9])<<16 | int(b[10])<<8 | int(b[11]); fragLen < len(body) {
+		body = body[:fragLen]
+	}
+
+	// client version, random and session ID
+	n := 2 + 32
+	if len(body) < n+1 {
+		return nil, ErrNotDTLSClientHello
+	}
+	n += 1 + int(body[n])
+	if len(body) < n+1 {
+		return nil, ErrNotDTLSClientHello
+	}
+	cookieLen := int(body[n])
+	if len(body) < n+1+cookieLen {
+		return nil, ErrNotDTLSClientHello
+	}
+
+	msgLen := len(body) - 1 - cookieLen
+	msg := make([]byte, 0, 4+msgLen)
+	msg = append(msg, 1, byte(msgLen>>16), byte(msgLen>>8), byte(msgLen))
+	msg = append(msg, body[:n]...)
+	msg = append(msg, body[n+1+cookieLen:]...)
+
+	// the DTLS 1.0 and 1.2 are based on the TLS 1.1 and 1.2 respectively.
+	version := uint16(tls.VersionTLS12)
+	if binary.BigEndian.Uint16(msg[4:6]) == dtlsVersion10 {
+		version = tls.VersionTLS11
+	}
+	binary.BigEndian.PutUint16(msg[4:6], version)
+
+	return msg, nil
+}
@@ -0,0 +1,261 @@
+package sniffing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrNotQUICInitial = errors.New("sniffing: not a QUIC initial packet")
+	ErrQUICDecrypt    = errors.New("sniffing: QUIC initial packet decryption failed")
+)
+
+const (
+	quicVersion1 = 0x00000001
+	quicVersion2 = 0x6b3343cf
+)
+
+var (
+	// initial salts of RFC 9001 and RFC 9369.
+	quicSaltV1 = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+	quicSaltV2 = []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9}
+)
+
+// isQUICInitial reports whether b looks like a QUIC v1 or v2 Initial packet, before it is decrypted.
+func isQUICInitial(b []byte) bool {
+	if len(b) < 7 || b[0]&0xc0 != 0xc0 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(b[1:5]) {
+	case quicVersion1:
+		return (b[0]>>4)&0x03 == 0
+	case quicVersion2:
+		return (b[0]>>4)&0x03 == 1
+	}
+	return false
+}
+
+// quicClientHello decrypts the Initial packet of the client and returns the ClientHello message in its CRYPTO frames.
+// The message may be truncated if the ClientHello spans several packets.
+func quicClientHello(b []byte) ([]byte, error) {
+	if !isQUICInitial(b) {
+		return nil, ErrNotQUICInitial
+	}
+
+	version := binary.BigEndian.Uint32(b[1:5])
+	salt, labelPrefix := quicSaltV1, "quic "
+	if version == quicVersion2 {
+		salt, labelPrefix = quicSaltV2, "quicv2 "
+	}
+
+	r := bytes.NewReader(b[5:])
+	dcid, err := readQUICBytes(r, 1)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readQUICBytes(r, 1); err != nil { // source connection ID
+		return nil, err
+	}
+	tokenLen, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(int64(tokenLen), 1); err != nil {
+		return nil, err
+	}
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	pnOffset := len(b) - r.Len()
+	if length < 20 || uint64(r.Len()) < length {
+		return nil, ErrNotQUICInitial
+	}
+
+	secret := hkdf.Extract(sha256.New, dcid, salt)
+	clientSecret := hkdfExpandLabel(secret, "client in", sha256.Size)
+	key := hkdfExpandLabel(clientSecret, labelPrefix+"key", 16)
+	iv := hkdfExpandLabel(clientSecret, labelPrefix+"iv", 12)
+	hp := hkdfExpandLabel(clientSecret, labelPrefix+"hp", 16)
+
+	// remove the header protection, the sample starts at 4 bytes after the packet number offset.
+	hpBlock, err := aes.NewCipher(hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	hpBlock.Encrypt(mask, b[pnOffset+4:pnOffset+4+aes.BlockSize])
+
+	header := make([]byte, pnOffset+4)
+	copy(header, b)
+	header[0] ^= mask[0] & 0x0f
+	pnLen := int(header[0]&0x03) + 1
+	header = header[:pnOffset+pnLen]
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		header[pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(header[pnOffset+i])
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, len(iv))
+	copy(nonce, iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	payload, err := aead.Open(nil, nonce, b[pnOffset+pnLen:pnOffset+int(length)], header)
+	if err != nil {
+		return nil, ErrQUICDecrypt
+	}
+
+	return quicCryptoData(payload)
+}
+
+type cryptoFragment struct {
+	offset uint64
+	data   []byte
+}
+
+// quicCryptoData reassembles the contiguous data of the CRYPTO frames from the offset 0.
+func quicCryptoData(payload []byte) ([]byte, error) {
+	var frags []cryptoFragment
+
+	r := bytes.NewReader(payload)
+	for r.Len() > 0 {
+		typ, err := readVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case 0x00, 0x01: // PADDING, PING
+		case 0x02, 0x03: // ACK
+			// largest acknowledged, ack delay, range count, first range
+			var v [4]uint64
+			for i := range v {
+				if v[i], err = readVarint(r); err != nil {
+					return nil, err
+				}
+			}
+			for i := uint64(0); i < v[2]*2; i++ { // gap and range length
+				if _, err := readVarint(r); err != nil {
+					return nil, err
+				}
+			}
+			if typ == 0x03 { // ECN counts
+				for i := 0; i < 3; i++ {
+					if _, err := readVarint(r); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case 0x06: // CRYPTO
+			offset, err := readVarint(r)
+			if err != nil {
+				return nil, err
+			}
+			data, err := readQUICVarintBytes(r)
+			if err != nil {
+				return nil, err
+			}
+			frags = append(frags, cryptoFragment{offset: offset, data: data})
+		case 0x1c: // CONNECTION_CLOSE
+			return nil, ErrNotQUICInitial
+		default:
+			// the other frames are not allowed in the Initial packets.
+			r.Seek(0, 2)
+		}
+	}
+
+	sort.Slice(frags, func(i, j int) bool {
+		return frags[i].offset < frags[j].offset
+	})
+	var data []byte
+	for _, frag := range frags {
+		if frag.offset > uint64(len(data)) {
+			break
+		}
+		if end := frag.offset + uint64(len(frag.data)); end > uint64(len(data)) {
+			data = append(data, frag.data[uint64(len(data))-frag.offset:]...)
+		}
+	}
+	if len(data) == 0 {
+		return nil, ErrNotQUICInitial
+	}
+	return data, nil
+}
+
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0) // empty context
+
+	out := make([]byte, length)
+	hkdf.Expand(crypto.SHA256.New, secret, info).Read(out)
+	return out
+}
+
+func readVarint(r *bytes.Reader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := 1 << (b >> 6)
+	v := uint64(b & 0x3f)
+	for i := 1; i < n; i++ {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// readQUICBytes reads the bytes prefixed by the length of n bytes.
+func readQUICBytes(r *bytes.Reader, n int) ([]byte, error) {
+	var length int
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > r.Len() {
+		return nil, ErrNotQUICInitial
+	}
+	b := make([]byte, length)
+	r.Read(b)
+	return b, nil
+}
+
+// readQUICVarintBytes reads the bytes prefixed by the variable-length length.
+func readQUICVarintBytes(r *bytes.Reader) ([]byte, error) {
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(r.Len()) {
+		return nil, ErrNotQUICInitial
+	}
+	b := make([]byte, length)
+	r.Read(b)
+	return b, nil
+}
@@ -0,0 +1,211 @@
+package sniffing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-gost/core/metrics"
+	dissector "github.com/go-gost/tls-dissector"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	xio "github.com/wznpp1/gost_x/internal/io"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+const (
+	ProtoHTTP       = "http"
+	ProtoTLS        = "tls"
+	ProtoSSH        = "ssh"
+	ProtoBitTorrent = "bittorrent"
+	ProtoQUIC       = "quic"
+	ProtoDTLS       = "dtls"
+)
+
+const (
+	bittorrentHeader = "\x13BitTorrent protocol"
+	maxDatagramSize  = 65535
+)
+
+// Sniff detects the protocol of the stream from the first bytes sent by the client,
+// the host is the server name of the TLS ClientHello or the host of the HTTP request.
+// The returned rw replays the consumed bytes.
+func Sniff(ctx context.Context, rdw io.ReadWriter) (rw io.ReadWriter, host string, protocol string, err error) {
+	rw = rdw
+
+	// try to sniff TLS traffic
+	var hdr [dissector.RecordHeaderLen]byte
+	n, err := io.ReadFull(rw, hdr[:])
+	rw = xio.NewReadWriter(io.MultiReader(bytes.NewReader(hdr[:n]), rw), rw)
+	if err != nil {
+		return
+	}
+	if hdr[0] == dissector.Handshake &&
+		binary.BigEndian.Uint16(hdr[1:3]) == tls.VersionTLS10 {
+		rw, host, err = sniffSNI(ctx, rw)
+		protocol = ProtoTLS
+		return
+	}
+
+	// try to sniff HTTP traffic
+	if isHTTP(string(hdr[:])) {
+		buf := new(bytes.Buffer)
+		var r *http.Request
+		r, err = http.ReadRequest(bufio.NewReader(io.TeeReader(rw, buf)))
+		rw = xio.NewReadWriter(io.MultiReader(buf, rw), rw)
+		if err == nil {
+			host = r.Host
+			protocol = ProtoHTTP
+			return
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(string(hdr[:]), "SSH-"):
+		protocol = ProtoSSH
+	case string(hdr[:]) == bittorrentHeader[:len(hdr)]:
+		b := make([]byte, len(bittorrentHeader))
+		n, err = io.ReadFull(rw, b)
+		rw = xio.NewReadWriter(io.MultiReader(bytes.NewReader(b[:n]), rw), rw)
+		if err == nil && string(b) == bittorrentHeader {
+			protocol = ProtoBitTorrent
+		}
+	}
+
+	return
+}
+
+// SniffPacket detects the protocol of the first datagram sent by the client,
+// the host is the server name of the QUIC or DTLS ClientHello.
+func SniffPacket(b []byte) (host string, protocol string) {
+	switch {
+	case isQUICInitial(b):
+		protocol = ProtoQUIC
+		if data, err := quicClientHello(b); err == nil {
+			host = serverName(data)
+		}
+	case isDTLSHandshake(b):
+		protocol = ProtoDTLS
+		if data, err := dtlsClientHello(b); err == nil {
+			host = serverName(data)
+		}
+	case isBitTorrentPacket(b):
+		protocol = ProtoBitTorrent
+	}
+	return
+}
+
+// SniffDatagram reads the first datagram from rw and detects its protocol by SniffPacket,
+// the returned rw replays the datagram on the first read.
+func SniffDatagram(rw io.ReadWriter) (io.ReadWriter, string, string, error) {
+	b := make([]byte, maxDatagramSize)
+	n, err := rw.Read(b)
+	if err != nil {
+		return rw, "", "", err
+	}
+	host, protocol := SniffPacket(b[:n])
+	return &datagramReadWriter{ReadWriter: rw, b: b[:n]}, host, protocol, nil
+}
+
+// Record puts the sniffed protocol and host in the session of ctx and counts the sniffed connection.
+func Record(ctx context.Context, service string, host string, protocol string) {
+	if protocol == "" {
+		return
+	}
+
+	xctx.SetProtocol(ctx, protocol)
+	if host != "" {
+		xctx.SetHost(ctx, host)
+	}
+
+	if counter := xmetrics.GetCounter(
+		xmetrics.MetricServiceSniffedCounter,
+		metrics.Labels{
+			"service":  service,
+			"protocol": protocol,
+		}); counter != nil {
+		counter.Inc()
+	}
+}
+
+func sniffSNI(ctx context.Context, rw io.ReadWriter) (io.ReadWriter, string, error) {
+	buf := new(bytes.Buffer)
+	host, err := getServerName(ctx, io.TeeReader(rw, buf))
+	rw = xio.NewReadWriter(io.MultiReader(buf, rw), rw)
+	return rw, host, err
+}
+
+func getServerName(ctx context.Context, r io.Reader) (host string, err error) {
+	record, err := dissector.ReadRecord(r)
+	if err != nil {
+		return
+	}
+
+	clientHello := dissector.ClientHelloMsg{}
+	if err = clientHello.Decode(record.Opaque); err != nil {
+		return
+	}
+
+	for _, ext := range clientHello.Extensions {
+		if ext.Type() == dissector.ExtServerName {
+			snExtension := ext.(*dissector.ServerNameExtension)
+			host = snExtension.Name
+			break
+		}
+	}
+
+	return
+}
+
+// serverName returns the server name of the ClientHello message, including the handshake header.
+func serverName(b []byte) string {
+	clientHello := dissector.ClientHelloMsg{}
+	if err := clientHello.Decode(b); err != nil {
+		return ""
+	}
+	for _, ext := range clientHello.Extensions {
+		if ext.Type() == dissector.ExtServerName {
+			return ext.(*dissector.ServerNameExtension).Name
+		}
+	}
+	return ""
+}
+
+func isHTTP(s string) bool {
+	return strings.HasPrefix(http.MethodGet, s[:3]) ||
+		strings.HasPrefix(http.MethodPost, s[:4]) ||
+		strings.HasPrefix(http.MethodPut, s[:3]) ||
+		strings.HasPrefix(http.MethodDelete, s) ||
+		strings.HasPrefix(http.MethodOptions, s) ||
+		strings.HasPrefix(http.MethodPatch, s) ||
+		strings.HasPrefix(http.MethodHead, s[:4]) ||
+		strings.HasPrefix(http.MethodConnect, s) ||
+		strings.HasPrefix(http.MethodTrace, s)
+}
+
+// isBitTorrentPacket recognizes the bencoded DHT messages and the uTP SYN packets,
+// the DHT query, response and error messages start with the a, r and e keys respectively.
+func isBitTorrentPacket(b []byte) bool {
+	if len(b) >= 4 && string(b[:3]) == "d1:" && (b[3] == 'a' || b[3] == 'r' || b[3] == 'e') {
+		return true
+	}
+	// uTP header: type ST_SYN and version 1, the extension is none or selective ack.
+	return len(b) == 20 && b[0] == 0x41 && (b[1] == 0 || b[1] == 1)
+}
+
+type datagramReadWriter struct {
+	io.ReadWriter
+	b []byte
+}
+
+func (rw *datagramReadWriter) Read(p []byte) (int, error) {
+	if b := rw.b; b != nil {
+		rw.b = nil
+		return copy(p, b), nil
+	}
+	return rw.ReadWriter.Read(p)
+}
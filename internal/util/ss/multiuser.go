@@ -0,0 +1,220 @@
+package ss
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadowsocks/go-shadowsocks2/core"
+	"github.com/shadowsocks/go-shadowsocks2/shadowaead"
+	xnet "github.com/wznpp1/gost_x/internal/net"
+)
+
+const (
+	// trialSize is the size of the stream prefix used by the trial decryption,
+	// it contains the salt and the first length chunk for all the AEAD methods.
+	trialSize = 32 + 2 + 16
+
+	maxRecentUsers = 4096
+)
+
+// MultiUserCipher is the server side cipher of the legacy AEAD methods shared by multiple users,
+// the user of the stream is identified by trial decryption of the first chunk.
+type MultiUserCipher struct {
+	method   string
+	password string
+	key      string
+	users    func() map[string]string
+
+	ciphers []userCipher
+	updated time.Time
+	// recent is the last successful user of the source hosts.
+	recent map[string]string
+	mu     sync.Mutex
+}
+
+type userCipher struct {
+	user   string
+	cipher shadowaead.Cipher
+}
+
+// NewMultiUserCipher creates a MultiUserCipher with the default method and password.
+// The passwords of the users are in the form of method:password, or password with the default method.
+func NewMultiUserCipher(method, password string, key string, users func() map[string]string) *MultiUserCipher {
+	return &MultiUserCipher{
+		method:   method,
+		password: password,
+		key:      key,
+		users:    users,
+		recent:   make(map[string]string),
+	}
+}
+
+// StreamConn identifies the user of the stream and returns the decrypted stream and the user,
+// the user of the default method and password is empty.
+func (c *MultiUserCipher) StreamConn(conn net.Conn) (net.Conn, string, error) {
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	ciphers := c.candidates(host)
+	if len(ciphers) == 0 {
+		return nil, "", ErrUnknownUser
+	}
+	if len(ciphers) == 1 {
+		uc := ciphers[0]
+		return uc.cipher.(core.Cipher).StreamConn(conn), uc.user, nil
+	}
+
+	br := bufio.NewReader(conn)
+	b, err := br.Peek(trialSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, uc := range ciphers {
+		if !trialDecrypt(uc.cipher, b) {
+			continue
+		}
+		c.remember(host, uc.user)
+
+		return uc.cipher.(core.Cipher).StreamConn(xnet.NewBufferReaderConn(conn, br)), uc.user, nil
+	}
+
+	return nil, "", ErrUnknownUser
+}
+
+// PacketConn identifies the user of the packet connection by trial decryption of the first packet,
+// and returns the decrypted packet connection and the user, the user of the default method and password is empty.
+// The connection is expected to be the one of a single peer.
+func (c *MultiUserCipher) PacketConn(pc net.PacketConn) (net.PacketConn, string, error) {
+	b := make([]byte, udpBufferSize)
+	n, addr, err := pc.ReadFrom(b)
+	if err != nil {
+		return nil, "", err
+	}
+
+	host, _, _ := net.SplitHostPort(addr.String())
+	for _, uc := range c.candidates(host) {
+		payload, err := shadowaead.Unpack(make([]byte, n), b[:n], uc.cipher)
+		if err != nil {
+			continue
+		}
+		c.remember(host, uc.user)
+
+		return &firstPacketConn{
+			PacketConn: uc.cipher.(core.Cipher).PacketConn(pc),
+			first:      payload,
+			addr:       addr,
+		}, uc.user, nil
+	}
+
+	return nil, "", ErrUnknownUser
+}
+
+// remember records the user as the recent user of the host.
+func (c *MultiUserCipher) remember(host, user string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.recent) >= maxRecentUsers {
+		c.recent = make(map[string]string)
+	}
+	c.recent[host] = user
+}
+
+// candidates returns the ciphers to try, the recent user of the host is the first one.
+func (c *MultiUserCipher) candidates(host string) []userCipher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ciphers == nil || time.Since(c.updated) > userTableTTL {
+		c.ciphers = c.ciphers[:0]
+		if uc, err := newUserCipher("", c.method, c.password, c.key); err == nil {
+			c.ciphers = append(c.ciphers, uc)
+		}
+		if c.users != nil {
+			for user, password := range c.users() {
+				method := c.method
+				if i := strings.IndexByte(password, ':'); i > 0 && IsAEAD(password[:i]) {
+					method, password = password[:i], password[i+1:]
+				}
+				if uc, err := newUserCipher(user, method, password, ""); err == nil {
+					c.ciphers = append(c.ciphers, uc)
+				}
+			}
+		}
+		c.updated = time.Now()
+	}
+
+	ciphers := make([]userCipher, 0, len(c.ciphers))
+	user, ok := c.recent[host]
+	if ok {
+		for _, uc := range c.ciphers {
+			if uc.user == user {
+				ciphers = append(ciphers, uc)
+				break
+			}
+		}
+	}
+	for _, uc := range c.ciphers {
+		if !ok || uc.user != user {
+			ciphers = append(ciphers, uc)
+		}
+	}
+	return ciphers
+}
+
+func newUserCipher(user, method, password, key string) (userCipher, error) {
+	if method == "" || password == "" {
+		return userCipher{}, errors.New("ss: empty method or password")
+	}
+	c, err := core.PickCipher(method, []byte(key), password)
+	if err != nil {
+		return userCipher{}, err
+	}
+	aead, ok := c.(shadowaead.Cipher)
+	if !ok {
+		return userCipher{}, errors.New("ss: multiple users require AEAD method")
+	}
+	return userCipher{user: user, cipher: aead}, nil
+}
+
+// trialDecrypt reports whether the first length chunk of b can be decrypted by the cipher.
+func trialDecrypt(c shadowaead.Cipher, b []byte) bool {
+	saltSize := c.SaltSize()
+	aead, err := c.Decrypter(b[:saltSize])
+	if err != nil {
+		return false
+	}
+
+	chunk := b[saltSize : saltSize+2+aead.Overhead()]
+	_, err = aead.Open(nil, make([]byte, aead.NonceSize()), chunk, nil)
+	return err == nil
+}
+
+// IsAEAD reports whether the method is a legacy AEAD method.
+func IsAEAD(method string) bool {
+	_, err := core.PickCipher(method, nil, "-")
+	return err != core.ErrCipherNotSupported
+}
+
+// firstPacketConn returns the first packet read by the identification before the subsequent packets.
+type firstPacketConn struct {
+	net.PacketConn
+	first []byte
+	addr  net.Addr
+	mu    sync.Mutex
+}
+
+func (c *firstPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	first := c.first
+	c.first = nil
+	c.mu.Unlock()
+
+	if first != nil {
+		return copy(b, first), c.addr, nil
+	}
+	return c.PacketConn.ReadFrom(b)
+}
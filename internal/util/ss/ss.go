@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"net"
 
+	"github.com/go-gost/core/auth"
+
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	ss "github.com/shadowsocks/shadowsocks-go/shadowsocks"
+	xauth "github.com/wznpp1/gost_x/auth"
 )
 
 type shadowCipher struct {
@@ -25,6 +28,10 @@ func ShadowCipher(method, password string, key string) (core.Cipher, error) {
 		return nil, nil
 	}
 
+	if Is2022(method) {
+		return newCipher2022(method, password, false)
+	}
+
 	c, _ := ss.NewCipher(method, password)
 	if c != nil {
 		return &shadowCipher{cipher: c}, nil
@@ -33,6 +40,21 @@ func ShadowCipher(method, password string, key string) (core.Cipher, error) {
 	return core.PickCipher(method, []byte(key), password)
 }
 
+// Users returns the function which lists the users of the auther for the multi-user server cipher,
+// it returns nil if the auther can not list its users.
+// The entry of the method is excluded, as it is the handler auth rather than a user.
+func Users(auther auth.Authenticator, method string) func() map[string]string {
+	lister, ok := auther.(xauth.Lister)
+	if !ok {
+		return nil
+	}
+	return func() map[string]string {
+		users := lister.Users()
+		delete(users, method)
+		return users
+	}
+}
+
 // Due to in/out byte length is inconsistent of the shadowsocks.Conn.Write,
 // we wrap around it to make io.Copy happy.
 type shadowConn struct {
@@ -0,0 +1,292 @@
+package ss
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadowsocks/go-shadowsocks2/core"
+	"golang.org/x/crypto/chacha20poly1305"
+	"lukechampine.com/blake3"
+)
+
+// Shadowsocks 2022 Edition (SIP022) methods.
+const (
+	Method2022AES128GCM        = "2022-blake3-aes-128-gcm"
+	Method2022AES256GCM        = "2022-blake3-aes-256-gcm"
+	Method2022ChaCha20Poly1305 = "2022-blake3-chacha20-poly1305"
+)
+
+const (
+	headerTypeClient = 0
+	headerTypeServer = 1
+
+	// maxTimeDiff is the max allowed difference between the timestamp in the header and the local time.
+	maxTimeDiff = 30 * time.Second
+	// saltTTL is the time the salts are kept by the replay filter.
+	saltTTL = 60 * time.Second
+
+	maxPayloadSize = 0xFFFF
+	maxPaddingSize = 900
+
+	subkeyContextSession  = "shadowsocks 2022 session subkey"
+	subkeyContextIdentity = "shadowsocks 2022 identity subkey"
+)
+
+var (
+	ErrBadTimestamp  = errors.New("ss: bad timestamp")
+	ErrReplay        = errors.New("ss: replayed request")
+	ErrBadHeaderType = errors.New("ss: bad header type")
+	ErrBadSalt       = errors.New("ss: bad request salt")
+	ErrUnknownUser   = errors.New("ss: unknown user")
+)
+
+// Is2022 reports whether the method is a Shadowsocks 2022 Edition method.
+func Is2022(method string) bool {
+	switch strings.ToLower(method) {
+	case Method2022AES128GCM, Method2022AES256GCM, Method2022ChaCha20Poly1305:
+		return true
+	}
+	return false
+}
+
+// ShadowServerCipher creates the server side cipher.
+// For the Shadowsocks 2022 AES methods, the users are the name to PSK mapping of the multi-user
+// extensible identity headers (EIH), the password is the identity PSK in this case.
+// The users are not used by the legacy methods.
+func ShadowServerCipher(method, password string, key string, users func() map[string]string) (core.Cipher, error) {
+	if !Is2022(method) {
+		return ShadowCipher(method, password, key)
+	}
+	if method == "" || password == "" {
+		return nil, nil
+	}
+
+	c, err := newCipher2022(method, password, true)
+	if err != nil {
+		return nil, err
+	}
+	if users != nil && c.eih {
+		c.users = &userTable{
+			keySize: c.keySize,
+			users:   users,
+		}
+	}
+	return c, nil
+}
+
+type cipher2022 struct {
+	method  string
+	keySize int
+	// psks are the pre-shared keys, the last one is the user PSK,
+	// the others are the identity PSKs for the EIH.
+	psks   [][]byte
+	eih    bool
+	server bool
+	users  *userTable
+	salts  *saltPool
+}
+
+func newCipher2022(method, password string, server bool) (*cipher2022, error) {
+	c := &cipher2022{
+		method: strings.ToLower(method),
+		server: server,
+		salts:  newSaltPool(),
+	}
+	switch c.method {
+	case Method2022AES128GCM:
+		c.keySize = 16
+		c.eih = true
+	case Method2022AES256GCM:
+		c.keySize = 32
+		c.eih = true
+	case Method2022ChaCha20Poly1305:
+		c.keySize = 32
+	default:
+		return nil, fmt.Errorf("ss: unsupported method %s", method)
+	}
+
+	for _, s := range strings.Split(password, ":") {
+		psk, err := c.decodeKey(s)
+		if err != nil {
+			return nil, err
+		}
+		c.psks = append(c.psks, psk)
+	}
+	if len(c.psks) > 1 && !c.eih {
+		return nil, fmt.Errorf("ss: method %s does not support multiple PSKs", method)
+	}
+	if server && len(c.psks) > 1 {
+		return nil, errors.New("ss: server does not support multiple PSKs")
+	}
+
+	return c, nil
+}
+
+func (c *cipher2022) decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("ss: invalid PSK: %v", err)
+	}
+	if len(key) != c.keySize {
+		return nil, fmt.Errorf("ss: invalid PSK length %d, %d is required", len(key), c.keySize)
+	}
+	return key, nil
+}
+
+// userPSK returns the PSK used for the payload.
+func (c *cipher2022) userPSK() []byte {
+	return c.psks[len(c.psks)-1]
+}
+
+func (c *cipher2022) StreamConn(conn net.Conn) net.Conn {
+	return &streamConn2022{
+		Conn:       conn,
+		cipher:     c,
+		headerSent: make(chan struct{}),
+	}
+}
+
+func (c *cipher2022) PacketConn(conn net.PacketConn) net.PacketConn {
+	return newPacketConn2022(conn, c)
+}
+
+func (c *cipher2022) aead(key []byte) (cipher.AEAD, error) {
+	if c.method == Method2022ChaCha20Poly1305 {
+		return chacha20poly1305.New(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func deriveKey(ctx string, psk, salt []byte, size int) []byte {
+	material := make([]byte, 0, len(psk)+len(salt))
+	material = append(material, psk...)
+	material = append(material, salt...)
+
+	key := make([]byte, size)
+	blake3.DeriveKey(key, ctx, material)
+	return key
+}
+
+// pskHash returns the identity of the PSK used in the EIH.
+func pskHash(psk []byte) []byte {
+	h := blake3.Sum512(psk)
+	return h[:aes.BlockSize]
+}
+
+func checkTimestamp(ts uint64) error {
+	d := time.Since(time.Unix(int64(ts), 0))
+	if d > maxTimeDiff || d < -maxTimeDiff {
+		return ErrBadTimestamp
+	}
+	return nil
+}
+
+// saltPool is the replay filter of the TCP request salts.
+type saltPool struct {
+	salts map[string]time.Time
+	last  time.Time
+	mu    sync.Mutex
+}
+
+func newSaltPool() *saltPool {
+	return &saltPool{
+		salts: make(map[string]time.Time),
+	}
+}
+
+// check adds the salt to the pool, it reports whether the salt is not seen before.
+func (p *saltPool) check(salt []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.last) > saltTTL {
+		for k, t := range p.salts {
+			if now.Sub(t) > saltTTL {
+				delete(p.salts, k)
+			}
+		}
+		p.last = now
+	}
+
+	if _, ok := p.salts[string(salt)]; ok {
+		return false
+	}
+	p.salts[string(salt)] = now
+	return true
+}
+
+// userTable maps the PSK hashes in the EIH to the users.
+type userTable struct {
+	keySize int
+	users   func() map[string]string
+	table   map[string]userPSK
+	updated time.Time
+	mu      sync.Mutex
+}
+
+type userPSK struct {
+	name string
+	psk  []byte
+}
+
+const userTableTTL = 5 * time.Second
+
+// enabled reports whether there are any users, the EIH is required in this case.
+func (t *userTable) enabled() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refresh()
+	return len(t.table) > 0
+}
+
+func (t *userTable) lookup(hash []byte) (userPSK, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refresh()
+	u, ok := t.table[string(hash)]
+	return u, ok
+}
+
+func (t *userTable) refresh() {
+	if t.table != nil && time.Since(t.updated) < userTableTTL {
+		return
+	}
+
+	table := make(map[string]userPSK)
+	for name, s := range t.users() {
+		psk, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || len(psk) != t.keySize {
+			continue
+		}
+		table[string(pskHash(psk))] = userPSK{name: name, psk: psk}
+	}
+	t.table = table
+	t.updated = time.Now()
+}
+
+func increaseNonce(nonce []byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
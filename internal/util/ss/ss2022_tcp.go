@@ -0,0 +1,399 @@
+package ss
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	errShortAddr = errors.New("ss: short address")
+)
+
+// streamConn2022 is the Shadowsocks 2022 TCP stream.
+// The SOCKS address at the start of the stream is carried in the request header,
+// so it is transparent to the users of the connection.
+type streamConn2022 struct {
+	net.Conn
+	cipher *cipher2022
+	user   string
+	psk    []byte
+
+	// the salt of the request, it is sent back in the response header.
+	requestSalt []byte
+	// headerSent is closed when the client has sent the request header,
+	// or failed to send it with headerErr.
+	headerSent     chan struct{}
+	headerSentOnce sync.Once
+	headerErr      error
+
+	wmu    sync.Mutex
+	waead  cipher.AEAD
+	wnonce []byte
+
+	raead  cipher.AEAD
+	rnonce []byte
+	rbuf   []byte
+	rerr   error
+}
+
+// User returns the name of the user identified by the EIH on the server side.
+func (c *streamConn2022) User() string {
+	return c.user
+}
+
+func (c *streamConn2022) Read(b []byte) (n int, err error) {
+	if len(c.rbuf) == 0 {
+		if c.rerr != nil {
+			return 0, c.rerr
+		}
+		if c.raead == nil {
+			if c.cipher.server {
+				c.rbuf, err = c.readRequestHeader()
+			} else {
+				c.rbuf, err = c.readResponseHeader()
+			}
+		} else {
+			c.rbuf, err = c.readChunk()
+		}
+		if err != nil {
+			c.rerr = err
+			return
+		}
+	}
+
+	n = copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return
+}
+
+func (c *streamConn2022) Write(b []byte) (n int, err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	n = len(b)
+
+	var buf bytes.Buffer
+	if c.waead == nil {
+		if c.cipher.server {
+			b, err = c.writeResponseHeader(&buf, b)
+		} else {
+			b, err = c.writeRequestHeader(&buf, b)
+		}
+		if err != nil {
+			c.sendHeader(err)
+			return
+		}
+	}
+
+	for len(b) > 0 {
+		m := len(b)
+		if m > maxPayloadSize {
+			m = maxPayloadSize
+		}
+		c.seal(&buf, binary.BigEndian.AppendUint16(nil, uint16(m)))
+		c.seal(&buf, b[:m])
+		b = b[m:]
+	}
+
+	if _, err = c.Conn.Write(buf.Bytes()); err != nil {
+		c.sendHeader(err)
+		return 0, err
+	}
+	c.sendHeader(nil)
+
+	return
+}
+
+func (c *streamConn2022) Close() error {
+	c.sendHeader(net.ErrClosed)
+	return c.Conn.Close()
+}
+
+// sendHeader marks the request header as sent, or failed to be sent with err.
+func (c *streamConn2022) sendHeader(err error) {
+	c.headerSentOnce.Do(func() {
+		c.headerErr = err
+		close(c.headerSent)
+	})
+}
+
+func (c *streamConn2022) seal(buf *bytes.Buffer, b []byte) {
+	buf.Write(c.waead.Seal(nil, c.wnonce, b, nil))
+	increaseNonce(c.wnonce)
+}
+
+func (c *streamConn2022) open(b []byte) ([]byte, error) {
+	b, err := c.raead.Open(b[:0], c.rnonce, b, nil)
+	increaseNonce(c.rnonce)
+	return b, err
+}
+
+func (c *streamConn2022) readFull(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.Conn, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *streamConn2022) readChunk() ([]byte, error) {
+	overhead := c.raead.Overhead()
+	b, err := c.readFull(2 + overhead)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = c.open(b); err != nil {
+		return nil, err
+	}
+
+	if b, err = c.readFull(int(binary.BigEndian.Uint16(b)) + overhead); err != nil {
+		return nil, err
+	}
+	return c.open(b)
+}
+
+func (c *streamConn2022) writeRequestHeader(buf *bytes.Buffer, b []byte) ([]byte, error) {
+	psks := c.cipher.psks
+	keySize := c.cipher.keySize
+
+	addrLen, err := socksAddrLen(b)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, keySize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	buf.Write(salt)
+
+	// extensible identity headers.
+	for i := 0; i < len(psks)-1; i++ {
+		block, err := aes.NewCipher(deriveKey(subkeyContextIdentity, psks[i], salt, keySize))
+		if err != nil {
+			return nil, err
+		}
+		eih := make([]byte, aes.BlockSize)
+		block.Encrypt(eih, pskHash(psks[i+1]))
+		buf.Write(eih)
+	}
+
+	if c.waead, err = c.cipher.aead(deriveKey(subkeyContextSession, c.cipher.userPSK(), salt, keySize)); err != nil {
+		return nil, err
+	}
+	c.wnonce = make([]byte, c.waead.NonceSize())
+	c.requestSalt = salt
+
+	addr, payload := b[:addrLen], b[addrLen:]
+
+	var padding int
+	if len(payload) == 0 {
+		padding = randInt(maxPaddingSize) + 1
+	}
+	if max := maxPayloadSize - addrLen - 2 - padding; len(payload) > max {
+		payload = payload[:max]
+	}
+
+	varHeader := make([]byte, 0, addrLen+2+padding+len(payload))
+	varHeader = append(varHeader, addr...)
+	varHeader = binary.BigEndian.AppendUint16(varHeader, uint16(padding))
+	varHeader = append(varHeader, make([]byte, padding)...)
+	varHeader = append(varHeader, payload...)
+
+	fixedHeader := make([]byte, 0, 11)
+	fixedHeader = append(fixedHeader, headerTypeClient)
+	fixedHeader = binary.BigEndian.AppendUint64(fixedHeader, uint64(time.Now().Unix()))
+	fixedHeader = binary.BigEndian.AppendUint16(fixedHeader, uint16(len(varHeader)))
+
+	c.seal(buf, fixedHeader)
+	c.seal(buf, varHeader)
+
+	return b[addrLen+len(payload):], nil
+}
+
+func (c *streamConn2022) readRequestHeader() ([]byte, error) {
+	keySize := c.cipher.keySize
+
+	salt, err := c.readFull(keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	psk := c.cipher.userPSK()
+	if c.cipher.users.enabled() {
+		eih, err := c.readFull(aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(deriveKey(subkeyContextIdentity, psk, salt, keySize))
+		if err != nil {
+			return nil, err
+		}
+		block.Decrypt(eih, eih)
+
+		u, ok := c.cipher.users.lookup(eih)
+		if !ok {
+			return nil, ErrUnknownUser
+		}
+		c.user, psk = u.name, u.psk
+	}
+
+	if c.raead, err = c.cipher.aead(deriveKey(subkeyContextSession, psk, salt, keySize)); err != nil {
+		return nil, err
+	}
+	c.rnonce = make([]byte, c.raead.NonceSize())
+
+	b, err := c.readFull(11 + c.raead.Overhead())
+	if err != nil {
+		return nil, err
+	}
+	if b, err = c.open(b); err != nil {
+		return nil, err
+	}
+	if b[0] != headerTypeClient {
+		return nil, ErrBadHeaderType
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(b[1:])); err != nil {
+		return nil, err
+	}
+	if !c.cipher.salts.check(salt) {
+		return nil, ErrReplay
+	}
+	c.psk = psk
+	c.requestSalt = salt
+
+	if b, err = c.readFull(int(binary.BigEndian.Uint16(b[9:])) + c.raead.Overhead()); err != nil {
+		return nil, err
+	}
+	if b, err = c.open(b); err != nil {
+		return nil, err
+	}
+
+	// strip the padding between the address and the initial payload.
+	addrLen, err := socksAddrLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < addrLen+2 {
+		return nil, errShortAddr
+	}
+	padding := int(binary.BigEndian.Uint16(b[addrLen:]))
+	if len(b) < addrLen+2+padding {
+		return nil, errShortAddr
+	}
+	return append(b[:addrLen:addrLen], b[addrLen+2+padding:]...), nil
+}
+
+func (c *streamConn2022) writeResponseHeader(buf *bytes.Buffer, b []byte) ([]byte, error) {
+	if c.requestSalt == nil {
+		return nil, errors.New("ss: response before request")
+	}
+	keySize := c.cipher.keySize
+
+	salt := make([]byte, keySize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	buf.Write(salt)
+
+	var err error
+	if c.waead, err = c.cipher.aead(deriveKey(subkeyContextSession, c.psk, salt, keySize)); err != nil {
+		return nil, err
+	}
+	c.wnonce = make([]byte, c.waead.NonceSize())
+
+	payload := b
+	if len(payload) > maxPayloadSize {
+		payload = payload[:maxPayloadSize]
+	}
+
+	fixedHeader := make([]byte, 0, 1+8+keySize+2)
+	fixedHeader = append(fixedHeader, headerTypeServer)
+	fixedHeader = binary.BigEndian.AppendUint64(fixedHeader, uint64(time.Now().Unix()))
+	fixedHeader = append(fixedHeader, c.requestSalt...)
+	fixedHeader = binary.BigEndian.AppendUint16(fixedHeader, uint16(len(payload)))
+
+	c.seal(buf, fixedHeader)
+	c.seal(buf, payload)
+
+	return b[len(payload):], nil
+}
+
+func (c *streamConn2022) readResponseHeader() ([]byte, error) {
+	// the response can only be verified after the request is sent.
+	<-c.headerSent
+	if c.headerErr != nil {
+		return nil, c.headerErr
+	}
+
+	keySize := c.cipher.keySize
+
+	salt, err := c.readFull(keySize)
+	if err != nil {
+		return nil, err
+	}
+	if c.raead, err = c.cipher.aead(deriveKey(subkeyContextSession, c.cipher.userPSK(), salt, keySize)); err != nil {
+		return nil, err
+	}
+	c.rnonce = make([]byte, c.raead.NonceSize())
+
+	b, err := c.readFull(1 + 8 + keySize + 2 + c.raead.Overhead())
+	if err != nil {
+		return nil, err
+	}
+	if b, err = c.open(b); err != nil {
+		return nil, err
+	}
+	if b[0] != headerTypeServer {
+		return nil, ErrBadHeaderType
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(b[1:])); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[9:9+keySize], c.requestSalt) {
+		return nil, ErrBadSalt
+	}
+
+	if b, err = c.readFull(int(binary.BigEndian.Uint16(b[9+keySize:])) + c.raead.Overhead()); err != nil {
+		return nil, err
+	}
+	return c.open(b)
+}
+
+// socksAddrLen returns the length of the SOCKS address at the start of b.
+func socksAddrLen(b []byte) (n int, err error) {
+	if len(b) < 1 {
+		return 0, errShortAddr
+	}
+	switch b[0] {
+	case 1: // IPv4
+		n = 1 + net.IPv4len + 2
+	case 3: // domain
+		if len(b) < 2 {
+			return 0, errShortAddr
+		}
+		n = 1 + 1 + int(b[1]) + 2
+	case 4: // IPv6
+		n = 1 + net.IPv6len + 2
+	default:
+		return 0, errors.New("ss: unknown address type")
+	}
+	if len(b) < n {
+		return 0, errShortAddr
+	}
+	return
+}
+
+func randInt(n int) int {
+	v, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(v.Int64())
+}
@@ -0,0 +1,413 @@
+package ss
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	udpSessionTTL = 2 * time.Minute
+	// udpSweepInterval is the minimum interval between the sweeps of the inactive sessions and clients.
+	udpSweepInterval = 30 * time.Second
+	// udpBufferSize is the max size of the Shadowsocks 2022 UDP packets.
+	udpBufferSize = 65535
+)
+
+var (
+	errShortPacket = errors.New("ss: short packet")
+)
+
+// packetConn2022 is the Shadowsocks 2022 UDP relay.
+// It plays the client role to the peers which are not known as clients,
+// and the server role to the peers from which the client packets are received,
+// so the same connection can be used on both sides of the relay.
+type packetConn2022 struct {
+	net.PacketConn
+	cipher *cipher2022
+
+	// the client session of this side.
+	sessionID []byte
+	packetID  atomic.Uint64
+	aead      cipher.AEAD
+
+	// the sessions of the remote peers, keyed by the session ID.
+	sessions map[string]*udpSession
+	// the clients of this side as the server, keyed by the address.
+	clients map[string]*udpClient
+	swept   time.Time
+	mu      sync.Mutex
+}
+
+// udpSession is the session of the remote peer.
+type udpSession struct {
+	aead   cipher.AEAD
+	psk    []byte
+	user   string
+	window replayWindow
+	last   time.Time
+}
+
+// udpClient is the server session for the remote client.
+type udpClient struct {
+	clientID []byte
+	serverID []byte
+	packetID uint64
+	psk      []byte
+	aead     cipher.AEAD
+	last     time.Time
+}
+
+func newPacketConn2022(pc net.PacketConn, c *cipher2022) *packetConn2022 {
+	sid := make([]byte, 8)
+	rand.Read(sid)
+
+	aead, _ := c.aead(deriveKey(subkeyContextSession, c.userPSK(), sid, c.keySize))
+	return &packetConn2022{
+		PacketConn: pc,
+		cipher:     c,
+		sessionID:  sid,
+		aead:       aead,
+		sessions:   make(map[string]*udpSession),
+		clients:    make(map[string]*udpClient),
+	}
+}
+
+func (c *packetConn2022) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	buf := bufpool.Get(udpBufferSize)
+	defer bufpool.Put(buf)
+
+	for {
+		var m int
+		m, addr, err = c.PacketConn.ReadFrom(*buf)
+		if err != nil {
+			return
+		}
+
+		var payload []byte
+		if payload, err = c.decode((*buf)[:m], addr); err != nil {
+			// drop the invalid packets silently.
+			continue
+		}
+		n = copy(b, payload)
+		return
+	}
+}
+
+func (c *packetConn2022) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	c.mu.Lock()
+	client := c.clients[addr.String()]
+	c.mu.Unlock()
+
+	var p []byte
+	if client != nil {
+		p, err = c.encodeServer(b, client)
+	} else {
+		p, err = c.encodeClient(b)
+	}
+	if err != nil {
+		return
+	}
+
+	if _, err = c.PacketConn.WriteTo(p, addr); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+// encodeClient encodes the client packet.
+func (c *packetConn2022) encodeClient(b []byte) ([]byte, error) {
+	header := make([]byte, 16)
+	copy(header, c.sessionID)
+	binary.BigEndian.PutUint64(header[8:], c.packetID.Add(1)-1)
+
+	mainHeader := make([]byte, 0, 11+len(b))
+	mainHeader = append(mainHeader, headerTypeClient)
+	mainHeader = binary.BigEndian.AppendUint64(mainHeader, uint64(time.Now().Unix()))
+	mainHeader = binary.BigEndian.AppendUint16(mainHeader, 0)
+	mainHeader = append(mainHeader, b...)
+
+	if c.cipher.method == Method2022ChaCha20Poly1305 {
+		return sealXChaCha(c.cipher.userPSK(), header, mainHeader)
+	}
+
+	psks := c.cipher.psks
+	block, err := aes.NewCipher(psks[0])
+	if err != nil {
+		return nil, err
+	}
+
+	p := make([]byte, 16, 16+16*(len(psks)-1)+len(mainHeader)+c.aead.Overhead())
+	block.Encrypt(p, header)
+
+	// extensible identity headers.
+	for i := 0; i < len(psks)-1; i++ {
+		block, err := aes.NewCipher(psks[i])
+		if err != nil {
+			return nil, err
+		}
+		eih := pskHash(psks[i+1])
+		for j := range eih {
+			eih[j] ^= header[j]
+		}
+		block.Encrypt(eih, eih)
+		p = append(p, eih...)
+	}
+
+	return c.aead.Seal(p, header[4:16], mainHeader, nil), nil
+}
+
+// encodeServer encodes the server packet to the client.
+func (c *packetConn2022) encodeServer(b []byte, client *udpClient) ([]byte, error) {
+	c.mu.Lock()
+	packetID := client.packetID
+	client.packetID++
+	c.mu.Unlock()
+
+	header := make([]byte, 16)
+	copy(header, client.serverID)
+	binary.BigEndian.PutUint64(header[8:], packetID)
+
+	mainHeader := make([]byte, 0, 19+len(b))
+	mainHeader = append(mainHeader, headerTypeServer)
+	mainHeader = binary.BigEndian.AppendUint64(mainHeader, uint64(time.Now().Unix()))
+	mainHeader = append(mainHeader, client.clientID...)
+	mainHeader = binary.BigEndian.AppendUint16(mainHeader, 0)
+	mainHeader = append(mainHeader, b...)
+
+	if c.cipher.method == Method2022ChaCha20Poly1305 {
+		return sealXChaCha(client.psk, header, mainHeader)
+	}
+
+	block, err := aes.NewCipher(client.psk)
+	if err != nil {
+		return nil, err
+	}
+	p := make([]byte, 16, 16+len(mainHeader)+client.aead.Overhead())
+	block.Encrypt(p, header)
+
+	return client.aead.Seal(p, header[4:16], mainHeader, nil), nil
+}
+
+func sealXChaCha(psk, header, mainHeader []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(psk)
+	if err != nil {
+		return nil, err
+	}
+
+	p := make([]byte, aead.NonceSize(), aead.NonceSize()+len(header)+len(mainHeader)+aead.Overhead())
+	if _, err := rand.Read(p); err != nil {
+		return nil, err
+	}
+	plaintext := append(append(make([]byte, 0, len(header)+len(mainHeader)), header...), mainHeader...)
+	return aead.Seal(p, p, plaintext, nil), nil
+}
+
+// decode decodes the packet from addr and returns the payload.
+func (c *packetConn2022) decode(p []byte, addr net.Addr) ([]byte, error) {
+	var header, data []byte
+	var session *udpSession
+
+	if c.cipher.method == Method2022ChaCha20Poly1305 {
+		aead, err := chacha20poly1305.NewX(c.cipher.userPSK())
+		if err != nil {
+			return nil, err
+		}
+		if len(p) < aead.NonceSize()+16+aead.Overhead() {
+			return nil, errShortPacket
+		}
+		if data, err = aead.Open(nil, p[:aead.NonceSize()], p[aead.NonceSize():], nil); err != nil {
+			return nil, err
+		}
+		header, data = data[:16], data[16:]
+
+		c.mu.Lock()
+		session = c.sessions[string(header[:8])]
+		c.mu.Unlock()
+		if session == nil {
+			session = &udpSession{
+				psk: c.cipher.userPSK(),
+			}
+		}
+	} else {
+		if len(p) < 16 {
+			return nil, errShortPacket
+		}
+		block, err := aes.NewCipher(c.cipher.userPSK())
+		if err != nil {
+			return nil, err
+		}
+		header = make([]byte, 16)
+		block.Decrypt(header, p[:16])
+		p = p[16:]
+
+		var eih []byte
+		if c.cipher.users.enabled() {
+			if len(p) < aes.BlockSize {
+				return nil, errShortPacket
+			}
+			eih, p = p[:aes.BlockSize], p[aes.BlockSize:]
+		}
+
+		c.mu.Lock()
+		session = c.sessions[string(header[:8])]
+		c.mu.Unlock()
+
+		if session == nil {
+			session = &udpSession{
+				psk: c.cipher.userPSK(),
+			}
+			if eih != nil {
+				hash := make([]byte, aes.BlockSize)
+				block.Decrypt(hash, eih)
+				for i := range hash {
+					hash[i] ^= header[i]
+				}
+				u, ok := c.cipher.users.lookup(hash)
+				if !ok {
+					return nil, ErrUnknownUser
+				}
+				session.user, session.psk = u.name, u.psk
+			}
+			if session.aead, err = c.cipher.aead(deriveKey(subkeyContextSession, session.psk, header[:8], c.cipher.keySize)); err != nil {
+				return nil, err
+			}
+		}
+
+		if data, err = session.aead.Open(nil, header[4:16], p, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) < 11 {
+		return nil, errShortPacket
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(data[1:])); err != nil {
+		return nil, err
+	}
+
+	var client *udpClient
+	switch data[0] {
+	case headerTypeClient:
+		data = data[9:]
+		client = &udpClient{
+			clientID: header[:8],
+			psk:      session.psk,
+		}
+	case headerTypeServer:
+		if len(data) < 19 {
+			return nil, errShortPacket
+		}
+		if string(data[9:17]) != string(c.sessionID) {
+			return nil, ErrBadSalt
+		}
+		data = data[17:]
+	default:
+		return nil, ErrBadHeaderType
+	}
+
+	padding := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+padding {
+		return nil, errShortPacket
+	}
+	data = data[2+padding:]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !session.window.check(binary.BigEndian.Uint64(header[8:])) {
+		return nil, ErrReplay
+	}
+	now := time.Now()
+	c.expire(now)
+	if session.last.IsZero() {
+		c.sessions[string(header[:8])] = session
+	}
+	session.last = now
+
+	if client != nil {
+		if v := c.clients[addr.String()]; v != nil && string(v.clientID) == string(client.clientID) {
+			v.last = now
+			return data, nil
+		}
+		client.last = now
+		// new client session.
+		client.serverID = make([]byte, 8)
+		rand.Read(client.serverID)
+		if c.cipher.method != Method2022ChaCha20Poly1305 {
+			aead, err := c.cipher.aead(deriveKey(subkeyContextSession, client.psk, client.serverID, c.cipher.keySize))
+			if err != nil {
+				return nil, err
+			}
+			client.aead = aead
+		}
+		c.clients[addr.String()] = client
+	}
+
+	return data, nil
+}
+
+// expire removes the inactive sessions and clients, at most once in the sweep interval.
+func (c *packetConn2022) expire(now time.Time) {
+	if now.Sub(c.swept) < udpSweepInterval {
+		return
+	}
+	c.swept = now
+
+	for k, s := range c.sessions {
+		if now.Sub(s.last) > udpSessionTTL {
+			delete(c.sessions, k)
+		}
+	}
+	for k, client := range c.clients {
+		if now.Sub(client.last) > udpSessionTTL {
+			delete(c.clients, k)
+		}
+	}
+}
+
+const (
+	replayWindowSize  = 1024
+	replayWindowWords = replayWindowSize / 64
+)
+
+// replayWindow is the sliding window filter of the UDP packet IDs.
+type replayWindow struct {
+	last uint64
+	bits [replayWindowWords]uint64
+}
+
+// check reports whether the packet ID is not seen before and marks it as seen.
+func (w *replayWindow) check(id uint64) bool {
+	if id > w.last {
+		if id-w.last >= replayWindowSize {
+			w.bits = [replayWindowWords]uint64{}
+		} else {
+			for i := w.last + 1; i < id; i++ {
+				w.bits[(i%replayWindowSize)/64] &^= 1 << (i % 64)
+			}
+		}
+		w.last = id
+		w.bits[(id%replayWindowSize)/64] |= 1 << (id % 64)
+		return true
+	}
+
+	if w.last-id >= replayWindowSize {
+		return false
+	}
+	word, bit := (id%replayWindowSize)/64, uint64(1)<<(id%64)
+	if w.bits[word]&bit != 0 {
+		return false
+	}
+	w.bits[word] |= bit
+	return true
+}
@@ -0,0 +1,299 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	dissector "github.com/go-gost/tls-dissector"
+	xnet "github.com/wznpp1/gost_x/internal/net"
+)
+
+const (
+	defaultCamouflageTimeout = 10 * time.Second
+	// camouflageMaxTimeDiff is the max time difference of the session ID token.
+	camouflageMaxTimeDiff  = 2 * time.Minute
+	camouflageSessionIDLen = 32
+)
+
+var (
+	errCamouflageServerName = errors.New("camouflage: server name mismatch")
+	errCamouflageSessionID  = errors.New("camouflage: bad session ID")
+	errCamouflageReplay     = errors.New("camouflage: replayed session ID")
+)
+
+// Camouflage is the camouflage mode of the TLS listener.
+//
+// The ClientHello of the connection is checked before the TLS handshake,
+// the connection is spliced to the real site at Addr if the check fails,
+// so the active prober sees the certificate and content of the real site.
+//
+// Encrypted Client Hello (ECH) is out of scope: crypto/tls of Go 1.20 can not
+// decrypt the inner ClientHello, so the SNI checked here is the outer one in plain text.
+type Camouflage struct {
+	// Addr is the address of the real site, such as www.example.com:443.
+	Addr string
+	// ServerNames are the accepted SNIs, the name with the prefix "*." matches the subdomains.
+	// Any SNI is accepted if it is empty.
+	ServerNames []string
+	// Key is the shared key of the session ID token, which is made by the client with CamouflageKeyClientOption.
+	// The token is not checked if it is empty.
+	Key string
+	// Timeout is the timeout of reading the ClientHello.
+	Timeout time.Duration
+	Logger  logger.Logger
+
+	mu      sync.Mutex
+	replays map[[camouflageSessionIDLen]byte]time.Time
+}
+
+func (c *Camouflage) check(hello *dissector.ClientHelloMsg) error {
+	if len(c.ServerNames) > 0 {
+		var serverName string
+		for _, ext := range hello.Extensions {
+			if ext, ok := ext.(*dissector.ServerNameExtension); ok {
+				serverName = ext.Name
+				break
+			}
+		}
+		if !matchServerName(c.ServerNames, serverName) {
+			return errCamouflageServerName
+		}
+	}
+
+	if c.Key == "" {
+		return nil
+	}
+
+	random := make([]byte, 0, 32)
+	random = binary.BigEndian.AppendUint32(random, hello.Random.Time)
+	random = append(random, hello.Random.Opaque[:]...)
+	if !verifyCamouflageSessionID(c.Key, random, hello.SessionID, time.Now()) {
+		return errCamouflageSessionID
+	}
+
+	var sid [camouflageSessionIDLen]byte
+	copy(sid[:], hello.SessionID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.replays == nil {
+		c.replays = make(map[[camouflageSessionIDLen]byte]time.Time)
+	}
+	if _, ok := c.replays[sid]; ok {
+		return errCamouflageReplay
+	}
+	for k, t := range c.replays {
+		if now.Sub(t) > 2*camouflageMaxTimeDiff {
+			delete(c.replays, k)
+		}
+	}
+	c.replays[sid] = now
+
+	return nil
+}
+
+func matchServerName(names []string, serverName string) bool {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if serverName == "" {
+		return false
+	}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == serverName {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") && strings.HasSuffix(serverName, name[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// camouflageSessionID creates the session ID token of the ClientHello random:
+//
+//	+-----------+------------------------------------+
+//	| TIMESTAMP | HMAC-SHA256(KEY, RANDOM|TIMESTAMP) |
+//	+-----------+------------------------------------+
+//	|     8     |                 24                 |
+//	+-----------+------------------------------------+
+func camouflageSessionID(key string, random []byte, t time.Time) []byte {
+	sid := binary.BigEndian.AppendUint64(make([]byte, 0, camouflageSessionIDLen), uint64(t.Unix()))
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(random)
+	mac.Write(sid)
+	return mac.Sum(sid)[:camouflageSessionIDLen]
+}
+
+func verifyCamouflageSessionID(key string, random, sid []byte, now time.Time) bool {
+	if len(sid) != camouflageSessionIDLen {
+		return false
+	}
+	t := time.Unix(int64(binary.BigEndian.Uint64(sid)), 0)
+	if d := now.Sub(t); d > camouflageMaxTimeDiff || d < -camouflageMaxTimeDiff {
+		return false
+	}
+	return hmac.Equal(sid, camouflageSessionID(key, random, t))
+}
+
+type camouflageListener struct {
+	net.Listener
+	camouflage *Camouflage
+	cqueue     chan net.Conn
+	errChan    chan error
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// WrapCamouflageListener wraps the listener in the camouflage mode,
+// it is placed beneath the TLS listener and only the accepted connections are returned.
+// The listener is returned unchanged if the camouflage is nil or has no address.
+func WrapCamouflageListener(camouflage *Camouflage, ln net.Listener) net.Listener {
+	if camouflage == nil || camouflage.Addr == "" {
+		return ln
+	}
+
+	l := &camouflageListener{
+		Listener:   ln,
+		camouflage: camouflage,
+		cqueue:     make(chan net.Conn),
+		errChan:    make(chan error, 1),
+		closed:     make(chan struct{}),
+	}
+	go l.listenLoop()
+
+	return l
+}
+
+func (l *camouflageListener) listenLoop() {
+	var tempDelay time.Duration
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			// TODO: remove Temporary checking
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				select {
+				case <-time.After(tempDelay):
+					continue
+				case <-l.closed:
+				}
+			}
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		tempDelay = 0
+
+		go l.handle(conn)
+	}
+}
+
+func (l *camouflageListener) handle(conn net.Conn) {
+	timeout := l.camouflage.Timeout
+	if timeout <= 0 {
+		timeout = defaultCamouflageTimeout
+	}
+
+	buf := new(bytes.Buffer)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	err := l.readClientHello(io.TeeReader(conn, buf))
+	conn.SetReadDeadline(time.Time{})
+
+	if err == nil {
+		select {
+		case l.cqueue <- &camouflageConn{Conn: conn, r: io.MultiReader(buf, conn)}:
+		case <-l.closed:
+			conn.Close()
+		}
+		return
+	}
+
+	defer conn.Close()
+
+	log := l.camouflage.Logger
+	if log == nil {
+		log = logger.Default()
+	}
+	log = log.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+	log.Debugf("%v, fallback to %s", err, l.camouflage.Addr)
+
+	cc, err := net.DialTimeout("tcp", l.camouflage.Addr, timeout)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer cc.Close()
+
+	if _, err := buf.WriteTo(cc); err != nil {
+		log.Error(err)
+		return
+	}
+	xnet.Transport(conn, cc)
+}
+
+func (l *camouflageListener) readClientHello(r io.Reader) error {
+	record, err := dissector.ReadRecord(r)
+	if err != nil {
+		return err
+	}
+	if record.Type != dissector.Handshake {
+		return dissector.ErrBadType
+	}
+
+	hello := &dissector.ClientHelloMsg{}
+	if err := hello.Decode(record.Opaque); err != nil {
+		return err
+	}
+	return l.camouflage.check(hello)
+}
+
+func (l *camouflageListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = net.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *camouflageListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
+
+// camouflageConn replays the ClientHello read by the listener.
+type camouflageConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *camouflageConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
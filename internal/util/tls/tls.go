@@ -1,6 +1,7 @@
 package tls
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -101,6 +102,28 @@ func loadCA(caFile string) (cp *x509.CertPool, err error) {
 	return
 }
 
+type ClientOptions struct {
+	Fingerprint   string
+	CamouflageKey string
+}
+
+type ClientOption func(opts *ClientOptions)
+
+// FingerprintClientOption sets the fingerprint of the ClientHello.
+func FingerprintClientOption(fingerprint string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.Fingerprint = fingerprint
+	}
+}
+
+// CamouflageKeyClientOption sets the key of the session ID token checked by the camouflage listener,
+// see Camouflage for details.
+func CamouflageKeyClientOption(key string) ClientOption {
+	return func(opts *ClientOptions) {
+		opts.CamouflageKey = key
+	}
+}
+
 // Wrap a net.Conn into a client tls connection, performing any
 // additional verification as needed.
 //
@@ -114,7 +137,16 @@ func loadCA(caFile string) (cp *x509.CertPool, err error) {
 //
 // This code is taken from consul:
 // https://github.com/hashicorp/consul/blob/master/tlsutil/config.go
-func WrapTLSClient(conn net.Conn, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+//
+// If the fingerprint is set by FingerprintClientOption, the handshake is made by uTLS with the ClientHello of the fingerprint,
+// see ValidateFingerprint for the known fingerprints.
+// The connection is closed if the handshake fails.
+func WrapTLSClient(ctx context.Context, conn net.Conn, tlsConfig *tls.Config, timeout time.Duration, opts ...ClientOption) (net.Conn, error) {
+	var options ClientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	var err error
 	var tlsConn *tls.Conn
 
@@ -123,13 +155,30 @@ func WrapTLSClient(conn net.Conn, tlsConfig *tls.Config, timeout time.Duration)
 		defer conn.SetDeadline(time.Time{})
 	}
 
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	if options.CamouflageKey != "" && options.Fingerprint == "" {
+		// the session ID token can only be made by uTLS.
+		options.Fingerprint = FingerprintChrome
+	}
+	if options.Fingerprint != "" {
+		uconn, err := uClient(ctx, conn, tlsConfig, &options)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return uconn, nil
+	}
+
 	tlsConn = tls.Client(conn, tlsConfig)
 
 	// Otherwise perform handshake, but don't verify the domain
 	//
 	// The following is lightly-modified from the doFullHandshake
 	// method in https://golang.org/src/crypto/tls/handshake_client.go
-	if err = tlsConn.Handshake(); err != nil {
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
 		tlsConn.Close()
 		return nil, err
 	}
@@ -0,0 +1,159 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+const (
+	FingerprintChrome     = "chrome"
+	FingerprintFirefox    = "firefox"
+	FingerprintSafari     = "safari"
+	FingerprintIOS        = "ios"
+	FingerprintEdge       = "edge"
+	FingerprintAndroid    = "android"
+	FingerprintRandomized = "randomized"
+)
+
+var fingerprints = map[string]utls.ClientHelloID{
+	FingerprintChrome:     utls.HelloChrome_Auto,
+	FingerprintFirefox:    utls.HelloFirefox_Auto,
+	FingerprintSafari:     utls.HelloSafari_Auto,
+	FingerprintIOS:        utls.HelloIOS_Auto,
+	FingerprintEdge:       utls.HelloEdge_Auto,
+	FingerprintAndroid:    utls.HelloAndroid_11_OkHttp,
+	FingerprintRandomized: utls.HelloRandomized,
+}
+
+// ValidateFingerprint checks whether the fingerprint is known, the empty fingerprint means the Go ClientHello.
+func ValidateFingerprint(fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	if _, ok := fingerprints[fingerprint]; !ok {
+		return fmt.Errorf("unknown TLS fingerprint %s", fingerprint)
+	}
+	return nil
+}
+
+// uClient performs the client handshake with the ClientHello of the fingerprint.
+// The ALPN protocols of the fingerprint are replaced by the NextProtos of the config if present,
+// so that the server does not select a protocol the caller can not speak.
+func uClient(ctx context.Context, conn net.Conn, tlsConfig *tls.Config, options *ClientOptions) (*utls.UConn, error) {
+	id, ok := fingerprints[options.Fingerprint]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS fingerprint %s", options.Fingerprint)
+	}
+	if id == utls.HelloRandomized && len(tlsConfig.NextProtos) > 0 {
+		id = utls.HelloRandomizedALPN
+	}
+
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsConfig.NextProtos) > 0 {
+		setALPN(&spec, tlsConfig.NextProtos)
+	}
+
+	uconn := utls.UClient(conn, uConfig(tlsConfig), utls.HelloCustom)
+	if err := uconn.ApplyPreset(&spec); err != nil {
+		return nil, err
+	}
+	if options.CamouflageKey != "" {
+		hello := uconn.HandshakeState.Hello
+		hello.SessionId = camouflageSessionID(options.CamouflageKey, hello.Random, time.Now())
+	}
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return uconn, nil
+}
+
+// setALPN sets the protocols of the ALPN extension,
+// the extension is added before the padding if the spec does not have one.
+func setALPN(spec *utls.ClientHelloSpec, protos []string) {
+	for _, ext := range spec.Extensions {
+		if alpn, ok := ext.(*utls.ALPNExtension); ok {
+			alpn.AlpnProtocols = protos
+			return
+		}
+	}
+
+	i := len(spec.Extensions)
+	for j, ext := range spec.Extensions {
+		if _, ok := ext.(*utls.UtlsPaddingExtension); ok {
+			i = j
+			break
+		}
+	}
+	exts := append([]utls.TLSExtension{}, spec.Extensions[:i]...)
+	exts = append(exts, &utls.ALPNExtension{AlpnProtocols: protos})
+	spec.Extensions = append(exts, spec.Extensions[i:]...)
+}
+
+// uConfig converts the config to the uTLS config, only the client side fields are taken.
+func uConfig(cfg *tls.Config) *utls.Config {
+	config := &utls.Config{
+		Rand:                  cfg.Rand,
+		Time:                  cfg.Time,
+		RootCAs:               cfg.RootCAs,
+		NextProtos:            cfg.NextProtos,
+		ServerName:            cfg.ServerName,
+		InsecureSkipVerify:    cfg.InsecureSkipVerify,
+		VerifyPeerCertificate: cfg.VerifyPeerCertificate,
+		MinVersion:            cfg.MinVersion,
+		MaxVersion:            cfg.MaxVersion,
+		KeyLogWriter:          cfg.KeyLogWriter,
+	}
+	for _, cert := range cfg.Certificates {
+		config.Certificates = append(config.Certificates, utls.Certificate{
+			Certificate:                 cert.Certificate,
+			PrivateKey:                  cert.PrivateKey,
+			OCSPStaple:                  cert.OCSPStaple,
+			SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+			Leaf:                        cert.Leaf,
+		})
+	}
+	if verify := cfg.VerifyConnection; verify != nil {
+		config.VerifyConnection = func(state utls.ConnectionState) error {
+			return verify(connectionState(&state))
+		}
+	}
+	return config
+}
+
+// ConnectionState returns the TLS state of the connection established by WrapTLSClient.
+func ConnectionState(conn net.Conn) (tls.ConnectionState, bool) {
+	switch c := conn.(type) {
+	case *tls.Conn:
+		return c.ConnectionState(), true
+	case *utls.UConn:
+		state := c.ConnectionState()
+		return connectionState(&state), true
+	default:
+		return tls.ConnectionState{}, false
+	}
+}
+
+func connectionState(state *utls.ConnectionState) tls.ConnectionState {
+	return tls.ConnectionState{
+		Version:                     state.Version,
+		HandshakeComplete:           state.HandshakeComplete,
+		DidResume:                   state.DidResume,
+		CipherSuite:                 state.CipherSuite,
+		NegotiatedProtocol:          state.NegotiatedProtocol,
+		NegotiatedProtocolIsMutual:  state.NegotiatedProtocolIsMutual,
+		ServerName:                  state.ServerName,
+		PeerCertificates:            state.PeerCertificates,
+		VerifiedChains:              state.VerifiedChains,
+		SignedCertificateTimestamps: state.SignedCertificateTimestamps,
+		OCSPResponse:                state.OCSPResponse,
+		TLSUnique:                   state.TLSUnique,
+	}
+}
@@ -0,0 +1,214 @@
+package trojan
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/go-gost/gosocks5"
+)
+
+const (
+	CmdConnect      uint8 = 0x01
+	CmdUDPAssociate uint8 = 0x03
+)
+
+const (
+	// KeyLen is the length of the hex encoded SHA224 of the password.
+	KeyLen = sha256.Size224 * 2
+)
+
+var (
+	crlf = []byte{'\r', '\n'}
+
+	ErrBadRequest = errors.New("trojan: bad request")
+)
+
+// Key returns the key of the password used to authenticate the client.
+func Key(password string) []byte {
+	h := sha256.Sum224([]byte(password))
+	key := make([]byte, KeyLen)
+	hex.Encode(key, h[:])
+	return key
+}
+
+// Request is the Trojan request header:
+//
+//	+-----------------------+---------+----------------+---------+----------+
+//	| hex(SHA224(password)) |  CRLF   | Trojan Request |  CRLF   | Payload  |
+//	+-----------------------+---------+----------------+---------+----------+
+//	|          56           | X'0D0A' |    Variable    | X'0D0A' | Variable |
+//	+-----------------------+---------+----------------+---------+----------+
+//
+// The Trojan Request is the CMD and the SOCKS5 address.
+type Request struct {
+	Key  []byte
+	Cmd  uint8
+	Addr *gosocks5.Addr
+}
+
+func (r *Request) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.Write(r.Key)
+	buf.Write(crlf)
+	buf.WriteByte(r.Cmd)
+
+	addr := r.Addr
+	if addr == nil {
+		addr = &gosocks5.Addr{}
+		addr.ParseFrom("0.0.0.0:0")
+	}
+	if _, err := addr.WriteTo(&buf); err != nil {
+		return 0, err
+	}
+	buf.Write(crlf)
+
+	return buf.WriteTo(w)
+}
+
+// ReadFrom reads the request, the key is read separately by ReadKey.
+func (r *Request) ReadFrom(br *bufio.Reader) (n int64, err error) {
+	if r.Cmd, err = br.ReadByte(); err != nil {
+		return
+	}
+	n++
+
+	r.Addr = &gosocks5.Addr{}
+	nn, err := r.Addr.ReadFrom(br)
+	n += nn
+	if err != nil {
+		return
+	}
+
+	err = readCRLF(br)
+	n += 2
+	return
+}
+
+// ReadKey peeks the key from the buffered reader,
+// the key is kept in the reader until it is discarded by the caller.
+func ReadKey(br *bufio.Reader) ([]byte, error) {
+	b, err := br.Peek(KeyLen + 2)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[KeyLen:], crlf) {
+		return nil, ErrBadRequest
+	}
+
+	key := make([]byte, KeyLen)
+	copy(key, b)
+	return key, nil
+}
+
+func readCRLF(r io.Reader) error {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(b[:], crlf) {
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// udpConn is the UDP relay over the Trojan stream, the packets are in the form of:
+//
+//	+------+----------+----------+--------+---------+----------+
+//	| ATYP | DST.ADDR | DST.PORT | Length |  CRLF   | Payload  |
+//	+------+----------+----------+--------+---------+----------+
+//	|  1   | Variable |    2     |   2    | X'0D0A' | Variable |
+//	+------+----------+----------+--------+---------+----------+
+type udpConn struct {
+	net.Conn
+	br    *bufio.Reader
+	taddr net.Addr
+}
+
+// UDPClientConn creates the client side UDP relay connection,
+// the Write sends the packets to the target address.
+func UDPClientConn(c net.Conn, targetAddr net.Addr) net.Conn {
+	return &udpConn{
+		Conn:  c,
+		br:    bufio.NewReader(c),
+		taddr: targetAddr,
+	}
+}
+
+// UDPServerConn creates the server side UDP relay connection.
+func UDPServerConn(c net.Conn) net.PacketConn {
+	return &udpConn{
+		Conn: c,
+		br:   bufio.NewReader(c),
+	}
+}
+
+func (c *udpConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	socksAddr := gosocks5.Addr{}
+	if _, err = socksAddr.ReadFrom(c.br); err != nil {
+		return
+	}
+
+	var lb [2]byte
+	if _, err = io.ReadFull(c.br, lb[:]); err != nil {
+		return
+	}
+	if err = readCRLF(c.br); err != nil {
+		return
+	}
+
+	length := int(binary.BigEndian.Uint16(lb[:]))
+	if length > len(b) {
+		if _, err = io.ReadFull(c.br, b); err != nil {
+			return
+		}
+		_, err = c.br.Discard(length - len(b))
+		n = len(b)
+	} else {
+		n, err = io.ReadFull(c.br, b[:length])
+	}
+	if err != nil {
+		return
+	}
+
+	addr, err = net.ResolveUDPAddr("udp", socksAddr.String())
+	return
+}
+
+func (c *udpConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *udpConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if len(b) > 0xFFFF {
+		return 0, errors.New("trojan: packet too large")
+	}
+
+	socksAddr := gosocks5.Addr{}
+	if err = socksAddr.ParseFrom(addr.String()); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err = socksAddr.WriteTo(&buf); err != nil {
+		return
+	}
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(b))))
+	buf.Write(crlf)
+	buf.Write(b)
+
+	if _, err = c.Conn.Write(buf.Bytes()); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *udpConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.taddr)
+}
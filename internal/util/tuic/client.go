@@ -0,0 +1,187 @@
+package tuic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/google/uuid"
+	"github.com/quic-go/quic-go"
+)
+
+var (
+	ErrAuth              = errors.New("tuic: authentication failed")
+	ErrInvalidConnection = errors.New("tuic: invalid connection")
+)
+
+const (
+	defaultHeartbeat = 10 * time.Second
+)
+
+// ClientConfig is the config of the client.
+type ClientConfig struct {
+	UUID     uuid.UUID
+	Password string
+	// UDPMode is the mode of the UDP relay, native or quic.
+	UDPMode string
+	// Heartbeat is the interval of the heartbeats which keep the connection alive when the UDP relay is active.
+	Heartbeat time.Duration
+	Logger    logger.Logger
+}
+
+// Client is the QUIC connection to the server.
+type Client struct {
+	conn   quic.EarlyConnection
+	config ClientConfig
+	udp    *UDPMux
+}
+
+// NewClient creates the client on the QUIC connection which may be in the 0-RTT state,
+// the client is authenticated once the handshake is complete,
+// the commands before it are handled by the server after the authentication.
+func NewClient(conn quic.EarlyConnection, config *ClientConfig) *Client {
+	c := &Client{
+		conn: conn,
+	}
+	if config != nil {
+		c.config = *config
+	}
+	if c.config.UDPMode == "" {
+		c.config.UDPMode = UDPModeNative
+	}
+	if c.config.Heartbeat <= 0 {
+		c.config.Heartbeat = defaultHeartbeat
+	}
+	if c.config.Logger == nil {
+		c.config.Logger = logger.Default()
+	}
+	c.udp = NewUDPMux(conn, c.config.UDPMode)
+
+	go c.authenticate()
+	go c.serveUniStreams()
+	go c.serveDatagrams()
+	go c.heartbeat()
+
+	return c
+}
+
+func (c *Client) authenticate() {
+	select {
+	case <-c.conn.HandshakeComplete().Done():
+	case <-c.conn.Context().Done():
+		return
+	}
+
+	err := func() error {
+		state := c.conn.ConnectionState().TLS
+		token, err := Token(&state.ConnectionState, c.config.UUID, c.config.Password)
+		if err != nil {
+			return err
+		}
+
+		stream, err := c.conn.OpenUniStream()
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		_, err = (&Header{
+			Cmd:   CmdAuthenticate,
+			UUID:  c.config.UUID,
+			Token: token,
+		}).WriteTo(stream)
+		return err
+	}()
+	if err != nil {
+		c.config.Logger.Error(err)
+		c.conn.CloseWithError(0, err.Error())
+	}
+}
+
+// serveUniStreams receives the packets sent in the quic mode.
+func (c *Client) serveUniStreams() {
+	for {
+		stream, err := c.conn.AcceptUniStream(context.Background())
+		if err != nil {
+			return
+		}
+
+		go func() {
+			h := &Header{}
+			if _, err := h.ReadFrom(stream); err != nil || h.Cmd != CmdPacket {
+				stream.CancelRead(0)
+				return
+			}
+			data, err := ReadPacket(stream, h)
+			if err != nil {
+				return
+			}
+			c.udp.Feed(h, data, UDPModeQUIC, nil)
+		}()
+	}
+}
+
+// serveDatagrams receives the packets sent in the native mode.
+func (c *Client) serveDatagrams() {
+	defer c.udp.CloseAll()
+
+	for {
+		b, err := c.conn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		h, data, err := ParseDatagram(b)
+		if err != nil || h.Cmd != CmdPacket {
+			continue
+		}
+		c.udp.Feed(h, data, UDPModeNative, nil)
+	}
+}
+
+func (c *Client) heartbeat() {
+	ticker := time.NewTicker(c.config.Heartbeat)
+	defer ticker.Stop()
+
+	b, _ := (&Header{Cmd: CmdHeartbeat}).Bytes()
+	for {
+		select {
+		case <-ticker.C:
+			if c.udp.Len() > 0 {
+				c.conn.SendMessage(b)
+			}
+		case <-c.conn.Context().Done():
+			return
+		}
+	}
+}
+
+// DialTCP relays to addr by the Connect command, the stream can be sent in the 0-RTT data.
+func (c *Client) DialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn := NewTCPConn(stream, c.conn.LocalAddr(), c.conn.RemoteAddr(), addr, "")
+
+	if _, err := (&Header{Cmd: CmdConnect, Addr: addr}).WriteTo(stream); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// DialUDP creates the UDP association, the Write of the connection sends the packets to addr.
+func (c *Client) DialUDP(addr net.Addr) (net.Conn, error) {
+	return c.udp.NewConn(addr), nil
+}
+
+// Closed returns a channel which is closed when the client is closed.
+func (c *Client) Closed() <-chan struct{} {
+	return c.conn.Context().Done()
+}
+
+func (c *Client) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
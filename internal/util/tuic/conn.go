@@ -0,0 +1,109 @@
+package tuic
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// TCPConn is the stream of the Connect command.
+type TCPConn struct {
+	quic.Stream
+	laddr   net.Addr
+	raddr   net.Addr
+	dstAddr string
+	user    string
+}
+
+func NewTCPConn(stream quic.Stream, laddr, raddr net.Addr, dstAddr string, user string) *TCPConn {
+	return &TCPConn{
+		Stream:  stream,
+		laddr:   laddr,
+		raddr:   raddr,
+		dstAddr: dstAddr,
+		user:    user,
+	}
+}
+
+// DstAddr returns the target address of the command.
+func (c *TCPConn) DstAddr() string {
+	return c.dstAddr
+}
+
+// User returns the user authenticated by the server.
+func (c *TCPConn) User() string {
+	return c.user
+}
+
+func (c *TCPConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *TCPConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// Close closes both directions of the stream.
+func (c *TCPConn) Close() error {
+	c.Stream.CancelRead(0)
+	return c.Stream.Close()
+}
+
+// ClientConn is the placeholder connection of the client returned by the dialer,
+// the connector relays through the client of it.
+type ClientConn struct {
+	net.Conn
+	client *Client
+}
+
+func NewClientConn(client *Client) net.Conn {
+	return &ClientConn{
+		Conn:   &nopConn{},
+		client: client,
+	}
+}
+
+func (c *ClientConn) Client() *Client {
+	return c.client
+}
+
+// Close does not close the client which is shared by the connections.
+func (c *ClientConn) Close() error {
+	return nil
+}
+
+type nopConn struct{}
+
+func (c *nopConn) Close() error {
+	return nil
+}
+
+func (c *nopConn) Read(b []byte) (n int, err error) {
+	return 0, &net.OpError{Op: "read", Net: "nop", Source: nil, Addr: nil, Err: errors.New("read not supported")}
+}
+
+func (c *nopConn) Write(b []byte) (n int, err error) {
+	return 0, &net.OpError{Op: "write", Net: "nop", Source: nil, Addr: nil, Err: errors.New("write not supported")}
+}
+
+func (c *nopConn) LocalAddr() net.Addr {
+	return &net.UDPAddr{}
+}
+
+func (c *nopConn) RemoteAddr() net.Addr {
+	return &net.UDPAddr{}
+}
+
+func (c *nopConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *nopConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *nopConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
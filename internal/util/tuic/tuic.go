@@ -0,0 +1,272 @@
+package tuic
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Version = 0x05
+)
+
+const (
+	CmdAuthenticate uint8 = 0x00
+	CmdConnect      uint8 = 0x01
+	CmdPacket       uint8 = 0x02
+	CmdDissociate   uint8 = 0x03
+	CmdHeartbeat    uint8 = 0x04
+)
+
+const (
+	AddrNone   uint8 = 0xff
+	AddrDomain uint8 = 0x00
+	AddrIPv4   uint8 = 0x01
+	AddrIPv6   uint8 = 0x02
+)
+
+const (
+	// TokenLen is the length of the authentication token.
+	TokenLen = 32
+
+	// UDPModeNative relays the UDP packets in the QUIC datagrams.
+	UDPModeNative = "native"
+	// UDPModeQUIC relays the UDP packets in the QUIC unidirectional streams.
+	UDPModeQUIC = "quic"
+)
+
+var (
+	ErrBadVersion = errors.New("tuic: bad version")
+	ErrBadCommand = errors.New("tuic: bad command")
+	ErrBadAddress = errors.New("tuic: bad address")
+)
+
+// ID returns the UUID of s, the s which is not a UUID is mapped to the name based UUID.
+func ID(s string) uuid.UUID {
+	if id, err := uuid.Parse(s); err == nil {
+		return id
+	}
+	return uuid.NewSHA1(uuid.Nil, []byte(s))
+}
+
+// Token returns the authentication token which is exported from the TLS keying material of the connection,
+// with the UUID as the label and the password as the context.
+func Token(state *tls.ConnectionState, id uuid.UUID, password string) ([]byte, error) {
+	return state.ExportKeyingMaterial(string(id[:]), []byte(password), TokenLen)
+}
+
+// Header is the header of the commands:
+//
+//	+-----+------+----------+
+//	| VER | TYPE |   OPT    |
+//	+-----+------+----------+
+//	|  1  |  1   | Variable |
+//	+-----+------+----------+
+type Header struct {
+	Cmd uint8
+
+	// Authenticate
+	UUID  uuid.UUID
+	Token []byte
+
+	// Connect and Packet
+	Addr string
+
+	// Packet and Dissociate
+	AssocID   uint16
+	PacketID  uint16
+	FragTotal uint8
+	FragID    uint8
+	Size      uint16
+}
+
+func (h *Header) Bytes() ([]byte, error) {
+	b := []byte{Version, h.Cmd}
+	switch h.Cmd {
+	case CmdAuthenticate:
+		if len(h.Token) != TokenLen {
+			return nil, errors.New("tuic: bad token")
+		}
+		b = append(b, h.UUID[:]...)
+		b = append(b, h.Token...)
+	case CmdConnect:
+		return AppendAddr(b, h.Addr)
+	case CmdPacket:
+		b = binary.BigEndian.AppendUint16(b, h.AssocID)
+		b = binary.BigEndian.AppendUint16(b, h.PacketID)
+		b = append(b, h.FragTotal, h.FragID)
+		b = binary.BigEndian.AppendUint16(b, h.Size)
+		return AppendAddr(b, h.Addr)
+	case CmdDissociate:
+		b = binary.BigEndian.AppendUint16(b, h.AssocID)
+	case CmdHeartbeat:
+	default:
+		return nil, ErrBadCommand
+	}
+	return b, nil
+}
+
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	b, err := h.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (h *Header) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [2]byte
+	nn, err := io.ReadFull(r, b[:])
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+	if b[0] != Version {
+		return n, ErrBadVersion
+	}
+	h.Cmd = b[1]
+
+	switch h.Cmd {
+	case CmdAuthenticate:
+		buf := make([]byte, 16+TokenLen)
+		nn, err = io.ReadFull(r, buf)
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		copy(h.UUID[:], buf)
+		h.Token = buf[16:]
+	case CmdConnect:
+		var nn int64
+		h.Addr, nn, err = ReadAddr(r)
+		n += nn
+	case CmdPacket:
+		var buf [8]byte
+		nn, err = io.ReadFull(r, buf[:])
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		h.AssocID = binary.BigEndian.Uint16(buf[:])
+		h.PacketID = binary.BigEndian.Uint16(buf[2:])
+		h.FragTotal = buf[4]
+		h.FragID = buf[5]
+		h.Size = binary.BigEndian.Uint16(buf[6:])
+
+		var nn int64
+		h.Addr, nn, err = ReadAddr(r)
+		n += nn
+	case CmdDissociate:
+		var buf [2]byte
+		nn, err = io.ReadFull(r, buf[:])
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		h.AssocID = binary.BigEndian.Uint16(buf[:])
+	case CmdHeartbeat:
+	default:
+		err = ErrBadCommand
+	}
+	return
+}
+
+// AppendAddr appends the address:
+//
+//	+------+----------+------+
+//	| TYPE |   ADDR   | PORT |
+//	+------+----------+------+
+//	|  1   | Variable |  2   |
+//	+------+----------+------+
+//
+// The empty address is of the type None which has no ADDR and PORT.
+func AppendAddr(b []byte, addr string) ([]byte, error) {
+	if addr == "" {
+		return append(b, AddrNone), nil
+	}
+
+	host, sport, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("tuic: bad port %s", sport)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, AddrIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, AddrIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, ErrBadAddress
+		}
+		b = append(b, AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+// ReadAddr reads the address, the address of the type None is empty.
+func ReadAddr(r io.Reader) (addr string, n int64, err error) {
+	var b [1]byte
+	nn, err := io.ReadFull(r, b[:])
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+
+	var host string
+	switch b[0] {
+	case AddrNone:
+		return
+	case AddrIPv4, AddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if b[0] == AddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		nn, err = io.ReadFull(r, ip)
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		host = ip.String()
+	case AddrDomain:
+		nn, err = io.ReadFull(r, b[:])
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		domain := make([]byte, b[0])
+		nn, err = io.ReadFull(r, domain)
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+		host = string(domain)
+	default:
+		err = ErrBadAddress
+		return
+	}
+
+	var port [2]byte
+	nn, err = io.ReadFull(r, port[:])
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+	addr = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	return
+}
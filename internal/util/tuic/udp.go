@@ -0,0 +1,457 @@
+package tuic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+var (
+	ErrUDPClosed   = errors.New("tuic: UDP association closed")
+	ErrUDPTooLarge = errors.New("tuic: UDP packet too large")
+)
+
+const (
+	// maxDatagramSize is the max size of the packet carried in one QUIC datagram,
+	// the larger packet is fragmented.
+	maxDatagramSize = 1150
+	udpQueueSize    = 128
+)
+
+// ReadPacket reads the payload of the packet whose header is read.
+func ReadPacket(r io.Reader, h *Header) ([]byte, error) {
+	data := make([]byte, h.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParseDatagram parses the command carried in the QUIC datagram.
+func ParseDatagram(b []byte) (*Header, []byte, error) {
+	r := bytes.NewReader(b)
+	h := &Header{}
+	if _, err := h.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	if h.Cmd != CmdPacket {
+		return h, nil, nil
+	}
+	if int(h.Size) != r.Len() {
+		return nil, nil, errors.New("tuic: bad packet size")
+	}
+	return h, b[len(b)-r.Len():], nil
+}
+
+// UDPMux dispatches the packets of the QUIC connection to the associations.
+type UDPMux struct {
+	conn     quic.Connection
+	mode     string
+	sessions map[uint16]*UDPConn
+	nextID   uint16
+	mu       sync.Mutex
+}
+
+// NewUDPMux creates the mux, the packets are sent in the mode by the client side associations.
+func NewUDPMux(conn quic.Connection, mode string) *UDPMux {
+	return &UDPMux{
+		conn:     conn,
+		mode:     mode,
+		sessions: make(map[uint16]*UDPConn),
+	}
+}
+
+// Feed dispatches the packet received in the mode.
+// The association of the unknown ID is created and passed to accept on the server side,
+// the packets of the unknown ID are dropped if accept is nil.
+func (m *UDPMux) Feed(h *Header, data []byte, mode string, accept func(c *UDPConn)) {
+	m.mu.Lock()
+	c := m.sessions[h.AssocID]
+	if c == nil && accept != nil {
+		c = m.newConn(h.AssocID, nil, mode)
+		m.mu.Unlock()
+		accept(c)
+	} else {
+		m.mu.Unlock()
+	}
+
+	if c != nil {
+		c.feed(h, data, mode)
+	}
+}
+
+// Dissociate closes the association dissociated by the peer.
+func (m *UDPMux) Dissociate(id uint16) {
+	m.mu.Lock()
+	c := m.sessions[id]
+	m.mu.Unlock()
+
+	if c != nil {
+		c.close(false)
+	}
+}
+
+// Len returns the number of the associations.
+func (m *UDPMux) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.sessions)
+}
+
+// NewConn creates the client side association, the Write sends the packets to targetAddr.
+func (m *UDPMux) NewConn(targetAddr net.Addr) *UDPConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		m.nextID++
+		if _, ok := m.sessions[m.nextID]; !ok {
+			break
+		}
+	}
+	c := m.newConn(m.nextID, targetAddr, m.mode)
+	c.client = true
+	return c
+}
+
+func (m *UDPMux) newConn(id uint16, targetAddr net.Addr, mode string) *UDPConn {
+	c := &UDPConn{
+		mux:     m,
+		assocID: id,
+		taddr:   targetAddr,
+		mode:    mode,
+		rch:     make(chan *udpPacket, udpQueueSize),
+		closed:  make(chan struct{}),
+	}
+	c.touch()
+	m.sessions[id] = c
+	return c
+}
+
+func (m *UDPMux) remove(id uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+}
+
+// CheckIdle closes the associations idle for idleTimeout until the QUIC connection is closed.
+func (m *UDPMux) CheckIdle(idleTimeout time.Duration) {
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var conns []*UDPConn
+			m.mu.Lock()
+			for _, c := range m.sessions {
+				if time.Since(time.Unix(0, atomic.LoadInt64(&c.active))) > idleTimeout {
+					conns = append(conns, c)
+				}
+			}
+			m.mu.Unlock()
+
+			for _, c := range conns {
+				c.Close()
+			}
+		case <-m.conn.Context().Done():
+			return
+		}
+	}
+}
+
+// CloseAll closes all the associations.
+func (m *UDPMux) CloseAll() {
+	m.mu.Lock()
+	var conns []*UDPConn
+	for _, c := range m.sessions {
+		conns = append(conns, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.close(false)
+	}
+}
+
+type udpPacket struct {
+	addr string
+	data []byte
+}
+
+// UDPConn is the UDP association,
+// it is both the net.Conn with the target address on the client side and the net.PacketConn on the server side.
+type UDPConn struct {
+	mux      *UDPMux
+	assocID  uint16
+	taddr    net.Addr
+	user     string
+	client   bool
+	mode     string
+	packetID uint32
+	active   int64
+
+	// the fragments of the latest packet
+	fragID    uint16
+	fragAddr  string
+	frags     [][]byte
+	fragCount int
+	fragMu    sync.Mutex
+
+	rch       chan *udpPacket
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *UDPConn) feed(h *Header, data []byte, mode string) {
+	c.fragMu.Lock()
+	// the server replies in the mode of the client.
+	if !c.client {
+		c.mode = mode
+	}
+	pkt := c.defrag(h, data)
+	c.fragMu.Unlock()
+
+	if pkt == nil {
+		return
+	}
+	c.touch()
+
+	select {
+	case c.rch <- pkt:
+	case <-c.closed:
+	default: // drop the packet if the queue is full
+	}
+}
+
+func (c *UDPConn) defrag(h *Header, data []byte) *udpPacket {
+	if h.FragTotal <= 1 {
+		return &udpPacket{addr: h.Addr, data: data}
+	}
+	if h.FragID >= h.FragTotal {
+		return nil
+	}
+
+	if h.PacketID != c.fragID || int(h.FragTotal) != len(c.frags) {
+		c.fragID = h.PacketID
+		c.frags = make([][]byte, h.FragTotal)
+		c.fragCount = 0
+		c.fragAddr = ""
+	}
+	if c.frags[h.FragID] != nil {
+		return nil
+	}
+	c.frags[h.FragID] = data
+	c.fragCount++
+	if h.Addr != "" {
+		c.fragAddr = h.Addr
+	}
+	if c.fragCount < len(c.frags) {
+		return nil
+	}
+
+	pkt := &udpPacket{addr: c.fragAddr}
+	for _, frag := range c.frags {
+		pkt.data = append(pkt.data, frag...)
+	}
+	c.frags = nil
+	c.fragCount = 0
+	return pkt
+}
+
+func (c *UDPConn) touch() {
+	atomic.StoreInt64(&c.active, time.Now().UnixNano())
+}
+
+func (c *UDPConn) AssocID() uint16 {
+	return c.assocID
+}
+
+// User returns the user authenticated by the server.
+func (c *UDPConn) User() string {
+	return c.user
+}
+
+func (c *UDPConn) SetUser(user string) {
+	c.user = user
+}
+
+func (c *UDPConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	select {
+	case pkt := <-c.rch:
+		n = copy(b, pkt.data)
+		addr, err = net.ResolveUDPAddr("udp", pkt.addr)
+		return
+	case <-c.closed:
+		err = ErrUDPClosed
+		return
+	}
+}
+
+func (c *UDPConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, ErrUDPClosed
+	default:
+	}
+	if addr == nil {
+		return 0, errors.New("tuic: missing target address")
+	}
+	if len(b) > 0xFFFF {
+		return 0, ErrUDPTooLarge
+	}
+
+	c.fragMu.Lock()
+	mode := c.mode
+	c.fragMu.Unlock()
+
+	h := Header{
+		Cmd:       CmdPacket,
+		AssocID:   c.assocID,
+		PacketID:  uint16(atomic.AddUint32(&c.packetID, 1)),
+		FragTotal: 1,
+		Addr:      addr.String(),
+		Size:      uint16(len(b)),
+	}
+
+	if mode == UDPModeQUIC {
+		err = c.writeStream(&h, b)
+	} else {
+		err = c.writeDatagram(&h, b)
+	}
+	if err != nil {
+		return
+	}
+	c.touch()
+
+	return len(b), nil
+}
+
+func (c *UDPConn) writeStream(h *Header, b []byte) error {
+	hb, err := h.Bytes()
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.mux.conn.OpenUniStreamSync(context.Background())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = stream.Write(append(hb, b...))
+	return err
+}
+
+// writeDatagram sends the packet in the datagrams,
+// only the first fragment carries the address if the packet is fragmented.
+func (c *UDPConn) writeDatagram(h *Header, b []byte) error {
+	hb, err := h.Bytes()
+	if err != nil {
+		return err
+	}
+	if len(hb)+len(b) <= maxDatagramSize {
+		return c.mux.conn.SendMessage(append(hb, b...))
+	}
+
+	maxPayload := maxDatagramSize - len(hb)
+	total := (len(b) + maxPayload - 1) / maxPayload
+	if total > 255 {
+		return ErrUDPTooLarge
+	}
+
+	addr := h.Addr
+	for i := 0; i < total; i++ {
+		end := (i + 1) * maxPayload
+		if end > len(b) {
+			end = len(b)
+		}
+		frag := *h
+		frag.FragTotal = uint8(total)
+		frag.FragID = uint8(i)
+		frag.Size = uint16(end - i*maxPayload)
+		if i > 0 {
+			frag.Addr = ""
+		} else {
+			frag.Addr = addr
+		}
+
+		fb, err := frag.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := c.mux.conn.SendMessage(append(fb, b[i*maxPayload:end]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *UDPConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.taddr)
+}
+
+// Close closes the association, the client side association is dissociated from the server.
+func (c *UDPConn) Close() error {
+	return c.close(c.client)
+}
+
+func (c *UDPConn) close(dissociate bool) (err error) {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mux.remove(c.assocID)
+
+		if dissociate {
+			err = c.dissociate()
+		}
+	})
+	return
+}
+
+func (c *UDPConn) dissociate() error {
+	stream, err := c.mux.conn.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = (&Header{Cmd: CmdDissociate, AssocID: c.assocID}).WriteTo(stream)
+	return err
+}
+
+func (c *UDPConn) LocalAddr() net.Addr {
+	return c.mux.conn.LocalAddr()
+}
+
+func (c *UDPConn) RemoteAddr() net.Addr {
+	if c.taddr != nil {
+		return c.taddr
+	}
+	return c.mux.conn.RemoteAddr()
+}
+
+func (c *UDPConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *UDPConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *UDPConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -17,3 +17,9 @@ type Config struct {
 	Gateway net.IP
 	Routes  []Route
 }
+
+// Configurator applies the settings assigned by the server to the TUN device at runtime.
+type Configurator interface {
+	// Configure replaces the addresses of the device and adds the routes and DNS servers.
+	Configure(nets []net.IPNet, routes []Route, dns []net.IP) error
+}
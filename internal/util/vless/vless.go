@@ -0,0 +1,318 @@
+package vless
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Version = 0
+)
+
+const (
+	CmdTCP uint8 = 0x01
+	CmdUDP uint8 = 0x02
+	CmdMux uint8 = 0x03
+)
+
+const (
+	AddrIPv4   uint8 = 0x01
+	AddrDomain uint8 = 0x02
+	AddrIPv6   uint8 = 0x03
+)
+
+var (
+	ErrBadVersion  = errors.New("vless: bad version")
+	ErrBadAddrType = errors.New("vless: bad address type")
+	ErrBadAddons   = errors.New("vless: request addons (flow) are not supported")
+)
+
+// ID returns the UUID of the user ID.
+// The ID which is not a UUID is mapped to the UUIDv5 of the ID in the nil namespace,
+// the same as the other implementations do.
+func ID(s string) uuid.UUID {
+	if id, err := uuid.Parse(s); err == nil {
+		return id
+	}
+	return uuid.NewSHA1(uuid.Nil, []byte(s))
+}
+
+// Request is the VLESS request header:
+//
+//	+-----+------+-----------+--------+-----+------+------+----------+
+//	| VER |  ID  | ADDONSLEN | ADDONS | CMD | PORT | ATYP |   ADDR   |
+//	+-----+------+-----------+--------+-----+------+------+----------+
+//	|  1  |  16  |     1     |   M    |  1  |  2   |  1   | Variable |
+//	+-----+------+-----------+--------+-----+------+------+----------+
+//
+// The address is absent for the mux command.
+type Request struct {
+	ID   uuid.UUID
+	Cmd  uint8
+	Addr string
+}
+
+func (r *Request) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(Version)
+	buf.Write(r.ID[:])
+	buf.WriteByte(0) // no addons
+	buf.WriteByte(r.Cmd)
+	if r.Cmd != CmdMux {
+		if err := writeAddr(&buf, r.Addr); err != nil {
+			return 0, err
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (r *Request) ReadFrom(br *bufio.Reader) (n int64, err error) {
+	b := make([]byte, 1+16+1)
+	if _, err = io.ReadFull(br, b); err != nil {
+		return
+	}
+	n += int64(len(b))
+
+	if b[0] != Version {
+		return n, ErrBadVersion
+	}
+	copy(r.ID[:], b[1:17])
+	if b[17] > 0 {
+		return n, ErrBadAddons
+	}
+
+	if r.Cmd, err = br.ReadByte(); err != nil {
+		return
+	}
+	n++
+
+	if r.Cmd != CmdMux {
+		var nn int
+		r.Addr, nn, err = readAddr(br)
+		n += int64(nn)
+	}
+	return
+}
+
+// ReadID peeks the user ID from the buffered reader,
+// the ID is kept in the reader so the request can be relayed to the fallback.
+func ReadID(br *bufio.Reader) (id uuid.UUID, err error) {
+	b, err := br.Peek(1 + 16)
+	if err != nil {
+		return
+	}
+	if b[0] != Version {
+		return id, ErrBadVersion
+	}
+	copy(id[:], b[1:])
+	return
+}
+
+func writeAddr(w io.Writer, addr string) error {
+	host, sport, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	b := binary.BigEndian.AppendUint16(nil, uint16(port))
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, AddrIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, AddrIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 0xFF {
+			return fmt.Errorf("vless: domain %s is too long", host)
+		}
+		b = append(b, AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func readAddr(r io.Reader) (addr string, n int, err error) {
+	b := make([]byte, 2+1+0xFF)
+	if _, err = io.ReadFull(r, b[:4]); err != nil {
+		return
+	}
+	n = 4
+
+	port := binary.BigEndian.Uint16(b)
+	var host string
+	switch b[2] {
+	case AddrIPv4:
+		if _, err = io.ReadFull(r, b[4:2+1+net.IPv4len]); err != nil {
+			return
+		}
+		n = 2 + 1 + net.IPv4len
+		host = net.IP(b[3:n]).String()
+	case AddrIPv6:
+		if _, err = io.ReadFull(r, b[4:2+1+net.IPv6len]); err != nil {
+			return
+		}
+		n = 2 + 1 + net.IPv6len
+		host = net.IP(b[3:n]).String()
+	case AddrDomain:
+		length := int(b[3])
+		if _, err = io.ReadFull(r, b[4:4+length]); err != nil {
+			return
+		}
+		n = 4 + length
+		host = string(b[4:n])
+	default:
+		err = ErrBadAddrType
+		return
+	}
+
+	addr = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	return
+}
+
+// clientConn sends the request header with the first write,
+// and strips the response header from the stream.
+type clientConn struct {
+	net.Conn
+	header   []byte
+	wmu      sync.Mutex
+	rmu      sync.Mutex
+	respRead bool
+}
+
+// ClientConn creates the client side connection,
+// the header is the request header which is not sent yet, or nil if it has been sent.
+func ClientConn(c net.Conn, header []byte) net.Conn {
+	return &clientConn{
+		Conn:   c,
+		header: header,
+	}
+}
+
+func (c *clientConn) Write(b []byte) (n int, err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.header != nil {
+		n = len(b)
+		_, err = c.Conn.Write(append(c.header, b...))
+		c.header = nil
+		return
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *clientConn) Read(b []byte) (n int, err error) {
+	c.rmu.Lock()
+	if !c.respRead {
+		// response header: VER | ADDONSLEN | ADDONS
+		var hdr [2]byte
+		if _, err = io.ReadFull(c.Conn, hdr[:]); err == nil {
+			if hdr[0] != Version {
+				err = ErrBadVersion
+			} else {
+				_, err = io.CopyN(io.Discard, c.Conn, int64(hdr[1]))
+			}
+		}
+		if err != nil {
+			c.rmu.Unlock()
+			return
+		}
+		c.respRead = true
+	}
+	c.rmu.Unlock()
+
+	return c.Conn.Read(b)
+}
+
+// serverConn sends the response header with the first write.
+type serverConn struct {
+	net.Conn
+	mu       sync.Mutex
+	respSent bool
+}
+
+// ServerConn creates the server side connection.
+func ServerConn(c net.Conn) net.Conn {
+	return &serverConn{
+		Conn: c,
+	}
+}
+
+func (c *serverConn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.respSent {
+		c.respSent = true
+		n = len(b)
+		_, err = c.Conn.Write(append([]byte{Version, 0}, b...))
+		return
+	}
+	return c.Conn.Write(b)
+}
+
+// packetConn is the UDP relay over the VLESS stream, each packet is prefixed by the 2-byte length.
+type packetConn struct {
+	net.Conn
+	rmu sync.Mutex
+	wmu sync.Mutex
+}
+
+// PacketConn creates the UDP relay connection on the VLESS stream,
+// each Read returns one packet, the excess of the packet which does not fit in the buffer is discarded.
+func PacketConn(c net.Conn) net.Conn {
+	return &packetConn{
+		Conn: c,
+	}
+}
+
+func (c *packetConn) Read(b []byte) (n int, err error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	var lb [2]byte
+	if _, err = io.ReadFull(c.Conn, lb[:]); err != nil {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(lb[:]))
+	if length > len(b) {
+		if _, err = io.ReadFull(c.Conn, b); err != nil {
+			return
+		}
+		_, err = io.CopyN(io.Discard, c.Conn, int64(length-len(b)))
+		return len(b), err
+	}
+	return io.ReadFull(c.Conn, b[:length])
+}
+
+func (c *packetConn) Write(b []byte) (n int, err error) {
+	if len(b) > 0xFFFF {
+		return 0, errors.New("vless: packet too large")
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if _, err = c.Conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...)); err != nil {
+		return
+	}
+	return len(b), nil
+}
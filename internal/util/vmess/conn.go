@@ -0,0 +1,350 @@
+package vmess
+
+import (
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// maxChunkSize is the max size of the sealed chunk including the padding.
+	maxChunkSize = 16 * 1024
+	// maxPayloadSize is the max size of the payload carried by a chunk we send.
+	maxPayloadSize = 8*1024 - 16 - 64
+	maxPaddingSize = 64
+)
+
+var (
+	errBadChunkSize = errors.New("vmess: bad chunk size")
+)
+
+// chunkWriter seals the body into chunks:
+//
+//	+--------+---------------------+---------+
+//	| LENGTH | PAYLOAD (SEALED)    | PADDING |
+//	+--------+---------------------+---------+
+//	|   2    |      Variable       |    P    |
+//	+--------+---------------------+---------+
+//
+// The length is masked by the SHAKE128 stream of the IV if the chunk masking is enabled.
+type chunkWriter struct {
+	aead    cipher.AEAD
+	nonce   nonceGenerator
+	mask    sha3.ShakeHash
+	padding bool
+}
+
+// chunkReader opens the chunks written by the chunkWriter.
+type chunkReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	nonce   nonceGenerator
+	mask    sha3.ShakeHash
+	padding bool
+}
+
+func newChunkStream(key, iv []byte, security, option uint8) (aead cipher.AEAD, mask sha3.ShakeHash, padding bool, err error) {
+	switch security {
+	case SecurityAES128GCM:
+		aead, err = newGCM(key)
+	case SecurityChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(chachaKey(key))
+	}
+	if err != nil {
+		return
+	}
+
+	if option&OptionChunkMasking != 0 {
+		mask = sha3.NewShake128()
+		mask.Write(iv)
+		padding = option&OptionGlobalPadding != 0 && aead != nil
+	}
+	return
+}
+
+// seal appends the chunk of b to dst.
+func (w *chunkWriter) seal(dst, b []byte) ([]byte, error) {
+	var paddingSize int
+	if w.padding {
+		paddingSize = int(nextMask(w.mask) % maxPaddingSize)
+	}
+
+	size := len(b) + paddingSize
+	if w.aead != nil {
+		size += w.aead.Overhead()
+	}
+	if size > 0xFFFF {
+		return nil, errBadChunkSize
+	}
+	if w.mask != nil {
+		size ^= int(nextMask(w.mask))
+	}
+
+	dst = binary.BigEndian.AppendUint16(dst, uint16(size))
+	if w.aead != nil {
+		dst = w.aead.Seal(dst, w.nonce.next(), b, nil)
+	} else {
+		dst = append(dst, b...)
+	}
+	if paddingSize > 0 {
+		p := make([]byte, paddingSize)
+		rand.Read(p)
+		dst = append(dst, p...)
+	}
+	return dst, nil
+}
+
+// readChunk reads the next chunk, io.EOF is returned for the empty chunk which is the end of the stream.
+func (r *chunkReader) readChunk() ([]byte, error) {
+	var paddingSize int
+	if r.padding {
+		paddingSize = int(nextMask(r.mask) % maxPaddingSize)
+	}
+
+	var lb [2]byte
+	if _, err := io.ReadFull(r.r, lb[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(lb[:]))
+	if r.mask != nil {
+		size ^= int(nextMask(r.mask))
+	}
+
+	overhead := 0
+	if r.aead != nil {
+		overhead = r.aead.Overhead()
+	}
+	if size < overhead+paddingSize || size > maxChunkSize {
+		return nil, errBadChunkSize
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	b = b[:size-paddingSize]
+	if r.aead != nil {
+		var err error
+		if b, err = r.aead.Open(b[:0], r.nonce.next(), b, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(b) == 0 {
+		return nil, io.EOF
+	}
+	return b, nil
+}
+
+func nextMask(h sha3.ShakeHash) uint16 {
+	var b [2]byte
+	h.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// nonceGenerator generates the chunk nonce which is the 2-byte count followed by the IV.
+type nonceGenerator struct {
+	nonce []byte
+	count uint16
+}
+
+func newNonceGenerator(iv []byte, size int) nonceGenerator {
+	nonce := make([]byte, size)
+	copy(nonce, iv)
+	return nonceGenerator{nonce: nonce}
+}
+
+func (g *nonceGenerator) next() []byte {
+	binary.BigEndian.PutUint16(g.nonce, g.count)
+	g.count++
+	return g.nonce
+}
+
+// chachaKey expands the 16-byte body key to the ChaCha20-Poly1305 key.
+func chachaKey(key []byte) []byte {
+	k := make([]byte, 0, 32)
+	h := md5.Sum(key)
+	k = append(k, h[:]...)
+	h = md5.Sum(h[:])
+	return append(k, h[:]...)
+}
+
+// conn is the VMess body stream.
+type conn struct {
+	net.Conn
+	req    *Request
+	client bool
+	packet bool
+
+	// header is the request or response header which is not sent yet.
+	header []byte
+	wmu    sync.Mutex
+	w      *chunkWriter
+
+	rmu      sync.Mutex
+	r        *chunkReader
+	respRead bool
+	rbuf     []byte
+	rerr     error
+}
+
+// ClientConn creates the client side connection of the request,
+// the request header is sent with the first write.
+// For the UDP request, each Read and Write is a packet.
+func ClientConn(c net.Conn, req *Request) (net.Conn, error) {
+	header, err := req.Seal()
+	if err != nil {
+		return nil, err
+	}
+
+	cc := &conn{
+		Conn:   c,
+		req:    req,
+		client: true,
+		packet: req.Cmd == CmdUDP,
+		header: header,
+	}
+	if err := cc.init(req.key[:], req.iv[:], true); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// ServerConn creates the server side connection of the request read by the Server,
+// the response header is sent with the first write.
+// For the UDP request, each Read and Write is a packet.
+func ServerConn(c net.Conn, req *Request) (net.Conn, error) {
+	header, err := req.sealResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &conn{
+		Conn:   c,
+		req:    req,
+		packet: req.Cmd == CmdUDP,
+		header: header,
+	}
+	if err := sc.init(req.key[:], req.iv[:], false); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (c *conn) init(key, iv []byte, client bool) error {
+	respKey, respIV := c.req.responseKey()
+
+	wkey, wiv, rkey, riv := key, iv, respKey, respIV
+	if !client {
+		wkey, wiv, rkey, riv = respKey, respIV, key, iv
+	}
+
+	if c.req.Option&OptionChunkStream == 0 {
+		return nil
+	}
+
+	aead, mask, padding, err := newChunkStream(wkey, wiv, c.req.Security, c.req.Option)
+	if err != nil {
+		return err
+	}
+	c.w = &chunkWriter{
+		aead:    aead,
+		mask:    mask,
+		padding: padding,
+	}
+	if aead != nil {
+		c.w.nonce = newNonceGenerator(wiv, aead.NonceSize())
+	}
+
+	if aead, mask, padding, err = newChunkStream(rkey, riv, c.req.Security, c.req.Option); err != nil {
+		return err
+	}
+	c.r = &chunkReader{
+		r:       c.Conn,
+		aead:    aead,
+		mask:    mask,
+		padding: padding,
+	}
+	if aead != nil {
+		c.r.nonce = newNonceGenerator(riv, aead.NonceSize())
+	}
+
+	return nil
+}
+
+func (c *conn) Write(b []byte) (n int, err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.packet && len(b) > maxPayloadSize {
+		return 0, errors.New("vmess: packet too large")
+	}
+	n = len(b)
+
+	// the header is sent along with the first chunk.
+	buf := c.header
+	c.header = nil
+
+	if c.w == nil {
+		buf = append(buf, b...)
+	} else {
+		for len(b) > 0 {
+			m := len(b)
+			if m > maxPayloadSize {
+				m = maxPayloadSize
+			}
+			if buf, err = c.w.seal(buf, b[:m]); err != nil {
+				return 0, err
+			}
+			b = b[m:]
+		}
+	}
+
+	if len(buf) > 0 {
+		if _, err = c.Conn.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return
+}
+
+func (c *conn) Read(b []byte) (n int, err error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.client && !c.respRead {
+		if err = c.req.openResponse(c.Conn); err != nil {
+			return
+		}
+		c.respRead = true
+	}
+
+	if c.r == nil {
+		return c.Conn.Read(b)
+	}
+
+	if len(c.rbuf) == 0 {
+		if c.rerr != nil {
+			return 0, c.rerr
+		}
+		if c.rbuf, err = c.r.readChunk(); err != nil {
+			c.rerr = err
+			return
+		}
+	}
+
+	n = copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	if c.packet {
+		// discard the excess of the packet.
+		c.rbuf = nil
+	}
+	return
+}
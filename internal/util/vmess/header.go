@@ -0,0 +1,508 @@
+package vmess
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Version = 1
+)
+
+const (
+	CmdTCP uint8 = 0x01
+	CmdUDP uint8 = 0x02
+	CmdMux uint8 = 0x03
+)
+
+// Security is the encryption method of the body.
+const (
+	SecurityAES128GCM        uint8 = 0x03
+	SecurityChaCha20Poly1305 uint8 = 0x04
+	SecurityNone             uint8 = 0x05
+	SecurityZero             uint8 = 0x06
+)
+
+const (
+	OptionChunkStream         uint8 = 0x01
+	OptionChunkMasking        uint8 = 0x04
+	OptionGlobalPadding       uint8 = 0x08
+	OptionAuthenticatedLength uint8 = 0x10
+)
+
+const (
+	addrIPv4   uint8 = 0x01
+	addrDomain uint8 = 0x02
+	addrIPv6   uint8 = 0x03
+)
+
+const (
+	// maxTimeDiff is the max allowed difference between the timestamp in the auth ID and the local time.
+	maxTimeDiff = 120 * time.Second
+	// authIDTTL is the time the auth IDs are kept by the replay filter.
+	authIDTTL = 2 * maxTimeDiff
+	// usersTTL is the time the user table is cached.
+	usersTTL = 5 * time.Second
+)
+
+var (
+	ErrBadVersion     = errors.New("vmess: bad version")
+	ErrBadAddrType    = errors.New("vmess: bad address type")
+	ErrBadChecksum    = errors.New("vmess: bad header checksum")
+	ErrBadResponse    = errors.New("vmess: bad response header")
+	ErrUnknownUser    = errors.New("vmess: unknown user")
+	ErrReplay         = errors.New("vmess: replayed request")
+	ErrBadSecurity    = errors.New("vmess: unsupported security")
+	ErrBadOption      = errors.New("vmess: unsupported option")
+	ErrNoUsers        = errors.New("vmess: no users")
+	errHeaderTooLarge = errors.New("vmess: header too large")
+)
+
+// ParseSecurity returns the security of the name, the empty name and auto are AES-128-GCM.
+func ParseSecurity(s string) (uint8, error) {
+	switch strings.ToLower(s) {
+	case "", "auto", "aes-128-gcm":
+		return SecurityAES128GCM, nil
+	case "chacha20-poly1305", "chacha20-ietf-poly1305":
+		return SecurityChaCha20Poly1305, nil
+	case "none":
+		return SecurityNone, nil
+	case "zero":
+		return SecurityZero, nil
+	default:
+		return 0, fmt.Errorf("vmess: unknown security %s", s)
+	}
+}
+
+// ID returns the UUID of the user ID.
+// The ID which is not a UUID is mapped to the UUIDv5 of the ID in the nil namespace.
+func ID(s string) uuid.UUID {
+	if id, err := uuid.Parse(s); err == nil {
+		return id
+	}
+	return uuid.NewSHA1(uuid.Nil, []byte(s))
+}
+
+// Request is the VMess request header, it is sealed by the AEAD of the user ID:
+//
+//	+-----+----+-----+---+-----+------+-----+-----+------+------+------+---------+-------+
+//	| VER | IV | KEY | V | OPT | P:S  | RSV | CMD | PORT | ATYP | ADDR | PADDING | FNV1A |
+//	+-----+----+-----+---+-----+------+-----+-----+------+------+------+---------+-------+
+//	|  1  | 16 | 16  | 1 |  1  | 4:4  |  1  |  1  |  2   |  1   | Var  |    P    |   4   |
+//	+-----+----+-----+---+-----+------+-----+-----+------+------+------+---------+-------+
+type Request struct {
+	ID       uuid.UUID
+	Security uint8
+	Option   uint8
+	Cmd      uint8
+	Addr     string
+
+	key      [16]byte
+	iv       [16]byte
+	respAuth uint8
+}
+
+// NewRequest creates the client request with the random body key.
+func NewRequest(id uuid.UUID, security uint8, cmd uint8, addr string) *Request {
+	r := &Request{
+		ID:       id,
+		Security: security,
+		Cmd:      cmd,
+		Addr:     addr,
+	}
+
+	switch security {
+	case SecurityAES128GCM, SecurityChaCha20Poly1305:
+		r.Option = OptionChunkStream | OptionChunkMasking | OptionGlobalPadding
+	case SecurityNone:
+		r.Option = OptionChunkStream | OptionChunkMasking
+	case SecurityZero:
+		// no chunk stream, the body is not encrypted.
+		r.Security = SecurityNone
+	}
+	if cmd == CmdUDP {
+		r.Option |= OptionChunkStream
+	}
+
+	rand.Read(r.key[:])
+	rand.Read(r.iv[:])
+	var b [1]byte
+	rand.Read(b[:])
+	r.respAuth = b[0]
+
+	return r
+}
+
+// Seal encodes and seals the request header:
+//
+//	+---------+---------------+-------+--------------+
+//	| AUTH ID | HEADER LENGTH | NONCE | HEADER       |
+//	+---------+---------------+-------+--------------+
+//	|   16    |     2+16      |   8   | Variable+16  |
+//	+---------+---------------+-------+--------------+
+func (r *Request) Seal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(Version)
+	buf.Write(r.iv[:])
+	buf.Write(r.key[:])
+	buf.WriteByte(r.respAuth)
+	buf.WriteByte(r.Option)
+
+	var pb [1]byte
+	rand.Read(pb[:])
+	padding := int(pb[0] % 16)
+	buf.WriteByte(byte(padding<<4) | r.Security)
+	buf.WriteByte(0) // reserved
+	buf.WriteByte(r.Cmd)
+	if r.Cmd != CmdMux {
+		if err := writeAddr(&buf, r.Addr); err != nil {
+			return nil, err
+		}
+	}
+	if padding > 0 {
+		p := make([]byte, padding)
+		rand.Read(p)
+		buf.Write(p)
+	}
+	h := fnv.New32a()
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+
+	key := cmdKey(r.ID)
+	block, err := authIDCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	authID := newAuthID(block, time.Now())
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+
+	aead, err := newGCM(kdf16(key, kdfSaltHeaderPayloadLengthKey, string(authID), string(nonce)))
+	if err != nil {
+		return nil, err
+	}
+	b := append([]byte{}, authID...)
+	b = aead.Seal(b, kdf(key, kdfSaltHeaderPayloadLengthIV, string(authID), string(nonce))[:12],
+		binary.BigEndian.AppendUint16(nil, uint16(buf.Len())), authID)
+	b = append(b, nonce...)
+
+	if aead, err = newGCM(kdf16(key, kdfSaltHeaderPayloadKey, string(authID), string(nonce))); err != nil {
+		return nil, err
+	}
+	return aead.Seal(b, kdf(key, kdfSaltHeaderPayloadIV, string(authID), string(nonce))[:12], buf.Bytes(), authID), nil
+}
+
+func (r *Request) decode(b []byte) error {
+	if len(b) < 1+16+16+1+1+1+1+1+4 {
+		return io.ErrUnexpectedEOF
+	}
+
+	h := fnv.New32a()
+	h.Write(b[:len(b)-4])
+	if !bytes.Equal(h.Sum(nil), b[len(b)-4:]) {
+		return ErrBadChecksum
+	}
+	if b[0] != Version {
+		return ErrBadVersion
+	}
+	copy(r.iv[:], b[1:17])
+	copy(r.key[:], b[17:33])
+	r.respAuth = b[33]
+	r.Option = b[34]
+	r.Security = b[35] & 0x0F
+	r.Cmd = b[37]
+
+	if r.Cmd != CmdMux {
+		addr, _, err := readAddr(bytes.NewReader(b[38 : len(b)-4]))
+		if err != nil {
+			return err
+		}
+		r.Addr = addr
+	}
+
+	switch r.Security {
+	case SecurityAES128GCM, SecurityChaCha20Poly1305, SecurityNone:
+	case SecurityZero:
+		r.Security = SecurityNone
+	default:
+		return ErrBadSecurity
+	}
+	if r.Option&OptionAuthenticatedLength != 0 {
+		return ErrBadOption
+	}
+
+	return nil
+}
+
+// responseKey returns the key and IV of the response body.
+func (r *Request) responseKey() (key, iv []byte) {
+	k := sha256.Sum256(r.key[:])
+	v := sha256.Sum256(r.iv[:])
+	return k[:16], v[:16]
+}
+
+// sealResponse seals the response header:
+//
+//	+---------------+---+-----+-----+--------+-----+
+//	| HEADER LENGTH | V | OPT | CMD | CMDLEN | TAG |
+//	+---------------+---+-----+-----+--------+-----+
+//	|     2+16      | 1 |  1  |  1  |   1    | 16  |
+//	+---------------+---+-----+-----+--------+-----+
+func (r *Request) sealResponse() ([]byte, error) {
+	key, iv := r.responseKey()
+	header := []byte{r.respAuth, 0, 0, 0}
+
+	aead, err := newGCM(kdf16(key, kdfSaltRespHeaderLengthKey))
+	if err != nil {
+		return nil, err
+	}
+	b := aead.Seal(nil, kdf(iv, kdfSaltRespHeaderLengthIV)[:12], binary.BigEndian.AppendUint16(nil, uint16(len(header))), nil)
+
+	if aead, err = newGCM(kdf16(key, kdfSaltRespHeaderPayloadKey)); err != nil {
+		return nil, err
+	}
+	return aead.Seal(b, kdf(iv, kdfSaltRespHeaderPayloadIV)[:12], header, nil), nil
+}
+
+func (r *Request) openResponse(rd io.Reader) error {
+	key, iv := r.responseKey()
+
+	aead, err := newGCM(kdf16(key, kdfSaltRespHeaderLengthKey))
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2+aead.Overhead())
+	if _, err := io.ReadFull(rd, b); err != nil {
+		return err
+	}
+	if b, err = aead.Open(b[:0], kdf(iv, kdfSaltRespHeaderLengthIV)[:12], b, nil); err != nil {
+		return err
+	}
+
+	if aead, err = newGCM(kdf16(key, kdfSaltRespHeaderPayloadKey)); err != nil {
+		return err
+	}
+	b = make([]byte, int(binary.BigEndian.Uint16(b))+aead.Overhead())
+	if _, err := io.ReadFull(rd, b); err != nil {
+		return err
+	}
+	if b, err = aead.Open(b[:0], kdf(iv, kdfSaltRespHeaderPayloadIV)[:12], b, nil); err != nil {
+		return err
+	}
+	if len(b) < 4 || b[0] != r.respAuth {
+		return ErrBadResponse
+	}
+	return nil
+}
+
+// Server authenticates the requests of the users.
+type Server struct {
+	users func() map[string]string
+
+	table   []*user
+	updated time.Time
+	// authIDs is the replay filter of the auth IDs.
+	authIDs map[[16]byte]time.Time
+	mu      sync.Mutex
+}
+
+type user struct {
+	name   string
+	id     uuid.UUID
+	cmdKey []byte
+	block  cipher.Block
+}
+
+// NewServer creates a Server, the users are the name to ID mapping.
+func NewServer(users func() map[string]string) *Server {
+	return &Server{
+		users:   users,
+		authIDs: make(map[[16]byte]time.Time),
+	}
+}
+
+// ReadRequest reads and authenticates the request header, it returns the request and the name of the user.
+func (s *Server) ReadRequest(r io.Reader) (*Request, string, error) {
+	authID := make([]byte, 16)
+	if _, err := io.ReadFull(r, authID); err != nil {
+		return nil, "", err
+	}
+
+	u, err := s.authenticate(authID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	b := make([]byte, 2+16+8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, "", err
+	}
+	nonce := b[18:]
+
+	aead, err := newGCM(kdf16(u.cmdKey, kdfSaltHeaderPayloadLengthKey, string(authID), string(nonce)))
+	if err != nil {
+		return nil, "", err
+	}
+	lb, err := aead.Open(nil, kdf(u.cmdKey, kdfSaltHeaderPayloadLengthIV, string(authID), string(nonce))[:12], b[:18], authID)
+	if err != nil {
+		return nil, "", err
+	}
+	length := int(binary.BigEndian.Uint16(lb))
+	if length > 2048 {
+		return nil, "", errHeaderTooLarge
+	}
+
+	if aead, err = newGCM(kdf16(u.cmdKey, kdfSaltHeaderPayloadKey, string(authID), string(nonce))); err != nil {
+		return nil, "", err
+	}
+	b = make([]byte, length+aead.Overhead())
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, "", err
+	}
+	if b, err = aead.Open(b[:0], kdf(u.cmdKey, kdfSaltHeaderPayloadIV, string(authID), string(nonce))[:12], b, authID); err != nil {
+		return nil, "", err
+	}
+
+	req := &Request{ID: u.id}
+	if err := req.decode(b); err != nil {
+		return nil, "", err
+	}
+	return req, u.name, nil
+}
+
+func (s *Server) authenticate(authID []byte) (*user, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.table == nil || time.Since(s.updated) > usersTTL {
+		s.table = s.table[:0]
+		if s.users != nil {
+			for name, id := range s.users() {
+				u := &user{
+					name: name,
+					id:   ID(id),
+				}
+				u.cmdKey = cmdKey(u.id)
+				block, err := authIDCipher(u.cmdKey)
+				if err != nil {
+					continue
+				}
+				u.block = block
+				s.table = append(s.table, u)
+			}
+		}
+		s.updated = time.Now()
+	}
+	if len(s.table) == 0 {
+		return nil, ErrNoUsers
+	}
+
+	now := time.Now()
+	for _, u := range s.table {
+		ts, ok := openAuthID(u.block, authID)
+		if !ok {
+			continue
+		}
+		if d := now.Sub(time.Unix(ts, 0)); d > maxTimeDiff || d < -maxTimeDiff {
+			continue
+		}
+
+		var key [16]byte
+		copy(key[:], authID)
+		if _, ok := s.authIDs[key]; ok {
+			return nil, ErrReplay
+		}
+		for k, t := range s.authIDs {
+			if now.Sub(t) > authIDTTL {
+				delete(s.authIDs, k)
+			}
+		}
+		s.authIDs[key] = now
+		return u, nil
+	}
+
+	return nil, ErrUnknownUser
+}
+
+func writeAddr(w io.Writer, addr string) error {
+	host, sport, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	b := binary.BigEndian.AppendUint16(nil, uint16(port))
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, addrIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, addrIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 0xFF {
+			return fmt.Errorf("vmess: domain %s is too long", host)
+		}
+		b = append(b, addrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func readAddr(r io.Reader) (addr string, n int, err error) {
+	b := make([]byte, 2+1+0xFF)
+	if _, err = io.ReadFull(r, b[:4]); err != nil {
+		return
+	}
+	n = 4
+
+	port := binary.BigEndian.Uint16(b)
+	var host string
+	switch b[2] {
+	case addrIPv4:
+		if _, err = io.ReadFull(r, b[4:2+1+net.IPv4len]); err != nil {
+			return
+		}
+		n = 2 + 1 + net.IPv4len
+		host = net.IP(b[3:n]).String()
+	case addrIPv6:
+		if _, err = io.ReadFull(r, b[4:2+1+net.IPv6len]); err != nil {
+			return
+		}
+		n = 2 + 1 + net.IPv6len
+		host = net.IP(b[3:n]).String()
+	case addrDomain:
+		length := int(b[3])
+		if _, err = io.ReadFull(r, b[4:4+length]); err != nil {
+			return
+		}
+		n = 4 + length
+		host = string(b[4:n])
+	default:
+		err = ErrBadAddrType
+		return
+	}
+
+	addr = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	return
+}
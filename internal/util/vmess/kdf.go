@@ -0,0 +1,102 @@
+package vmess
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	kdfSaltVMessAEADKDF           = "VMess AEAD KDF"
+	kdfSaltAuthIDEncryptionKey    = "AES Auth ID Encryption"
+	kdfSaltHeaderPayloadKey       = "VMess Header AEAD Key"
+	kdfSaltHeaderPayloadIV        = "VMess Header AEAD Nonce"
+	kdfSaltHeaderPayloadLengthKey = "VMess Header AEAD Key_Length"
+	kdfSaltHeaderPayloadLengthIV  = "VMess Header AEAD Nonce_Length"
+	kdfSaltRespHeaderLengthKey    = "AEAD Resp Header Len Key"
+	kdfSaltRespHeaderLengthIV     = "AEAD Resp Header Len IV"
+	kdfSaltRespHeaderPayloadKey   = "AEAD Resp Header Key"
+	kdfSaltRespHeaderPayloadIV    = "AEAD Resp Header IV"
+	cmdKeySalt                    = "c48619fe-8f02-49e0-b9e9-edf763e17e21"
+)
+
+// kdf is the nested HMAC-SHA256 key derivation function of VMess AEAD.
+func kdf(key []byte, path ...string) []byte {
+	h := func() hash.Hash {
+		return sha256.New()
+	}
+	for _, v := range append([]string{kdfSaltVMessAEADKDF}, path...) {
+		h = nestedHMAC(h, []byte(v))
+	}
+
+	mac := h()
+	mac.Write(key)
+	return mac.Sum(nil)
+}
+
+func nestedHMAC(parent func() hash.Hash, key []byte) func() hash.Hash {
+	return func() hash.Hash {
+		return hmac.New(parent, key)
+	}
+}
+
+func kdf16(key []byte, path ...string) []byte {
+	return kdf(key, path...)[:16]
+}
+
+// cmdKey returns the command key of the user ID.
+func cmdKey(id uuid.UUID) []byte {
+	h := md5.New()
+	h.Write(id[:])
+	h.Write([]byte(cmdKeySalt))
+	return h.Sum(nil)
+}
+
+// authIDCipher returns the block cipher to encrypt the auth ID.
+func authIDCipher(cmdKey []byte) (cipher.Block, error) {
+	return aes.NewCipher(kdf16(cmdKey, kdfSaltAuthIDEncryptionKey))
+}
+
+// newAuthID creates the auth ID:
+//
+//	+-----------+--------+-------+
+//	| TIMESTAMP |  RAND  | CRC32 |
+//	+-----------+--------+-------+
+//	|     8     |   4    |   4   |
+//	+-----------+--------+-------+
+func newAuthID(block cipher.Block, t time.Time) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(t.Unix()))
+	rand.Read(b[8:12])
+	binary.BigEndian.PutUint32(b[12:], crc32.ChecksumIEEE(b[:12]))
+
+	block.Encrypt(b, b)
+	return b
+}
+
+// openAuthID decrypts the auth ID and returns the timestamp in it.
+func openAuthID(block cipher.Block, authID []byte) (int64, bool) {
+	b := make([]byte, 16)
+	block.Decrypt(b, authID)
+	if binary.BigEndian.Uint32(b[12:]) != crc32.ChecksumIEEE(b[:12]) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(b)), true
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,92 @@
+package wireguard
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/conn"
+)
+
+// packetBind is the conn.Bind on the UDP socket created by the listen function,
+// so the socket options of the dialer (interface, mark) are applied to the device.
+type packetBind struct {
+	listen func() (net.PacketConn, error)
+	mu     sync.Mutex
+	pc     net.PacketConn
+}
+
+func (b *packetBind) Open(port uint16) ([]conn.ReceiveFunc, uint16, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pc != nil {
+		return nil, 0, conn.ErrBindAlreadyOpen
+	}
+
+	pc, err := b.listen()
+	if err != nil {
+		return nil, 0, err
+	}
+	b.pc = pc
+
+	var actualPort uint16
+	if addr, ok := pc.LocalAddr().(*net.UDPAddr); ok {
+		actualPort = uint16(addr.Port)
+	}
+
+	recv := func(buf []byte) (int, conn.Endpoint, error) {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		var ap netip.AddrPort
+		if ua, ok := addr.(*net.UDPAddr); ok {
+			ap = ua.AddrPort()
+		}
+		return n, conn.StdNetEndpoint(netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())), nil
+	}
+	return []conn.ReceiveFunc{recv}, actualPort, nil
+}
+
+func (b *packetBind) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pc == nil {
+		return nil
+	}
+	err := b.pc.Close()
+	b.pc = nil
+	return err
+}
+
+// SetMark does nothing, the mark is set on the socket by the listen function.
+func (b *packetBind) SetMark(mark uint32) error {
+	return nil
+}
+
+func (b *packetBind) Send(buf []byte, ep conn.Endpoint) error {
+	nep, ok := ep.(conn.StdNetEndpoint)
+	if !ok {
+		return conn.ErrWrongEndpointType
+	}
+
+	b.mu.Lock()
+	pc := b.pc
+	b.mu.Unlock()
+
+	if pc == nil {
+		return net.ErrClosed
+	}
+	_, err := pc.WriteTo(buf, net.UDPAddrFromAddrPort(netip.AddrPort(nep)))
+	return err
+}
+
+func (b *packetBind) ParseEndpoint(s string) (conn.Endpoint, error) {
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return nil, err
+	}
+	return conn.StdNetEndpoint(ap), nil
+}
@@ -0,0 +1,270 @@
+package wireguard
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+const (
+	DefaultMTU = 1420
+)
+
+var (
+	ErrInvalidConnection = errors.New("wireguard: invalid connection")
+)
+
+// Peer is the WireGuard peer.
+type Peer struct {
+	// PublicKey is the base64 encoded public key of the peer.
+	PublicKey string
+	// PresharedKey is the optional base64 encoded preshared key.
+	PresharedKey string
+	// Endpoint is the address of the peer, it is empty if the peer initiates the connection.
+	Endpoint   string
+	AllowedIPs []netip.Prefix
+	KeepAlive  time.Duration
+}
+
+// Config is the configuration of the userspace WireGuard device.
+type Config struct {
+	// PrivateKey is the base64 encoded private key of the device.
+	PrivateKey string
+	// Addresses are the addresses of the device in the tunnel.
+	Addresses []netip.Addr
+	DNS       []netip.Addr
+	MTU       int
+	// ListenPort is the UDP port of the device, zero for a random port.
+	ListenPort int
+	Peers      []Peer
+	// ListenPacket creates the UDP socket of the device, the default socket bound to ListenPort is used if it is nil.
+	ListenPacket func() (net.PacketConn, error)
+}
+
+// Validate checks the keys of the config.
+func (c *Config) Validate() error {
+	if _, err := hexKey(c.PrivateKey); err != nil {
+		return fmt.Errorf("wireguard: private key: %w", err)
+	}
+	for _, peer := range c.Peers {
+		if _, err := hexKey(peer.PublicKey); err != nil {
+			return fmt.Errorf("wireguard: public key %s: %w", peer.PublicKey, err)
+		}
+		if peer.PresharedKey != "" {
+			if _, err := hexKey(peer.PresharedKey); err != nil {
+				return fmt.Errorf("wireguard: preshared key of %s: %w", peer.PublicKey, err)
+			}
+		}
+	}
+	return nil
+}
+
+// uapi returns the configuration in the form of the WireGuard cross-platform userspace API.
+func (c *Config) uapi() (string, error) {
+	var b strings.Builder
+
+	key, err := hexKey(c.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("wireguard: private key: %w", err)
+	}
+	fmt.Fprintf(&b, "private_key=%s\n", key)
+	if c.ListenPort > 0 {
+		fmt.Fprintf(&b, "listen_port=%d\n", c.ListenPort)
+	}
+	b.WriteString("replace_peers=true\n")
+
+	for _, peer := range c.Peers {
+		key, err := hexKey(peer.PublicKey)
+		if err != nil {
+			return "", fmt.Errorf("wireguard: public key: %w", err)
+		}
+		fmt.Fprintf(&b, "public_key=%s\n", key)
+
+		if peer.PresharedKey != "" {
+			key, err := hexKey(peer.PresharedKey)
+			if err != nil {
+				return "", fmt.Errorf("wireguard: preshared key: %w", err)
+			}
+			fmt.Fprintf(&b, "preshared_key=%s\n", key)
+		}
+		if peer.Endpoint != "" {
+			addr, err := net.ResolveUDPAddr("udp", peer.Endpoint)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "endpoint=%s\n", addr)
+		}
+		if peer.KeepAlive > 0 {
+			fmt.Fprintf(&b, "persistent_keepalive_interval=%d\n", int(peer.KeepAlive.Seconds()))
+		}
+		b.WriteString("replace_allowed_ips=true\n")
+		for _, prefix := range peer.AllowedIPs {
+			fmt.Fprintf(&b, "allowed_ip=%s\n", prefix)
+		}
+	}
+
+	return b.String(), nil
+}
+
+func hexKey(s string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	if len(key) != device.NoisePublicKeySize {
+		return "", errors.New("invalid key length")
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Tunnel is the userspace WireGuard device with the gVisor network stack,
+// the connections are made in the tunnel without the kernel interface.
+type Tunnel struct {
+	dev  *device.Device
+	tnet *netstack.Net
+}
+
+// NewTunnel creates the device of the config and brings it up.
+func NewTunnel(config *Config, log logger.Logger) (*Tunnel, error) {
+	uapi, err := config.uapi()
+	if err != nil {
+		return nil, err
+	}
+
+	mtu := config.MTU
+	if mtu <= 0 {
+		mtu = DefaultMTU
+	}
+	tdev, tnet, err := netstack.CreateNetTUN(config.Addresses, config.DNS, mtu)
+	if err != nil {
+		return nil, err
+	}
+
+	bind := conn.NewDefaultBind()
+	if config.ListenPacket != nil {
+		bind = &packetBind{listen: config.ListenPacket}
+	}
+	dev := device.NewDevice(tdev, bind, &device.Logger{
+		Verbosef: func(format string, args ...any) {
+			log.Tracef(format, args...)
+		},
+		Errorf: func(format string, args ...any) {
+			log.Debugf(format, args...)
+		},
+	})
+	if err := dev.IpcSet(uapi); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	if err := dev.Up(); err != nil {
+		dev.Close()
+		return nil, err
+	}
+
+	return &Tunnel{
+		dev:  dev,
+		tnet: tnet,
+	}, nil
+}
+
+// DialContext connects to the address in the tunnel.
+func (t *Tunnel) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return t.tnet.DialContext(ctx, network, address)
+}
+
+// Listen listens on the TCP address in the tunnel.
+func (t *Tunnel) Listen(network string, addr netip.AddrPort) (net.Listener, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("network %s is unsupported", network)
+	}
+	return t.tnet.ListenTCPAddrPort(addr)
+}
+
+// ListenPacket listens on the UDP address in the tunnel.
+func (t *Tunnel) ListenPacket(addr netip.AddrPort) (net.PacketConn, error) {
+	return t.tnet.ListenUDPAddrPort(addr)
+}
+
+// Closed returns a channel which is closed when the device is closed.
+func (t *Tunnel) Closed() <-chan struct{} {
+	return t.dev.Wait()
+}
+
+func (t *Tunnel) Close() error {
+	t.dev.Close()
+	return nil
+}
+
+// TunnelConn is the placeholder connection of the tunnel returned by the dialer,
+// the connector dials the target through the tunnel of it.
+type TunnelConn struct {
+	net.Conn
+	tunnel *Tunnel
+}
+
+func NewTunnelConn(tunnel *Tunnel) net.Conn {
+	return &TunnelConn{
+		Conn:   &nopConn{},
+		tunnel: tunnel,
+	}
+}
+
+func (c *TunnelConn) Tunnel() *Tunnel {
+	return c.tunnel
+}
+
+// Close does not close the tunnel which is shared by the connections.
+func (c *TunnelConn) Close() error {
+	return nil
+}
+
+// ParsePrefixes parses the comma separated list of the CIDRs or IPs.
+func ParsePrefixes(s string) (prefixes []netip.Prefix, err error) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			prefixes = append(prefixes, prefix)
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("wireguard: invalid address or CIDR %q", v)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return
+}
+
+// ParseAddrs parses the comma separated list of the CIDRs or IPs, the prefix lengths are ignored.
+func ParseAddrs(s string) (addrs []netip.Addr, err error) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(v); err == nil {
+			addrs = append(addrs, prefix.Addr())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("wireguard: invalid address %q", v)
+		}
+		addrs = append(addrs, addr)
+	}
+	return
+}
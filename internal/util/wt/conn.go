@@ -0,0 +1,30 @@
+package wt
+
+import (
+	"net"
+
+	"github.com/quic-go/webtransport-go"
+)
+
+type streamConn struct {
+	webtransport.Stream
+	laddr net.Addr
+	raddr net.Addr
+}
+
+// StreamConn wraps the bidirectional stream of the session as a net.Conn.
+func StreamConn(stream webtransport.Stream, laddr, raddr net.Addr) net.Conn {
+	return &streamConn{
+		Stream: stream,
+		laddr:  laddr,
+		raddr:  raddr,
+	}
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
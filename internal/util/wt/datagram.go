@@ -0,0 +1,224 @@
+package wt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+var (
+	ErrDatagramClosed = errors.New("wt: datagram closed")
+)
+
+const (
+	datagramQueueSize = 128
+)
+
+var (
+	muxes   = make(map[quic.Connection]*DatagramMux)
+	muxesMu sync.Mutex
+)
+
+// DatagramMux dispatches the HTTP/3 datagrams (RFC 9297) of the QUIC connection to the sessions,
+// the datagram is prefixed by the quarter stream ID of the CONNECT stream of the session.
+type DatagramMux struct {
+	conn      quic.Connection
+	conns     map[uint64]*DatagramConn
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+// DatagramMuxOf returns the mux of the QUIC connection, it is created on the first call
+// and removed when the connection is closed.
+func DatagramMuxOf(conn quic.Connection) *DatagramMux {
+	muxesMu.Lock()
+	defer muxesMu.Unlock()
+
+	if m := muxes[conn]; m != nil {
+		return m
+	}
+
+	m := &DatagramMux{
+		conn:  conn,
+		conns: make(map[uint64]*DatagramConn),
+	}
+	muxes[conn] = m
+	go m.serve()
+	go func() {
+		<-conn.Context().Done()
+		m.close()
+	}()
+
+	return m
+}
+
+// close removes the mux and closes all the connections of it.
+func (m *DatagramMux) close() {
+	m.closeOnce.Do(func() {
+		muxesMu.Lock()
+		if muxes[m.conn] == m {
+			delete(muxes, m.conn)
+		}
+		muxesMu.Unlock()
+
+		m.closeAll()
+	})
+}
+
+func (m *DatagramMux) serve() {
+	defer m.close()
+
+	for {
+		b, err := m.conn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+
+		r := bytes.NewReader(b)
+		id, err := quicvarint.Read(r)
+		if err != nil {
+			continue
+		}
+
+		m.mu.Lock()
+		c := m.conns[id]
+		m.mu.Unlock()
+
+		if c != nil {
+			c.feed(b[len(b)-r.Len():])
+		}
+	}
+}
+
+// NewConn creates the datagram connection of the session whose CONNECT stream is streamID,
+// accept is called with the connection when the first datagram of the session is received.
+// The connection is closed with the session.
+func (m *DatagramMux) NewConn(ctx context.Context, streamID quic.StreamID, accept func(c net.Conn)) *DatagramConn {
+	c := &DatagramConn{
+		mux:    m,
+		id:     uint64(streamID) / 4,
+		accept: accept,
+		rch:    make(chan []byte, datagramQueueSize),
+		closed: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.conns[c.id] = c
+	m.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.closed:
+		}
+	}()
+
+	return c
+}
+
+func (m *DatagramMux) remove(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.conns, id)
+}
+
+func (m *DatagramMux) closeAll() {
+	m.mu.Lock()
+	var conns []*DatagramConn
+	for _, c := range m.conns {
+		conns = append(conns, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// DatagramConn is the datagram flow of the session,
+// each Write sends one datagram and each Read receives one datagram.
+// The datagrams are unreliable and the size is limited by the path MTU.
+type DatagramConn struct {
+	mux        *DatagramMux
+	id         uint64
+	accept     func(c net.Conn)
+	acceptOnce sync.Once
+	rch        chan []byte
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+func (c *DatagramConn) feed(b []byte) {
+	c.acceptOnce.Do(func() {
+		if c.accept != nil {
+			c.accept(c)
+		}
+	})
+
+	select {
+	case c.rch <- b:
+	case <-c.closed:
+	default: // drop the datagram if the queue is full
+	}
+}
+
+func (c *DatagramConn) Read(b []byte) (n int, err error) {
+	select {
+	case p := <-c.rch:
+		n = copy(b, p)
+		return
+	case <-c.closed:
+		err = ErrDatagramClosed
+		return
+	}
+}
+
+func (c *DatagramConn) Write(b []byte) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, ErrDatagramClosed
+	default:
+	}
+
+	buf := quicvarint.Append(make([]byte, 0, int(quicvarint.Len(c.id))+len(b)), c.id)
+	if err = c.mux.conn.SendMessage(append(buf, b...)); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *DatagramConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mux.remove(c.id)
+	})
+	return nil
+}
+
+func (c *DatagramConn) LocalAddr() net.Addr {
+	return c.mux.conn.LocalAddr()
+}
+
+func (c *DatagramConn) RemoteAddr() net.Addr {
+	return c.mux.conn.RemoteAddr()
+}
+
+func (c *DatagramConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *DatagramConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *DatagramConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
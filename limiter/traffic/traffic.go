@@ -118,7 +118,7 @@ func NewTrafficLimiter(opts ...Option) limiter.TrafficLimiter {
 }
 
 // In obtains a traffic input limiter based on key.
-// The key should be client connection address.
+// The key should be client connection address, or the name such as the user.
 func (l *trafficLimiter) In(key string) limiter.Limiter {
 	var lims []limiter.Limiter
 
@@ -146,7 +146,11 @@ func (l *trafficLimiter) In(key string) limiter.Limiter {
 		}
 	}
 
-	host, _, _ := net.SplitHostPort(key)
+	host, _, err := net.SplitHostPort(key)
+	if err != nil {
+		// the key is a name, such as the user.
+		host = key
+	}
 	// IP level limiter
 	if lim, ok := l.inLimits.Get(host); ok {
 		// cached IP limiter
@@ -182,7 +186,7 @@ func (l *trafficLimiter) In(key string) limiter.Limiter {
 }
 
 // Out obtains a traffic output limiter based on key.
-// The key should be client connection address.
+// The key should be client connection address, or the name such as the user.
 func (l *trafficLimiter) Out(key string) limiter.Limiter {
 	var lims []limiter.Limiter
 
@@ -210,7 +214,11 @@ func (l *trafficLimiter) Out(key string) limiter.Limiter {
 		}
 	}
 
-	host, _, _ := net.SplitHostPort(key)
+	host, _, err := net.SplitHostPort(key)
+	if err != nil {
+		// the key is a name, such as the user.
+		host = key
+	}
 	// IP level limiter
 	if lim, ok := l.outLimits.Get(host); ok {
 		if lim != nil {
@@ -29,6 +29,8 @@ type serverConn struct {
 	expIn      int64
 	limiterOut limiter.Limiter
 	expOut     int64
+	// key is the key of the limits, it is the remote address if empty.
+	key string
 }
 
 func WrapConn(limiter limiter.TrafficLimiter, c net.Conn) net.Conn {
@@ -41,11 +43,30 @@ func WrapConn(limiter limiter.TrafficLimiter, c net.Conn) net.Conn {
 	}
 }
 
+// WrapUserConn wraps the connection of the user, the limits are keyed by the user.
+func WrapUserConn(limiter limiter.TrafficLimiter, user string, c net.Conn) net.Conn {
+	if limiter == nil || user == "" {
+		return c
+	}
+	return &serverConn{
+		Conn:    c,
+		limiter: limiter,
+		key:     user,
+	}
+}
+
+func (c *serverConn) limitKey(addr net.Addr) string {
+	if c.key != "" {
+		return c.key
+	}
+	return addr.String()
+}
+
 func (c *serverConn) getInLimiter(addr net.Addr) limiter.Limiter {
 	now := time.Now().UnixNano()
 	// cache the limiter for 1s
 	if c.limiter != nil && time.Duration(now-c.expIn) > time.Second {
-		c.limiterIn = c.limiter.In(addr.String())
+		c.limiterIn = c.limiter.In(c.limitKey(addr))
 		c.expIn = now
 	}
 	return c.limiterIn
@@ -55,7 +76,7 @@ func (c *serverConn) getOutLimiter(addr net.Addr) limiter.Limiter {
 	now := time.Now().UnixNano()
 	// cache the limiter for 1s
 	if c.limiter != nil && time.Duration(now-c.expOut) > time.Second {
-		c.limiterOut = c.limiter.Out(addr.String())
+		c.limiterOut = c.limiter.Out(c.limitKey(addr))
 		c.expOut = now
 	}
 	return c.limiterOut
@@ -131,6 +152,8 @@ type packetConn struct {
 	limiter   limiter.TrafficLimiter
 	inLimits  *cache.Cache
 	outLimits *cache.Cache
+	// key is the key of the limits, it is the peer address if empty.
+	key string
 }
 
 func WrapPacketConn(lim limiter.TrafficLimiter, pc net.PacketConn) net.PacketConn {
@@ -145,13 +168,35 @@ func WrapPacketConn(lim limiter.TrafficLimiter, pc net.PacketConn) net.PacketCon
 	}
 }
 
+// WrapUserPacketConn wraps the packet connection of the user, the limits are keyed by the user.
+func WrapUserPacketConn(lim limiter.TrafficLimiter, user string, pc net.PacketConn) net.PacketConn {
+	if lim == nil || user == "" {
+		return pc
+	}
+	return &packetConn{
+		PacketConn: pc,
+		limiter:    lim,
+		key:        user,
+		inLimits:   cache.New(time.Second, 10*time.Second),
+		outLimits:  cache.New(time.Second, 10*time.Second),
+	}
+}
+
+func (c *packetConn) limitKey(addr net.Addr) string {
+	if c.key != "" {
+		return c.key
+	}
+	return addr.String()
+}
+
 func (c *packetConn) getInLimiter(addr net.Addr) limiter.Limiter {
 	if c.limiter == nil {
 		return nil
 	}
+	key := c.limitKey(addr)
 
 	lim, ok := func() (lim limiter.Limiter, ok bool) {
-		v, ok := c.inLimits.Get(addr.String())
+		v, ok := c.inLimits.Get(key)
 		if ok {
 			if v != nil {
 				lim = v.(limiter.Limiter)
@@ -163,8 +208,8 @@ func (c *packetConn) getInLimiter(addr net.Addr) limiter.Limiter {
 		return lim
 	}
 
-	lim = c.limiter.In(addr.String())
-	c.inLimits.Set(addr.String(), lim, 0)
+	lim = c.limiter.In(key)
+	c.inLimits.Set(key, lim, 0)
 
 	return lim
 }
@@ -173,9 +218,10 @@ func (c *packetConn) getOutLimiter(addr net.Addr) limiter.Limiter {
 	if c.limiter == nil {
 		return nil
 	}
+	key := c.limitKey(addr)
 
 	lim, ok := func() (lim limiter.Limiter, ok bool) {
-		v, ok := c.outLimits.Get(addr.String())
+		v, ok := c.outLimits.Get(key)
 		if ok {
 			if v != nil {
 				lim = v.(limiter.Limiter)
@@ -187,8 +233,8 @@ func (c *packetConn) getOutLimiter(addr net.Addr) limiter.Limiter {
 		return lim
 	}
 
-	lim = c.limiter.Out(addr.String())
-	c.outLimits.Set(addr.String(), lim, 0)
+	lim = c.limiter.Out(key)
+	c.outLimits.Set(key, lim, 0)
 
 	return lim
 }
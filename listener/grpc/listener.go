@@ -11,6 +11,7 @@ import (
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/internal/net/proxyproto"
 	pb "github.com/wznpp1/gost_x/internal/util/grpc/proto"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
@@ -54,7 +55,7 @@ func (l *grpcListener) Init(md md.Metadata) (err error) {
 	if xnet.IsIPv4(l.options.Addr) {
 		network = "tcp4"
 	}
-	ln, err := net.Listen(network, l.options.Addr)
+	ln, err := xnet.Listen(network, l.options.Addr, l.options.Service)
 	if err != nil {
 		return
 	}
@@ -66,6 +67,7 @@ func (l *grpcListener) Init(md md.Metadata) (err error) {
 
 	var opts []grpc.ServerOption
 	if !l.md.insecure {
+		ln = tls_util.WrapCamouflageListener(l.md.camouflage, ln)
 		opts = append(opts, grpc.Creds(credentials.NewTLS(l.options.TLSConfig)))
 	}
 	if l.md.keepalive {
@@ -5,6 +5,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -21,6 +22,8 @@ type metadata struct {
 	keepaliveTimeout             time.Duration
 	keepalivePermitWithoutStream bool
 	keepaliveMaxConnectionIdle   time.Duration
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *grpcListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -51,5 +54,15 @@ func (l *grpcListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.keepaliveMaxConnectionIdle = mdutil.GetDuration(md, "grpc.keepalive.maxConnectionIdle", "keepalive.maxConnectionIdle")
 	}
 
+	if addr := mdutil.GetString(md, "grpc.camouflage", "camouflage"); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, "grpc.camouflageServerNames", "camouflageServerNames"),
+			Key:         mdutil.GetString(md, "grpc.camouflageKey", "camouflageKey"),
+			Timeout:     mdutil.GetDuration(md, "grpc.camouflageTimeout", "camouflageTimeout"),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
@@ -14,6 +14,7 @@ import (
 	admission "github.com/wznpp1/gost_x/admission/wrapper"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/internal/net/proxyproto"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
@@ -77,7 +78,7 @@ func (l *h2Listener) Init(md md.Metadata) (err error) {
 	if xnet.IsIPv4(l.options.Addr) {
 		network = "tcp4"
 	}
-	ln, err := net.Listen(network, l.options.Addr)
+	ln, err := xnet.Listen(network, l.options.Addr, l.options.Service)
 	if err != nil {
 		return err
 	}
@@ -99,6 +100,7 @@ func (l *h2Listener) Init(md md.Metadata) (err error) {
 			ln.Close()
 			return err
 		}
+		ln = tls_util.WrapCamouflageListener(l.md.camouflage, ln)
 		ln = tls.NewListener(ln, l.options.TLSConfig)
 	}
 
@@ -3,6 +3,7 @@ package h2
 import (
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -12,12 +13,19 @@ const (
 type metadata struct {
 	path    string
 	backlog int
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *h2Listener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		path    = "path"
 		backlog = "backlog"
+
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
 	)
 
 	l.md.backlog = mdutil.GetInt(md, backlog)
@@ -26,5 +34,16 @@ func (l *h2Listener) parseMetadata(md mdata.Metadata) (err error) {
 	}
 
 	l.md.path = mdutil.GetString(md, path)
+
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
@@ -63,7 +63,7 @@ func (l *http2Listener) Init(md md.Metadata) (err error) {
 	if xnet.IsIPv4(l.options.Addr) {
 		network = "tcp4"
 	}
-	ln, err := net.Listen(network, l.options.Addr)
+	ln, err := xnet.Listen(network, l.options.Addr, l.options.Service)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,298 @@
+package hysteria2
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	admission "github.com/wznpp1/gost_x/admission/wrapper"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xnet "github.com/wznpp1/gost_x/internal/net"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
+	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ListenerRegistry().Register("hysteria2", NewListener)
+	registry.ListenerRegistry().Register("hy2", NewListener)
+}
+
+type hysteria2Listener struct {
+	conn       net.PacketConn
+	ln         quic.EarlyListener
+	masquerade http.Handler
+	cqueue     chan net.Conn
+	errChan    chan error
+	logger     logger.Logger
+	md         metadata
+	options    listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &hysteria2Listener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *hysteria2Listener) Init(md md.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	addr := l.options.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	network := "udp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "udp4"
+	}
+	var laddr *net.UDPAddr
+	laddr, err = net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return
+	}
+
+	var conn net.PacketConn
+	conn, err = net.ListenUDP(network, laddr)
+	if err != nil {
+		return
+	}
+	if l.md.obfsPassword != "" {
+		var pc net.PacketConn
+		if pc, err = hy2.SalamanderPacketConn(conn, []byte(l.md.obfsPassword)); err != nil {
+			conn.Close()
+			return
+		}
+		conn = pc
+	}
+
+	config := &quic.Config{
+		KeepAlivePeriod:      l.md.keepAlivePeriod,
+		HandshakeIdleTimeout: l.md.handshakeTimeout,
+		MaxIdleTimeout:       l.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		MaxIncomingStreams: int64(l.md.maxStreams),
+		EnableDatagrams:    true,
+	}
+
+	tlsCfg := l.options.TLSConfig.Clone()
+	tlsCfg.NextProtos = []string{http3.NextProtoH3}
+
+	ln, err := quic.ListenEarly(conn, tlsCfg, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	if target := l.md.masquerade; target != nil {
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Host = target.Host
+		}
+		l.masquerade = proxy
+	} else {
+		l.masquerade = http.NotFoundHandler()
+	}
+
+	l.conn = conn
+	l.ln = ln
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *hysteria2Listener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+		conn = metrics.WrapConn(l.options.Service, conn)
+		conn = admission.WrapConn(l.options.Admission, conn)
+		conn = limiter.WrapConn(l.options.TrafficLimiter, conn)
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+// Close closes the QUIC listener and the UDP socket of it,
+// quic.ListenEarly does not close the socket passed by the caller.
+func (l *hysteria2Listener) Close() error {
+	err := l.ln.Close()
+	l.conn.Close()
+	return err
+}
+
+func (l *hysteria2Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *hysteria2Listener) listenLoop() {
+	for {
+		ctx := context.Background()
+		conn, err := l.ln.Accept(ctx)
+		if err != nil {
+			l.logger.Error("accept:", err)
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.serveConn(conn)
+	}
+}
+
+// serveConn serves the HTTP/3 requests on the QUIC connection,
+// the TCP request streams and the UDP messages are accepted after the client is authenticated.
+func (l *hysteria2Listener) serveConn(conn quic.Connection) {
+	defer conn.CloseWithError(0, "")
+
+	var (
+		authenticated bool
+		user          string
+		mu            sync.Mutex
+		once          sync.Once
+	)
+
+	server := &http3.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Host != hy2.URLHost || r.URL.Path != hy2.URLPath {
+				l.masquerade.ServeHTTP(w, r)
+				return
+			}
+
+			var req hy2.AuthRequest
+			req.ReadHeader(r.Header)
+			u, ok := l.authenticate(req.Auth)
+			if !ok {
+				l.logger.Warnf("%s: %v", conn.RemoteAddr(), hy2.ErrAuth)
+				l.masquerade.ServeHTTP(w, r)
+				return
+			}
+
+			mu.Lock()
+			authenticated, user = true, u
+			mu.Unlock()
+
+			resp := hy2.AuthResponse{
+				UDPEnabled: l.md.enableUDP,
+				Rx:         l.md.down,
+				RxAuto:     l.md.ignoreClientBandwidth,
+			}
+			resp.WriteHeader(w.Header())
+			w.WriteHeader(hy2.StatusAuthOK)
+
+			l.logger.Debugf("%s: authenticated, client rx %d B/s", conn.RemoteAddr(), req.Rx)
+
+			if l.md.enableUDP {
+				once.Do(func() {
+					go l.serveUDP(conn, u)
+				})
+			}
+		}),
+		StreamHijacker: func(ft http3.FrameType, _ quic.Connection, stream quic.Stream, err error) (bool, error) {
+			if err != nil || ft != hy2.FrameTypeTCPRequest {
+				return false, nil
+			}
+
+			mu.Lock()
+			ok, u := authenticated, user
+			mu.Unlock()
+			if !ok {
+				return false, nil
+			}
+
+			go l.handleStream(conn, stream, u)
+			return true, nil
+		},
+	}
+
+	if err := server.ServeQUICConn(conn); err != nil {
+		l.logger.Debugf("%s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func (l *hysteria2Listener) handleStream(conn quic.Connection, stream quic.Stream, user string) {
+	addr, err := hy2.ReadTCPRequest(stream)
+	if err != nil {
+		l.logger.Error(err)
+		stream.CancelRead(0)
+		stream.Close()
+		return
+	}
+
+	c := hy2.NewTCPConn(stream, conn.LocalAddr(), conn.RemoteAddr(), addr, user)
+	select {
+	case l.cqueue <- c:
+	default:
+		c.Close()
+		l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+	}
+}
+
+func (l *hysteria2Listener) serveUDP(conn quic.Connection, user string) {
+	mux := hy2.NewUDPMux(conn)
+	mux.Serve(func(c *hy2.UDPConn) {
+		c.SetUser(user)
+		select {
+		case l.cqueue <- c:
+		default:
+			c.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+		}
+	}, l.md.udpIdleTimeout)
+}
+
+// authenticate returns the user of the auth which is the password or in the form of user:password.
+func (l *hysteria2Listener) authenticate(auth string) (string, bool) {
+	if l.options.Auther == nil && l.options.Auth == nil {
+		return "", true
+	}
+
+	if l.options.Auth != nil {
+		u := l.options.Auth.Username()
+		p, _ := l.options.Auth.Password()
+		if auth == p || auth == u+":"+p {
+			return u, true
+		}
+	}
+
+	if l.options.Auther != nil {
+		if u, p, ok := strings.Cut(auth, ":"); ok && l.options.Auther.Authenticate(u, p) {
+			return u, true
+		}
+		if lister, ok := l.options.Auther.(xauth.Lister); ok {
+			for u, p := range lister.Users() {
+				if auth == p {
+					return u, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,102 @@
+package hysteria2
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	hy2 "github.com/wznpp1/gost_x/internal/util/hysteria2"
+)
+
+const (
+	defaultBacklog        = 128
+	defaultUDPIdleTimeout = 60 * time.Second
+)
+
+type metadata struct {
+	keepAlivePeriod  time.Duration
+	handshakeTimeout time.Duration
+	maxIdleTimeout   time.Duration
+	maxStreams       int
+
+	obfsPassword          string
+	down                  uint64
+	ignoreClientBandwidth bool
+	enableUDP             bool
+	udpIdleTimeout        time.Duration
+	masquerade            *url.URL
+	backlog               int
+}
+
+func (l *hysteria2Listener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		keepAlive        = "keepAlive"
+		keepAlivePeriod  = "ttl"
+		handshakeTimeout = "handshakeTimeout"
+		maxIdleTimeout   = "maxIdleTimeout"
+		maxStreams       = "maxStreams"
+
+		obfs                  = "obfs"
+		obfsPassword          = "obfsPassword"
+		up                    = "up"
+		down                  = "down"
+		ignoreClientBandwidth = "ignoreClientBandwidth"
+		enableUDP             = "udp"
+		udpIdleTimeout        = "udpIdleTimeout"
+		masquerade            = "masquerade"
+		backlog               = "backlog"
+	)
+
+	l.md.backlog = mdutil.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		l.md.keepAlivePeriod = mdutil.GetDuration(md, keepAlivePeriod)
+		if l.md.keepAlivePeriod <= 0 {
+			l.md.keepAlivePeriod = 10 * time.Second
+		}
+	}
+	l.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	l.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
+	l.md.maxStreams = mdutil.GetInt(md, maxStreams)
+
+	switch v := mdutil.GetString(md, obfs); v {
+	case "", "plain":
+	case "salamander":
+		l.md.obfsPassword = mdutil.GetString(md, obfsPassword)
+		if len(l.md.obfsPassword) < hy2.SalamanderMinPSKLen {
+			return hy2.ErrSalamanderPSK
+		}
+	default:
+		return fmt.Errorf("hysteria2: unknown obfs %s", v)
+	}
+
+	if mdutil.GetString(md, up) != "" {
+		return hy2.ErrUpBandwidth
+	}
+	if l.md.down, err = hy2.ParseBandwidth(mdutil.GetString(md, down)); err != nil {
+		return
+	}
+	l.md.ignoreClientBandwidth = mdutil.GetBool(md, ignoreClientBandwidth)
+
+	l.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		l.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+	l.md.udpIdleTimeout = mdutil.GetDuration(md, udpIdleTimeout)
+	if l.md.udpIdleTimeout <= 0 {
+		l.md.udpIdleTimeout = defaultUDPIdleTimeout
+	}
+
+	if v := mdutil.GetString(md, masquerade); v != "" {
+		if l.md.masquerade, err = url.Parse(v); err != nil {
+			return
+		}
+	}
+
+	return
+}
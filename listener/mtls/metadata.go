@@ -1,10 +1,10 @@
 package mtls
 
 import (
-	"time"
-
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -12,26 +12,21 @@ const (
 )
 
 type metadata struct {
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
 
 	backlog int
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *mtlsListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		backlog = "backlog"
 
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
 	)
 
 	l.md.backlog = mdutil.GetInt(md, backlog)
@@ -39,12 +34,17 @@ func (l *mtlsListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.backlog = defaultBacklog
 	}
 
-	l.md.muxKeepAliveDisabled = mdutil.GetBool(md, muxKeepAliveDisabled)
-	l.md.muxKeepAliveInterval = mdutil.GetDuration(md, muxKeepAliveInterval)
-	l.md.muxKeepAliveTimeout = mdutil.GetDuration(md, muxKeepAliveTimeout)
-	l.md.muxMaxFrameSize = mdutil.GetInt(md, muxMaxFrameSize)
-	l.md.muxMaxReceiveBuffer = mdutil.GetInt(md, muxMaxReceiveBuffer)
-	l.md.muxMaxStreamBuffer = mdutil.GetInt(md, muxMaxStreamBuffer)
+	l.md.muxCfg = mux.ParseConfig(md)
+
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
 
 	return
 }
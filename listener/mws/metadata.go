@@ -6,6 +6,8 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	"github.com/wznpp1/gost_x/internal/util/mux"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -24,12 +26,9 @@ type metadata struct {
 	writeBufferSize   int
 	enableCompression bool
 
-	muxKeepAliveDisabled bool
-	muxKeepAliveInterval time.Duration
-	muxKeepAliveTimeout  time.Duration
-	muxMaxFrameSize      int
-	muxMaxReceiveBuffer  int
-	muxMaxStreamBuffer   int
+	muxCfg *mux.Config
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -44,12 +43,10 @@ func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
 		writeBufferSize   = "writeBufferSize"
 		enableCompression = "enableCompression"
 
-		muxKeepAliveDisabled = "muxKeepAliveDisabled"
-		muxKeepAliveInterval = "muxKeepAliveInterval"
-		muxKeepAliveTimeout  = "muxKeepAliveTimeout"
-		muxMaxFrameSize      = "muxMaxFrameSize"
-		muxMaxReceiveBuffer  = "muxMaxReceiveBuffer"
-		muxMaxStreamBuffer   = "muxMaxStreamBuffer"
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
 	)
 
 	l.md.path = mdutil.GetString(md, path)
@@ -68,12 +65,7 @@ func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
 	l.md.writeBufferSize = mdutil.GetInt(md, writeBufferSize)
 	l.md.enableCompression = mdutil.GetBool(md, enableCompression)
 
-	l.md.muxKeepAliveDisabled = mdutil.GetBool(md, muxKeepAliveDisabled)
-	l.md.muxKeepAliveInterval = mdutil.GetDuration(md, muxKeepAliveInterval)
-	l.md.muxKeepAliveTimeout = mdutil.GetDuration(md, muxKeepAliveTimeout)
-	l.md.muxMaxFrameSize = mdutil.GetInt(md, muxMaxFrameSize)
-	l.md.muxMaxReceiveBuffer = mdutil.GetInt(md, muxMaxReceiveBuffer)
-	l.md.muxMaxStreamBuffer = mdutil.GetInt(md, muxMaxStreamBuffer)
+	l.md.muxCfg = mux.ParseConfig(md)
 
 	if mm := mdutil.GetStringMapString(md, header); len(mm) > 0 {
 		hd := http.Header{}
@@ -82,5 +74,16 @@ func (l *mwsListener) parseMetadata(md mdata.Metadata) (err error) {
 		}
 		l.md.header = hd
 	}
+
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
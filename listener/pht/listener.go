@@ -71,6 +71,7 @@ func (l *phtListener) Init(md md.Metadata) (err error) {
 		l.options.Addr,
 		pht_util.TLSConfigServerOption(l.options.TLSConfig),
 		pht_util.EnableTLSServerOption(l.tlsEnabled),
+		pht_util.CamouflageServerOption(l.md.camouflage),
 		pht_util.BacklogServerOption(l.md.backlog),
 		pht_util.PathServerOption(l.md.authorizePath, l.md.pushPath, l.md.pullPath),
 		pht_util.LoggerServerOption(l.options.Logger),
@@ -5,6 +5,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -19,6 +20,8 @@ type metadata struct {
 	pushPath      string
 	pullPath      string
 	backlog       int
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *phtListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -28,6 +31,11 @@ func (l *phtListener) parseMetadata(md mdata.Metadata) (err error) {
 		pullPath      = "pullPath"
 
 		backlog = "backlog"
+
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
 	)
 
 	l.md.authorizePath = mdutil.GetString(md, authorizePath)
@@ -48,5 +56,15 @@ func (l *phtListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.backlog = defaultBacklog
 	}
 
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
@@ -11,6 +11,7 @@ import (
 	admission "github.com/wznpp1/gost_x/admission/wrapper"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/internal/net/proxyproto"
+	"github.com/wznpp1/gost_x/internal/net/tproxy"
 	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
@@ -25,6 +26,8 @@ func init() {
 
 type redirectListener struct {
 	ln      net.Listener
+	tcpLn   net.Listener
+	rules   *tproxy.Rules
 	logger  logger.Logger
 	md      metadata
 	options listener.Options
@@ -58,6 +61,7 @@ func (l *redirectListener) Init(md md.Metadata) (err error) {
 	if err != nil {
 		return err
 	}
+	l.tcpLn = ln
 
 	ln = metrics.WrapListener(l.options.Service, ln)
 	ln = proxyproto.WrapListener(l.options.ProxyProtocol, ln, 10*time.Second)
@@ -77,5 +81,38 @@ func (l *redirectListener) Addr() net.Addr {
 }
 
 func (l *redirectListener) Close() error {
+	if l.rules != nil {
+		l.rules.Close()
+	}
 	return l.ln.Close()
 }
+
+// SetupRules implements tproxy.Manager interface.
+func (l *redirectListener) SetupRules(bypassMark int) bool {
+	if !l.md.tproxy || !l.md.auto {
+		return false
+	}
+
+	rules, err := tproxy.Setup(tproxy.Options{
+		Name:       l.options.Service,
+		Network:    "tcp",
+		Addr:       l.tcpLn.Addr().String(),
+		Mark:       l.md.mark,
+		Table:      l.md.table,
+		BypassMark: bypassMark,
+		Output:     l.md.output,
+		Backend:    l.md.backend,
+		Logger:     l.logger,
+	})
+	if err != nil {
+		l.logger.Error(err)
+		return false
+	}
+	l.rules = rules
+
+	// the accepted connections inherit the mark, so their packets are not intercepted again.
+	if err := l.setMark(bypassMark); err != nil {
+		l.logger.Warnf("set mark %#x: %v", bypassMark, err)
+	}
+	return true
+}
@@ -10,3 +10,7 @@ import (
 func (l *redirectListener) control(network, address string, c syscall.RawConn) error {
 	return errors.New("TProxy is not available on non-linux platform")
 }
+
+func (l *redirectListener) setMark(mark int) error {
+	return errors.New("TProxy is not available on non-linux platform")
+}
@@ -7,12 +7,31 @@ import (
 
 type metadata struct {
 	tproxy bool
+
+	// the TPROXY rules and policy routes are managed by the service if auto is enabled.
+	auto    bool
+	mark    int
+	table   int
+	output  bool
+	backend string
 }
 
 func (l *redirectListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		tproxy = "tproxy"
+
+		auto    = "tproxy.auto"
+		mark    = "tproxy.mark"
+		table   = "tproxy.table"
+		output  = "tproxy.output"
+		backend = "tproxy.backend"
 	)
 	l.md.tproxy = mdutil.GetBool(md, tproxy)
+
+	l.md.auto = mdutil.GetBool(md, auto)
+	l.md.mark = mdutil.GetInt(md, mark)
+	l.md.table = mdutil.GetInt(md, table)
+	l.md.output = mdutil.GetBool(md, output)
+	l.md.backend = mdutil.GetString(md, backend)
 	return
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/go-gost/core/logger"
 	md "github.com/go-gost/core/metadata"
 	admission "github.com/wznpp1/gost_x/admission/wrapper"
+	"github.com/wznpp1/gost_x/internal/net/tproxy"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
 	"github.com/wznpp1/gost_x/registry"
@@ -17,7 +18,10 @@ func init() {
 }
 
 type redirectListener struct {
-	ln      *net.UDPConn
+	ln *net.UDPConn
+	// mark is the socket mark of the reply sockets.
+	mark    int
+	rules   *tproxy.Rules
 	logger  logger.Logger
 	md      metadata
 	options listener.Options
@@ -64,5 +68,36 @@ func (l *redirectListener) Addr() net.Addr {
 }
 
 func (l *redirectListener) Close() error {
+	if l.rules != nil {
+		l.rules.Close()
+	}
 	return l.ln.Close()
 }
+
+// SetupRules implements tproxy.Manager interface.
+func (l *redirectListener) SetupRules(bypassMark int) bool {
+	if !l.md.auto {
+		return false
+	}
+
+	rules, err := tproxy.Setup(tproxy.Options{
+		Name:       l.options.Service,
+		Network:    "udp",
+		Addr:       l.ln.LocalAddr().String(),
+		Mark:       l.md.mark,
+		Table:      l.md.table,
+		BypassMark: bypassMark,
+		Output:     l.md.output,
+		Backend:    l.md.backend,
+		Logger:     l.logger,
+	})
+	if err != nil {
+		l.logger.Error(err)
+		return false
+	}
+	l.rules = rules
+
+	// the replies are sent by the sockets with the mark, so they are not intercepted again.
+	l.mark = bypassMark
+	return true
+}
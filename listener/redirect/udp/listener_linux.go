@@ -1,13 +1,12 @@
 package udp
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"syscall"
 	"unsafe"
 
@@ -20,11 +19,25 @@ func (l *redirectListener) listenUDP(addr string) (*net.UDPConn, error) {
 	lc := net.ListenConfig{
 		Control: func(network, address string, c syscall.RawConn) error {
 			return c.Control(func(fd uintptr) {
-				if err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1); err != nil {
-					l.logger.Errorf("SetsockoptInt(SOL_IP, IP_TRANSPARENT, 1): %v", err)
+				if strings.HasSuffix(network, "4") {
+					if err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1); err != nil {
+						l.logger.Errorf("SetsockoptInt(SOL_IP, IP_TRANSPARENT, 1): %v", err)
+					}
+					if err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_RECVORIGDSTADDR, 1); err != nil {
+						l.logger.Errorf("SetsockoptInt(SOL_IP, IP_RECVORIGDSTADDR, 1): %v", err)
+					}
+					return
 				}
+
+				if err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1); err != nil {
+					l.logger.Errorf("SetsockoptInt(SOL_IPV6, IPV6_TRANSPARENT, 1): %v", err)
+				}
+				if err := unix.SetsockoptInt(int(fd), unix.SOL_IPV6, unix.IPV6_RECVORIGDSTADDR, 1); err != nil {
+					l.logger.Errorf("SetsockoptInt(SOL_IPV6, IPV6_RECVORIGDSTADDR, 1): %v", err)
+				}
+				// the IPv4 packets received by the dual-stack socket carry the IPv4 original destination.
 				if err := unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_RECVORIGDSTADDR, 1); err != nil {
-					l.logger.Errorf("SetsockoptInt(SOL_IP, IP_RECVORIGDSTADDR, 1): %v", err)
+					l.logger.Debugf("SetsockoptInt(SOL_IP, IP_RECVORIGDSTADDR, 1): %v", err)
 				}
 			})
 		},
@@ -57,7 +70,7 @@ func (l *redirectListener) accept() (conn net.Conn, err error) {
 	if xnet.IsIPv4(l.options.Addr) {
 		network = "udp4"
 	}
-	c, err := dialUDP(network, dstAddr, raddr)
+	c, err := dialUDP(network, dstAddr, raddr, l.mark)
 	if err != nil {
 		l.logger.Error(err)
 		return
@@ -92,33 +105,33 @@ func readFromUDP(conn *net.UDPConn, b []byte) (n int, remoteAddr *net.UDPAddr, d
 	}
 
 	for _, msg := range msgs {
-		if msg.Header.Level == unix.SOL_IP && msg.Header.Type == unix.IP_RECVORIGDSTADDR {
-			originalDstRaw := &unix.RawSockaddrInet4{}
-			if err = binary.Read(bytes.NewReader(msg.Data), binary.LittleEndian, originalDstRaw); err != nil {
-				return 0, nil, nil, fmt.Errorf("reading original destination address: %s", err)
+		switch {
+		case msg.Header.Level == unix.SOL_IP && msg.Header.Type == unix.IP_RECVORIGDSTADDR:
+			if len(msg.Data) < unix.SizeofSockaddrInet4 {
+				return 0, nil, nil, fmt.Errorf("reading original destination address: short message")
+			}
+			pp := (*unix.RawSockaddrInet4)(unsafe.Pointer(&msg.Data[0]))
+			p := (*[2]byte)(unsafe.Pointer(&pp.Port))
+			dstAddr = &net.UDPAddr{
+				IP:   net.IPv4(pp.Addr[0], pp.Addr[1], pp.Addr[2], pp.Addr[3]),
+				Port: int(p[0])<<8 + int(p[1]),
 			}
 
-			switch originalDstRaw.Family {
-			case unix.AF_INET:
-				pp := (*unix.RawSockaddrInet4)(unsafe.Pointer(originalDstRaw))
-				p := (*[2]byte)(unsafe.Pointer(&pp.Port))
-				dstAddr = &net.UDPAddr{
-					IP:   net.IPv4(pp.Addr[0], pp.Addr[1], pp.Addr[2], pp.Addr[3]),
-					Port: int(p[0])<<8 + int(p[1]),
-				}
-
-			case unix.AF_INET6:
-				pp := (*unix.RawSockaddrInet6)(unsafe.Pointer(originalDstRaw))
-				p := (*[2]byte)(unsafe.Pointer(&pp.Port))
-				dstAddr = &net.UDPAddr{
-					IP:   net.IP(pp.Addr[:]),
-					Port: int(p[0])<<8 + int(p[1]),
-					Zone: strconv.Itoa(int(pp.Scope_id)),
-				}
-
-			default:
-				return 0, nil, nil, fmt.Errorf("original destination is an unsupported network family")
+		case msg.Header.Level == unix.SOL_IPV6 && msg.Header.Type == unix.IPV6_RECVORIGDSTADDR:
+			if len(msg.Data) < unix.SizeofSockaddrInet6 {
+				return 0, nil, nil, fmt.Errorf("reading original destination address: short message")
+			}
+			pp := (*unix.RawSockaddrInet6)(unsafe.Pointer(&msg.Data[0]))
+			p := (*[2]byte)(unsafe.Pointer(&pp.Port))
+			dstAddr = &net.UDPAddr{
+				IP:   append(net.IP{}, pp.Addr[:]...),
+				Port: int(p[0])<<8 + int(p[1]),
 			}
+			if pp.Scope_id > 0 {
+				dstAddr.Zone = strconv.Itoa(int(pp.Scope_id))
+			}
+		}
+		if dstAddr != nil {
 			break
 		}
 	}
@@ -133,7 +146,7 @@ func readFromUDP(conn *net.UDPConn, b []byte) (n int, remoteAddr *net.UDPAddr, d
 // DialUDP connects to the remote address raddr on the network net,
 // which must be "udp", "udp4", or "udp6".  If laddr is not nil, it is
 // used as the local address for the connection.
-func dialUDP(network string, laddr *net.UDPAddr, raddr *net.UDPAddr) (net.Conn, error) {
+func dialUDP(network string, laddr *net.UDPAddr, raddr *net.UDPAddr, mark int) (net.Conn, error) {
 	remoteSocketAddress, err := udpAddrToSocketAddr(raddr)
 	if err != nil {
 		return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("build destination socket address: %s", err)}
@@ -149,7 +162,12 @@ func dialUDP(network string, laddr *net.UDPAddr, raddr *net.UDPAddr) (net.Conn,
 		return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("socket open: %s", err)}
 	}
 
-	if err = unix.SetsockoptInt(fileDescriptor, unix.SOL_IP, unix.IP_TRANSPARENT, 1); err != nil {
+	if _, ok := localSocketAddress.(*unix.SockaddrInet6); ok {
+		err = unix.SetsockoptInt(fileDescriptor, unix.SOL_IPV6, unix.IPV6_TRANSPARENT, 1)
+	} else {
+		err = unix.SetsockoptInt(fileDescriptor, unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+	}
+	if err != nil {
 		unix.Close(fileDescriptor)
 		return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("set socket option: IP_TRANSPARENT: %s", err)}
 	}
@@ -163,6 +181,13 @@ func dialUDP(network string, laddr *net.UDPAddr, raddr *net.UDPAddr) (net.Conn,
 		return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("set socket option: SO_REUSEPORT: %s", err)}
 	}
 
+	if mark > 0 {
+		if err = unix.SetsockoptInt(fileDescriptor, unix.SOL_SOCKET, unix.SO_MARK, mark); err != nil {
+			unix.Close(fileDescriptor)
+			return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("set socket option: SO_MARK: %s", err)}
+		}
+	}
+
 	if err = unix.Bind(fileDescriptor, localSocketAddress); err != nil {
 		unix.Close(fileDescriptor)
 		return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("socket bind %v: %s", laddr, err)}
@@ -200,9 +225,16 @@ func udpAddrToSocketAddr(addr *net.UDPAddr) (unix.Sockaddr, error) {
 		ip := [16]byte{}
 		copy(ip[:], addr.IP.To16())
 
-		zoneID, err := strconv.ParseUint(addr.Zone, 10, 32)
-		if err != nil {
-			return nil, err
+		var zoneID uint64
+		if addr.Zone != "" {
+			var err error
+			if zoneID, err = strconv.ParseUint(addr.Zone, 10, 32); err != nil {
+				ifce, err := net.InterfaceByName(addr.Zone)
+				if err != nil {
+					return nil, err
+				}
+				zoneID = uint64(ifce.Index)
+			}
 		}
 
 		return &unix.SockaddrInet6{Addr: ip, Port: addr.Port, ZoneId: uint32(zoneID)}, nil
@@ -221,7 +253,7 @@ func udpAddrFamily(net string, laddr, raddr *net.UDPAddr) int {
 	}
 
 	if (laddr == nil || laddr.IP.To4() != nil) &&
-		(raddr == nil || laddr.IP.To4() != nil) {
+		(raddr == nil || raddr.IP.To4() != nil) {
 		return unix.AF_INET
 	}
 	return unix.AF_INET6
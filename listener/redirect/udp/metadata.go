@@ -15,12 +15,25 @@ const (
 type metadata struct {
 	ttl            time.Duration
 	readBufferSize int
+
+	// the TPROXY rules and policy routes are managed by the service if auto is enabled.
+	auto    bool
+	mark    int
+	table   int
+	output  bool
+	backend string
 }
 
 func (l *redirectListener) parseMetadata(md mdata.Metadata) (err error) {
 	const (
 		ttl            = "ttl"
 		readBufferSize = "readBufferSize"
+
+		auto    = "tproxy.auto"
+		mark    = "tproxy.mark"
+		table   = "tproxy.table"
+		output  = "tproxy.output"
+		backend = "tproxy.backend"
 	)
 
 	l.md.ttl = mdutil.GetDuration(md, ttl)
@@ -33,5 +46,11 @@ func (l *redirectListener) parseMetadata(md mdata.Metadata) (err error) {
 		l.md.readBufferSize = defaultReadBufferSize
 	}
 
+	l.md.auto = mdutil.GetBool(md, auto)
+	l.md.mark = mdutil.GetInt(md, mark)
+	l.md.table = mdutil.GetInt(md, table)
+	l.md.output = mdutil.GetBool(md, output)
+	l.md.backend = mdutil.GetString(md, backend)
+
 	return
 }
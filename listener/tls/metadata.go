@@ -2,11 +2,31 @@ package tls
 
 import (
 	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 type metadata struct {
+	camouflage *tls_util.Camouflage
 }
 
 func (l *tlsListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
+	)
+
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
@@ -0,0 +1,348 @@
+package tuic
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/google/uuid"
+	"github.com/quic-go/quic-go"
+	admission "github.com/wznpp1/gost_x/admission/wrapper"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xnet "github.com/wznpp1/gost_x/internal/net"
+	"github.com/wznpp1/gost_x/internal/util/tuic"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
+	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ListenerRegistry().Register("tuic", NewListener)
+}
+
+type tuicListener struct {
+	conn    net.PacketConn
+	ln      quic.EarlyListener
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.Logger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &tuicListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *tuicListener) Init(md md.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	addr := l.options.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	network := "udp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "udp4"
+	}
+	var laddr *net.UDPAddr
+	laddr, err = net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return
+	}
+
+	var conn net.PacketConn
+	conn, err = net.ListenUDP(network, laddr)
+	if err != nil {
+		return
+	}
+
+	config := &quic.Config{
+		KeepAlivePeriod:      l.md.keepAlivePeriod,
+		HandshakeIdleTimeout: l.md.handshakeTimeout,
+		MaxIdleTimeout:       l.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		MaxIncomingStreams:    int64(l.md.maxStreams),
+		MaxIncomingUniStreams: int64(l.md.maxStreams),
+		EnableDatagrams:       true,
+	}
+	if l.md.zeroRTT {
+		config.Allow0RTT = func(net.Addr) bool { return true }
+	}
+
+	tlsCfg := l.options.TLSConfig.Clone()
+	tlsCfg.NextProtos = l.md.alpn
+
+	ln, err := quic.ListenEarly(conn, tlsCfg, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	l.conn = conn
+	l.ln = ln
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *tuicListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+		conn = metrics.WrapConn(l.options.Service, conn)
+		conn = admission.WrapConn(l.options.Admission, conn)
+		conn = limiter.WrapConn(l.options.TrafficLimiter, conn)
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+// Close closes the QUIC listener and the UDP socket of it,
+// quic.ListenEarly does not close the socket passed by the caller.
+func (l *tuicListener) Close() error {
+	err := l.ln.Close()
+	l.conn.Close()
+	return err
+}
+
+func (l *tuicListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *tuicListener) listenLoop() {
+	for {
+		ctx := context.Background()
+		conn, err := l.ln.Accept(ctx)
+		if err != nil {
+			l.logger.Error("accept:", err)
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.serveConn(conn)
+	}
+}
+
+// session is the state of the QUIC connection,
+// the commands are handled after the client is authenticated.
+type session struct {
+	conn   quic.EarlyConnection
+	udp    *tuic.UDPMux
+	user   string
+	authed chan struct{}
+	once   sync.Once
+}
+
+// waitAuth waits for the authentication, it returns false if the connection is closed.
+func (s *session) waitAuth() bool {
+	select {
+	case <-s.authed:
+		return true
+	case <-s.conn.Context().Done():
+		return false
+	}
+}
+
+func (l *tuicListener) serveConn(conn quic.EarlyConnection) {
+	defer conn.CloseWithError(0, "")
+
+	s := &session{
+		conn:   conn,
+		udp:    tuic.NewUDPMux(conn, tuic.UDPModeNative),
+		authed: make(chan struct{}),
+	}
+	defer s.udp.CloseAll()
+
+	go func() {
+		select {
+		case <-s.authed:
+		case <-time.After(l.md.authTimeout):
+			l.logger.Warnf("%s: authentication timeout", conn.RemoteAddr())
+			conn.CloseWithError(0, "")
+		case <-conn.Context().Done():
+		}
+	}()
+	go l.serveUniStreams(s)
+	go l.serveDatagrams(s)
+	go s.udp.CheckIdle(l.md.udpIdleTimeout)
+
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			l.logger.Debugf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		go l.handleStream(s, stream)
+	}
+}
+
+func (l *tuicListener) handleStream(s *session, stream quic.Stream) {
+	if !s.waitAuth() {
+		return
+	}
+
+	h := &tuic.Header{}
+	if _, err := h.ReadFrom(stream); err != nil || h.Cmd != tuic.CmdConnect || h.Addr == "" {
+		l.logger.Errorf("%s: bad command", s.conn.RemoteAddr())
+		stream.CancelRead(0)
+		stream.Close()
+		return
+	}
+
+	c := tuic.NewTCPConn(stream, s.conn.LocalAddr(), s.conn.RemoteAddr(), h.Addr, s.user)
+	select {
+	case l.cqueue <- c:
+	default:
+		c.Close()
+		l.logger.Warnf("connection queue is full, client %s discarded", s.conn.RemoteAddr())
+	}
+}
+
+func (l *tuicListener) serveUniStreams(s *session) {
+	for {
+		stream, err := s.conn.AcceptUniStream(context.Background())
+		if err != nil {
+			return
+		}
+
+		go func() {
+			h := &tuic.Header{}
+			if _, err := h.ReadFrom(stream); err != nil {
+				l.logger.Errorf("%s: %v", s.conn.RemoteAddr(), err)
+				stream.CancelRead(0)
+				return
+			}
+
+			switch h.Cmd {
+			case tuic.CmdAuthenticate:
+				l.handleAuth(s, h)
+			case tuic.CmdPacket:
+				data, err := tuic.ReadPacket(stream, h)
+				if err != nil || !s.waitAuth() {
+					return
+				}
+				s.udp.Feed(h, data, tuic.UDPModeQUIC, l.acceptUDP(s))
+			case tuic.CmdDissociate:
+				if s.waitAuth() {
+					s.udp.Dissociate(h.AssocID)
+				}
+			default:
+				stream.CancelRead(0)
+			}
+		}()
+	}
+}
+
+func (l *tuicListener) serveDatagrams(s *session) {
+	for {
+		b, err := s.conn.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		h, data, err := tuic.ParseDatagram(b)
+		if err != nil || h.Cmd != tuic.CmdPacket {
+			// the heartbeats are discarded
+			continue
+		}
+		if !s.waitAuth() {
+			return
+		}
+		s.udp.Feed(h, data, tuic.UDPModeNative, l.acceptUDP(s))
+	}
+}
+
+func (l *tuicListener) acceptUDP(s *session) func(c *tuic.UDPConn) {
+	return func(c *tuic.UDPConn) {
+		c.SetUser(s.user)
+		select {
+		case l.cqueue <- c:
+		default:
+			c.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", s.conn.RemoteAddr())
+		}
+	}
+}
+
+func (l *tuicListener) handleAuth(s *session, h *tuic.Header) {
+	select {
+	case <-s.authed:
+		return
+	case <-s.conn.HandshakeComplete().Done():
+	case <-s.conn.Context().Done():
+		return
+	}
+
+	state := s.conn.ConnectionState().TLS
+	user, ok := l.authenticate(&state.ConnectionState, h.UUID, h.Token)
+	if !ok {
+		l.logger.Warnf("%s: %v", s.conn.RemoteAddr(), tuic.ErrAuth)
+		s.conn.CloseWithError(0, "")
+		return
+	}
+
+	s.once.Do(func() {
+		s.user = user
+		close(s.authed)
+	})
+}
+
+// authenticate checks the token against the users of the auther and the auth of the listener,
+// the username is the UUID of the user.
+// The token is made of the password, so only the auther which lists the users is supported,
+// the user found by the token is authenticated by the auther as well.
+func (l *tuicListener) authenticate(state *tls.ConnectionState, id uuid.UUID, token []byte) (string, bool) {
+	if l.options.Auther == nil && l.options.Auth == nil {
+		return "", true
+	}
+
+	if l.options.Auth != nil {
+		user := l.options.Auth.Username()
+		password, _ := l.options.Auth.Password()
+		if l.checkToken(state, id, token, user, password) {
+			return user, true
+		}
+	}
+
+	if lister, ok := l.options.Auther.(xauth.Lister); ok {
+		for user, password := range lister.Users() {
+			if l.checkToken(state, id, token, user, password) &&
+				l.options.Auther.Authenticate(user, password) {
+				return user, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (l *tuicListener) checkToken(state *tls.ConnectionState, id uuid.UUID, token []byte, user, password string) bool {
+	if tuic.ID(user) != id {
+		return false
+	}
+	b, err := tuic.Token(state, id, password)
+	return err == nil && subtle.ConstantTimeCompare(b, token) == 1
+}
@@ -0,0 +1,77 @@
+package tuic
+
+import (
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+)
+
+const (
+	defaultBacklog        = 128
+	defaultAuthTimeout    = 3 * time.Second
+	defaultUDPIdleTimeout = 60 * time.Second
+)
+
+type metadata struct {
+	keepAlivePeriod  time.Duration
+	handshakeTimeout time.Duration
+	maxIdleTimeout   time.Duration
+	maxStreams       int
+
+	alpn           []string
+	zeroRTT        bool
+	authTimeout    time.Duration
+	udpIdleTimeout time.Duration
+	backlog        int
+}
+
+func (l *tuicListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		keepAlive        = "keepAlive"
+		keepAlivePeriod  = "ttl"
+		handshakeTimeout = "handshakeTimeout"
+		maxIdleTimeout   = "maxIdleTimeout"
+		maxStreams       = "maxStreams"
+
+		alpn           = "alpn"
+		zeroRTT        = "zeroRTT"
+		authTimeout    = "authTimeout"
+		udpIdleTimeout = "udpIdleTimeout"
+		backlog        = "backlog"
+	)
+
+	l.md.backlog = mdutil.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+
+	if !md.IsExists(keepAlive) || mdutil.GetBool(md, keepAlive) {
+		l.md.keepAlivePeriod = mdutil.GetDuration(md, keepAlivePeriod)
+		if l.md.keepAlivePeriod <= 0 {
+			l.md.keepAlivePeriod = 10 * time.Second
+		}
+	}
+	l.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	l.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
+	l.md.maxStreams = mdutil.GetInt(md, maxStreams)
+
+	l.md.alpn = mdutil.GetStrings(md, alpn)
+	if len(l.md.alpn) == 0 {
+		l.md.alpn = []string{"h3"}
+	}
+	l.md.zeroRTT = true
+	if md.IsExists(zeroRTT) {
+		l.md.zeroRTT = mdutil.GetBool(md, zeroRTT)
+	}
+	l.md.authTimeout = mdutil.GetDuration(md, authTimeout)
+	if l.md.authTimeout <= 0 {
+		l.md.authTimeout = defaultAuthTimeout
+	}
+	l.md.udpIdleTimeout = mdutil.GetDuration(md, udpIdleTimeout)
+	if l.md.udpIdleTimeout <= 0 {
+		l.md.udpIdleTimeout = defaultUDPIdleTimeout
+	}
+
+	return
+}
@@ -3,12 +3,14 @@ package tun
 import (
 	"context"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/go-gost/core/listener"
 	"github.com/go-gost/core/logger"
 	mdata "github.com/go-gost/core/metadata"
 	xnet "github.com/wznpp1/gost_x/internal/net"
+	tun_util "github.com/wznpp1/gost_x/internal/util/tun"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
 	mdx "github.com/wznpp1/gost_x/metadata"
 	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
@@ -92,6 +94,10 @@ func (l *tunListener) listenLoop() {
 			c = limiter.WrapConn(l.options.TrafficLimiter, c)
 			c = withMetadata(mdx.NewMetadata(map[string]any{
 				"config": l.md.config,
+				"configurator": &configurator{
+					l:    l,
+					name: name,
+				},
 			}), c)
 
 			l.cqueue <- c
@@ -136,3 +142,18 @@ func (l *tunListener) Close() error {
 	}
 	return nil
 }
+
+// configurator configures the TUN device by the settings assigned by the server.
+type configurator struct {
+	l    *tunListener
+	name string
+	mu   sync.Mutex
+}
+
+// Configure implements tun_util.Configurator interface.
+func (c *configurator) Configure(nets []net.IPNet, routes []tun_util.Route, dns []net.IP) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.l.configure(c.name, nets, routes, dns)
+}
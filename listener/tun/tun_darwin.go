@@ -56,3 +56,27 @@ func (l *tunListener) addRoutes(ifName string, routes ...tun_util.Route) error {
 	}
 	return nil
 }
+
+func (l *tunListener) configure(name string, nets []net.IPNet, routes []tun_util.Route, dns []net.IP) error {
+	if len(nets) > 0 {
+		peer := l.md.config.Peer
+		if peer == "" {
+			peer = nets[0].IP.String()
+		}
+		cmd := fmt.Sprintf("ifconfig %s inet %s %s up", name, nets[0].String(), peer)
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			return fmt.Errorf("%s: %v", cmd, er)
+		}
+	}
+
+	if err := l.addRoutes(name, routes...); err != nil {
+		return err
+	}
+
+	if len(dns) > 0 {
+		l.logger.Warnf("DNS servers %v are not applied to %s, it is not supported on this platform", dns, name)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os/exec"
+	"strings"
 
 	"github.com/vishvananda/netlink"
 
@@ -64,3 +66,61 @@ func (l *tunListener) addRoutes(ifce *net.Interface, routes ...tun_util.Route) e
 	}
 	return nil
 }
+
+func (l *tunListener) configure(name string, nets []net.IPNet, routes []tun_util.Route, dns []net.IP) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() || containsNet(nets, addr.IPNet) {
+			continue
+		}
+		if err := netlink.AddrDel(link, &addr); err != nil {
+			l.logger.Warnf("delete address %v: %v", addr.IPNet, err)
+		}
+	}
+	for i := range nets {
+		if err := netlink.AddrReplace(link, &netlink.Addr{
+			IPNet: &nets[i],
+		}); err != nil {
+			return fmt.Errorf("add address %v: %v", &nets[i], err)
+		}
+	}
+
+	ifce, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if err := l.addRoutes(ifce, routes...); err != nil {
+		return err
+	}
+
+	if len(dns) > 0 {
+		// the per-link DNS servers are managed by systemd-resolved.
+		args := []string{"dns", name}
+		for _, ip := range dns {
+			args = append(args, ip.String())
+		}
+		l.logger.Debugf("resolvectl %s", strings.Join(args, " "))
+		if err := exec.Command("resolvectl", args...).Run(); err != nil {
+			l.logger.Warnf("set DNS servers %v: %v", dns, err)
+		}
+	}
+
+	return nil
+}
+
+func containsNet(nets []net.IPNet, ipNet *net.IPNet) bool {
+	for _, n := range nets {
+		if n.IP.Equal(ipNet.IP) && n.Mask.String() == ipNet.Mask.String() {
+			return true
+		}
+	}
+	return false
+}
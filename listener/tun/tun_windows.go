@@ -74,3 +74,36 @@ func (l *tunListener) deleteRoute(ifName string, route string) error {
 func ipMask(mask net.IPMask) string {
 	return fmt.Sprintf("%d.%d.%d.%d", mask[0], mask[1], mask[2], mask[3])
 }
+
+func (l *tunListener) configure(name string, nets []net.IPNet, routes []tun_util.Route, dns []net.IP) error {
+	if len(nets) > 0 {
+		ipNet := nets[0]
+		cmd := fmt.Sprintf("netsh interface ip set address name=%s "+
+			"source=static addr=%s mask=%s gateway=none",
+			name, ipNet.IP.String(), ipMask(ipNet.Mask))
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			return fmt.Errorf("%s: %v", cmd, er)
+		}
+	}
+
+	if err := l.addRoutes(name, nil, routes...); err != nil {
+		return err
+	}
+
+	for i, ip := range dns {
+		cmd := fmt.Sprintf("netsh interface ip add dnsservers name=%s address=%s index=%d validate=no",
+			name, ip.String(), i+1)
+		if i == 0 {
+			cmd = fmt.Sprintf("netsh interface ip set dnsservers name=%s source=static address=%s validate=no",
+				name, ip.String())
+		}
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			l.logger.Warnf("%s: %v", cmd, er)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package wireguard
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/go-gost/core/common/net/udp"
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	admission "github.com/wznpp1/gost_x/admission/wrapper"
+	wg_util "github.com/wznpp1/gost_x/internal/util/wireguard"
+	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
+	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ListenerRegistry().Register("wireguard", NewListener)
+	registry.ListenerRegistry().Register("wg", NewListener)
+}
+
+// wireguardListener terminates the WireGuard peers on the UDP port of the listener address,
+// the TCP connections and the UDP flows to the port of the services on the tunnel addresses are accepted.
+type wireguardListener struct {
+	addr    net.Addr
+	tunnel  *wg_util.Tunnel
+	lns     []net.Listener
+	cqueue  chan net.Conn
+	errChan chan error
+	closed  chan struct{}
+	mu      sync.Mutex
+	logger  logger.Logger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &wireguardListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *wireguardListener) Init(md md.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", l.options.Addr)
+	if err != nil {
+		return
+	}
+	l.addr = laddr
+
+	config := *l.md.config
+	config.ListenPort = laddr.Port
+	l.tunnel, err = wg_util.NewTunnel(&config, l.logger)
+	if err != nil {
+		return
+	}
+
+	port := l.md.port
+	if port <= 0 {
+		port = laddr.Port
+	}
+
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+	l.closed = make(chan struct{})
+
+	for _, addr := range config.Addresses {
+		ln, err := l.listen(netip.AddrPortFrom(addr, uint16(port)))
+		if err != nil {
+			l.Close()
+			return err
+		}
+		l.lns = append(l.lns, ln...)
+	}
+	for _, ln := range l.lns {
+		go l.listenLoop(ln)
+	}
+
+	return
+}
+
+func (l *wireguardListener) listen(addr netip.AddrPort) (lns []net.Listener, err error) {
+	ln, err := l.tunnel.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	ln = metrics.WrapListener(l.options.Service, ln)
+	ln = admission.WrapListener(l.options.Admission, ln)
+	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
+	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
+	lns = append(lns, ln)
+
+	if !l.md.enableUDP {
+		return
+	}
+
+	pc, err := l.tunnel.ListenPacket(addr)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	pc = metrics.WrapPacketConn(l.options.Service, pc)
+	pc = admission.WrapPacketConn(l.options.Admission, pc)
+	pc = limiter.WrapPacketConn(l.options.TrafficLimiter, pc)
+
+	lns = append(lns, udp.NewListener(pc, &udp.ListenConfig{
+		Backlog:        l.md.backlog,
+		ReadQueueSize:  l.md.readQueueSize,
+		ReadBufferSize: l.md.readBufferSize,
+		KeepAlive:      true,
+		TTL:            l.md.ttl,
+		Logger:         l.logger,
+	}))
+	return
+}
+
+func (l *wireguardListener) listenLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case l.errChan <- err:
+			default:
+			}
+			return
+		}
+
+		select {
+		case l.cqueue <- conn:
+		case <-l.closed:
+			conn.Close()
+			return
+		default:
+			l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+			conn.Close()
+		}
+	}
+}
+
+func (l *wireguardListener) Accept() (conn net.Conn, err error) {
+	select {
+	case conn = <-l.cqueue:
+	case err = <-l.errChan:
+		l.Close()
+	case <-l.closed:
+		err = listener.ErrClosed
+	}
+	return
+}
+
+func (l *wireguardListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *wireguardListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.closed:
+		return nil
+	default:
+		close(l.closed)
+	}
+
+	for _, ln := range l.lns {
+		ln.Close()
+	}
+	return l.tunnel.Close()
+}
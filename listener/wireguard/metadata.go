@@ -0,0 +1,108 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	mdata "github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/core/metadata/util"
+	wg_util "github.com/wznpp1/gost_x/internal/util/wireguard"
+)
+
+const (
+	defaultBacklog        = 128
+	defaultTTL            = 5 * time.Second
+	defaultReadBufferSize = 4096
+	defaultReadQueueSize  = 1024
+)
+
+type metadata struct {
+	config *wg_util.Config
+	// port is the port of the services in the tunnel.
+	port      int
+	enableUDP bool
+
+	backlog        int
+	ttl            time.Duration
+	readBufferSize int
+	readQueueSize  int
+}
+
+func (l *wireguardListener) parseMetadata(md mdata.Metadata) (err error) {
+	const (
+		privateKey = "privateKey"
+		netKey     = "net"
+		mtu        = "mtu"
+		peers      = "peers"
+		port       = "port"
+		enableUDP  = "udp"
+
+		backlog        = "backlog"
+		ttl            = "ttl"
+		readBufferSize = "readBufferSize"
+		readQueueSize  = "readQueueSize"
+	)
+
+	config := &wg_util.Config{
+		PrivateKey: mdutil.GetString(md, privateKey),
+		MTU:        mdutil.GetInt(md, mtu),
+	}
+	if config.PrivateKey == "" {
+		return errors.New("wireguard: private key is required")
+	}
+	if config.Addresses, err = wg_util.ParseAddrs(mdutil.GetString(md, netKey)); err != nil {
+		return
+	}
+	if len(config.Addresses) == 0 {
+		return errors.New("wireguard: tunnel address (net) is required")
+	}
+
+	// each peer is in the form of: publicKey allowedIPs [presharedKey]
+	for _, s := range mdutil.GetStrings(md, peers) {
+		ss := strings.Fields(s)
+		if len(ss) < 2 || len(ss) > 3 {
+			return fmt.Errorf("wireguard: invalid peer %q, want: publicKey allowedIPs [presharedKey]", s)
+		}
+		peer := wg_util.Peer{
+			PublicKey: ss[0],
+		}
+		if peer.AllowedIPs, err = wg_util.ParsePrefixes(ss[1]); err != nil {
+			return
+		}
+		if len(ss) > 2 {
+			peer.PresharedKey = ss[2]
+		}
+		config.Peers = append(config.Peers, peer)
+	}
+	if err = config.Validate(); err != nil {
+		return
+	}
+	l.md.config = config
+
+	l.md.port = mdutil.GetInt(md, port)
+	l.md.enableUDP = true
+	if md.IsExists(enableUDP) {
+		l.md.enableUDP = mdutil.GetBool(md, enableUDP)
+	}
+
+	l.md.backlog = mdutil.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+	l.md.ttl = mdutil.GetDuration(md, ttl)
+	if l.md.ttl <= 0 {
+		l.md.ttl = defaultTTL
+	}
+	l.md.readBufferSize = mdutil.GetInt(md, readBufferSize)
+	if l.md.readBufferSize <= 0 {
+		l.md.readBufferSize = defaultReadBufferSize
+	}
+	l.md.readQueueSize = mdutil.GetInt(md, readQueueSize)
+	if l.md.readQueueSize <= 0 {
+		l.md.readQueueSize = defaultReadQueueSize
+	}
+
+	return
+}
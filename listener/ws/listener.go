@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -13,6 +14,7 @@ import (
 	admission "github.com/wznpp1/gost_x/admission/wrapper"
 	xnet "github.com/wznpp1/gost_x/internal/net"
 	"github.com/wznpp1/gost_x/internal/net/proxyproto"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 	ws_util "github.com/wznpp1/gost_x/internal/util/ws"
 	climiter "github.com/wznpp1/gost_x/limiter/conn/wrapper"
 	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
@@ -90,7 +92,7 @@ func (l *wsListener) Init(md md.Metadata) (err error) {
 		network = "tcp4"
 	}
 
-	ln, err := net.Listen(network, l.options.Addr)
+	ln, err := xnet.Listen(network, l.options.Addr, l.options.Service)
 	if err != nil {
 		return
 	}
@@ -100,6 +102,13 @@ func (l *wsListener) Init(md md.Metadata) (err error) {
 	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
 	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
 
+	if l.tlsEnabled {
+		ln = tls_util.WrapCamouflageListener(l.md.camouflage, ln)
+		ln = tls.NewListener(ln, l.options.TLSConfig)
+	}
+
+	l.addr = ln.Addr()
+
 	go func() {
 		err := l.srv.Serve(ln)
 		if err != nil {
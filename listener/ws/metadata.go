@@ -6,6 +6,7 @@ import (
 
 	mdata "github.com/go-gost/core/metadata"
 	mdutil "github.com/go-gost/core/metadata/util"
+	tls_util "github.com/wznpp1/gost_x/internal/util/tls"
 )
 
 const (
@@ -24,6 +25,8 @@ type metadata struct {
 	enableCompression bool
 
 	header http.Header
+
+	camouflage *tls_util.Camouflage
 }
 
 func (l *wsListener) parseMetadata(md mdata.Metadata) (err error) {
@@ -38,6 +41,11 @@ func (l *wsListener) parseMetadata(md mdata.Metadata) (err error) {
 		enableCompression = "enableCompression"
 
 		header = "header"
+
+		camouflage            = "camouflage"
+		camouflageServerNames = "camouflageServerNames"
+		camouflageKey         = "camouflageKey"
+		camouflageTimeout     = "camouflageTimeout"
 	)
 
 	l.md.path = mdutil.GetString(md, path)
@@ -63,5 +71,16 @@ func (l *wsListener) parseMetadata(md mdata.Metadata) (err error) {
 		}
 		l.md.header = hd
 	}
+
+	if addr := mdutil.GetString(md, camouflage); addr != "" {
+		l.md.camouflage = &tls_util.Camouflage{
+			Addr:        addr,
+			ServerNames: mdutil.GetStrings(md, camouflageServerNames),
+			Key:         mdutil.GetString(md, camouflageKey),
+			Timeout:     mdutil.GetDuration(md, camouflageTimeout),
+			Logger:      l.logger,
+		}
+	}
+
 	return
 }
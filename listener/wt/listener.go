@@ -0,0 +1,182 @@
+package wt
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/go-gost/core/listener"
+	"github.com/go-gost/core/logger"
+	md "github.com/go-gost/core/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+	admission "github.com/wznpp1/gost_x/admission/wrapper"
+	xnet "github.com/wznpp1/gost_x/internal/net"
+	wt_util "github.com/wznpp1/gost_x/internal/util/wt"
+	limiter "github.com/wznpp1/gost_x/limiter/traffic/wrapper"
+	metrics "github.com/wznpp1/gost_x/metrics/wrapper"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+func init() {
+	registry.ListenerRegistry().Register("wt", NewListener)
+}
+
+type wtListener struct {
+	conn    net.PacketConn
+	srv     *webtransport.Server
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.Logger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.Listener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &wtListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *wtListener) Init(md md.Metadata) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	addr := l.options.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	network := "udp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "udp4"
+	}
+	var laddr *net.UDPAddr
+	laddr, err = net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return
+	}
+
+	l.conn, err = net.ListenUDP(network, laddr)
+	if err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(l.md.path, http.HandlerFunc(l.upgrade))
+
+	l.srv = &webtransport.Server{
+		H3: http3.Server{
+			TLSConfig: l.options.TLSConfig,
+			QuicConfig: &quic.Config{
+				KeepAlivePeriod:      l.md.keepAlivePeriod,
+				HandshakeIdleTimeout: l.md.handshakeTimeout,
+				MaxIdleTimeout:       l.md.maxIdleTimeout,
+				Versions: []quic.VersionNumber{
+					quic.Version1,
+				},
+				MaxIncomingStreams: int64(l.md.maxStreams),
+			},
+			Handler: mux,
+		},
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go func() {
+		err := l.srv.Serve(l.conn)
+		if err != nil {
+			l.errChan <- err
+		}
+		close(l.errChan)
+	}()
+
+	return
+}
+
+func (l *wtListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+		conn = metrics.WrapConn(l.options.Service, conn)
+		conn = admission.WrapConn(l.options.Admission, conn)
+		conn = limiter.WrapConn(l.options.TrafficLimiter, conn)
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *wtListener) Close() error {
+	err := l.srv.Close()
+	l.conn.Close()
+	return err
+}
+
+func (l *wtListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+func (l *wtListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	if l.logger.IsLevelEnabled(logger.TraceLevel) {
+		log := l.logger.WithFields(map[string]any{
+			"local":  l.conn.LocalAddr().String(),
+			"remote": r.RemoteAddr,
+		})
+		dump, _ := httputil.DumpRequest(r, false)
+		log.Trace(string(dump))
+	}
+
+	for k, v := range l.md.header {
+		w.Header()[k] = v
+	}
+
+	session, err := l.srv.Upgrade(w, r)
+	if err != nil {
+		l.logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the datagrams of the session are accepted as a connection when the first one is received.
+	if qconn, ok := w.(http3.Hijacker).StreamCreator().(quic.Connection); ok {
+		streamID := r.Body.(http3.HTTPStreamer).HTTPStream().StreamID()
+		wt_util.DatagramMuxOf(qconn).NewConn(session.Context(), streamID, func(c net.Conn) {
+			l.enqueue(c)
+		})
+	}
+
+	go l.mux(session)
+}
+
+func (l *wtListener) mux(session *webtransport.Session) {
+	for {
+		stream, err := session.AcceptStream(context.Background())
+		if err != nil {
+			l.logger.Debugf("%s: %v", session.RemoteAddr(), err)
+			return
+		}
+		l.enqueue(wt_util.StreamConn(stream, session.LocalAddr(), session.RemoteAddr()))
+	}
+}
+
+func (l *wtListener) enqueue(conn net.Conn) {
+	select {
+	case l.cqueue <- conn:
+	default:
+		conn.Close()
+		l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+	}
+}
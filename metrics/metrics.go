@@ -21,6 +21,50 @@ const (
 	MetricServiceHandlerErrorsCounter metrics.MetricName = "gost_service_handler_errors_total"
 	// Total chain connect errors. Labels: host, chain, node.
 	MetricChainErrorsCounter metrics.MetricName = "gost_chain_errors_total"
+	// Total chain node input data transfer size in bytes. Labels: host, chain, node.
+	MetricNodeTransferInputBytesCounter metrics.MetricName = "gost_chain_node_transfer_input_bytes_total"
+	// Total chain node output data transfer size in bytes. Labels: host, chain, node.
+	MetricNodeTransferOutputBytesCounter metrics.MetricName = "gost_chain_node_transfer_output_bytes_total"
+	// Number of active streams through the chain node. Labels: host, chain, node.
+	MetricNodeStreamsGauge metrics.MetricName = "gost_chain_node_streams"
+	// Total chain node handshake errors. Labels: host, chain, node.
+	MetricNodeHandshakeErrorsCounter metrics.MetricName = "gost_chain_node_handshake_errors_total"
+	// Total service authentication failures. Labels: host, service, handler.
+	MetricServiceAuthFailuresCounter metrics.MetricName = "gost_service_auth_failures_total"
+	// Total service bypass hits. Labels: host, service.
+	MetricServiceBypassHitsCounter metrics.MetricName = "gost_service_bypass_hits_total"
+	// Total service admission denials. Labels: host, service.
+	MetricServiceAdmissionDenialsCounter metrics.MetricName = "gost_service_admission_denials_total"
+	// Total sniffed connections. Labels: host, service, protocol.
+	MetricServiceSniffedCounter metrics.MetricName = "gost_service_sniffed_total"
+	// Total DNS cache lookups. Labels: host, result.
+	MetricDNSCacheLookupsCounter metrics.MetricName = "gost_dns_cache_lookups_total"
+	// Total service input data transfer size in bytes per user. Labels: host, service, user.
+	// It is only available when the user label is enabled.
+	MetricUserTransferInputBytesCounter metrics.MetricName = "gost_service_user_transfer_input_bytes_total"
+	// Total service output data transfer size in bytes per user. Labels: host, service, user.
+	// It is only available when the user label is enabled.
+	MetricUserTransferOutputBytesCounter metrics.MetricName = "gost_service_user_transfer_output_bytes_total"
+	// Total service input data transfer size in bytes per target. Labels: host, service, target.
+	// It is only available when the target label is enabled.
+	MetricTargetTransferInputBytesCounter metrics.MetricName = "gost_service_target_transfer_input_bytes_total"
+	// Total service output data transfer size in bytes per target. Labels: host, service, target.
+	// It is only available when the target label is enabled.
+	MetricTargetTransferOutputBytesCounter metrics.MetricName = "gost_service_target_transfer_output_bytes_total"
+)
+
+const (
+	// LabelUser is the opt-in label of the authenticated user.
+	LabelUser = "user"
+	// LabelTarget is the opt-in label of the target host.
+	LabelTarget = "target"
+
+	// LabelValueOther is the value used for the opt-in labels
+	// when the number of distinct values exceeds the limit.
+	LabelValueOther = "other"
+
+	// DefaultMaxLabelValues is the default limit of distinct values for each opt-in label.
+	DefaultMaxLabelValues = 1000
 )
 
 var (
@@ -50,3 +94,15 @@ func GetGauge(name metrics.MetricName, labels metrics.Labels) metrics.Gauge {
 func GetObserver(name metrics.MetricName, labels metrics.Labels) metrics.Observer {
 	return global.Observer(name, labels)
 }
+
+// IsLabelEnabled reports whether the opt-in label is enabled.
+func IsLabelEnabled(label string) bool {
+	if v, ok := global.(labelEnabler); ok {
+		return v.IsLabelEnabled(label)
+	}
+	return false
+}
+
+type labelEnabler interface {
+	IsLabelEnabled(label string) bool
+}
@@ -2,22 +2,54 @@ package metrics
 
 import (
 	"os"
+	"sync"
 
 	"github.com/go-gost/core/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+type options struct {
+	labels         []string
+	maxLabelValues int
+}
+
+type Option func(opts *options)
+
+// LabelsOption enables the opt-in labels, such as LabelUser and LabelTarget.
+func LabelsOption(labels []string) Option {
+	return func(opts *options) {
+		opts.labels = labels
+	}
+}
+
+// MaxLabelValuesOption sets the maximum number of distinct values for each opt-in label.
+func MaxLabelValuesOption(n int) Option {
+	return func(opts *options) {
+		opts.maxLabelValues = n
+	}
+}
+
 type promMetrics struct {
 	host       string
 	gauges     map[metrics.MetricName]*prometheus.GaugeVec
 	counters   map[metrics.MetricName]*prometheus.CounterVec
 	histograms map[metrics.MetricName]*prometheus.HistogramVec
+	limiters   map[string]*labelLimiter
 }
 
-func NewMetrics() metrics.Metrics {
+func NewMetrics(opts ...Option) metrics.Metrics {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.maxLabelValues <= 0 {
+		options.maxLabelValues = DefaultMaxLabelValues
+	}
+
 	host, _ := os.Hostname()
 	m := &promMetrics{
-		host: host,
+		host:     host,
+		limiters: make(map[string]*labelLimiter),
 		gauges: map[metrics.MetricName]*prometheus.GaugeVec{
 			MetricServicesGauge: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
@@ -31,6 +63,12 @@ func NewMetrics() metrics.Metrics {
 					Help: "Current in-flight requests",
 				},
 				[]string{"host", "service"}),
+			MetricNodeStreamsGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricNodeStreamsGauge),
+					Help: "Current number of active streams through chain node",
+				},
+				[]string{"host", "chain", "node"}),
 		},
 		counters: map[metrics.MetricName]*prometheus.CounterVec{
 			MetricServiceRequestsCounter: prometheus.NewCounterVec(
@@ -63,6 +101,54 @@ func NewMetrics() metrics.Metrics {
 					Help: "Total chain errors",
 				},
 				[]string{"host", "chain", "node"}),
+			MetricNodeTransferInputBytesCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricNodeTransferInputBytesCounter),
+					Help: "Total chain node input data transfer size in bytes",
+				},
+				[]string{"host", "chain", "node"}),
+			MetricNodeTransferOutputBytesCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricNodeTransferOutputBytesCounter),
+					Help: "Total chain node output data transfer size in bytes",
+				},
+				[]string{"host", "chain", "node"}),
+			MetricNodeHandshakeErrorsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricNodeHandshakeErrorsCounter),
+					Help: "Total chain node handshake errors",
+				},
+				[]string{"host", "chain", "node"}),
+			MetricServiceAuthFailuresCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceAuthFailuresCounter),
+					Help: "Total service authentication failures",
+				},
+				[]string{"host", "service", "handler"}),
+			MetricServiceBypassHitsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceBypassHitsCounter),
+					Help: "Total service bypass hits",
+				},
+				[]string{"host", "service"}),
+			MetricServiceAdmissionDenialsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceAdmissionDenialsCounter),
+					Help: "Total service admission denials",
+				},
+				[]string{"host", "service"}),
+			MetricServiceSniffedCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricServiceSniffedCounter),
+					Help: "Total sniffed connections by protocol",
+				},
+				[]string{"host", "service", "protocol"}),
+			MetricDNSCacheLookupsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricDNSCacheLookupsCounter),
+					Help: "Total DNS cache lookups, the result label is hit or miss",
+				},
+				[]string{"host", "result"}),
 		},
 		histograms: map[metrics.MetricName]*prometheus.HistogramVec{
 			MetricServiceRequestsDurationObserver: prometheus.NewHistogramVec(
@@ -85,6 +171,40 @@ func NewMetrics() metrics.Metrics {
 				[]string{"host", "chain", "node"}),
 		},
 	}
+	for _, label := range options.labels {
+		switch label {
+		case LabelUser:
+			m.counters[MetricUserTransferInputBytesCounter] = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricUserTransferInputBytesCounter),
+					Help: "Total service input data transfer size in bytes per user",
+				},
+				[]string{"host", "service", LabelUser})
+			m.counters[MetricUserTransferOutputBytesCounter] = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricUserTransferOutputBytesCounter),
+					Help: "Total service output data transfer size in bytes per user",
+				},
+				[]string{"host", "service", LabelUser})
+		case LabelTarget:
+			m.counters[MetricTargetTransferInputBytesCounter] = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricTargetTransferInputBytesCounter),
+					Help: "Total service input data transfer size in bytes per target",
+				},
+				[]string{"host", "service", LabelTarget})
+			m.counters[MetricTargetTransferOutputBytesCounter] = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricTargetTransferOutputBytesCounter),
+					Help: "Total service output data transfer size in bytes per target",
+				},
+				[]string{"host", "service", LabelTarget})
+		default:
+			continue
+		}
+		m.limiters[label] = newLabelLimiter(options.maxLabelValues)
+	}
+
 	for k := range m.gauges {
 		prometheus.MustRegister(m.gauges[k])
 	}
@@ -107,6 +227,7 @@ func (m *promMetrics) Gauge(name metrics.MetricName, labels metrics.Labels) metr
 		labels = metrics.Labels{}
 	}
 	labels["host"] = m.host
+	m.limitLabels(labels)
 	return v.With(prometheus.Labels(labels))
 }
 
@@ -119,6 +240,7 @@ func (m *promMetrics) Counter(name metrics.MetricName, labels metrics.Labels) me
 		labels = metrics.Labels{}
 	}
 	labels["host"] = m.host
+	m.limitLabels(labels)
 	return v.With(prometheus.Labels(labels))
 }
 
@@ -131,5 +253,55 @@ func (m *promMetrics) Observer(name metrics.MetricName, labels metrics.Labels) m
 		labels = metrics.Labels{}
 	}
 	labels["host"] = m.host
+	m.limitLabels(labels)
 	return v.With(prometheus.Labels(labels))
 }
+
+func (m *promMetrics) IsLabelEnabled(label string) bool {
+	_, ok := m.limiters[label]
+	return ok
+}
+
+func (m *promMetrics) limitLabels(labels metrics.Labels) {
+	for name, limiter := range m.limiters {
+		if value, ok := labels[name]; ok {
+			labels[name] = limiter.limit(value)
+		}
+	}
+}
+
+// labelLimiter bounds the cardinality of a label,
+// the values beyond the limit are aggregated into LabelValueOther.
+type labelLimiter struct {
+	max    int
+	values map[string]struct{}
+	mu     sync.RWMutex
+}
+
+func newLabelLimiter(max int) *labelLimiter {
+	return &labelLimiter{
+		max:    max,
+		values: make(map[string]struct{}),
+	}
+}
+
+func (l *labelLimiter) limit(value string) string {
+	l.mu.RLock()
+	_, ok := l.values[value]
+	l.mu.RUnlock()
+	if ok {
+		return value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.values[value]; ok {
+		return value
+	}
+	if len(l.values) >= l.max {
+		return LabelValueOther
+	}
+	l.values[value] = struct{}{}
+	return value
+}
@@ -0,0 +1,37 @@
+package wrapper
+
+import (
+	"github.com/go-gost/core/admission"
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+// admitter is an Admission with metrics supported.
+type admitter struct {
+	admission.Admission
+	service string
+}
+
+func WrapAdmission(service string, adm admission.Admission) admission.Admission {
+	if adm == nil || !xmetrics.IsEnabled() {
+		return adm
+	}
+	return &admitter{
+		Admission: adm,
+		service:   service,
+	}
+}
+
+func (adm *admitter) Admit(addr string) bool {
+	ok := adm.Admission.Admit(addr)
+	if !ok {
+		if counter := xmetrics.GetCounter(
+			xmetrics.MetricServiceAdmissionDenialsCounter,
+			metrics.Labels{
+				"service": adm.service,
+			}); counter != nil {
+			counter.Inc()
+		}
+	}
+	return ok
+}
@@ -0,0 +1,49 @@
+package wrapper
+
+import (
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/metrics"
+	xauth "github.com/wznpp1/gost_x/auth"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+// authenticator is an Authenticator with metrics supported.
+type authenticator struct {
+	auth.Authenticator
+	service string
+	handler string
+}
+
+func WrapAuthenticator(service, handler string, au auth.Authenticator) auth.Authenticator {
+	if au == nil || !xmetrics.IsEnabled() {
+		return au
+	}
+	return &authenticator{
+		Authenticator: au,
+		service:       service,
+		handler:       handler,
+	}
+}
+
+func (au *authenticator) Authenticate(user, password string) bool {
+	ok := au.Authenticator.Authenticate(user, password)
+	if !ok {
+		if counter := xmetrics.GetCounter(
+			xmetrics.MetricServiceAuthFailuresCounter,
+			metrics.Labels{
+				"service": au.service,
+				"handler": au.handler,
+			}); counter != nil {
+			counter.Inc()
+		}
+	}
+	return ok
+}
+
+// Users implements auth.Lister interface.
+func (au *authenticator) Users() map[string]string {
+	if lister, ok := au.Authenticator.(xauth.Lister); ok {
+		return lister.Users()
+	}
+	return nil
+}
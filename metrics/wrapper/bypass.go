@@ -0,0 +1,37 @@
+package wrapper
+
+import (
+	"github.com/go-gost/core/bypass"
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+// bypasser is a Bypass with metrics supported.
+type bypasser struct {
+	bypass.Bypass
+	service string
+}
+
+func WrapBypass(service string, bp bypass.Bypass) bypass.Bypass {
+	if bp == nil || !xmetrics.IsEnabled() {
+		return bp
+	}
+	return &bypasser{
+		Bypass:  bp,
+		service: service,
+	}
+}
+
+func (bp *bypasser) Contains(addr string) bool {
+	ok := bp.Bypass.Contains(addr)
+	if ok {
+		if counter := xmetrics.GetCounter(
+			xmetrics.MetricServiceBypassHitsCounter,
+			metrics.Labels{
+				"service": bp.service,
+			}); counter != nil {
+			counter.Inc()
+		}
+	}
+	return ok
+}
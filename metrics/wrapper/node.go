@@ -0,0 +1,137 @@
+package wrapper
+
+import (
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/metrics"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+type nodeMetrics struct {
+	inputs  []metrics.Counter
+	outputs []metrics.Counter
+	streams []metrics.Gauge
+}
+
+func (m *nodeMetrics) addInput(n int) {
+	if n <= 0 {
+		return
+	}
+	for _, counter := range m.inputs {
+		counter.Add(float64(n))
+	}
+}
+
+func (m *nodeMetrics) addOutput(n int) {
+	if n <= 0 {
+		return
+	}
+	for _, counter := range m.outputs {
+		counter.Add(float64(n))
+	}
+}
+
+// nodeConn is a client side Conn established through the chain nodes with metrics supported.
+type nodeConn struct {
+	net.Conn
+	metrics   *nodeMetrics
+	closeOnce sync.Once
+}
+
+// WrapNodeConn wraps the connection established through the chain nodes,
+// the transferred data and the active stream are accounted to each of the nodes.
+func WrapNodeConn(chain string, nodes []string, c net.Conn) net.Conn {
+	if !xmetrics.IsEnabled() || len(nodes) == 0 {
+		return c
+	}
+
+	m := &nodeMetrics{}
+	for _, node := range nodes {
+		labels := metrics.Labels{
+			"chain": chain,
+			"node":  node,
+		}
+		if counter := xmetrics.GetCounter(
+			xmetrics.MetricNodeTransferInputBytesCounter, labels); counter != nil {
+			m.inputs = append(m.inputs, counter)
+		}
+		if counter := xmetrics.GetCounter(
+			xmetrics.MetricNodeTransferOutputBytesCounter, labels); counter != nil {
+			m.outputs = append(m.outputs, counter)
+		}
+		if gauge := xmetrics.GetGauge(
+			xmetrics.MetricNodeStreamsGauge, labels); gauge != nil {
+			gauge.Inc()
+			m.streams = append(m.streams, gauge)
+		}
+	}
+
+	nc := &nodeConn{
+		Conn:    c,
+		metrics: m,
+	}
+	if pc, ok := c.(net.PacketConn); ok {
+		return &nodePacketConn{
+			nodeConn: nc,
+			pc:       pc,
+		}
+	}
+	return nc
+}
+
+func (c *nodeConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.metrics.addInput(n)
+	return
+}
+
+func (c *nodeConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	c.metrics.addOutput(n)
+	return
+}
+
+func (c *nodeConn) Close() error {
+	c.closeOnce.Do(func() {
+		for _, gauge := range c.metrics.streams {
+			gauge.Dec()
+		}
+	})
+	return c.Conn.Close()
+}
+
+func (c *nodeConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
+
+func (c *nodeConn) Metadata() metadata.Metadata {
+	if md, ok := c.Conn.(metadata.Metadatable); ok {
+		return md.Metadata()
+	}
+	return nil
+}
+
+type nodePacketConn struct {
+	*nodeConn
+	pc net.PacketConn
+}
+
+func (c *nodePacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(p)
+	c.metrics.addInput(n)
+	return
+}
+
+func (c *nodePacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	n, err = c.pc.WriteTo(p, addr)
+	c.metrics.addOutput(n)
+	return
+}
@@ -0,0 +1,123 @@
+package wrapper
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/metrics"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+	xmetrics "github.com/wznpp1/gost_x/metrics"
+)
+
+// sessionConn is a server side Conn with per-user and per-target metrics supported.
+// The user and target are taken from the session when the data is transferred,
+// as they are only known after the handler processed the request.
+type sessionConn struct {
+	net.Conn
+	service string
+	session *xctx.Session
+}
+
+// WrapSessionConn wraps the connection to collect the metrics of the opt-in labels.
+// The connection is returned as is if none of the opt-in labels are enabled.
+func WrapSessionConn(service string, session *xctx.Session, c net.Conn) net.Conn {
+	if session == nil ||
+		!xmetrics.IsLabelEnabled(xmetrics.LabelUser) &&
+			!xmetrics.IsLabelEnabled(xmetrics.LabelTarget) {
+		return c
+	}
+	sc := &sessionConn{
+		Conn:    c,
+		service: service,
+		session: session,
+	}
+	if pc, ok := c.(net.PacketConn); ok {
+		return &sessionPacketConn{
+			sessionConn: sc,
+			pc:          pc,
+		}
+	}
+	return sc
+}
+
+func (c *sessionConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.add(xmetrics.MetricUserTransferInputBytesCounter,
+		xmetrics.MetricTargetTransferInputBytesCounter, n)
+	return
+}
+
+func (c *sessionConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	c.add(xmetrics.MetricUserTransferOutputBytesCounter,
+		xmetrics.MetricTargetTransferOutputBytesCounter, n)
+	return
+}
+
+func (c *sessionConn) add(userMetric, targetMetric metrics.MetricName, n int) {
+	if n <= 0 {
+		return
+	}
+	if user := c.session.User(); user != "" {
+		if counter := xmetrics.GetCounter(userMetric,
+			metrics.Labels{
+				"service":          c.service,
+				xmetrics.LabelUser: user,
+			}); counter != nil {
+			counter.Add(float64(n))
+		}
+	}
+	if target := c.session.Target(); target != "" {
+		if host, _, _ := net.SplitHostPort(target); host != "" {
+			target = host
+		}
+		if counter := xmetrics.GetCounter(targetMetric,
+			metrics.Labels{
+				"service":            c.service,
+				xmetrics.LabelTarget: target,
+			}); counter != nil {
+			counter.Add(float64(n))
+		}
+	}
+}
+
+func (c *sessionConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
+
+func (c *sessionConn) Metadata() metadata.Metadata {
+	if md, ok := c.Conn.(metadata.Metadatable); ok {
+		return md.Metadata()
+	}
+	return nil
+}
+
+// Unwrap implements xnet.Unwrapper interface.
+func (c *sessionConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+type sessionPacketConn struct {
+	*sessionConn
+	pc net.PacketConn
+}
+
+func (c *sessionPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(p)
+	c.add(xmetrics.MetricUserTransferInputBytesCounter,
+		xmetrics.MetricTargetTransferInputBytesCounter, n)
+	return
+}
+
+func (c *sessionPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	n, err = c.pc.WriteTo(p, addr)
+	c.add(xmetrics.MetricUserTransferOutputBytesCounter,
+		xmetrics.MetricTargetTransferOutputBytesCounter, n)
+	return
+}
@@ -2,6 +2,7 @@ package registry
 
 import (
 	"github.com/go-gost/core/auth"
+	xauth "github.com/wznpp1/gost_x/auth"
 )
 
 type autherRegistry struct {
@@ -35,3 +36,11 @@ func (w *autherWrapper) Authenticate(user, password string) bool {
 	}
 	return v.Authenticate(user, password)
 }
+
+// Users implements auth.Lister interface.
+func (w *autherWrapper) Users() map[string]string {
+	if lister, ok := w.r.get(w.name).(xauth.Lister); ok {
+		return lister.Users()
+	}
+	return nil
+}
@@ -7,3 +7,10 @@ import (
 type serviceRegistry struct {
 	registry[service.Service]
 }
+
+// Unregister removes the service from the registry without closing it,
+// the caller is responsible for shutting down the service,
+// so that the active connections can be drained.
+func (r *serviceRegistry) Unregister(name string) {
+	r.m.Delete(name)
+}
@@ -102,7 +102,7 @@ func (r *resolver) Resolve(ctx context.Context, network, host string) (ips []net
 	}
 
 	for _, server := range r.servers {
-		ips, err = r.resolve(ctx, &server, host)
+		ips, err = r.resolve(ctx, &server, network, host)
 		if err != nil {
 			r.options.logger.Error(err)
 			continue
@@ -118,11 +118,18 @@ func (r *resolver) Resolve(ctx context.Context, network, host string) (ips []net
 	return
 }
 
-func (r *resolver) resolve(ctx context.Context, server *NameServer, host string) (ips []net.IP, err error) {
+func (r *resolver) resolve(ctx context.Context, server *NameServer, network, host string) (ips []net.IP, err error) {
 	if server == nil {
 		return
 	}
 
+	switch network {
+	case "ip4":
+		return r.resolve4(ctx, server, host)
+	case "ip6":
+		return r.resolve6(ctx, server, host)
+	}
+
 	if server.Prefer == "ipv6" { // prefer ipv6
 		if ips, err = r.resolve6(ctx, server, host); len(ips) > 0 {
 			return
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-gost/core/admission"
@@ -15,19 +17,35 @@ import (
 	"github.com/go-gost/core/metrics"
 	"github.com/go-gost/core/recorder"
 	"github.com/go-gost/core/service"
+	"github.com/rs/xid"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
 	sx "github.com/wznpp1/gost_x/internal/util/selector"
 	xmetrics "github.com/wznpp1/gost_x/metrics"
-	"github.com/rs/xid"
+	metrics_wrapper "github.com/wznpp1/gost_x/metrics/wrapper"
+	xtracing "github.com/wznpp1/gost_x/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultGracePeriod is the default time to wait for the active connections
+	// to finish before they are force-closed when the service is shut down.
+	DefaultGracePeriod = 30 * time.Second
+)
+
+var (
+	errUnsupport = errors.New("unsupported operation")
 )
 
 type options struct {
-	admission admission.Admission
-	recorders []recorder.RecorderObject
-	preUp     []string
-	postUp    []string
-	preDown   []string
-	postDown  []string
-	logger    logger.Logger
+	admission   admission.Admission
+	recorders   []recorder.RecorderObject
+	preUp       []string
+	postUp      []string
+	preDown     []string
+	postDown    []string
+	gracePeriod time.Duration
+	logger      logger.Logger
 }
 
 type Option func(opts *options)
@@ -68,21 +86,46 @@ func PostDownOption(cmds []string) Option {
 	}
 }
 
+// GracePeriodOption sets the time to wait for the active connections
+// to finish when the service is shut down.
+func GracePeriodOption(d time.Duration) Option {
+	return func(opts *options) {
+		opts.gracePeriod = d
+	}
+}
+
 func LoggerOption(logger logger.Logger) Option {
 	return func(opts *options) {
 		opts.logger = logger
 	}
 }
 
+// Drainer is implemented by the services which support graceful shutdown.
+type Drainer interface {
+	// Drain stops accepting new connections, the returned channel is closed
+	// when all the active connections are finished.
+	Drain() <-chan struct{}
+	// Shutdown drains the service and waits for the active connections to finish
+	// until the grace period elapses or ctx is done, then closes the service.
+	Shutdown(ctx context.Context) error
+}
+
 type defaultService struct {
-	name     string
-	listener listener.Listener
-	handler  handler.Handler
-	options  options
+	name      string
+	listener  listener.Listener
+	handler   handler.Handler
+	tracker   *connTracker
+	drainOnce sync.Once
+	drained   chan struct{}
+	lnErr     error
+	closeOnce sync.Once
+	options   options
 }
 
 func NewService(name string, ln listener.Listener, h handler.Handler, opts ...Option) service.Service {
-	var options options
+	options := options{
+		gracePeriod: DefaultGracePeriod,
+	}
 	for _, opt := range opts {
 		opt(&options)
 	}
@@ -90,6 +133,8 @@ func NewService(name string, ln listener.Listener, h handler.Handler, opts ...Op
 		name:     name,
 		listener: ln,
 		handler:  h,
+		tracker:  newConnTracker(name),
+		drained:  make(chan struct{}),
 		options:  options,
 	}
 
@@ -102,14 +147,63 @@ func (s *defaultService) Addr() net.Addr {
 	return s.listener.Addr()
 }
 
-func (s *defaultService) Close() error {
-	s.execCmds("pre-down", s.options.preDown)
-	defer s.execCmds("post-down", s.options.postDown)
+// Close closes the service and all its active connections immediately.
+func (s *defaultService) Close() (err error) {
+	s.closeOnce.Do(func() {
+		s.execCmds("pre-down", s.options.preDown)
+		defer s.execCmds("post-down", s.options.postDown)
+
+		s.Drain()
+		if n := s.tracker.closeAll(); n > 0 {
+			s.options.logger.Debugf("%d connections are closed", n)
+		}
+		if closer, ok := s.handler.(io.Closer); ok {
+			closer.Close()
+		}
+		err = s.lnErr
+	})
+	return
+}
+
+// Drain implements Drainer interface.
+func (s *defaultService) Drain() <-chan struct{} {
+	s.drainOnce.Do(func() {
+		s.lnErr = s.listener.Close()
+
+		go func() {
+			defer close(s.drained)
+
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for s.tracker.active() > 0 {
+				<-ticker.C
+			}
+		}()
+	})
+	return s.drained
+}
+
+// Shutdown implements Drainer interface.
+func (s *defaultService) Shutdown(ctx context.Context) error {
+	if s.options.gracePeriod <= 0 {
+		return s.Close()
+	}
+
+	done := s.Drain()
+	if n := s.tracker.active(); n > 0 {
+		s.options.logger.Infof("draining %d connections, grace period %v", n, s.options.gracePeriod)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.options.gracePeriod)
+	defer cancel()
 
-	if closer, ok := s.handler.(io.Closer); ok {
-		closer.Close()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.options.logger.Warnf("grace period exceeded, %d connections are force-closed", s.tracker.active())
 	}
-	return s.listener.Close()
+
+	return s.Close()
 }
 
 func (s *defaultService) Serve() error {
@@ -186,19 +280,89 @@ func (s *defaultService) Serve() error {
 
 			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
 			ctx := sx.ContextWithHash(context.Background(), &sx.Hash{Source: host})
-			ctx = ContextWithSid(ctx, xid.New().String())
-
-			if err := s.handler.Handle(ctx, conn); err != nil {
+			sid := xid.New().String()
+			ctx = ContextWithSid(ctx, sid)
+
+			session := &xctx.Session{}
+			ctx = xctx.ContextWithSession(ctx, session)
+			conn := s.tracker.track(sid, session,
+				metrics_wrapper.WrapSessionConn(s.name, session, conn))
+
+			ctx, span := xtracing.Start(ctx, "service.handle",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String(xtracing.AttrService, s.name),
+					attribute.String(xtracing.AttrSid, sid),
+					attribute.String(xtracing.AttrClient, conn.RemoteAddr().String()),
+				),
+			)
+
+			err := s.handler.Handle(ctx, conn)
+			if err != nil {
 				s.options.logger.Error(err)
 				if v := xmetrics.GetCounter(xmetrics.MetricServiceHandlerErrorsCounter,
 					metrics.Labels{"service": s.name}); v != nil {
 					v.Inc()
 				}
 			}
+
+			if user := session.User(); user != "" {
+				span.SetAttributes(attribute.String(xtracing.AttrUser, user))
+			}
+			if target := session.Target(); target != "" {
+				span.SetAttributes(attribute.String(xtracing.AttrTarget, target))
+			}
+			xtracing.End(span, err)
 		}()
 	}
 }
 
+// Connections implements ConnTracker interface.
+func (s *defaultService) Connections() []ConnInfo {
+	return s.tracker.Connections()
+}
+
+// CloseConnection implements ConnTracker interface.
+func (s *defaultService) CloseConnection(sid string) bool {
+	return s.tracker.CloseConnection(sid)
+}
+
+// CloseUserConnections implements ConnTracker interface.
+func (s *defaultService) CloseUserConnections(user string) int {
+	return s.tracker.CloseUserConnections(user)
+}
+
+// Shutdown shuts down the services concurrently, the services which implement
+// Drainer interface are drained before they are closed.
+func Shutdown(ctx context.Context, services ...service.Service) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(services))
+	for i, svc := range services {
+		if svc == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, svc service.Service) {
+			defer wg.Done()
+
+			if d, ok := svc.(Drainer); ok {
+				errs[i] = d.Shutdown(ctx)
+			} else {
+				errs[i] = svc.Close()
+			}
+		}(i, svc)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *defaultService) execCmds(phase string, cmds []string) {
 	for _, cmd := range cmds {
 		cmd := strings.TrimSpace(cmd)
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-gost/core/service"
+	"github.com/wznpp1/gost_x/registry"
+)
+
+// ShutdownOnSignal blocks until one of the signals (SIGINT and SIGTERM by default) is received,
+// then shuts down all the registered services gracefully.
+func ShutdownOnSignal(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	<-ch
+
+	var services []service.Service
+	for name, svc := range registry.ServiceRegistry().GetAll() {
+		registry.ServiceRegistry().Unregister(name)
+		services = append(services, svc)
+	}
+	return Shutdown(context.Background(), services...)
+}
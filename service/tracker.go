@@ -0,0 +1,212 @@
+package service
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+	xctx "github.com/wznpp1/gost_x/internal/ctx"
+)
+
+// ConnInfo is the snapshot of an active connection handled by a service.
+type ConnInfo struct {
+	Sid         string    `json:"sid"`
+	Service     string    `json:"service"`
+	Client      string    `json:"client"`
+	User        string    `json:"user,omitempty"`
+	Target      string    `json:"target,omitempty"`
+	Node        string    `json:"node,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	Start       time.Time `json:"start"`
+	InputBytes  int64     `json:"inputBytes"`
+	OutputBytes int64     `json:"outputBytes"`
+}
+
+// ConnTracker is implemented by the services which track their active connections.
+type ConnTracker interface {
+	// Connections returns the snapshots of the active connections.
+	Connections() []ConnInfo
+	// CloseConnection closes the connection with the session ID sid,
+	// it reports whether the connection is found.
+	CloseConnection(sid string) bool
+	// CloseUserConnections closes all the connections of the user,
+	// it returns the number of the closed connections.
+	CloseUserConnections(user string) int
+}
+
+type connTracker struct {
+	service string
+	conns   sync.Map
+	count   atomic.Int64
+}
+
+func newConnTracker(service string) *connTracker {
+	return &connTracker{
+		service: service,
+	}
+}
+
+// track registers the connection until it is closed.
+func (t *connTracker) track(sid string, session *xctx.Session, c net.Conn) net.Conn {
+	tc := &trackedConn{
+		Conn:    c,
+		sid:     sid,
+		start:   time.Now(),
+		session: session,
+		tracker: t,
+	}
+	t.conns.Store(sid, tc)
+	t.count.Add(1)
+
+	if pc, ok := c.(net.PacketConn); ok {
+		return &trackedPacketConn{
+			trackedConn: tc,
+			pc:          pc,
+		}
+	}
+	return tc
+}
+
+func (t *connTracker) Connections() []ConnInfo {
+	var conns []ConnInfo
+	t.conns.Range(func(key, value any) bool {
+		if c, ok := value.(*trackedConn); ok {
+			conns = append(conns, c.info(t.service))
+		}
+		return true
+	})
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].Start.Before(conns[j].Start)
+	})
+	return conns
+}
+
+func (t *connTracker) CloseConnection(sid string) bool {
+	v, ok := t.conns.Load(sid)
+	if !ok {
+		return false
+	}
+	v.(*trackedConn).Close()
+	return true
+}
+
+func (t *connTracker) CloseUserConnections(user string) (n int) {
+	if user == "" {
+		return
+	}
+	t.conns.Range(func(key, value any) bool {
+		if c, ok := value.(*trackedConn); ok && c.session.User() == user {
+			c.Close()
+			n++
+		}
+		return true
+	})
+	return
+}
+
+// closeAll closes all the active connections,
+// it returns the number of the closed connections.
+func (t *connTracker) closeAll() (n int) {
+	t.conns.Range(func(key, value any) bool {
+		if c, ok := value.(*trackedConn); ok {
+			c.Close()
+			n++
+		}
+		return true
+	})
+	return
+}
+
+// active returns the number of the active connections.
+func (t *connTracker) active() int64 {
+	return t.count.Load()
+}
+
+type trackedConn struct {
+	net.Conn
+	sid         string
+	start       time.Time
+	session     *xctx.Session
+	tracker     *connTracker
+	inputBytes  atomic.Int64
+	outputBytes atomic.Int64
+	closeOnce   sync.Once
+}
+
+func (c *trackedConn) info(service string) ConnInfo {
+	return ConnInfo{
+		Sid:         c.sid,
+		Service:     service,
+		Client:      c.RemoteAddr().String(),
+		User:        c.session.User(),
+		Target:      c.session.Target(),
+		Node:        c.session.Node(),
+		Protocol:    c.session.Protocol(),
+		Host:        c.session.Host(),
+		Start:       c.start,
+		InputBytes:  c.inputBytes.Load(),
+		OutputBytes: c.outputBytes.Load(),
+	}
+}
+
+func (c *trackedConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.inputBytes.Add(int64(n))
+	return
+}
+
+func (c *trackedConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	c.outputBytes.Add(int64(n))
+	return
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.tracker.conns.Delete(c.sid)
+		c.tracker.count.Add(-1)
+	})
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		return sc.SyscallConn()
+	}
+	err = errUnsupport
+	return
+}
+
+func (c *trackedConn) Metadata() metadata.Metadata {
+	if md, ok := c.Conn.(metadata.Metadatable); ok {
+		return md.Metadata()
+	}
+	return nil
+}
+
+// Unwrap implements xnet.Unwrapper interface.
+func (c *trackedConn) Unwrap() net.Conn {
+	return c.Conn
+}
+
+type trackedPacketConn struct {
+	*trackedConn
+	pc net.PacketConn
+}
+
+func (c *trackedPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(p)
+	c.inputBytes.Add(int64(n))
+	return
+}
+
+func (c *trackedPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	n, err = c.pc.WriteTo(p, addr)
+	c.outputBytes.Add(int64(n))
+	return
+}
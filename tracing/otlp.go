@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+const (
+	DefaultServiceName = "gost"
+	defaultTimeout     = 10 * time.Second
+)
+
+type options struct {
+	serviceName string
+	insecure    bool
+	sampleRatio float64
+	timeout     time.Duration
+}
+
+type Option func(opts *options)
+
+func ServiceNameOption(name string) Option {
+	return func(opts *options) {
+		opts.serviceName = name
+	}
+}
+
+func InsecureOption(insecure bool) Option {
+	return func(opts *options) {
+		opts.insecure = insecure
+	}
+}
+
+// SampleRatioOption sets the fraction of the traces to be sampled, all traces are sampled by default.
+func SampleRatioOption(ratio float64) Option {
+	return func(opts *options) {
+		opts.sampleRatio = ratio
+	}
+}
+
+func TimeoutOption(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
+// NewTracerProvider creates a tracer provider which exports the spans to the OTLP collector at addr via gRPC.
+// The caller should call the Shutdown method of the provider to flush the remaining spans before exit.
+func NewTracerProvider(addr string, opts ...Option) (*sdktrace.TracerProvider, error) {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.serviceName == "" {
+		options.serviceName = DefaultServiceName
+	}
+	if options.timeout <= 0 {
+		options.timeout = defaultTimeout
+	}
+
+	clientOpts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(addr),
+		otlptracegrpc.WithTimeout(options.timeout),
+	}
+	if options.insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if options.sampleRatio > 0 && options.sampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(options.sampleRatio)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(options.serviceName),
+		)),
+	), nil
+}
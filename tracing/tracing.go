@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the instrumentation name of the tracer.
+	TracerName = "github.com/wznpp1/gost_x"
+)
+
+const (
+	// Attribute keys of the spans.
+	AttrService  = "gost.service"
+	AttrSid      = "gost.sid"
+	AttrClient   = "gost.client"
+	AttrUser     = "gost.user"
+	AttrTarget   = "gost.target"
+	AttrChain    = "gost.chain"
+	AttrNode     = "gost.node"
+	AttrNodeAddr = "gost.node.addr"
+	AttrNetwork  = "gost.network"
+	AttrAddress  = "gost.address"
+)
+
+var (
+	noop   = trace.NewNoopTracerProvider()
+	global = noop
+)
+
+// Init sets the global tracer provider, tracing is disabled if tp is nil.
+func Init(tp trace.TracerProvider) {
+	if v, ok := tp.(*sdktrace.TracerProvider); ok && v == nil {
+		tp = nil
+	}
+	if tp != nil {
+		global = tp
+	} else {
+		global = noop
+	}
+}
+
+func IsEnabled() bool {
+	return global != noop
+}
+
+// Start creates a span as the child of the span in ctx if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return global.Tracer(TracerName).Start(ctx, name, opts...)
+}
+
+// End records the err if it is not nil and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}